@@ -3,12 +3,16 @@ module github.com/alexanderzobnin/grafana-zabbix
 go 1.12
 
 require (
+	github.com/ClickHouse/clickhouse-go v1.4.3
 	github.com/bitly/go-simplejson v0.5.0
 	github.com/bmizerany/assert v0.0.0-20160611221934-b7ed37b82869 // indirect
-	github.com/grafana/grafana-plugin-sdk-go v0.65.0
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/grafana/grafana-plugin-sdk-go v0.114.0
 	github.com/hashicorp/go-hclog v0.9.2 // indirect
+	github.com/lib/pq v1.8.0
 	github.com/patrickmn/go-cache v2.1.0+incompatible
-	github.com/stretchr/testify v1.5.1
-	golang.org/x/net v0.0.0-20190923162816-aa69164e4478
+	github.com/prometheus/client_golang v1.10.0
+	github.com/stretchr/testify v1.7.0
+	golang.org/x/net v0.0.0-20201021035429-f5854403a974
 	gotest.tools v2.2.0+incompatible
 )
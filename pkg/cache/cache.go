@@ -34,7 +34,18 @@ func (c *Cache) Set(request string, response interface{}) {
 	c.cache.SetDefault(request, response)
 }
 
+// SetWithExpiration sets the value of the key "request" to "response" with the given expiration time,
+// NoExpiration keeps it until it's replaced.
+func (c *Cache) SetWithExpiration(request string, response interface{}, expiration time.Duration) {
+	c.cache.Set(request, response, expiration)
+}
+
 // Get the value associated with request from the cache
 func (c *Cache) Get(request string) (interface{}, bool) {
 	return c.cache.Get(request)
 }
+
+// ItemCount returns number of the items in the cache, may include expired items not yet cleaned up
+func (c *Cache) ItemCount() int {
+	return c.cache.ItemCount()
+}
@@ -0,0 +1,174 @@
+package datasource
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/net/context"
+)
+
+// Zabbix event acknowledge actions, can be combined
+const (
+	AckActionClose      = 1
+	AckActionAck        = 2
+	AckActionAddMessage = 4
+)
+
+// Grafana alert statuses
+const (
+	AlertStatusFiring   = "firing"
+	AlertStatusResolved = "resolved"
+)
+
+// Alert labels used to find Zabbix event of the alert
+const (
+	AlertLabelEventID   = "eventid"
+	AlertLabelTriggerID = "triggerid"
+)
+
+// Older Zabbix versions limit acknowledge message to 255 characters
+const ackMessageMaxLength = 255
+
+// AlertWebhookPayload is a notification sent by Grafana webhook contact point
+type AlertWebhookPayload struct {
+	Receiver string         `json:"receiver"`
+	Status   string         `json:"status"`
+	Alerts   []WebhookAlert `json:"alerts"`
+}
+
+// WebhookAlert is a single alert of the webhook notification
+type WebhookAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// AlertAcknowledgeResult describes Zabbix events updated for the alert. Alerts without event are skipped
+// and have no event ids.
+type AlertAcknowledgeResult struct {
+	Fingerprint string   `json:"fingerprint"`
+	Status      string   `json:"status"`
+	EventIDs    []string `json:"eventids"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// acknowledgeAlerts posts state of the Grafana alerts to the corresponding Zabbix events. Firing alert
+// acknowledges the event, resolved one adds a message only, so operators see the state in Zabbix.
+// Errors of the single alerts don't stop processing of the others and are returned in the results.
+// canAcknowledge checks if the Grafana user is allowed to acknowledge problems. Viewers and requests without
+// the user can't acknowledge if it's disabled for read-only users in the data source settings, like in the
+// problems panel.
+func (ds *ZabbixDatasourceInstance) canAcknowledge(user *backend.User) bool {
+	if !ds.Settings.DisableReadOnlyUsersAck {
+		return true
+	}
+	return user != nil && (user.Role == "Editor" || user.Role == "Admin")
+}
+
+func (ds *ZabbixDatasourceInstance) acknowledgeAlerts(ctx context.Context, payload *AlertWebhookPayload) []AlertAcknowledgeResult {
+	results := make([]AlertAcknowledgeResult, 0, len(payload.Alerts))
+	for _, alert := range payload.Alerts {
+		result := AlertAcknowledgeResult{Fingerprint: alert.Fingerprint, Status: alert.Status, EventIDs: []string{}}
+
+		eventIDs, err := ds.acknowledgeAlert(ctx, alert)
+		if err != nil {
+			requestid.Logger(ctx, ds.logger).Warn("Cannot acknowledge Zabbix event", "fingerprint", alert.Fingerprint, "error", err)
+			result.Error = err.Error()
+		} else {
+			result.EventIDs = eventIDs
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (ds *ZabbixDatasourceInstance) acknowledgeAlert(ctx context.Context, alert WebhookAlert) ([]string, error) {
+	params := alertEventParams(alert)
+	if params == nil {
+		return []string{}, nil
+	}
+
+	events, err := ds.getEvents(ctx, "event.get", params)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return []string{}, nil
+	}
+
+	eventIDs := make([]string, 0, len(events))
+	unacknowledged := false
+	for _, event := range events {
+		eventIDs = append(eventIDs, event.ID)
+		if event.Acknowledged == "0" {
+			unacknowledged = true
+		}
+	}
+
+	// Acknowledging already acknowledged event fails, so message is only added in that case
+	action := AckActionAddMessage
+	if alert.Status == AlertStatusFiring && unacknowledged {
+		action |= AckActionAck
+	}
+
+	_, err = ds.ZabbixQuery(ctx, &ZabbixAPIRequest{
+		Method: "event.acknowledge",
+		Params: ZabbixAPIParams{
+			"eventids": eventIDs,
+			"action":   action,
+			"message":  alertAckMessage(alert),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eventIDs, nil
+}
+
+// alertEventParams returns event.get params selecting event of the alert by the eventid label or the last
+// problem event of the trigger set by the triggerid label. Returns nil if alert has no such labels.
+func alertEventParams(alert WebhookAlert) ZabbixAPIParams {
+	params := ZabbixAPIParams{
+		"output": []string{"eventid", "acknowledged"},
+		"source": 0,
+		"object": 0,
+	}
+
+	if eventID := alert.Labels[AlertLabelEventID]; eventID != "" {
+		params["eventids"] = []string{eventID}
+		return params
+	}
+
+	if triggerID := alert.Labels[AlertLabelTriggerID]; triggerID != "" {
+		params["objectids"] = []string{triggerID}
+		params["value"] = TriggerStateProblem
+		params["sortfield"] = []string{"clock", "eventid"}
+		params["sortorder"] = "DESC"
+		params["limit"] = 1
+		return params
+	}
+
+	return nil
+}
+
+// alertAckMessage returns message like `Grafana alert "High CPU" is firing: CPU usage is 95%`
+func alertAckMessage(alert WebhookAlert) string {
+	name := alert.Labels["alertname"]
+	if name == "" {
+		name = alert.Fingerprint
+	}
+	message := fmt.Sprintf("Grafana alert \"%s\" is %s", name, alert.Status)
+	if summary := strings.TrimSpace(alert.Annotations["summary"]); summary != "" {
+		message = fmt.Sprintf("%s: %s", message, summary)
+	}
+
+	// Limit is in characters, so message is truncated by runes to keep it valid UTF-8
+	if runes := []rune(message); len(runes) > ackMessageMaxLength {
+		message = string(runes[:ackMessageMaxLength-3]) + "..."
+	}
+	return message
+}
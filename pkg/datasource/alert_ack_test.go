@@ -0,0 +1,104 @@
+package datasource
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertEventParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected ZabbixAPIParams
+	}{
+		{
+			name:   "event id",
+			labels: map[string]string{"eventid": "100", "triggerid": "10"},
+			expected: ZabbixAPIParams{
+				"output":   []string{"eventid", "acknowledged"},
+				"source":   0,
+				"object":   0,
+				"eventids": []string{"100"},
+			},
+		},
+		{
+			name:   "trigger id",
+			labels: map[string]string{"triggerid": "10"},
+			expected: ZabbixAPIParams{
+				"output":    []string{"eventid", "acknowledged"},
+				"source":    0,
+				"object":    0,
+				"objectids": []string{"10"},
+				"value":     TriggerStateProblem,
+				"sortfield": []string{"clock", "eventid"},
+				"sortorder": "DESC",
+				"limit":     1,
+			},
+		},
+		{
+			name:     "no labels",
+			labels:   map[string]string{"alertname": "CPU"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, alertEventParams(WebhookAlert{Labels: tt.labels}))
+		})
+	}
+}
+
+func TestAlertAckMessage(t *testing.T) {
+	alert := WebhookAlert{
+		Status:      AlertStatusFiring,
+		Labels:      map[string]string{"alertname": "High CPU"},
+		Annotations: map[string]string{"summary": "CPU usage is 95%"},
+	}
+	assert.Equal(t, `Grafana alert "High CPU" is firing: CPU usage is 95%`, alertAckMessage(alert))
+
+	alert = WebhookAlert{Status: AlertStatusResolved, Fingerprint: "abc"}
+	assert.Equal(t, `Grafana alert "abc" is resolved`, alertAckMessage(alert))
+
+	alert = WebhookAlert{Status: AlertStatusFiring, Annotations: map[string]string{"summary": strings.Repeat("a", 300)}}
+	message := alertAckMessage(alert)
+	assert.Equal(t, ackMessageMaxLength, len(message))
+	assert.True(t, strings.HasSuffix(message, "..."))
+
+	alert = WebhookAlert{Status: AlertStatusFiring, Annotations: map[string]string{"summary": strings.Repeat("Загрузка ЦП ", 30)}}
+	message = alertAckMessage(alert)
+	assert.True(t, utf8.ValidString(message))
+	assert.Equal(t, ackMessageMaxLength, utf8.RuneCountInString(message))
+	assert.True(t, strings.HasSuffix(message, "..."))
+}
+
+func TestAcknowledgeAlertsWithoutEvent(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	payload := &AlertWebhookPayload{Alerts: []WebhookAlert{
+		{Status: AlertStatusFiring, Fingerprint: "1", Labels: map[string]string{"triggerid": "10"}},
+		{Status: AlertStatusFiring, Fingerprint: "2", Labels: map[string]string{"alertname": "CPU"}},
+	}}
+	results := dsInstance.acknowledgeAlerts(context.Background(), payload)
+	assert.Equal(t, []AlertAcknowledgeResult{
+		{Fingerprint: "1", Status: AlertStatusFiring, EventIDs: []string{}},
+		{Fingerprint: "2", Status: AlertStatusFiring, EventIDs: []string{}},
+	}, results)
+}
+
+func TestCanAcknowledge(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	assert.True(t, dsInstance.canAcknowledge(&backend.User{Role: "Viewer"}))
+
+	dsInstance.Settings.DisableReadOnlyUsersAck = true
+	assert.False(t, dsInstance.canAcknowledge(&backend.User{Role: "Viewer"}))
+	assert.False(t, dsInstance.canAcknowledge(nil))
+	assert.True(t, dsInstance.canAcknowledge(&backend.User{Role: "Editor"}))
+	assert.True(t, dsInstance.canAcknowledge(&backend.User{Role: "Admin"}))
+}
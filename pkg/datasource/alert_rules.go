@@ -0,0 +1,369 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"golang.org/x/net/context"
+)
+
+// Data source of the Grafana server-side expressions
+const expressionDatasourceUID = "__expr__"
+
+// Default values of the exported alert rules
+const (
+	defaultAlertRuleFolder   = "Zabbix"
+	defaultAlertRuleInterval = "1m"
+)
+
+// AlertRuleProvisioning is a Grafana alerting provisioning file with the rule groups
+type AlertRuleProvisioning struct {
+	APIVersion int              `json:"apiVersion"`
+	Groups     []AlertRuleGroup `json:"groups"`
+}
+
+// AlertRuleGroup model
+type AlertRuleGroup struct {
+	OrgID    int64       `json:"orgId"`
+	Name     string      `json:"name"`
+	Folder   string      `json:"folder"`
+	Interval string      `json:"interval"`
+	Rules    []AlertRule `json:"rules"`
+}
+
+// AlertRule is a definition of the Grafana alert rule
+type AlertRule struct {
+	UID          string            `json:"uid"`
+	Title        string            `json:"title"`
+	Condition    string            `json:"condition"`
+	Data         []AlertRuleQuery  `json:"data"`
+	NoDataState  string            `json:"noDataState"`
+	ExecErrState string            `json:"execErrState"`
+	For          string            `json:"for"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+}
+
+// AlertRuleQuery is a query or expression of the alert rule
+type AlertRuleQuery struct {
+	RefID             string                 `json:"refId"`
+	RelativeTimeRange RelativeTimeRange      `json:"relativeTimeRange"`
+	DatasourceUID     string                 `json:"datasourceUid"`
+	Model             map[string]interface{} `json:"model"`
+}
+
+// RelativeTimeRange of the alert rule query in seconds before now
+type RelativeTimeRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// AlertRulesExport contains provisioning of the converted triggers and triggers which can't be converted with
+// the reason.
+type AlertRulesExport struct {
+	Provisioning AlertRuleProvisioning `json:"provisioning"`
+	Skipped      []SkippedTrigger      `json:"skipped"`
+}
+
+// SkippedTrigger is a trigger which can't be converted to the alert rule
+type SkippedTrigger struct {
+	TriggerID   string `json:"triggerid"`
+	Description string `json:"description"`
+	Reason      string `json:"reason"`
+}
+
+// Evaluators of the threshold expression for the trigger operators. Threshold expression has only strict
+// comparisons, so ">=" and "<=" are compared by the math expression.
+var thresholdEvaluators = map[string]string{
+	">": "gt",
+	"<": "lt",
+}
+
+// Matches the expression comparing the last value of the item with a constant or a user macro, like
+// "last(/web01/system.cpu.util)>90" or "{web01:system.cpu.util.last()}>={$CPU.MAX}". Other functions and
+// expressions with several conditions can't be reproduced by the alert rule.
+var lastValueConditionPattern = regexp.MustCompile(`^\s*(?:last\(/([^/]+)/([\w.-]+(?:\[[^\]]*\])?)(?:,#1)?\)|\{([^:{}]+):([\w.-]+(?:\[[^\]]*\])?)\.last\((?:0|#1)?\)\})\s*((?:>=|<=|>|<)\s*(?:-?\d+(?:\.\d+)?[KMGTsmhdw]?|\{\$[A-Z0-9_.]+(?::[^}]*)?\}))\s*$`)
+
+const unsupportedExpressionReason = "unsupported expression: only comparison of the last item value with a constant or a user macro can be converted"
+
+// lastValueCondition is a trigger expression comparing the last value of the host item
+type lastValueCondition struct {
+	host      string
+	key       string
+	condition TriggerCondition
+}
+
+// parseLastValueCondition parses the expression comparing the last value of the item, see
+// lastValueConditionPattern. Returns false for other expressions.
+func parseLastValueCondition(expression string, macros *userMacros, hostids []string) (*lastValueCondition, bool) {
+	matches := lastValueConditionPattern.FindStringSubmatch(expression)
+	if matches == nil {
+		return nil, false
+	}
+	conditions := parseTriggerConditions(matches[5], macros, hostids)
+	if len(conditions) != 1 {
+		return nil, false
+	}
+
+	result := &lastValueCondition{host: matches[1], key: matches[2], condition: conditions[0]}
+	if result.host == "" {
+		result.host = matches[3]
+		result.key = matches[4]
+	}
+	return result, true
+}
+
+// exportAlertRules converts triggers into Grafana alert rules. Each rule queries the item of the trigger,
+// reduces it to the last value and compares it with the constant of the trigger expression. Only triggers
+// comparing the last value of a single item are converted. Trigger tags, severity and trigger id are set as
+// the rule labels.
+func (ds *ZabbixDatasourceInstance) exportAlertRules(ctx context.Context, req *AlertRulesResourceRequest) (*AlertRulesExport, error) {
+	export := &AlertRulesExport{
+		Provisioning: AlertRuleProvisioning{APIVersion: 1, Groups: []AlertRuleGroup{}},
+		Skipped:      []SkippedTrigger{},
+	}
+	if len(req.TriggerIDs) == 0 {
+		return export, nil
+	}
+
+	triggers, err := ds.getExportTriggers(ctx, req.TriggerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	macros, err := ds.getTriggersMacros(ctx, triggers)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := req.Folder
+	if folder == "" {
+		folder = defaultAlertRuleFolder
+	}
+	interval := req.Interval
+	if interval == "" {
+		interval = defaultAlertRuleInterval
+	}
+
+	// Rules are grouped by host, so the group name is meaningful in the alerting UI
+	groups := make(map[string]*AlertRuleGroup)
+	for _, trigger := range triggers {
+		rule, reason := convertTriggerToAlertRule(trigger, macros, req.DatasourceUID)
+		if rule == nil {
+			export.Skipped = append(export.Skipped, SkippedTrigger{
+				TriggerID:   trigger.ID,
+				Description: trigger.Description,
+				Reason:      reason,
+			})
+			continue
+		}
+
+		groupName := trigger.Hosts[0].Name
+		group, ok := groups[groupName]
+		if !ok {
+			group = &AlertRuleGroup{OrgID: req.OrgID, Name: groupName, Folder: folder, Interval: interval, Rules: []AlertRule{}}
+			if group.OrgID == 0 {
+				group.OrgID = 1
+			}
+			groups[groupName] = group
+		}
+		group.Rules = append(group.Rules, *rule)
+	}
+
+	for _, group := range groups {
+		export.Provisioning.Groups = append(export.Provisioning.Groups, *group)
+	}
+	sort.Slice(export.Provisioning.Groups, func(i, j int) bool {
+		return export.Provisioning.Groups[i].Name < export.Provisioning.Groups[j].Name
+	})
+	return export, nil
+}
+
+func (ds *ZabbixDatasourceInstance) getExportTriggers(ctx context.Context, triggerids []string) (Triggers, error) {
+	params := ZabbixAPIParams{
+		"output":            []string{"triggerid", "description", "expression", "priority", "comments", "recovery_mode", "recovery_expression"},
+		"triggerids":        triggerids,
+		"expandExpression":  true,
+		"expandDescription": true,
+		"selectHosts":       []string{"hostid", "name"},
+		"selectItems":       []string{"itemid", "hostid", "name", "key_", "value_type"},
+		"selectTags":        "extend",
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "trigger.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	responseJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	triggers := Triggers{}
+	err = json.Unmarshal(responseJSON, &triggers)
+	if err != nil {
+		return nil, err
+	}
+	return triggers, nil
+}
+
+// convertTriggerToAlertRule returns alert rule for the trigger or the reason why trigger can't be converted
+func convertTriggerToAlertRule(trigger Trigger, macros *userMacros, datasourceUID string) (*AlertRule, string) {
+	if len(trigger.Hosts) == 0 {
+		return nil, "trigger has no hosts"
+	}
+
+	lastValue, ok := parseLastValueCondition(trigger.Expression, macros, triggerHostIDs(trigger))
+	if !ok {
+		return nil, unsupportedExpressionReason
+	}
+	if lastValue.condition.Value == nil {
+		return nil, fmt.Sprintf("cannot resolve value of the %s macro", lastValue.condition.Macro)
+	}
+
+	var host *ItemHost
+	for i := range trigger.Hosts {
+		if trigger.Hosts[i].Name == lastValue.host {
+			host = &trigger.Hosts[i]
+			break
+		}
+	}
+	var item *TriggerItem
+	for i := range trigger.Items {
+		if host != nil && trigger.Items[i].Key == lastValue.key && (trigger.Items[i].HostID == "" || trigger.Items[i].HostID == host.ID) {
+			item = &trigger.Items[i]
+			break
+		}
+	}
+	if item == nil {
+		return nil, fmt.Sprintf("item %s of the host %s not found", lastValue.key, lastValue.host)
+	}
+	if item.ValueType != ValueTypeFloat && item.ValueType != ValueTypeUint {
+		return nil, fmt.Sprintf("item %s is not numeric", lastValue.key)
+	}
+
+	itemName := (&Item{Name: item.Name, Key: item.Key}).ExpandItem()
+	queryModel := map[string]interface{}{
+		"refId":       "A",
+		"queryType":   "0",
+		"mode":        QueryModeMetrics,
+		"group":       QueryFilter{Filter: "/.*/"},
+		"host":        QueryFilter{Filter: exactMatchFilter(host.Name)},
+		"application": QueryFilter{Filter: ""},
+		"item":        QueryFilter{Filter: exactMatchFilter(itemName)},
+		"functions":   []interface{}{},
+		"options":     map[string]interface{}{},
+	}
+
+	expressionDatasource := map[string]string{"type": expressionDatasourceUID, "uid": expressionDatasourceUID}
+	rule := &AlertRule{
+		UID:       fmt.Sprintf("zabbix-trigger-%s", trigger.ID),
+		Title:     fmt.Sprintf("%s: %s", host.Name, trigger.Description),
+		Condition: "C",
+		Data: []AlertRuleQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: 600, To: 0},
+				DatasourceUID:     datasourceUID,
+				Model:             queryModel,
+			},
+			{
+				RefID:         "B",
+				DatasourceUID: expressionDatasourceUID,
+				Model: map[string]interface{}{
+					"refId":      "B",
+					"type":       "reduce",
+					"expression": "A",
+					"reducer":    "last",
+					"datasource": expressionDatasource,
+				},
+			},
+			{
+				RefID:         "C",
+				DatasourceUID: expressionDatasourceUID,
+				Model:         alertConditionModel(trigger, macros, lastValue, expressionDatasource),
+			},
+		},
+		NoDataState:  "NoData",
+		ExecErrState: "Error",
+		For:          "0s",
+		Labels:       triggerAlertLabels(trigger),
+		Annotations: map[string]string{
+			"summary":     trigger.Description,
+			"description": trigger.Comments,
+			"expression":  trigger.Expression,
+		},
+	}
+	return rule, ""
+}
+
+// alertConditionModel returns the condition expression of the alert rule comparing reduced value (B) like the
+// trigger. Strict comparisons are converted to the threshold expression, so recovery threshold can be set.
+// Threshold expression doesn't support ">=" and "<=", so they're compared by the math expression.
+func alertConditionModel(trigger Trigger, macros *userMacros, lastValue *lastValueCondition, datasource map[string]string) map[string]interface{} {
+	condition := lastValue.condition
+	evaluator, ok := thresholdEvaluators[condition.Operator]
+	if !ok {
+		return map[string]interface{}{
+			"refId":      "C",
+			"type":       "math",
+			"expression": fmt.Sprintf("$B %s %s", condition.Operator, strconv.FormatFloat(*condition.Value, 'f', -1, 64)),
+			"datasource": datasource,
+		}
+	}
+
+	thresholdCondition := map[string]interface{}{
+		"evaluator": map[string]interface{}{"type": evaluator, "params": []float64{*condition.Value}},
+	}
+	if unloadEvaluator := recoveryUnloadEvaluator(trigger, macros, lastValue, evaluator); unloadEvaluator != nil {
+		thresholdCondition["unloadEvaluator"] = unloadEvaluator
+	}
+	return map[string]interface{}{
+		"refId":      "C",
+		"type":       "threshold",
+		"expression": "B",
+		"conditions": []interface{}{thresholdCondition},
+		"datasource": datasource,
+	}
+}
+
+// recoveryUnloadEvaluator returns recovery threshold of the alert rule from the trigger recovery expression, so
+// the alert is resolved with the same hysteresis as the trigger. Recovery expression should compare the last
+// value of the same item in the opposite direction, like "> 90" for the problem and "< 80" for the recovery.
+// Returns nil if trigger is recovered by the problem expression or recovery expression can't be converted.
+func recoveryUnloadEvaluator(trigger Trigger, macros *userMacros, problem *lastValueCondition, evaluator string) map[string]interface{} {
+	if trigger.RecoveryMode != TriggerRecoveryModeRecoveryExpression {
+		return nil
+	}
+	recovery, ok := parseLastValueCondition(trigger.RecoveryExpression, macros, triggerHostIDs(trigger))
+	if !ok || recovery.host != problem.host || recovery.key != problem.key || recovery.condition.Value == nil {
+		return nil
+	}
+	unloadType, ok := thresholdEvaluators[recovery.condition.Operator]
+	if !ok || unloadType == evaluator {
+		return nil
+	}
+	return map[string]interface{}{"type": unloadType, "params": []float64{*recovery.condition.Value}}
+}
+
+// triggerAlertLabels returns labels of the alert from trigger tags, severity and trigger id. Trigger id label
+// allows to find the trigger event when the alert state is sent back to Zabbix.
+func triggerAlertLabels(trigger Trigger) map[string]string {
+	labels := make(map[string]string, len(trigger.Tags)+2)
+	for _, tag := range trigger.Tags {
+		labels[tag.Tag] = tag.Value
+	}
+	if trigger.Priority >= 0 && trigger.Priority < len(SeverityNames) {
+		labels["severity"] = SeverityNames[trigger.Priority]
+	}
+	labels[AlertLabelTriggerID] = trigger.ID
+	return labels
+}
+
+// exactMatchFilter returns regex filter matching exactly the given name
+func exactMatchFilter(name string) string {
+	return "/^" + regexp.QuoteMeta(name) + "$/"
+}
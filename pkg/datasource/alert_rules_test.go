@@ -0,0 +1,182 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertTriggerToAlertRule(t *testing.T) {
+	trigger := Trigger{
+		ID:          "10",
+		Description: "CPU is high",
+		Expression:  "last(/web01/system.cpu.util)>{$CPU.MAX}",
+		Priority:    SeverityHigh,
+		Hosts:       []ItemHost{{ID: "1", Name: "web01"}},
+		Items: []TriggerItem{
+			{ID: "101", Name: "CPU load", Key: "system.cpu.load", ValueType: ValueTypeFloat, HostID: "1"},
+			{ID: "100", Name: "CPU utilization", Key: "system.cpu.util", ValueType: ValueTypeFloat, HostID: "1"},
+		},
+		Tags: []ItemTag{{Tag: "service", Value: "web"}},
+	}
+	macros := &userMacros{host: map[string]map[string]string{}, global: map[string]string{"{$CPU.MAX}": "90"}}
+
+	rule, reason := convertTriggerToAlertRule(trigger, macros, "zabbix")
+	assert.Empty(t, reason)
+	assert.Equal(t, "zabbix-trigger-10", rule.UID)
+	assert.Equal(t, "web01: CPU is high", rule.Title)
+	assert.Equal(t, map[string]string{"service": "web", "severity": "High", "triggerid": "10"}, rule.Labels)
+	assert.Equal(t, 3, len(rule.Data))
+	assert.Equal(t, "zabbix", rule.Data[0].DatasourceUID)
+	assert.Equal(t, QueryFilter{Filter: "/^web01$/"}, rule.Data[0].Model["host"])
+	assert.Equal(t, QueryFilter{Filter: "/^CPU utilization$/"}, rule.Data[0].Model["item"])
+	evaluator := rule.Data[2].Model["conditions"].([]interface{})[0].(map[string]interface{})["evaluator"]
+	assert.Equal(t, map[string]interface{}{"type": "gt", "params": []float64{90}}, evaluator)
+
+	// Threshold expression has no non-strict comparisons
+	trigger.Expression = "{web01:system.cpu.util.last()}>=90.5"
+	rule, reason = convertTriggerToAlertRule(trigger, macros, "zabbix")
+	assert.Empty(t, reason)
+	assert.Equal(t, "math", rule.Data[2].Model["type"])
+	assert.Equal(t, "$B >= 90.5", rule.Data[2].Model["expression"])
+}
+
+func TestConvertTriggerToAlertRuleSkipped(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger Trigger
+		reason  string
+	}{
+		{
+			name: "text item",
+			trigger: Trigger{
+				Expression: "last(/web01/log)>0",
+				Hosts:      []ItemHost{{ID: "1", Name: "web01"}},
+				Items:      []TriggerItem{{ID: "100", Key: "log", ValueType: ValueTypeLog}},
+			},
+			reason: "item log is not numeric",
+		},
+		{
+			name: "equality",
+			trigger: Trigger{
+				Expression: "last(/web01/agent.ping)=0",
+				Hosts:      []ItemHost{{ID: "1", Name: "web01"}},
+				Items:      []TriggerItem{{ID: "100", Key: "agent.ping", ValueType: ValueTypeUint}},
+			},
+			reason: unsupportedExpressionReason,
+		},
+		{
+			name: "other function",
+			trigger: Trigger{
+				Expression: "avg(/web01/system.cpu.util,5m)>90",
+				Hosts:      []ItemHost{{ID: "1", Name: "web01"}},
+				Items:      []TriggerItem{{ID: "100", Key: "system.cpu.util", ValueType: ValueTypeFloat}},
+			},
+			reason: unsupportedExpressionReason,
+		},
+		{
+			name: "several conditions",
+			trigger: Trigger{
+				Expression: "last(/web01/system.cpu.util)>90 and last(/web01/system.cpu.load)>5",
+				Hosts:      []ItemHost{{ID: "1", Name: "web01"}},
+				Items:      []TriggerItem{{ID: "100", Key: "system.cpu.util", ValueType: ValueTypeFloat}},
+			},
+			reason: unsupportedExpressionReason,
+		},
+		{
+			name: "item not found",
+			trigger: Trigger{
+				Expression: "last(/web01/system.cpu.util)>90",
+				Hosts:      []ItemHost{{ID: "1", Name: "web01"}},
+				Items:      []TriggerItem{{ID: "100", Key: "system.cpu.load", ValueType: ValueTypeFloat}},
+			},
+			reason: "item system.cpu.util of the host web01 not found",
+		},
+		{
+			name: "unknown macro",
+			trigger: Trigger{
+				Expression: "last(/web01/system.cpu.util)>{$CPU.MAX}",
+				Hosts:      []ItemHost{{ID: "1", Name: "web01"}},
+				Items:      []TriggerItem{{ID: "100", Key: "system.cpu.util", ValueType: ValueTypeFloat}},
+			},
+			reason: "cannot resolve value of the {$CPU.MAX} macro",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, reason := convertTriggerToAlertRule(tt.trigger, nil, "zabbix")
+			assert.Nil(t, rule)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestExactMatchFilter(t *testing.T) {
+	assert.Equal(t, `/^Free disk space on / \(percentage\)$/`, exactMatchFilter("Free disk space on / (percentage)"))
+}
+
+func TestRecoveryUnloadEvaluator(t *testing.T) {
+	macros := &userMacros{host: map[string]map[string]string{}, global: map[string]string{"{$CPU.RECOVERY}": "80"}}
+
+	tests := []struct {
+		name     string
+		trigger  Trigger
+		expected map[string]interface{}
+	}{
+		{
+			name: "recovery expression",
+			trigger: Trigger{
+				Expression:         "last(/web01/system.cpu.util)>90",
+				RecoveryMode:       TriggerRecoveryModeRecoveryExpression,
+				RecoveryExpression: "last(/web01/system.cpu.util)<{$CPU.RECOVERY}",
+			},
+			expected: map[string]interface{}{"type": "lt", "params": []float64{80}},
+		},
+		{
+			name: "problem expression",
+			trigger: Trigger{
+				Expression:         "last(/web01/system.cpu.util)>90",
+				RecoveryMode:       TriggerRecoveryModeExpression,
+				RecoveryExpression: "last(/web01/system.cpu.util)<80",
+			},
+		},
+		{
+			name: "other item",
+			trigger: Trigger{
+				Expression:         "last(/web01/system.cpu.util)>90",
+				RecoveryMode:       TriggerRecoveryModeRecoveryExpression,
+				RecoveryExpression: "last(/web01/system.cpu.load)<5",
+			},
+		},
+		{
+			name: "same direction",
+			trigger: Trigger{
+				Expression:         "last(/web01/system.cpu.util)>90",
+				RecoveryMode:       TriggerRecoveryModeRecoveryExpression,
+				RecoveryExpression: "last(/web01/system.cpu.util)>=80",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem, ok := parseLastValueCondition(tt.trigger.Expression, macros, nil)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expected, recoveryUnloadEvaluator(tt.trigger, macros, problem, "gt"))
+		})
+	}
+}
+
+func TestParseLastValueCondition(t *testing.T) {
+	condition, ok := parseLastValueCondition("last(/web01/vfs.fs.size[/,pused]) >= 90", nil, nil)
+	assert.True(t, ok)
+	assert.Equal(t, &lastValueCondition{host: "web01", key: "vfs.fs.size[/,pused]", condition: TriggerCondition{Operator: ">=", Value: floatPtr(90)}}, condition)
+
+	condition, ok = parseLastValueCondition("{web01:system.cpu.util.last(0)}<5", nil, nil)
+	assert.True(t, ok)
+	assert.Equal(t, &lastValueCondition{host: "web01", key: "system.cpu.util", condition: TriggerCondition{Operator: "<", Value: floatPtr(5)}}, condition)
+
+	_, ok = parseLastValueCondition("{web01:system.cpu.util.avg(5m)}<5", nil, nil)
+	assert.False(t, ok)
+}
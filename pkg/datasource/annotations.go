@@ -0,0 +1,92 @@
+package datasource
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Titles of the problem annotations
+const (
+	annotationTitleProblem  = "Problem"
+	annotationTitleResolved = "OK"
+)
+
+// convertProblemsToAnnotationsFrame returns problems as annotations: time and end time of the problem, title,
+// problem name as text and tags. Grafana reads annotations from the frame with these field names.
+func convertProblemsToAnnotationsFrame(problems Events, triggers map[string]Trigger, options QueryOptions) *data.Frame {
+	timeField := data.NewFieldFromFieldType(data.FieldTypeTime, 0)
+	timeField.Name = "time"
+	timeEndField := data.NewFieldFromFieldType(data.FieldTypeNullableTime, 0)
+	timeEndField.Name = "timeEnd"
+	titleField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	titleField.Name = "title"
+	textField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	textField.Name = "text"
+	tagsField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	tagsField.Name = "tags"
+
+	for _, problem := range problems {
+		title := annotationTitleProblem
+		var timeEnd *time.Time
+		if problem.REventID != "" && problem.REventID != "0" {
+			title = annotationTitleResolved
+			if problem.RClock > 0 {
+				end := time.Unix(problem.RClock, 0)
+				timeEnd = &end
+			}
+		}
+
+		tags := annotationTags(problem.Tags, options.AnnotationTags, options.AnnotationTagsExclude)
+		if options.AnnotationHostTags {
+			for _, host := range triggers[problem.ObjectID].Hosts {
+				tags = append(tags, host.Name)
+			}
+		}
+
+		timeField.Append(time.Unix(problem.Clock, problem.NS))
+		timeEndField.Append(timeEnd)
+		titleField.Append(title)
+		textField.Append(problem.Name)
+		// Grafana splits tags of the annotation frame by comma
+		tagsField.Append(strings.Join(tags, ","))
+	}
+
+	return data.NewFrame("annotations", timeField, timeEndField, titleField, textField, tagsField)
+}
+
+// annotationTags converts event tags to annotation tags like "service:web", or "scope" for tags without value.
+// Only tags with names in the include list are returned if it's set, tags with names in the exclude list are
+// skipped.
+func annotationTags(tags []ItemTag, include []string, exclude []string) []string {
+	included := make(map[string]bool, len(include))
+	for _, name := range include {
+		included[name] = true
+	}
+	excluded := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if len(included) > 0 && !included[tag.Tag] || excluded[tag.Tag] {
+			continue
+		}
+		annotationTag := tag.Tag
+		if tag.Value != "" {
+			annotationTag = tag.Tag + ":" + tag.Value
+		}
+		// Comma separates the tags
+		annotationTag = strings.ReplaceAll(annotationTag, ",", " ")
+		if !seen[annotationTag] {
+			seen[annotationTag] = true
+			result = append(result, annotationTag)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,62 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertProblemsToAnnotationsFrame(t *testing.T) {
+	problems := Events{
+		{
+			ID: "10", ObjectID: "100", Clock: 1600000000, Name: "CPU is high",
+			Tags: []ItemTag{{Tag: "service", Value: "web"}, {Tag: "scope", Value: "performance"}, {Tag: "critical"}},
+		},
+		{ID: "11", ObjectID: "101", Clock: 1600000500, Name: "Disk is low", REventID: "12", RClock: 1600000800},
+	}
+	triggers := map[string]Trigger{
+		"100": {ID: "100", Hosts: []ItemHost{{ID: "1", Name: "web01"}}},
+		"101": {ID: "101", Hosts: []ItemHost{{ID: "2", Name: "db01"}}},
+	}
+
+	frame := convertProblemsToAnnotationsFrame(problems, triggers, QueryOptions{AnnotationHostTags: true})
+	assert.Equal(t, []string{"time", "timeEnd", "title", "text", "tags"}, fieldNames(frame))
+	assert.Equal(t, 2, frame.Rows())
+	assert.Equal(t, time.Unix(1600000000, 0), frame.Fields[0].At(0))
+	assert.Nil(t, frame.Fields[1].At(0))
+	resolved := time.Unix(1600000800, 0)
+	assert.Equal(t, &resolved, frame.Fields[1].At(1))
+	assert.Equal(t, "Problem", frame.Fields[2].At(0))
+	assert.Equal(t, "OK", frame.Fields[2].At(1))
+	assert.Equal(t, "CPU is high", frame.Fields[3].At(0))
+	assert.Equal(t, "critical,scope:performance,service:web,web01", frame.Fields[4].At(0))
+	assert.Equal(t, "db01", frame.Fields[4].At(1))
+}
+
+func TestAnnotationTags(t *testing.T) {
+	tags := []ItemTag{
+		{Tag: "service", Value: "web"},
+		{Tag: "service", Value: "web"},
+		{Tag: "scope", Value: "availability, performance"},
+		{Tag: "critical"},
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{name: "All tags", want: []string{"critical", "scope:availability  performance", "service:web"}},
+		{name: "Include", include: []string{"service", "env"}, want: []string{"service:web"}},
+		{name: "Exclude", exclude: []string{"scope"}, want: []string{"critical", "service:web"}},
+		{name: "Include and exclude", include: []string{"service"}, exclude: []string{"service"}, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, annotationTags(tags, tt.include, tt.exclude))
+		})
+	}
+}
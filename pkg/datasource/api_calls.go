@@ -0,0 +1,98 @@
+package datasource
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Max length of the request params stored in the API call summary
+const apiCallParamsMaxLength = 500
+
+// APICall describes Zabbix API request made during the query execution. It's shown in the query inspector
+// as a part of the frame meta.
+type APICall struct {
+	Method     string  `json:"method"`
+	Params     string  `json:"params"`
+	DurationMs float64 `json:"durationMs"`
+	ResultSize int     `json:"resultSize"`
+	Cached     bool    `json:"cached"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type apiCallsRecorderKey struct{}
+
+// apiCallsRecorder collects API calls of the single query. Requests may be done concurrently.
+type apiCallsRecorder struct {
+	mu    sync.Mutex
+	calls []APICall
+}
+
+// withAPICallsRecorder returns context recording API calls made with it
+func withAPICallsRecorder(ctx context.Context) (context.Context, *apiCallsRecorder) {
+	recorder := &apiCallsRecorder{}
+	return context.WithValue(ctx, apiCallsRecorderKey{}, recorder), recorder
+}
+
+// recordAPICall adds API call to the recorder of the context if any
+func recordAPICall(ctx context.Context, call APICall) {
+	recorder, ok := ctx.Value(apiCallsRecorderKey{}).(*apiCallsRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.calls = append(recorder.calls, call)
+}
+
+// Calls returns recorded API calls in order they're finished
+func (r *apiCallsRecorder) Calls() []APICall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := make([]APICall, len(r.calls))
+	copy(calls, r.calls)
+	return calls
+}
+
+func newAPICall(apiReq *ZabbixAPIRequest, duration time.Duration) APICall {
+	params, _ := json.Marshal(apiReq.Params)
+	paramsSummary := string(params)
+	if len(paramsSummary) > apiCallParamsMaxLength {
+		paramsSummary = paramsSummary[:apiCallParamsMaxLength] + "..."
+	}
+	return APICall{
+		Method:     apiReq.Method,
+		Params:     paramsSummary,
+		DurationMs: float64(duration) / float64(time.Millisecond),
+	}
+}
+
+// resultSize returns number of the returned objects, or 1 for a single value
+func resultSize(result *simplejson.Json) int {
+	if array, err := result.Array(); err == nil {
+		return len(array)
+	}
+	return 1
+}
+
+// setAPICallsMeta attaches API calls to the custom meta of the frames
+func setAPICallsMeta(frames []*data.Frame, calls []APICall) {
+	if len(calls) == 0 {
+		return
+	}
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		custom, ok := frame.Meta.Custom.(map[string]interface{})
+		if !ok {
+			custom = map[string]interface{}{}
+		}
+		custom["apiCalls"] = calls
+		frame.Meta.Custom = custom
+	}
+}
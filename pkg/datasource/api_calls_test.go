@@ -0,0 +1,66 @@
+package datasource
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAPICalls(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"hostid":"1"},{"hostid":"2"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	ctx, recorder := withAPICallsRecorder(context.Background())
+	query := mockZabbixQuery("host.get", ZabbixAPIParams{"output": []string{"hostid"}})
+
+	_, err := dsInstance.ZabbixQuery(ctx, query)
+	assert.NoError(t, err)
+	// Second request is returned from cache
+	_, err = dsInstance.ZabbixQuery(ctx, query)
+	assert.NoError(t, err)
+	// Requests without recorder are not recorded
+	_, err = dsInstance.ZabbixQuery(context.Background(), query)
+	assert.NoError(t, err)
+
+	calls := recorder.Calls()
+	assert.Len(t, calls, 2)
+	assert.Equal(t, "host.get", calls[0].Method)
+	assert.Equal(t, `{"output":["hostid"]}`, calls[0].Params)
+	assert.Equal(t, 2, calls[0].ResultSize)
+	assert.False(t, calls[0].Cached)
+	assert.True(t, calls[1].Cached)
+}
+
+func TestRecordAPICallError(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"error":{"code":-32602,"message":"Invalid params."}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	ctx, recorder := withAPICallsRecorder(context.Background())
+
+	_, err := dsInstance.ZabbixQuery(ctx, mockZabbixQuery("history.get", emptyParams))
+	assert.Error(t, err)
+
+	calls := recorder.Calls()
+	assert.Len(t, calls, 1)
+	assert.NotEmpty(t, calls[0].Error)
+}
+
+func TestNewAPICallTruncatesParams(t *testing.T) {
+	call := newAPICall(mockZabbixQuery("item.get", ZabbixAPIParams{"itemids": strings.Repeat("1", 1000)}), 0)
+	assert.Len(t, call.Params, apiCallParamsMaxLength+len("..."))
+}
+
+func TestSetAPICallsMeta(t *testing.T) {
+	frame := data.NewFrame("test")
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	calls := []APICall{{Method: "item.get"}}
+
+	setAPICallsMeta([]*data.Frame{frame}, calls)
+	assert.Equal(t, data.VisType(data.VisTypeTable), frame.Meta.PreferredVisualization)
+	assert.Equal(t, map[string]interface{}{"apiCalls": calls}, frame.Meta.Custom)
+
+	emptyFrame := data.NewFrame("empty")
+	setAPICallsMeta([]*data.Frame{emptyFrame}, nil)
+	assert.Nil(t, emptyFrame.Meta)
+}
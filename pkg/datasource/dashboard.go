@@ -0,0 +1,331 @@
+package datasource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Type of the Zabbix data source used in the generated panels
+const zabbixDatasourceType = "alexanderzobnin-zabbix-datasource"
+
+// Layout of the generated dashboard: two panels in a row
+const (
+	dashboardPanelWidth  = 12
+	dashboardPanelHeight = 8
+	dashboardColumns     = 2
+)
+
+// Host variable of the dashboard generated from the template graphs
+const dashboardHostVariable = "$host"
+
+// zabbixUnits maps Zabbix item units to Grafana units. Other units are shown as a suffix.
+var zabbixUnits = map[string]string{
+	"B":        "bytes",
+	"Bps":      "Bps",
+	"bps":      "bps",
+	"%":        "percent",
+	"s":        "s",
+	"uptime":   "dtdurations",
+	"unixtime": "dateTimeAsIso",
+	"rpm":      "rpm",
+	"Hz":       "hertz",
+	"W":        "watt",
+	"V":        "volt",
+	"A":        "amp",
+	"C":        "celsius",
+}
+
+// GeneratedDashboard is a Grafana dashboard JSON with panels built from the Zabbix graphs
+type GeneratedDashboard struct {
+	Title         string              `json:"title"`
+	Tags          []string            `json:"tags"`
+	Editable      bool                `json:"editable"`
+	SchemaVersion int                 `json:"schemaVersion"`
+	Time          DashboardTime       `json:"time"`
+	Templating    DashboardTemplating `json:"templating"`
+	Panels        []DashboardPanel    `json:"panels"`
+}
+
+type DashboardTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type DashboardTemplating struct {
+	List []map[string]interface{} `json:"list"`
+}
+
+// DashboardPanel is a time series or pie chart panel of the generated dashboard
+type DashboardPanel struct {
+	ID          int                      `json:"id"`
+	Type        string                   `json:"type"`
+	Title       string                   `json:"title"`
+	GridPos     DashboardGridPos         `json:"gridPos"`
+	Datasource  DashboardDatasource      `json:"datasource"`
+	Targets     []map[string]interface{} `json:"targets"`
+	FieldConfig DashboardFieldConfig     `json:"fieldConfig"`
+	Options     map[string]interface{}   `json:"options,omitempty"`
+}
+
+type DashboardGridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type DashboardDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type DashboardFieldConfig struct {
+	Defaults  map[string]interface{} `json:"defaults"`
+	Overrides []DashboardOverride    `json:"overrides"`
+}
+
+// DashboardOverride sets properties (color, draw style) of the series with the given display name
+type DashboardOverride struct {
+	Matcher    DashboardMatcher    `json:"matcher"`
+	Properties []DashboardProperty `json:"properties"`
+}
+
+type DashboardMatcher struct {
+	ID      string `json:"id"`
+	Options string `json:"options"`
+}
+
+type DashboardProperty struct {
+	ID    string      `json:"id"`
+	Value interface{} `json:"value"`
+}
+
+// generateDashboard returns a dashboard with a panel per graph of the host or template. Panels query the graph
+// items by host and item name, so they can be edited like the panels created manually. Dashboard of the template
+// has a host variable used instead of the host name.
+func (ds *ZabbixDatasourceInstance) generateDashboard(ctx context.Context, req *DashboardResourceRequest) (*GeneratedDashboard, error) {
+	if req.HostID == "" && req.TemplateID == "" {
+		return nil, errors.New("hostid or templateid is required")
+	}
+
+	method, idField, id := "host.get", "hostids", req.HostID
+	if req.HostID == "" {
+		method, idField, id = "template.get", "templateids", req.TemplateID
+	}
+	name, err := ds.getHostName(ctx, method, idField, id)
+	if err != nil {
+		return nil, err
+	}
+
+	graphs, err := ds.getGraphs(ctx, idField, id)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &GeneratedDashboard{
+		Title:         name,
+		Tags:          []string{"zabbix"},
+		Editable:      true,
+		SchemaVersion: 27,
+		Time:          DashboardTime{From: "now-6h", To: "now"},
+		Templating:    DashboardTemplating{List: []map[string]interface{}{}},
+		Panels:        []DashboardPanel{},
+	}
+
+	hostName := name
+	if req.HostID == "" {
+		hostName = dashboardHostVariable
+		dashboard.Templating.List = append(dashboard.Templating.List, map[string]interface{}{
+			"name":       strings.TrimPrefix(dashboardHostVariable, "$"),
+			"label":      "Host",
+			"type":       "query",
+			"datasource": DashboardDatasource{Type: zabbixDatasourceType, UID: req.DatasourceUID},
+			"query":      VariableQuery{QueryType: VariableQueryTypeHost, Group: "/.*/", Host: "/.*/"},
+			"refresh":    1,
+		})
+	}
+
+	for _, graph := range graphs {
+		panel := convertGraphToPanel(graph, hostName, req.DatasourceUID)
+		if panel == nil {
+			continue
+		}
+		i := len(dashboard.Panels)
+		panel.ID = i + 1
+		panel.GridPos = DashboardGridPos{
+			X: (i % dashboardColumns) * dashboardPanelWidth,
+			Y: (i / dashboardColumns) * dashboardPanelHeight,
+			W: dashboardPanelWidth,
+			H: dashboardPanelHeight,
+		}
+		dashboard.Panels = append(dashboard.Panels, *panel)
+	}
+	return dashboard, nil
+}
+
+func (ds *ZabbixDatasourceInstance) getHostName(ctx context.Context, method string, idField string, id string) (string, error) {
+	params := ZabbixAPIParams{
+		"output": []string{"name"},
+		idField:  []string{id},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: method, Params: params})
+	if err != nil {
+		return "", err
+	}
+
+	for _, host := range response.MustArray() {
+		if name, ok := host.(map[string]interface{})["name"].(string); ok {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found: %s", strings.TrimSuffix(idField, "ids"), id)
+}
+
+func (ds *ZabbixDatasourceInstance) getGraphs(ctx context.Context, idField string, id string) ([]Graph, error) {
+	params := ZabbixAPIParams{
+		"output":           []string{"graphid", "name", "graphtype"},
+		idField:            []string{id},
+		"selectGraphItems": []string{"itemid", "color", "drawtype", "sortorder", "yaxisside"},
+		"selectItems":      []string{"itemid", "name", "key_", "value_type", "units"},
+		"sortfield":        "name",
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "graph.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	graphsJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var graphs []Graph
+	err = json.Unmarshal(graphsJSON, &graphs)
+	if err != nil {
+		return nil, err
+	}
+	return graphs, nil
+}
+
+// convertGraphToPanel returns panel with a query per numeric item of the graph, or nil if the graph has no
+// numeric items. Graph item colors and draw styles are set as overrides of the item series.
+func convertGraphToPanel(graph Graph, hostName string, datasourceUID string) *DashboardPanel {
+	itemsByID := make(map[string]Item, len(graph.Items))
+	for _, item := range graph.Items {
+		itemsByID[item.ID] = item
+	}
+
+	graphItems := make([]GraphItem, len(graph.GraphItems))
+	copy(graphItems, graph.GraphItems)
+	sort.SliceStable(graphItems, func(i, j int) bool {
+		return graphItems[i].SortOrder < graphItems[j].SortOrder
+	})
+
+	panel := &DashboardPanel{
+		Type:        "timeseries",
+		Title:       graph.Name,
+		Datasource:  DashboardDatasource{Type: zabbixDatasourceType, UID: datasourceUID},
+		Targets:     []map[string]interface{}{},
+		FieldConfig: DashboardFieldConfig{Defaults: map[string]interface{}{}, Overrides: []DashboardOverride{}},
+	}
+
+	custom := map[string]interface{}{}
+	switch graph.GraphType {
+	case GraphTypeStacked:
+		custom["stacking"] = map[string]interface{}{"mode": "normal", "group": "A"}
+		custom["fillOpacity"] = 70
+	case GraphTypePie, GraphTypeExploded:
+		panel.Type = "piechart"
+		panel.Options = map[string]interface{}{
+			"reduceOptions": map[string]interface{}{"calcs": []string{"lastNotNull"}, "values": false},
+			"pieType":       "pie",
+		}
+	}
+	if len(custom) > 0 {
+		panel.FieldConfig.Defaults["custom"] = custom
+	}
+
+	for _, graphItem := range graphItems {
+		item, ok := itemsByID[graphItem.ItemID]
+		if !ok || item.ValueType != ValueTypeFloat && item.ValueType != ValueTypeUint {
+			continue
+		}
+		refID := string(rune('A' + len(panel.Targets)%26))
+		if n := len(panel.Targets) / 26; n > 0 {
+			refID = fmt.Sprintf("%s%d", refID, n)
+		}
+
+		itemName := item.ExpandItem()
+		hostFilter := hostName
+		if !strings.HasPrefix(hostName, "$") {
+			hostFilter = exactMatchFilter(hostName)
+		}
+		panel.Targets = append(panel.Targets, map[string]interface{}{
+			"refId":       refID,
+			"queryType":   "0",
+			"mode":        QueryModeMetrics,
+			"group":       QueryFilter{Filter: "/.*/"},
+			"host":        QueryFilter{Filter: hostFilter},
+			"application": QueryFilter{Filter: ""},
+			"item":        QueryFilter{Filter: exactMatchFilter(itemName)},
+			"functions":   []interface{}{},
+			"options":     map[string]interface{}{},
+		})
+
+		if _, ok := panel.FieldConfig.Defaults["unit"]; !ok && item.Units != "" {
+			panel.FieldConfig.Defaults["unit"] = grafanaUnit(item.Units)
+		}
+		if override := graphItemOverride(graphItem, fmt.Sprintf("%s: %s", hostName, itemName)); override != nil {
+			panel.FieldConfig.Overrides = append(panel.FieldConfig.Overrides, *override)
+		}
+	}
+
+	if len(panel.Targets) == 0 {
+		return nil
+	}
+	return panel
+}
+
+// graphItemOverride returns override with the color and draw style of the graph item
+func graphItemOverride(graphItem GraphItem, displayName string) *DashboardOverride {
+	var properties []DashboardProperty
+	if graphItem.Color != "" {
+		properties = append(properties, DashboardProperty{
+			ID:    "color",
+			Value: map[string]string{"mode": "fixed", "fixedColor": "#" + graphItem.Color},
+		})
+	}
+	switch graphItem.DrawType {
+	case GraphItemDrawFilled, GraphItemDrawGradient:
+		properties = append(properties, DashboardProperty{ID: "custom.fillOpacity", Value: 50})
+	case GraphItemDrawBoldLine:
+		properties = append(properties, DashboardProperty{ID: "custom.lineWidth", Value: 2})
+	case GraphItemDrawDot:
+		properties = append(properties, DashboardProperty{ID: "custom.drawStyle", Value: "points"})
+	case GraphItemDrawDashedLine:
+		properties = append(properties, DashboardProperty{ID: "custom.lineStyle", Value: map[string]interface{}{"fill": "dash", "dash": []int{10, 10}}})
+	}
+	if graphItem.YAxisSide == 1 {
+		properties = append(properties, DashboardProperty{ID: "custom.axisPlacement", Value: "right"})
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+	return &DashboardOverride{
+		Matcher:    DashboardMatcher{ID: "byName", Options: displayName},
+		Properties: properties,
+	}
+}
+
+// grafanaUnit returns Grafana unit for the Zabbix item units
+func grafanaUnit(units string) string {
+	if unit, ok := zabbixUnits[units]; ok {
+		return unit
+	}
+	return "suffix:" + units
+}
@@ -0,0 +1,91 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertGraphToPanel(t *testing.T) {
+	graph := Graph{
+		ID:        "1",
+		Name:      "Network traffic on eth0",
+		GraphType: GraphTypeStacked,
+		GraphItems: []GraphItem{
+			{ItemID: "101", Color: "F63100", DrawType: GraphItemDrawBoldLine, SortOrder: 1, YAxisSide: 1},
+			{ItemID: "100", Color: "1A7C11", DrawType: GraphItemDrawFilled, SortOrder: 0},
+			{ItemID: "102", SortOrder: 2},
+		},
+		Items: Items{
+			{ID: "100", Name: "Incoming traffic on $1", Key: "net.if.in[eth0]", ValueType: ValueTypeUint, Units: "bps"},
+			{ID: "101", Name: "Outgoing traffic on $1", Key: "net.if.out[eth0]", ValueType: ValueTypeUint, Units: "bps"},
+			{ID: "102", Name: "Interface name", Key: "net.if.name", ValueType: ValueTypeText},
+		},
+	}
+
+	panel := convertGraphToPanel(graph, "web01", "zabbix")
+	assert.Equal(t, "timeseries", panel.Type)
+	assert.Equal(t, "Network traffic on eth0", panel.Title)
+	assert.Equal(t, DashboardDatasource{Type: zabbixDatasourceType, UID: "zabbix"}, panel.Datasource)
+	assert.Equal(t, "bps", panel.FieldConfig.Defaults["unit"])
+	assert.Equal(t, map[string]interface{}{"mode": "normal", "group": "A"}, panel.FieldConfig.Defaults["custom"].(map[string]interface{})["stacking"])
+
+	assert.Len(t, panel.Targets, 2)
+	assert.Equal(t, "A", panel.Targets[0]["refId"])
+	assert.Equal(t, QueryFilter{Filter: "/^web01$/"}, panel.Targets[0]["host"])
+	assert.Equal(t, QueryFilter{Filter: "/^Incoming traffic on eth0$/"}, panel.Targets[0]["item"])
+	assert.Equal(t, QueryFilter{Filter: "/^Outgoing traffic on eth0$/"}, panel.Targets[1]["item"])
+
+	assert.Equal(t, []DashboardOverride{
+		{
+			Matcher: DashboardMatcher{ID: "byName", Options: "web01: Incoming traffic on eth0"},
+			Properties: []DashboardProperty{
+				{ID: "color", Value: map[string]string{"mode": "fixed", "fixedColor": "#1A7C11"}},
+				{ID: "custom.fillOpacity", Value: 50},
+			},
+		},
+		{
+			Matcher: DashboardMatcher{ID: "byName", Options: "web01: Outgoing traffic on eth0"},
+			Properties: []DashboardProperty{
+				{ID: "color", Value: map[string]string{"mode": "fixed", "fixedColor": "#F63100"}},
+				{ID: "custom.lineWidth", Value: 2},
+				{ID: "custom.axisPlacement", Value: "right"},
+			},
+		},
+	}, panel.FieldConfig.Overrides)
+
+	graph.GraphType = GraphTypePie
+	panel = convertGraphToPanel(graph, dashboardHostVariable, "zabbix")
+	assert.Equal(t, "piechart", panel.Type)
+	assert.Equal(t, QueryFilter{Filter: "$host"}, panel.Targets[0]["host"])
+
+	graph.Items = Items{{ID: "102", Name: "Interface name", ValueType: ValueTypeText}}
+	assert.Nil(t, convertGraphToPanel(graph, "web01", "zabbix"))
+}
+
+func TestGenerateDashboard(t *testing.T) {
+	// Same response is used for the host and the graphs
+	dsInstance := MockZabbixDataSource(`{"result":[{
+		"hostid":"10","templateid":"10","graphid":"1","name":"CPU load","graphtype":"0",
+		"gitems":[{"itemid":"100","color":"1A7C11","drawtype":"0","sortorder":"0","yaxisside":"0"}],
+		"items":[{"itemid":"100","name":"Load average","key_":"system.cpu.load","value_type":"0","units":""}]
+	}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	dashboard, err := dsInstance.generateDashboard(context.Background(), &DashboardResourceRequest{DatasourceUID: "zabbix", HostID: "10"})
+	assert.NoError(t, err)
+	assert.Equal(t, "CPU load", dashboard.Title)
+	assert.Empty(t, dashboard.Templating.List)
+	assert.Len(t, dashboard.Panels, 1)
+	assert.Equal(t, DashboardGridPos{X: 0, Y: 0, W: dashboardPanelWidth, H: dashboardPanelHeight}, dashboard.Panels[0].GridPos)
+	assert.Nil(t, dashboard.Panels[0].FieldConfig.Defaults["unit"])
+
+	dashboard, err = dsInstance.generateDashboard(context.Background(), &DashboardResourceRequest{DatasourceUID: "zabbix", TemplateID: "10"})
+	assert.NoError(t, err)
+	assert.Len(t, dashboard.Templating.List, 1)
+	assert.Equal(t, "host", dashboard.Templating.List[0]["name"])
+
+	_, err = dsInstance.generateDashboard(context.Background(), &DashboardResourceRequest{})
+	assert.EqualError(t, err, "hostid or templateid is required")
+}
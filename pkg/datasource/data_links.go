@@ -0,0 +1,75 @@
+package datasource
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Names of the problems frame fields used in the problem data link
+const (
+	problemEventIDField   = "Event ID"
+	problemTriggerIDField = "Trigger ID"
+)
+
+// zabbixWebLink returns URL of the Zabbix frontend page, webURL is the frontend URL from the settings
+func zabbixWebLink(webURL string, page string, query string) string {
+	return strings.TrimRight(webURL, "/") + "/" + page + "?" + query
+}
+
+// itemDataLinks returns links to the latest data graph of the item and to the dashboard of the item host
+func itemDataLinks(webURL string, item Item) []data.DataLink {
+	links := []data.DataLink{{
+		Title:       "Latest data graph",
+		TargetBlank: true,
+		URL:         zabbixWebLink(webURL, "history.php", "action=showgraph&itemids%5B%5D="+url.QueryEscape(item.ID)),
+	}}
+
+	hostID := item.HostID
+	if len(item.Hosts) > 0 {
+		hostID = item.Hosts[0].ID
+	}
+	if hostID != "" {
+		links = append(links, data.DataLink{
+			Title:       "Host dashboard",
+			TargetBlank: true,
+			URL:         zabbixWebLink(webURL, "zabbix.php", "action=host.dashboard.view&hostid="+url.QueryEscape(hostID)),
+		})
+	}
+	return links
+}
+
+// setItemDataLinks attaches links to the Zabbix frontend to the series of the items.
+// Series should be in the same order as items.
+func setItemDataLinks(series []*timeseries.TimeSeriesData, items Items, webURL string) {
+	for i, item := range items {
+		if i >= len(series) {
+			break
+		}
+		if series[i].Meta.FieldConfig == nil {
+			series[i].Meta.FieldConfig = &data.FieldConfig{}
+		}
+		series[i].Meta.FieldConfig.Links = itemDataLinks(webURL, item)
+	}
+}
+
+// setProblemDataLinks attaches link to the problem event details to the problem field. IDs differ by rows, so
+// they are filled in by Grafana from the event and trigger ID fields.
+func setProblemDataLinks(frame *data.Frame, webURL string) {
+	query := `triggerid=${__data.fields["` + problemTriggerIDField + `"]}&eventid=${__data.fields["` + problemEventIDField + `"]}`
+	for _, field := range frame.Fields {
+		if field.Name != "Problem" {
+			continue
+		}
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		field.Config.Links = []data.DataLink{{
+			Title:       "Problem in Zabbix",
+			TargetBlank: true,
+			URL:         zabbixWebLink(webURL, "tr_events.php", query),
+		}}
+	}
+}
@@ -0,0 +1,49 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetItemDataLinks(t *testing.T) {
+	items := Items{
+		{ID: "100", Hosts: []ItemHost{{ID: "10", Name: "web01"}}},
+		{ID: "101"},
+	}
+	series := []*timeseries.TimeSeriesData{timeseries.NewTimeSeriesData(), timeseries.NewTimeSeriesData()}
+
+	setItemDataLinks(series, items, "https://zabbix.example.com/zabbix")
+	assert.Equal(t, []data.DataLink{
+		{Title: "Latest data graph", TargetBlank: true, URL: "https://zabbix.example.com/zabbix/history.php?action=showgraph&itemids%5B%5D=100"},
+		{Title: "Host dashboard", TargetBlank: true, URL: "https://zabbix.example.com/zabbix/zabbix.php?action=host.dashboard.view&hostid=10"},
+	}, series[0].Meta.FieldConfig.Links)
+	assert.Equal(t, []data.DataLink{
+		{Title: "Latest data graph", TargetBlank: true, URL: "https://zabbix.example.com/zabbix/history.php?action=showgraph&itemids%5B%5D=101"},
+	}, series[1].Meta.FieldConfig.Links)
+}
+
+func TestSetProblemDataLinks(t *testing.T) {
+	frame := convertProblemsToFrame(Events{{ID: "10", ObjectID: "100", Name: "CPU is high"}}, nil, nil, Severities{}, time.Now())
+	setProblemDataLinks(frame, "https://zabbix.example.com")
+
+	assert.Equal(t, []data.DataLink{{
+		Title:       "Problem in Zabbix",
+		TargetBlank: true,
+		URL:         `https://zabbix.example.com/tr_events.php?triggerid=${__data.fields["Trigger ID"]}&eventid=${__data.fields["Event ID"]}`,
+	}}, frame.Fields[2].Config.Links)
+	assert.Nil(t, frame.Fields[1].Config)
+}
+
+func TestReadZabbixWebURL(t *testing.T) {
+	settings, err := readZabbixSettings(&backend.DataSourceInstanceSettings{JSONData: []byte(`{"zabbixWebUrl":"https://zabbix.example.com/zabbix/"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://zabbix.example.com/zabbix", settings.ZabbixWebURL)
+
+	_, err = readZabbixSettings(&backend.DataSourceInstanceSettings{JSONData: []byte(`{"zabbixWebUrl":"zabbix.example.com"}`)})
+	assert.EqualError(t, err, "invalid zabbixWebUrl: zabbix.example.com, expected absolute http or https URL")
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
 	"github.com/alexanderzobnin/grafana-zabbix/pkg/gtime"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
 	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -18,7 +23,6 @@ import (
 )
 
 var (
-	ErrFunctionsNotSupported      = errors.New("zabbix queries with functions are not supported")
 	ErrNonMetricQueryNotSupported = errors.New("non-metrics queries are not supported")
 )
 
@@ -30,18 +34,25 @@ type ZabbixDatasource struct {
 // ZabbixDatasourceInstance stores state about a specific datasource
 // and provides methods to make requests to the Zabbix API
 type ZabbixDatasourceInstance struct {
-	zabbixAPI  *zabbixapi.ZabbixAPI
-	dsInfo     *backend.DataSourceInstanceSettings
-	Settings   *ZabbixDatasourceSettings
-	queryCache *DatasourceCache
-	logger     log.Logger
+	zabbixAPI   *zabbixapi.ZabbixAPI
+	dbConnector historyConnector
+	dbState     dbState
+	apiState    apiState
+	queryStats  queryStats
+	timezone    timezoneState
+	// historyCache is nil if incremental history requests are disabled
+	historyCache *historyCache
+	dsInfo       *backend.DataSourceInstanceSettings
+	Settings     *ZabbixDatasourceSettings
+	queryCache   *DatasourceCache
+	logger       log.Logger
 }
 
 func NewZabbixDatasource() *ZabbixDatasource {
 	im := datasource.NewInstanceManager(newZabbixDatasourceInstance)
 	return &ZabbixDatasource{
 		im:     im,
-		logger: log.New(),
+		logger: newRedactingLogger(log.New(), "", nil, nil),
 	}
 }
 
@@ -56,30 +67,60 @@ func newZabbixDatasourceInstance(settings backend.DataSourceInstanceSettings) (i
 		return nil, err
 	}
 
-	zabbixAPI, err := zabbixapi.New(&settings, zabbixSettings.Timeout)
+	var zabbixAPI *zabbixapi.ZabbixAPI
+	logger = newRedactingLogger(logger, zabbixSettings.LogLevel, secureSettingsValues(&settings), func() string {
+		if zabbixAPI == nil {
+			return ""
+		}
+		return zabbixAPI.GetAuth()
+	})
+
+	zabbixAPI, err = zabbixapi.New(&settings, zabbixSettings.Timeout)
 	if err != nil {
 		logger.Error("Error initializing Zabbix API", "error", err)
 		return nil, err
 	}
+	if supported, ok := pinnedCapability(zabbixSettings, "loginUsername"); ok && supported {
+		zabbixAPI.SetUsernameParam("username")
+	}
+
+	dbConnector, err := newHistoryConnector(zabbixSettings, settings.DecryptedSecureJSONData, logger)
+	if err != nil {
+		logger.Error("Error initializing DB connection", "error", err)
+		return nil, err
+	}
 
 	return &ZabbixDatasourceInstance{
-		dsInfo:     &settings,
-		zabbixAPI:  zabbixAPI,
-		Settings:   zabbixSettings,
-		queryCache: NewDatasourceCache(zabbixSettings.CacheTTL, 10*time.Minute),
-		logger:     logger,
+		dsInfo:       &settings,
+		zabbixAPI:    zabbixAPI,
+		dbConnector:  dbConnector,
+		Settings:     zabbixSettings,
+		queryCache:   NewDatasourceCache(zabbixSettings.CacheTTL, 10*time.Minute),
+		historyCache: newHistoryCache(zabbixSettings),
+		logger:       logger,
 	}, nil
 }
 
+// Dispose closes DB connections of the instance before it's replaced with the new one on settings change
+func (ds *ZabbixDatasourceInstance) Dispose() {
+	if ds.dbConnector != nil {
+		if err := ds.dbConnector.Close(); err != nil {
+			ds.logger.Warn("Error closing DB connection", "error", err)
+		}
+	}
+}
+
 // CheckHealth checks if the plugin is running properly
 func (ds *ZabbixDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	ctx = requestid.With(ctx, requestid.New())
+	logger := requestid.Logger(ctx, ds.logger)
 	res := &backend.CheckHealthResult{}
 
 	dsInstance, err := ds.getDSInstance(req.PluginContext)
 	if err != nil {
 		res.Status = backend.HealthStatusError
-		res.Message = "Error getting datasource instance"
-		ds.logger.Error("Error getting datasource instance", "err", err)
+		res.Message = "Error getting datasource instance: " + err.Error()
+		logger.Error("Error getting datasource instance", "err", err)
 		return res, nil
 	}
 
@@ -87,16 +128,30 @@ func (ds *ZabbixDatasource) CheckHealth(ctx context.Context, req *backend.CheckH
 	if err != nil {
 		res.Status = backend.HealthStatusError
 		res.Message = err.Error()
-		ds.logger.Error("Error connecting Zabbix server", "err", err)
+		logger.Error("Error connecting Zabbix server", "err", err)
 		return res, nil
 	}
 
 	res.Status = backend.HealthStatusOk
 	res.Message = message
+
+	// Direct DB connection details (schema version, replication lag, pool usage) are returned as JSON
+	if dbHealth := dsInstance.DBHealth(ctx); dbHealth != nil {
+		res.JSONDetails, _ = json.Marshal(map[string]interface{}{"db": dbHealth})
+		if !dbHealth.Connected {
+			res.Status = backend.HealthStatusError
+			res.Message = fmt.Sprintf("direct DB connection (%s) failed: %s", dbHealth.Type, dbHealth.Error)
+			logger.Error("Error connecting Zabbix database", "type", dbHealth.Type, "err", dbHealth.Error)
+			return res, nil
+		}
+		res.Message = message + "; " + dbHealthMessage(dbHealth)
+	}
 	return res, nil
 }
 
 func (ds *ZabbixDatasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	ctx = requestid.With(ctx, requestid.FromMap(req.Headers))
+	logger := requestid.Logger(ctx, ds.logger)
 	qdr := backend.NewQueryDataResponse()
 
 	zabbixDS, err := ds.getDSInstance(req.PluginContext)
@@ -104,24 +159,133 @@ func (ds *ZabbixDatasource) QueryData(ctx context.Context, req *backend.QueryDat
 		return nil, err
 	}
 
+	// Math queries use results of other queries, so they're evaluated after all of them
+	var mathQueries []backend.DataQuery
 	for _, q := range req.Queries {
+		start := time.Now()
 		res := backend.DataResponse{}
 		query, err := ReadQuery(q)
-		ds.logger.Debug("DS query", "query", q)
+		logger.Debug("DS query", "query", q)
+		queryCtx, apiCalls := withAPICallsRecorder(ctx)
+		queryCtx, notices := withNoticesRecorder(queryCtx)
+		queryCtx, historySources := withHistorySourcesRecorder(queryCtx)
+		queryCtx, dbQueries := dbconnector.WithQueriesRecorder(queryCtx)
+		queryCtx = withGrafanaUser(queryCtx, req.PluginContext.User)
+		if err == nil {
+			query.Location = zabbixDS.getTimezone(queryCtx)
+		}
 		if err != nil {
 			res.Error = err
-		} else if len(query.Functions) > 0 {
-			res.Error = ErrFunctionsNotSupported
-		} else if query.Mode != 0 {
-			res.Error = ErrNonMetricQueryNotSupported
-		} else {
-			frame, err := zabbixDS.queryNumericItems(ctx, &query)
+		} else if query.Mode == QueryModeMath {
+			mathQueries = append(mathQueries, q)
+			continue
+		} else if query.Mode == QueryModeMetrics {
+			frame, err := zabbixDS.queryNumericItems(queryCtx, &query)
+			if err != nil {
+				res.Error = err
+			} else {
+				res.Frames = []*data.Frame{frame}
+			}
+		} else if query.Mode == QueryModeItemID {
+			frame, err := zabbixDS.queryItemIDs(queryCtx, &query)
+			if err != nil {
+				res.Error = err
+			} else {
+				res.Frames = []*data.Frame{frame}
+			}
+		} else if query.Mode == QueryModeText && query.Options.ExtractNumericValues {
+			frame, err := zabbixDS.queryTextItemsAsNumeric(queryCtx, &query)
+			if err != nil {
+				res.Error = err
+			} else {
+				res.Frames = []*data.Frame{frame}
+			}
+		} else if query.Mode == QueryModeText {
+			frames, err := zabbixDS.queryTextItems(queryCtx, &query)
+			if err != nil {
+				res.Error = err
+			} else {
+				res.Frames = frames
+			}
+		} else if query.Mode == QueryModeTriggers && query.Triggers.Count {
+			frame, err := zabbixDS.queryCurrentProblemsCount(queryCtx, &query)
+			if err != nil {
+				res.Error = err
+			} else {
+				res.Frames = []*data.Frame{frame}
+			}
+		} else if query.Mode == QueryModeTriggers {
+			frame, err := zabbixDS.queryTriggersCount(queryCtx, &query)
+			if err != nil {
+				res.Error = err
+			} else {
+				res.Frames = []*data.Frame{frame}
+			}
+		} else if query.Mode == QueryModeITService {
+			frame, err := zabbixDS.queryITServices(queryCtx, &query)
+			if err != nil {
+				res.Error = err
+			} else {
+				res.Frames = []*data.Frame{frame}
+			}
+		} else if query.Mode == QueryModeTriggerState {
+			frame, err := zabbixDS.queryTriggerState(queryCtx, &query)
 			if err != nil {
 				res.Error = err
 			} else {
 				res.Frames = []*data.Frame{frame}
 			}
+		} else if query.Mode == QueryModeProblems {
+			frame, err := zabbixDS.queryProblems(queryCtx, &query)
+			if err != nil {
+				res.Error = err
+			} else {
+				res.Frames = []*data.Frame{frame}
+			}
+		} else {
+			res.Error = ErrNonMetricQueryNotSupported
+		}
+		if res.Error == nil && query.Options.NumericOnly {
+			res.Frames = convertToNumericFrames(res.Frames)
+		}
+		if res.Error == nil && query.Options.Stream {
+			zabbixDS.setStreamChannel(res.Frames, &query, q.JSON, req.PluginContext.User)
 		}
+		if res.Error != nil {
+			res.Error = withErrorSource(res.Error)
+			logger.Error("Query failed", "refId", q.RefID, "errorSource", GetErrorSource(res.Error), "error", res.Error)
+		}
+		duration := time.Since(start)
+		calls := apiCalls.Calls()
+		zabbixDS.logSlowQuery(queryCtx, q, &query, duration, res, calls, dbQueries.Queries())
+		zabbixDS.queryStats.add(newQueryStatsEntry(q, &query, start, duration, calls, res.Error))
+		setAPICallsMeta(res.Frames, calls)
+		setHistorySourcesMeta(res.Frames, historySources.Sources())
+		setDBQueriesMeta(res.Frames, dbQueries.Queries())
+		setNotices(res.Frames, notices.Notices())
+		qdr.Responses[q.RefID] = res
+	}
+
+	mathQueries, circularRefs := sortMathQueries(mathQueries)
+	for _, q := range mathQueries {
+		start := time.Now()
+		res := backend.DataResponse{}
+		query, _ := ReadQuery(q)
+		if err, ok := circularRefs[q.RefID]; ok {
+			res.Error = err
+		} else if frame, err := queryMath(&query, qdr.Responses); err != nil {
+			res.Error = err
+		} else {
+			res.Frames = []*data.Frame{frame}
+		}
+		if res.Error != nil {
+			res.Error = withErrorSource(res.Error)
+			logger.Error("Query failed", "refId", q.RefID, "errorSource", GetErrorSource(res.Error), "error", res.Error)
+		}
+		// Math queries don't make API calls, so only duration is recorded
+		duration := time.Since(start)
+		zabbixDS.logSlowQuery(ctx, q, &query, duration, res, nil, nil)
+		zabbixDS.queryStats.add(newQueryStatsEntry(q, &query, start, duration, nil, res.Error))
 		qdr.Responses[q.RefID] = res
 	}
 
@@ -137,55 +301,178 @@ func (ds *ZabbixDatasource) getDSInstance(pluginContext backend.PluginContext) (
 	return instance.(*ZabbixDatasourceInstance), nil
 }
 
+// Defaults of the data source settings which aren't set in the jsonData
+const (
+	defaultTrendsFrom  = "7d"
+	defaultTrendsRange = "4d"
+	defaultCacheTTL    = "1h"
+	defaultTimeout     = "30"
+
+	defaultHistoryCacheOverlap   = "10m"
+	defaultHistoryCacheMaxPoints = 1000000
+)
+
+// readZabbixSettings parses and validates jsonData of the data source. Invalid values are returned as errors
+// instead of falling back to defaults, so provisioned data sources with typos fail the health check.
 func readZabbixSettings(dsInstanceSettings *backend.DataSourceInstanceSettings) (*ZabbixDatasourceSettings, error) {
 	zabbixSettingsDTO := &ZabbixDatasourceSettingsDTO{}
 
 	err := json.Unmarshal(dsInstanceSettings.JSONData, &zabbixSettingsDTO)
 	if err != nil {
-		return nil, err
-	}
-
-	if zabbixSettingsDTO.TrendsFrom == "" {
-		zabbixSettingsDTO.TrendsFrom = "7d"
-	}
-	if zabbixSettingsDTO.TrendsRange == "" {
-		zabbixSettingsDTO.TrendsRange = "4d"
-	}
-	if zabbixSettingsDTO.CacheTTL == "" {
-		zabbixSettingsDTO.CacheTTL = "1h"
+		return nil, fmt.Errorf("invalid settings: %w", err)
 	}
 
-	if zabbixSettingsDTO.Timeout == "" {
-		zabbixSettingsDTO.Timeout = "30"
+	trendsFrom, err := parseSettingsInterval("trendsFrom", zabbixSettingsDTO.TrendsFrom, defaultTrendsFrom)
+	if err != nil {
+		return nil, err
 	}
 
-	trendsFrom, err := gtime.ParseInterval(zabbixSettingsDTO.TrendsFrom)
+	trendsRange, err := parseSettingsInterval("trendsRange", zabbixSettingsDTO.TrendsRange, defaultTrendsRange)
 	if err != nil {
 		return nil, err
 	}
 
-	trendsRange, err := gtime.ParseInterval(zabbixSettingsDTO.TrendsRange)
+	cacheTTL, err := parseSettingsInterval("cacheTTL", zabbixSettingsDTO.CacheTTL, defaultCacheTTL)
 	if err != nil {
 		return nil, err
 	}
 
-	cacheTTL, err := gtime.ParseInterval(zabbixSettingsDTO.CacheTTL)
+	historyCacheOverlap, err := parseSettingsInterval("historyCacheOverlap", zabbixSettingsDTO.HistoryCacheOverlap, defaultHistoryCacheOverlap)
 	if err != nil {
 		return nil, err
 	}
 
-	timeout, err := strconv.Atoi(zabbixSettingsDTO.Timeout)
+	historyCacheMaxPoints := zabbixSettingsDTO.HistoryCacheMaxPoints
+	if historyCacheMaxPoints < 0 {
+		return nil, fmt.Errorf("invalid historyCacheMaxPoints: %d, expected non-negative number", historyCacheMaxPoints)
+	} else if historyCacheMaxPoints == 0 {
+		historyCacheMaxPoints = defaultHistoryCacheMaxPoints
+	}
+
+	timeoutValue := strings.TrimSpace(string(zabbixSettingsDTO.Timeout))
+	if timeoutValue == "" {
+		timeoutValue = defaultTimeout
+	}
+	timeout, err := strconv.Atoi(timeoutValue)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout: %s, expected positive number of seconds", timeoutValue)
+	}
+
+	var slowQueryThreshold time.Duration
+	if zabbixSettingsDTO.SlowQueryThreshold != "" {
+		slowQueryThreshold, err = parseSettingsInterval("slowQueryThreshold", zabbixSettingsDTO.SlowQueryThreshold, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := logLevels[zabbixSettingsDTO.LogLevel]; zabbixSettingsDTO.LogLevel != "" && !ok {
+		return nil, errors.New("invalid log level: " + zabbixSettingsDTO.LogLevel)
+	}
+
+	if zabbixSettingsDTO.ZabbixVersion != "" {
+		if _, err := parseZabbixVersion(zabbixSettingsDTO.ZabbixVersion); err != nil {
+			return nil, fmt.Errorf("invalid zabbixVersion: %w", err)
+		}
+	}
+
+	if zabbixSettingsDTO.ZabbixWebURL != "" {
+		webURL, err := url.Parse(zabbixSettingsDTO.ZabbixWebURL)
+		if err != nil || (webURL.Scheme != "http" && webURL.Scheme != "https") || webURL.Host == "" {
+			return nil, fmt.Errorf("invalid zabbixWebUrl: %s, expected absolute http or https URL", zabbixSettingsDTO.ZabbixWebURL)
+		}
+	}
+
+	var timezone *time.Location
+	if zabbixSettingsDTO.Timezone != "" {
+		timezone, err = time.LoadLocation(zabbixSettingsDTO.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+
+	severities, err := newSeverities(zabbixSettingsDTO.Severities)
 	if err != nil {
-		return nil, errors.New("failed to parse timeout: " + err.Error())
+		return nil, fmt.Errorf("invalid severities: %w", err)
+	}
+
+	pool := []struct {
+		name  string
+		value int
+	}{
+		{"dbMaxOpenConns", zabbixSettingsDTO.DBMaxOpenConns},
+		{"dbMaxIdleConns", zabbixSettingsDTO.DBMaxIdleConns},
+		{"dbConnMaxLifetime", zabbixSettingsDTO.DBConnMaxLifetime},
+	}
+	for _, p := range pool {
+		if p.value < 0 {
+			return nil, fmt.Errorf("invalid %s: %d, expected non-negative number", p.name, p.value)
+		}
+	}
+
+	// Password saved in the plain settings by the old plugin versions is used as a fallback
+	password := zabbixSettingsDTO.Password
+	if securePassword, exists := dsInstanceSettings.DecryptedSecureJSONData["password"]; exists {
+		password = securePassword
 	}
 
 	zabbixSettings := &ZabbixDatasourceSettings{
+		Username: zabbixSettingsDTO.Username,
+		Password: password,
+
 		Trends:      zabbixSettingsDTO.Trends,
 		TrendsFrom:  trendsFrom,
 		TrendsRange: trendsRange,
 		CacheTTL:    cacheTTL,
 		Timeout:     time.Duration(timeout) * time.Second,
+
+		HistoryCache:          zabbixSettingsDTO.HistoryCache,
+		HistoryCacheOverlap:   historyCacheOverlap,
+		HistoryCacheMaxPoints: historyCacheMaxPoints,
+
+		DisableDataAlignment:    zabbixSettingsDTO.DisableDataAlignment,
+		DisableReadOnlyUsersAck: zabbixSettingsDTO.DisableReadOnlyUsersAck,
+		AlertAcknowledge:        zabbixSettingsDTO.AlertAcknowledge,
+
+		DBConnectionType: zabbixSettingsDTO.DBConnectionType,
+		DBHost:           zabbixSettingsDTO.DBHost,
+		DBName:           zabbixSettingsDTO.DBName,
+		DBUser:           zabbixSettingsDTO.DBUser,
+		DBSchema:         zabbixSettingsDTO.DBSchema,
+		DBPartitioning:   zabbixSettingsDTO.DBPartitioning,
+		DBSSLMode:        zabbixSettingsDTO.DBSSLMode,
+		DBSSLRootCert:    zabbixSettingsDTO.DBSSLRootCert,
+		DBSSLCert:        zabbixSettingsDTO.DBSSLCert,
+		DBSSLKey:         zabbixSettingsDTO.DBSSLKey,
+		DBDatasourceUID:  zabbixSettingsDTO.DBDatasourceUID,
+		GrafanaURL:       zabbixSettingsDTO.GrafanaURL,
+
+		DBMaxOpenConns:    zabbixSettingsDTO.DBMaxOpenConns,
+		DBMaxIdleConns:    zabbixSettingsDTO.DBMaxIdleConns,
+		DBConnMaxLifetime: time.Duration(zabbixSettingsDTO.DBConnMaxLifetime) * time.Second,
+
+		LogLevel:           zabbixSettingsDTO.LogLevel,
+		SlowQueryThreshold: slowQueryThreshold,
+		ZabbixVersion:      zabbixSettingsDTO.ZabbixVersion,
+		ZabbixWebURL:       strings.TrimRight(zabbixSettingsDTO.ZabbixWebURL, "/"),
+		Timezone:           timezone,
+		Severities:         severities,
 	}
 
 	return zabbixSettings, nil
 }
+
+// parseSettingsInterval parses interval of the settings like 7d or 1h, default value is used if it isn't set
+func parseSettingsInterval(name string, value string, defaultValue string) (time.Duration, error) {
+	if value == "" {
+		value = defaultValue
+	}
+	interval, err := gtime.ParseInterval(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("invalid %s: %s, expected positive interval", name, value)
+	}
+	return interval, nil
+}
@@ -3,6 +3,7 @@ package datasource
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"sync/atomic"
 	"time"
 
 	"github.com/alexanderzobnin/grafana-zabbix/pkg/cache"
@@ -10,20 +11,51 @@ import (
 
 // DatasourceCache is a cache for datasource instance.
 type DatasourceCache struct {
+	// Number of the API requests found and not found in cache, updated atomically. Kept first to be 64-bit
+	// aligned on 32-bit platforms.
+	hits   int64
+	misses int64
+
 	cache *cache.Cache
+	ttl   time.Duration
+}
+
+// CacheStats is a summary of the cache usage
+type CacheStats struct {
+	TTLSeconds float64 `json:"ttlSeconds"`
+	Items      int     `json:"items"`
+	Hits       int64   `json:"hits"`
+	Misses     int64   `json:"misses"`
 }
 
 // NewDatasourceCache creates a DatasourceCache with expiration(ttl) time and cleanupInterval.
 func NewDatasourceCache(ttl time.Duration, cleanupInterval time.Duration) *DatasourceCache {
 	return &DatasourceCache{
-		cache.NewCache(ttl, cleanupInterval),
+		cache: cache.NewCache(ttl, cleanupInterval),
+		ttl:   ttl,
 	}
 }
 
 // GetAPIRequest gets request response from cache
 func (c *DatasourceCache) GetAPIRequest(request *ZabbixAPIRequest) (interface{}, bool) {
 	requestHash := HashString(request.String())
-	return c.cache.Get(requestHash)
+	response, ok := c.cache.Get(requestHash)
+	if ok {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+	}
+	return response, ok
+}
+
+// Stats returns number of the cached items and API requests hits and misses
+func (c *DatasourceCache) Stats() CacheStats {
+	return CacheStats{
+		TTLSeconds: c.ttl.Seconds(),
+		Items:      c.cache.ItemCount(),
+		Hits:       atomic.LoadInt64(&c.hits),
+		Misses:     atomic.LoadInt64(&c.misses),
+	}
 }
 
 // SetAPIRequest writes request response to cache
@@ -32,6 +64,43 @@ func (c *DatasourceCache) SetAPIRequest(request *ZabbixAPIRequest, response inte
 	c.cache.Set(requestHash, response)
 }
 
+// GetVariable gets values of the template variable query
+func (c *DatasourceCache) GetVariable(key string) ([]MetricFindValue, bool) {
+	cached, ok := c.cache.Get(HashString("variable" + key))
+	if !ok {
+		return nil, false
+	}
+	values, ok := cached.([]MetricFindValue)
+	return values, ok
+}
+
+// SetVariable writes values of the template variable query to cache
+func (c *DatasourceCache) SetVariable(key string, values []MetricFindValue) {
+	c.cache.Set(HashString("variable"+key), values)
+}
+
+// StreamQuery is a query of the stream and login of the Grafana user who ran it
+type StreamQuery struct {
+	Query QueryModel
+	User  string
+}
+
+// GetStreamQuery gets query of the stream by the stream channel path
+func (c *DatasourceCache) GetStreamQuery(path string) (StreamQuery, bool) {
+	cached, ok := c.cache.Get(HashString("stream" + path))
+	if !ok {
+		return StreamQuery{}, false
+	}
+	query, ok := cached.(StreamQuery)
+	return query, ok
+}
+
+// SetStreamQuery writes query of the stream to cache. Streams run as long as the panels are open, so the query
+// doesn't expire, otherwise resubscribing to the stream after the cache TTL fails.
+func (c *DatasourceCache) SetStreamQuery(path string, query StreamQuery) {
+	c.cache.SetWithExpiration(HashString("stream"+path), query, cache.NoExpiration)
+}
+
 // HashString converts the given text string to hash string
 func HashString(text string) string {
 	hash := sha1.New()
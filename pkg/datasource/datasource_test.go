@@ -2,6 +2,7 @@ package datasource
 
 import (
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"gotest.tools/assert"
@@ -65,3 +66,50 @@ func TestZabbixBackend_getCachedDatasource(t *testing.T) {
 		})
 	}
 }
+
+func TestReadZabbixSettings(t *testing.T) {
+	settings, err := readZabbixSettings(&backend.DataSourceInstanceSettings{JSONData: []byte(`{"username":"admin","password":"legacy"}`)})
+	assert.NilError(t, err)
+	assert.Equal(t, "admin", settings.Username)
+	assert.Equal(t, "legacy", settings.Password)
+	assert.Equal(t, 30*time.Second, settings.Timeout)
+	assert.Equal(t, time.Hour, settings.CacheTTL)
+	assert.Equal(t, false, settings.HistoryCache)
+	assert.Equal(t, 10*time.Minute, settings.HistoryCacheOverlap)
+	assert.Equal(t, defaultHistoryCacheMaxPoints, settings.HistoryCacheMaxPoints)
+
+	settings, err = readZabbixSettings(&backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"password":"legacy","timeout":60,"cacheTTL":"10m"}`),
+		DecryptedSecureJSONData: map[string]string{"password": "secure"},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, "secure", settings.Password)
+	assert.Equal(t, time.Minute, settings.Timeout)
+	assert.Equal(t, 10*time.Minute, settings.CacheTTL)
+
+	tests := []struct {
+		jsonData string
+		wantErr  string
+	}{
+		{jsonData: `{"username":"admin"}}`, wantErr: "invalid settings: invalid character '}' after top-level value"},
+		{jsonData: `{"trends":"yes"}`, wantErr: "invalid settings: json: cannot unmarshal string"},
+		{jsonData: `{"timeout":"30s"}`, wantErr: "invalid timeout: 30s, expected positive number of seconds"},
+		{jsonData: `{"timeout":0}`, wantErr: "invalid timeout: 0, expected positive number of seconds"},
+		{jsonData: `{"timeout":true}`, wantErr: "invalid settings: expected number, got true"},
+		{jsonData: `{"trendsFrom":"7days"}`, wantErr: `invalid trendsFrom: time: unknown unit "days" in duration "7days"`},
+		{jsonData: `{"cacheTTL":"-1h"}`, wantErr: "invalid cacheTTL: -1h, expected positive interval"},
+		{jsonData: `{"zabbixVersion":"6"}`, wantErr: "invalid zabbixVersion: invalid Zabbix version: 6"},
+		{jsonData: `{"timezone":"Europe/Nowhere"}`, wantErr: "invalid timezone: unknown time zone Europe/Nowhere"},
+		{jsonData: `{"severities":[{"severity":6,"name":"Fatal"}]}`, wantErr: "invalid severities: invalid severity: 6, expected 0-5"},
+		{jsonData: `{"historyCacheOverlap":"0"}`, wantErr: "invalid historyCacheOverlap: 0, expected positive interval"},
+		{jsonData: `{"historyCacheMaxPoints":-1}`, wantErr: "invalid historyCacheMaxPoints: -1, expected non-negative number"},
+		{jsonData: `{"dbMaxOpenConns":-1}`, wantErr: "invalid dbMaxOpenConns: -1, expected non-negative number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.jsonData, func(t *testing.T) {
+			_, err := readZabbixSettings(&backend.DataSourceInstanceSettings{JSONData: []byte(tt.jsonData)})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
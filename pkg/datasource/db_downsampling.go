@@ -0,0 +1,106 @@
+package datasource
+
+import (
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/net/context"
+)
+
+// Interval of the trends values
+const trendsInterval = time.Hour
+
+// aggregatingConnector is implemented by connectors able to aggregate values into time buckets on the
+// database side
+type aggregatingConnector interface {
+	HistoryAggregated(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time, interval time.Duration, aggFunction string) ([]dbconnector.Point, error)
+	TrendsAggregated(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time, interval time.Duration, aggFunction string, trendValue string) ([]dbconnector.Point, error)
+}
+
+// dbDownsampling is the consolidation of the query values requested from the database
+type dbDownsampling struct {
+	interval      time.Duration
+	maxDataPoints int64
+	aggFunction   string
+}
+
+// newDBDownsampling returns downsampling of the query values by the database, or nil if values should be
+// consolidated after fetching. Query result shouldn't depend on the raw values, so queries with functions
+// transforming values, tables, heatmaps and no data series are always consolidated after the functions are
+// applied.
+func newDBDownsampling(query *QueryModel, consolidateBy string) *dbDownsampling {
+	if query.MaxDataPoints <= 0 || query.Interval <= 0 || query.ResultFormat != ResultFormatTimeSeries || query.Options.NoDataPeriod != "" {
+		return nil
+	}
+	if !dbconnector.IsAggregationSupported(consolidateBy) {
+		return nil
+	}
+	for _, fn := range query.Functions {
+		if !isDownsamplingSafeFunction(fn.Def.Name) {
+			return nil
+		}
+	}
+	return &dbDownsampling{
+		interval:      query.Interval,
+		maxDataPoints: query.MaxDataPoints,
+		aggFunction:   consolidateBy,
+	}
+}
+
+// isDownsamplingSafeFunction returns true if function result is the same whether series is downsampled before
+// or after it, like alias functions. Filter functions (top, bottom) select series by the values aggregated with
+// any function, like max, so their result depends on the raw values.
+func isDownsamplingSafeFunction(name string) bool {
+	if _, ok := aliasFuncMap[name]; ok {
+		return true
+	}
+	return skippedFuncMap[name] || timeFuncMap[name]
+}
+
+// getDBDownsampling returns downsampling of the query if connector aggregates values and the time range has
+// more raw values than the query max data points. Number of values is estimated from the item update intervals,
+// items with unknown interval (user macros, trappers) are downsampled after fetching raw values.
+func (ds *ZabbixDatasourceInstance) getDBDownsampling(downsampling *dbDownsampling, timeRange backend.TimeRange, items Items, useTrend bool) *dbDownsampling {
+	if downsampling == nil || len(items) == 0 {
+		return nil
+	}
+	if _, ok := ds.dbConnector.(aggregatingConnector); !ok {
+		return nil
+	}
+	if useTrend && !ds.dbConnector.HasTrends() {
+		return nil
+	}
+
+	rawInterval := trendsInterval
+	if !useTrend {
+		var ok bool
+		if rawInterval, ok = minItemsUpdateInterval(items); !ok {
+			return nil
+		}
+	}
+	if int64(timeRange.To.Sub(timeRange.From)/rawInterval) <= downsampling.maxDataPoints {
+		return nil
+	}
+	return downsampling
+}
+
+// minItemsUpdateInterval returns the smallest update interval of the items including flexible intervals. Zero
+// intervals (no polling within the period) are ignored.
+func minItemsUpdateInterval(items Items) (time.Duration, bool) {
+	var minInterval time.Duration
+	for _, item := range items {
+		updateInterval, err := parseItemUpdateInterval(item.Delay)
+		if err != nil {
+			return 0, false
+		}
+		itemInterval := updateInterval.MinInterval()
+		if itemInterval <= 0 {
+			return 0, false
+		}
+		if minInterval == 0 || itemInterval < minInterval {
+			minInterval = itemInterval
+		}
+	}
+	return minInterval, true
+}
@@ -0,0 +1,63 @@
+package datasource
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// setDBQueriesMeta attaches queries executed by the direct DB connection to the frames meta. Statements with
+// their params are set as the executed query string, so they're shown in the query inspector like queries of
+// the SQL data sources. Total time and number of rows are added to the query stats.
+func setDBQueriesMeta(frames []*data.Frame, queries []dbconnector.ExecutedQuery) {
+	if len(queries) == 0 {
+		return
+	}
+
+	statements := make([]string, 0, len(queries))
+	durationMs := 0.0
+	rows := 0
+	for _, query := range queries {
+		statements = append(statements, formatExecutedQuery(query))
+		durationMs += query.DurationMs
+		rows += query.Rows
+	}
+	executedQueryString := strings.Join(statements, "\n\n")
+
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.ExecutedQueryString = executedQueryString
+		frame.Meta.Stats = append(frame.Meta.Stats,
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "DB queries time", Unit: "ms"}, Value: durationMs},
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "DB rows"}, Value: float64(rows)},
+		)
+
+		custom, ok := frame.Meta.Custom.(map[string]interface{})
+		if !ok {
+			custom = map[string]interface{}{}
+		}
+		custom["dbQueries"] = queries
+		frame.Meta.Custom = custom
+	}
+}
+
+// formatExecutedQuery returns statement followed by the comments with its params, time and number of rows
+func formatExecutedQuery(query dbconnector.ExecutedQuery) string {
+	lines := []string{query.Query}
+	if len(query.Args) > 0 {
+		args := make([]string, 0, len(query.Args))
+		for _, arg := range query.Args {
+			args = append(args, fmt.Sprintf("%v", arg))
+		}
+		lines = append(lines, "-- params: "+strings.Join(args, ", "))
+	}
+	summary := fmt.Sprintf("-- %.1f ms, %d rows", query.DurationMs, query.Rows)
+	if query.Error != "" {
+		summary += ", error: " + query.Error
+	}
+	return strings.Join(append(lines, summary), "\n")
+}
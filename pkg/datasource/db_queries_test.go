@@ -0,0 +1,30 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetDBQueriesMeta(t *testing.T) {
+	frame := data.NewFrame("test")
+	queries := []dbconnector.ExecutedQuery{
+		{Query: "SELECT itemid, clock, ns, value FROM history WHERE itemid IN (?)", Args: []interface{}{int64(1)}, DurationMs: 12.34, Rows: 10},
+		{Query: "SELECT itemid, clock, ns, value FROM history_uint WHERE itemid IN (?)", Args: []interface{}{int64(2)}, DurationMs: 1, Error: "timeout"},
+	}
+
+	setDBQueriesMeta([]*data.Frame{frame}, queries)
+	assert.Equal(t, "SELECT itemid, clock, ns, value FROM history WHERE itemid IN (?)\n-- params: 1\n-- 12.3 ms, 10 rows\n\n"+
+		"SELECT itemid, clock, ns, value FROM history_uint WHERE itemid IN (?)\n-- params: 2\n-- 1.0 ms, 0 rows, error: timeout", frame.Meta.ExecutedQueryString)
+	assert.Equal(t, []data.QueryStat{
+		{FieldConfig: data.FieldConfig{DisplayName: "DB queries time", Unit: "ms"}, Value: 13.34},
+		{FieldConfig: data.FieldConfig{DisplayName: "DB rows"}, Value: 10},
+	}, frame.Meta.Stats)
+	assert.Equal(t, map[string]interface{}{"dbQueries": queries}, frame.Meta.Custom)
+
+	emptyFrame := data.NewFrame("empty")
+	setDBQueriesMeta([]*data.Frame{emptyFrame}, nil)
+	assert.Nil(t, emptyFrame.Meta)
+}
@@ -0,0 +1,192 @@
+package datasource
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/esconnector"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"golang.org/x/net/context"
+)
+
+// historyConnector reads history and trends of the items directly from the storage (SQL database or
+// Elasticsearch), bypassing the API
+type historyConnector interface {
+	Type() string
+	History(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time) ([]dbconnector.Point, error)
+	Trends(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time, trendValue string) ([]dbconnector.Point, error)
+	// TextHistory returns history of the character, text and log items
+	TextHistory(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time) ([]dbconnector.TextPoint, error)
+	// HasTrends returns false if storage keeps only history, trends are requested from the API then
+	HasTrends() bool
+	TestConnection(ctx context.Context) error
+	Close() error
+}
+
+// dbHealthReporter is implemented by connectors reporting detailed health of the connection
+type dbHealthReporter interface {
+	Health(ctx context.Context) *dbconnector.Health
+}
+
+// DBHealth returns health of the direct DB connection or nil if it isn't configured. Connectors without
+// detailed health report connectivity only.
+func (ds *ZabbixDatasourceInstance) DBHealth(ctx context.Context) *dbconnector.Health {
+	if ds.dbConnector == nil {
+		return nil
+	}
+	if reporter, ok := ds.dbConnector.(dbHealthReporter); ok {
+		return reporter.Health(ctx)
+	}
+
+	health := &dbconnector.Health{Type: ds.dbConnector.Type()}
+	if err := ds.dbConnector.TestConnection(ctx); err != nil {
+		health.Error = err.Error()
+	} else {
+		health.Connected = true
+	}
+	return health
+}
+
+// dbHealthMessage returns summary of the DB health like "DB mysql: schema version 5040000, replication lag 3s"
+func dbHealthMessage(health *dbconnector.Health) string {
+	details := []string{}
+	if health.SchemaVersion != "" {
+		details = append(details, "schema version "+health.SchemaVersion)
+	}
+	if health.ReplicationLagSeconds != nil {
+		details = append(details, fmt.Sprintf("replication lag %s", time.Duration(*health.ReplicationLagSeconds*float64(time.Second)).Round(time.Second)))
+	}
+	details = append(details, health.Warnings...)
+
+	message := "DB " + health.Type
+	if len(details) > 0 {
+		message += ": " + strings.Join(details, ", ")
+	}
+	return message
+}
+
+// newHistoryConnector returns connector of the configured type or nil if direct connection isn't configured.
+// Host setting is used as URL of the Elasticsearch. If Grafana SQL data source is set, connection type is the
+// type of that data source.
+func newHistoryConnector(settings *ZabbixDatasourceSettings, secureSettings map[string]string, logger log.Logger) (historyConnector, error) {
+	switch settings.DBConnectionType {
+	case "":
+		if settings.DBDatasourceUID != "" {
+			return nil, fmt.Errorf("DB connection type is required to use the %s data source", settings.DBDatasourceUID)
+		}
+		return nil, nil
+	case esconnector.TypeElasticsearch:
+		connector, err := esconnector.New(esconnector.Settings{
+			URL:      settings.DBHost,
+			User:     settings.DBUser,
+			Password: secureSettings["dbPassword"],
+			Timeout:  settings.Timeout,
+			Schema:   settings.DBSchema,
+			Logger:   logger,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return connector, nil
+	}
+
+	connector, err := dbconnector.New(dbconnector.Settings{
+		Type:          settings.DBConnectionType,
+		Host:          settings.DBHost,
+		Database:      settings.DBName,
+		User:          settings.DBUser,
+		Password:      secureSettings["dbPassword"],
+		Timeout:       settings.Timeout,
+		Schema:        settings.DBSchema,
+		Partitioning:  settings.DBPartitioning,
+		SSLMode:       settings.DBSSLMode,
+		SSLRootCert:   settings.DBSSLRootCert,
+		SSLCert:       settings.DBSSLCert,
+		SSLKey:        settings.DBSSLKey,
+		DatasourceUID: settings.DBDatasourceUID,
+		GrafanaURL:    settings.GrafanaURL,
+		GrafanaToken:  secureSettings["grafanaToken"],
+
+		MaxOpenConns:    settings.DBMaxOpenConns,
+		MaxIdleConns:    settings.DBMaxIdleConns,
+		ConnMaxLifetime: settings.DBConnMaxLifetime,
+
+		Logger: logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connector, nil
+}
+
+// getHistoryOrTrendFromDB reads history or trends of the items from the Zabbix database, items are grouped by
+// value type since values of different types are stored in different tables. Values are aggregated into time
+// buckets by the database if downsampling is set.
+func (ds *ZabbixDatasourceInstance) getHistoryOrTrendFromDB(ctx context.Context, timeRange backend.TimeRange, items Items, useTrend bool, trendValueType string, downsampling *dbDownsampling) (History, error) {
+	groupedItems := map[int][]string{}
+	for _, item := range items {
+		groupedItems[item.ValueType] = append(groupedItems[item.ValueType], item.ID)
+	}
+
+	history := History{}
+	for valueType, itemids := range groupedItems {
+		var points []dbconnector.Point
+		var err error
+		if downsampling != nil {
+			points, err = ds.getAggregatedFromDB(ctx, timeRange, itemids, valueType, useTrend, trendValueType, downsampling)
+		} else if useTrend {
+			points, err = ds.dbConnector.Trends(ctx, itemids, valueType, timeRange.From, timeRange.To, trendValueType)
+		} else {
+			points, err = ds.dbConnector.History(ctx, itemids, valueType, timeRange.From, timeRange.To)
+		}
+		if err != nil {
+			return nil, &DownstreamError{Err: err}
+		}
+
+		for _, point := range points {
+			history = append(history, HistoryPoint{ItemID: point.ItemID, Clock: point.Clock, NS: point.NS, Value: point.Value})
+		}
+	}
+	return history, nil
+}
+
+// getTextHistoryFromDB reads history of the character, text and log items from the Zabbix database
+func (ds *ZabbixDatasourceInstance) getTextHistoryFromDB(ctx context.Context, timeRange backend.TimeRange, items Items) (TextHistory, error) {
+	groupedItems := map[int][]string{}
+	for _, item := range items {
+		groupedItems[item.ValueType] = append(groupedItems[item.ValueType], item.ID)
+	}
+
+	history := TextHistory{}
+	for valueType, itemids := range groupedItems {
+		points, err := ds.dbConnector.TextHistory(ctx, itemids, valueType, timeRange.From, timeRange.To)
+		if err != nil {
+			return nil, &DownstreamError{Err: err}
+		}
+
+		for _, point := range points {
+			history = append(history, TextHistoryPoint{
+				ItemID:     point.ItemID,
+				Clock:      point.Clock,
+				NS:         point.NS,
+				Value:      point.Value,
+				Source:     point.Source,
+				Severity:   point.Severity,
+				LogEventID: point.LogEventID,
+			})
+		}
+	}
+
+	return history, nil
+}
+
+func (ds *ZabbixDatasourceInstance) getAggregatedFromDB(ctx context.Context, timeRange backend.TimeRange, itemids []string, valueType int, useTrend bool, trendValueType string, downsampling *dbDownsampling) ([]dbconnector.Point, error) {
+	connector := ds.dbConnector.(aggregatingConnector)
+	if useTrend {
+		return connector.TrendsAggregated(ctx, itemids, valueType, timeRange.From, timeRange.To, downsampling.interval, downsampling.aggFunction, trendValueType)
+	}
+	return connector.HistoryAggregated(ctx, itemids, valueType, timeRange.From, timeRange.To, downsampling.interval, downsampling.aggFunction)
+}
@@ -0,0 +1,307 @@
+package datasource
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/esconnector"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHistoryFromDB(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	connector, mock, err := dbconnector.MockDBConnector(dbconnector.TypeMySQL, [][]driver.Value{
+		{int64(1), int64(1600000000), int64(0), 1.5},
+	})
+	assert.NoError(t, err)
+	dsInstance.dbConnector = connector
+
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+	history, err := dsInstance.getHistotyOrTrend(context.Background(), timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, false, "avg")
+	assert.NoError(t, err)
+	assert.Equal(t, History{{ItemID: "1", Clock: 1600000000, Value: 1.5}}, history)
+	assert.Len(t, mock.ExecutedQueries(), 1)
+
+}
+
+func TestGetHistoryFromDBFallback(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"itemid":"1","clock":"1600000000","value":"2.5"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	connector, mock, _ := dbconnector.MockDBConnector(dbconnector.TypeMySQL, nil)
+	mock.Err = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	dsInstance.dbConnector = connector
+
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+	ctx, sources := withHistorySourcesRecorder(context.Background())
+	ctx, notices := withNoticesRecorder(ctx)
+	history, err := dsInstance.getHistotyOrTrend(ctx, timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, false, "avg")
+	assert.NoError(t, err)
+	assert.Equal(t, History{{ItemID: "1", Clock: 1600000000, Value: 2.5}}, history)
+	assert.Equal(t, []string{HistorySourceAPI}, sources.Sources())
+	assert.Len(t, notices.Notices(), 1)
+	assert.Len(t, mock.ExecutedQueries(), 1)
+
+	// DB isn't queried again within the fallback period
+	_, err = dsInstance.getHistotyOrTrend(ctx, timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, false, "avg")
+	assert.NoError(t, err)
+	assert.Len(t, mock.ExecutedQueries(), 1)
+
+	// DB is used after the fallback period
+	dsInstance.dbState.unavailableUntil = time.Now().Add(-time.Second)
+	mock.Err = nil
+	ctx, sources = withHistorySourcesRecorder(context.Background())
+	_, err = dsInstance.getHistotyOrTrend(ctx, timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, false, "avg")
+	assert.NoError(t, err)
+	assert.Len(t, mock.ExecutedQueries(), 2)
+	assert.Equal(t, []string{HistorySourceDB}, sources.Sources())
+}
+
+func TestGetHistoryFromDBQueryError(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"itemid":"1","clock":"1600000000","value":"2.5"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	connector, mock, _ := dbconnector.MockDBConnector(dbconnector.TypeMySQL, nil)
+	mock.QueryErr = func(query dbconnector.MockQuery) error {
+		if strings.Contains(query.Query, "WHERE 1=0") {
+			return nil
+		}
+		return errors.New("Table 'zabbix.history' doesn't exist")
+	}
+	dsInstance.dbConnector = connector
+
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+	ctx, notices := withNoticesRecorder(context.Background())
+	history, err := dsInstance.getHistotyOrTrend(ctx, timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, false, "avg")
+	assert.NoError(t, err)
+	assert.Equal(t, History{{ItemID: "1", Clock: 1600000000, Value: 2.5}}, history)
+	assert.Len(t, notices.Notices(), 1)
+
+	// Query error doesn't mark DB unavailable if connection test passes
+	ok, _ := dsInstance.dbState.available()
+	assert.True(t, ok)
+
+	// DB is marked unavailable if connection test fails
+	mock.QueryErr = nil
+	mock.Err = errors.New("server has gone away")
+	_, err = dsInstance.getHistotyOrTrend(context.Background(), timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, false, "avg")
+	assert.NoError(t, err)
+	ok, _ = dsInstance.dbState.available()
+	assert.False(t, ok)
+}
+
+func TestGetHistoryFromDBCancelled(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	connector, mock, _ := dbconnector.MockDBConnector(dbconnector.TypeMySQL, nil)
+	mock.Err = context.Canceled
+	dsInstance.dbConnector = connector
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+	_, err := dsInstance.getHistotyOrTrend(ctx, timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, false, "avg")
+	assert.ErrorIs(t, err, context.Canceled)
+	ok, _ := dsInstance.dbState.available()
+	assert.True(t, ok)
+}
+
+func TestGetTextHistoryFromDB(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	connector, mock, err := dbconnector.MockDBConnector(dbconnector.TypeMySQL, [][]driver.Value{
+		{int64(1), int64(1600000000), int64(0), "Service stopped", "Service Control Manager", int64(4), int64(7036)},
+	})
+	assert.NoError(t, err)
+	dsInstance.dbConnector = connector
+
+	query := &QueryModel{TimeRange: backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}}
+	ctx, sources := withHistorySourcesRecorder(context.Background())
+	history, err := dsInstance.getTextHistory(ctx, query, Items{{ID: "1", ValueType: ValueTypeLog}})
+	assert.NoError(t, err)
+	assert.Equal(t, TextHistory{{
+		ItemID:     "1",
+		Clock:      1600000000,
+		Value:      "Service stopped",
+		Source:     "Service Control Manager",
+		Severity:   4,
+		LogEventID: "7036",
+	}}, history)
+	assert.Equal(t, []string{HistorySourceDB}, sources.Sources())
+	assert.Len(t, mock.ExecutedQueries(), 1)
+}
+
+func TestGetHistoryFromElasticsearch(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"itemid":"1","clock":"1600000000","value_avg":"2.5"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	esRequests := 0
+	dsInstance.dbConnector = esconnector.MockESConnector(false, func(req *http.Request) *http.Response {
+		esRequests++
+		return esconnector.MockResponse(`{"hits":{"hits":[{"_source":{"itemid":1,"clock":1600000000,"ns":0,"value":1.5}}]}}`, 200)
+	})
+
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+	history, err := dsInstance.getHistotyOrTrend(context.Background(), timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, false, "avg")
+	assert.NoError(t, err)
+	assert.Equal(t, History{{ItemID: "1", Clock: 1600000000, Value: 1.5}}, history)
+	assert.Equal(t, 1, esRequests)
+
+	// Trends aren't stored in Elasticsearch and requested from the API
+	trend, err := dsInstance.getHistotyOrTrend(context.Background(), timeRange, Items{{ID: "1", ValueType: ValueTypeFloat}}, true, "avg")
+	assert.NoError(t, err)
+	assert.Equal(t, History{{ItemID: "1", Clock: 1600000000, Value: 2.5}}, trend)
+	assert.Equal(t, 1, esRequests)
+}
+
+func TestNewHistoryConnector(t *testing.T) {
+	connector, err := newHistoryConnector(&ZabbixDatasourceSettings{}, nil, log.New())
+	assert.NoError(t, err)
+	assert.Nil(t, connector)
+
+	connector, err = newHistoryConnector(&ZabbixDatasourceSettings{DBConnectionType: esconnector.TypeElasticsearch, DBHost: "http://localhost:9200"}, nil, log.New())
+	assert.NoError(t, err)
+	assert.Equal(t, esconnector.TypeElasticsearch, connector.Type())
+
+	connector, err = newHistoryConnector(&ZabbixDatasourceSettings{DBConnectionType: "oracle"}, nil, log.New())
+	assert.Error(t, err)
+	assert.Nil(t, connector)
+
+	// Grafana data source doesn't need registered DB driver
+	connector, err = newHistoryConnector(&ZabbixDatasourceSettings{DBConnectionType: dbconnector.TypePostgres, DBDatasourceUID: "zabbix-db"}, nil, log.New())
+	assert.NoError(t, err)
+	assert.Equal(t, dbconnector.TypePostgres, connector.Type())
+
+	_, err = newHistoryConnector(&ZabbixDatasourceSettings{DBDatasourceUID: "zabbix-db"}, nil, log.New())
+	assert.Error(t, err)
+}
+
+func TestDBHealth(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	assert.Nil(t, dsInstance.DBHealth(context.Background()))
+
+	dsInstance.dbConnector = esconnector.MockESConnector(false, func(req *http.Request) *http.Response {
+		return esconnector.MockResponse(`{"error":"unauthorized"}`, 401)
+	})
+	health := dsInstance.DBHealth(context.Background())
+	assert.False(t, health.Connected)
+	assert.Contains(t, health.Error, "unauthorized")
+
+	connector, _, _ := dbconnector.MockDBConnector(dbconnector.TypeMySQL, nil)
+	dsInstance.dbConnector = connector
+	health = dsInstance.DBHealth(context.Background())
+	assert.True(t, health.Connected)
+}
+
+func TestDBHealthMessage(t *testing.T) {
+	lag := 2.6
+	health := &dbconnector.Health{Type: dbconnector.TypeMySQL, Connected: true, SchemaVersion: "5040000", ReplicationLagSeconds: &lag}
+	assert.Equal(t, "DB mysql: schema version 5040000, replication lag 3s", dbHealthMessage(health))
+
+	health = &dbconnector.Health{Type: esconnector.TypeElasticsearch, Connected: true}
+	assert.Equal(t, "DB elasticsearch", dbHealthMessage(health))
+}
+
+func TestGetNumericSeriesDownsampledInDB(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	connector, mock, err := dbconnector.MockDBConnector(dbconnector.TypeMySQL, [][]driver.Value{
+		{int64(1), int64(1600000000), 1.5},
+	})
+	assert.NoError(t, err)
+	dsInstance.dbConnector = connector
+	dsInstance.Settings = &ZabbixDatasourceSettings{}
+
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+	query := &QueryModel{TimeRange: timeRange, MaxDataPoints: 10, Interval: 6 * time.Minute, ResultFormat: ResultFormatTimeSeries}
+	ctx := context.Background()
+	downsampling := newDBDownsampling(query, "max")
+
+	series, useTrend, err := dsInstance.getNumericSeries(ctx, timeRange, Items{{ID: "1", ValueType: ValueTypeFloat, Delay: "1m"}}, "avg", downsampling)
+	assert.NoError(t, err)
+	assert.False(t, useTrend)
+	assert.Len(t, series, 1)
+	assert.Equal(t, timeseries.FixedInterval(6*time.Minute), series[0].Meta.Interval)
+	assert.Len(t, mock.ExecutedQueries(), 1)
+	assert.Contains(t, mock.ExecutedQueries()[0].Query, "clock DIV 360 * 360 AS clock_bucket, MAX(value)")
+
+	// 6 raw values of the item fit into max data points
+	connector, mock, err = dbconnector.MockDBConnector(dbconnector.TypeMySQL, [][]driver.Value{
+		{int64(1), int64(1600000000), int64(0), 1.5},
+	})
+	assert.NoError(t, err)
+	dsInstance.dbConnector = connector
+	_, _, err = dsInstance.getNumericSeries(ctx, timeRange, Items{{ID: "1", ValueType: ValueTypeFloat, Delay: "10m"}}, "avg", downsampling)
+	assert.NoError(t, err)
+	assert.Len(t, mock.ExecutedQueries(), 1)
+	assert.NotContains(t, mock.ExecutedQueries()[0].Query, "GROUP BY")
+}
+
+func TestNewDBDownsampling(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         *QueryModel
+		consolidateBy string
+		expected      bool
+	}{
+		{
+			name:          "time series",
+			query:         &QueryModel{MaxDataPoints: 100, Interval: time.Minute, ResultFormat: ResultFormatTimeSeries},
+			consolidateBy: "avg",
+			expected:      true,
+		},
+		{
+			name: "alias functions",
+			query: &QueryModel{MaxDataPoints: 100, Interval: time.Minute, ResultFormat: ResultFormatTimeSeries, Functions: []QueryFunction{
+				{Def: QueryFunctionDef{Name: "setAlias"}},
+				{Def: QueryFunctionDef{Name: "consolidateBy"}},
+			}},
+			consolidateBy: "max",
+			expected:      true,
+		},
+		{
+			name: "filter function",
+			query: &QueryModel{MaxDataPoints: 100, Interval: time.Minute, ResultFormat: ResultFormatTimeSeries, Functions: []QueryFunction{
+				{Def: QueryFunctionDef{Name: "top"}},
+			}},
+			consolidateBy: "avg",
+			expected:      false,
+		},
+		{
+			name: "transform function",
+			query: &QueryModel{MaxDataPoints: 100, Interval: time.Minute, ResultFormat: ResultFormatTimeSeries, Functions: []QueryFunction{
+				{Def: QueryFunctionDef{Name: "removeAboveValue"}},
+			}},
+			consolidateBy: "avg",
+			expected:      false,
+		},
+		{
+			name:          "unsupported consolidation",
+			query:         &QueryModel{MaxDataPoints: 100, Interval: time.Minute, ResultFormat: ResultFormatTimeSeries},
+			consolidateBy: "median",
+			expected:      false,
+		},
+		{
+			name:          "table",
+			query:         &QueryModel{MaxDataPoints: 100, Interval: time.Minute, ResultFormat: ResultFormatTable},
+			consolidateBy: "avg",
+			expected:      false,
+		},
+		{
+			name:          "no max data points",
+			query:         &QueryModel{ResultFormat: ResultFormatTimeSeries},
+			consolidateBy: "avg",
+			expected:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, newDBDownsampling(tt.query, tt.consolidateBy) != nil)
+		})
+	}
+}
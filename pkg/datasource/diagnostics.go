@@ -0,0 +1,213 @@
+package datasource
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// apiState tracks logins and failures of the Zabbix API requests
+type apiState struct {
+	mu              sync.Mutex
+	authenticatedAt time.Time
+	loginFailures   int
+	lastError       *LastError
+}
+
+// LastError is the last failed request of the data source
+type LastError struct {
+	Method string    `json:"method"`
+	Error  string    `json:"error"`
+	Time   time.Time `json:"time"`
+}
+
+func (s *apiState) setAuthenticated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authenticatedAt = time.Now()
+	s.loginFailures = 0
+}
+
+// setLoginFailed returns number of the failed logins since the last successful one
+func (s *apiState) setLoginFailed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loginFailures++
+	return s.loginFailures
+}
+
+// sessionAge returns time since the last successful login, or false if there was no login
+func (s *apiState) sessionAge() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.authenticatedAt.IsZero() {
+		return 0, false
+	}
+	return time.Since(s.authenticatedAt), true
+}
+
+func (s *apiState) setError(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = &LastError{Method: method, Error: err.Error(), Time: time.Now()}
+}
+
+// Diagnostics is a state of the data source instance returned by the /health/details resource, so it can be
+// attached to support requests
+type Diagnostics struct {
+	Datasource   DiagnosticsDatasource `json:"datasource"`
+	Zabbix       DiagnosticsZabbix     `json:"zabbix"`
+	Capabilities map[string]bool       `json:"capabilities,omitempty"`
+	Auth         DiagnosticsAuth       `json:"auth"`
+	Cache        CacheStats            `json:"cache"`
+	DB           *DiagnosticsDB        `json:"db,omitempty"`
+	LastError    *LastError            `json:"lastError,omitempty"`
+}
+
+type DiagnosticsDatasource struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type DiagnosticsZabbix struct {
+	Version string `json:"version,omitempty"`
+	// Version is pinned in the data source settings instead of the apiinfo.version response
+	Pinned bool   `json:"pinned,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type DiagnosticsAuth struct {
+	Authenticated   bool       `json:"authenticated"`
+	AuthenticatedAt *time.Time `json:"authenticatedAt,omitempty"`
+	TokenAgeSeconds float64    `json:"tokenAgeSeconds,omitempty"`
+	LoginFailures   int        `json:"loginFailures,omitempty"`
+}
+
+// DiagnosticsDB is a state of the direct DB connection. After the failure queries fall back to the API until
+// the connection is retried, like an open circuit breaker.
+type DiagnosticsDB struct {
+	Type          string     `json:"type"`
+	CircuitOpen   bool       `json:"circuitOpen"`
+	FallbackUntil *time.Time `json:"fallbackUntil,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+}
+
+// zabbixCapabilities are API features depending on the Zabbix version: min version the feature is available
+// in, and max version it's removed in (0 if not removed)
+var zabbixCapabilities = map[string][2]int{
+	"applications": {0, 504},
+	"itemTags":     {504, 0},
+	"serviceSLA":   {0, 600},
+	// user.login accepts username parameter instead of user
+	"loginUsername": {504, 0},
+}
+
+// GetDiagnostics returns state of the data source instance. Zabbix version is requested from the API, other
+// details are collected by the instance.
+func (ds *ZabbixDatasourceInstance) GetDiagnostics(ctx context.Context) *Diagnostics {
+	diagnostics := &Diagnostics{
+		Datasource: DiagnosticsDatasource{
+			ID:   ds.dsInfo.ID,
+			Name: ds.dsInfo.Name,
+			URL:  redactCredentials(ds.dsInfo.URL),
+		},
+		Cache: ds.queryCache.Stats(),
+	}
+
+	version, pinned, err := ds.getZabbixVersion(ctx)
+	if err != nil {
+		diagnostics.Zabbix.Error = err.Error()
+	} else {
+		diagnostics.Zabbix.Version = version
+		diagnostics.Zabbix.Pinned = pinned
+		if version, err := parseZabbixVersion(diagnostics.Zabbix.Version); err == nil {
+			diagnostics.Capabilities = getZabbixCapabilities(version)
+		}
+	}
+
+	ds.apiState.mu.Lock()
+	if ds.zabbixAPI.GetAuth() != "" && !ds.apiState.authenticatedAt.IsZero() {
+		authenticatedAt := ds.apiState.authenticatedAt
+		diagnostics.Auth = DiagnosticsAuth{
+			Authenticated:   true,
+			AuthenticatedAt: &authenticatedAt,
+			TokenAgeSeconds: time.Since(authenticatedAt).Seconds(),
+		}
+	}
+	diagnostics.Auth.LoginFailures = ds.apiState.loginFailures
+	diagnostics.LastError = ds.apiState.lastError
+	ds.apiState.mu.Unlock()
+
+	if ds.dbConnector != nil {
+		diagnostics.DB = &DiagnosticsDB{Type: ds.dbConnector.Type()}
+		ds.dbState.mu.Lock()
+		if time.Now().Before(ds.dbState.unavailableUntil) {
+			fallbackUntil := ds.dbState.unavailableUntil
+			diagnostics.DB.CircuitOpen = true
+			diagnostics.DB.FallbackUntil = &fallbackUntil
+		}
+		if ds.dbState.lastError != nil {
+			diagnostics.DB.LastError = ds.dbState.lastError.Error()
+		}
+		ds.dbState.mu.Unlock()
+	}
+
+	return diagnostics
+}
+
+// getZabbixVersion returns Zabbix version pinned in the settings, or requests it from the API if not pinned
+func (ds *ZabbixDatasourceInstance) getZabbixVersion(ctx context.Context) (string, bool, error) {
+	if ds.Settings != nil && ds.Settings.ZabbixVersion != "" {
+		return ds.Settings.ZabbixVersion, true, nil
+	}
+
+	response, err := ds.ZabbixRequest(ctx, "apiinfo.version", ZabbixAPIParams{})
+	if err != nil {
+		return "", false, err
+	}
+	return response.MustString(), false, nil
+}
+
+// pinnedCapability returns if the feature is supported by the Zabbix version pinned in the settings. Second
+// value is false if the version isn't pinned, then the feature is detected by the API errors.
+func pinnedCapability(settings *ZabbixDatasourceSettings, name string) (bool, bool) {
+	if settings == nil || settings.ZabbixVersion == "" {
+		return false, false
+	}
+	version, err := parseZabbixVersion(settings.ZabbixVersion)
+	if err != nil {
+		return false, false
+	}
+	supported, ok := getZabbixCapabilities(version)[name]
+	return supported, ok
+}
+
+// parseZabbixVersion returns version like "5.4.2" as a number 504
+func parseZabbixVersion(version string) (int, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid Zabbix version: %s", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid Zabbix version: %s", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid Zabbix version: %s", version)
+	}
+	return major*100 + minor, nil
+}
+
+func getZabbixCapabilities(version int) map[string]bool {
+	capabilities := make(map[string]bool, len(zabbixCapabilities))
+	for name, versions := range zabbixCapabilities {
+		capabilities[name] = version >= versions[0] && (versions[1] == 0 || version < versions[1])
+	}
+	return capabilities
+}
@@ -0,0 +1,91 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/esconnector"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDiagnostics(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":"5.4.2"}`, 200)
+	diagnostics := dsInstance.GetDiagnostics(context.Background())
+
+	assert.Equal(t, DiagnosticsDatasource{ID: 1, Name: "TestDatasource", URL: "http://zabbix.org/zabbix"}, diagnostics.Datasource)
+	assert.Equal(t, DiagnosticsZabbix{Version: "5.4.2"}, diagnostics.Zabbix)
+	assert.Equal(t, map[string]bool{"applications": false, "itemTags": true, "serviceSLA": true, "loginUsername": true}, diagnostics.Capabilities)
+	assert.False(t, diagnostics.Auth.Authenticated)
+	assert.Nil(t, diagnostics.DB)
+	assert.Nil(t, diagnostics.LastError)
+
+	err := dsInstance.login(context.Background())
+	assert.NoError(t, err)
+	dsInstance.queryCache.GetAPIRequest(&ZabbixAPIRequest{Method: "host.get"})
+	dsInstance.queryCache.SetAPIRequest(&ZabbixAPIRequest{Method: "host.get"}, nil)
+	dsInstance.queryCache.GetAPIRequest(&ZabbixAPIRequest{Method: "host.get"})
+	dsInstance.dbConnector, _ = esconnector.New(esconnector.Settings{URL: "http://localhost:9200"})
+	dsInstance.dbState.setFailed(errors.New("connection refused"))
+
+	diagnostics = dsInstance.GetDiagnostics(context.Background())
+	assert.True(t, diagnostics.Auth.Authenticated)
+	assert.NotNil(t, diagnostics.Auth.AuthenticatedAt)
+	assert.Equal(t, 1, diagnostics.Cache.Items)
+	assert.Equal(t, int64(1), diagnostics.Cache.Hits)
+	assert.Equal(t, int64(1), diagnostics.Cache.Misses)
+	assert.Equal(t, esconnector.TypeElasticsearch, diagnostics.DB.Type)
+	assert.True(t, diagnostics.DB.CircuitOpen)
+	assert.Equal(t, "connection refused", diagnostics.DB.LastError)
+}
+
+func TestGetDiagnosticsAPIError(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"error":{"code":-32602,"message":"Invalid params.","data":"Not authorized"}}`, 200)
+	diagnostics := dsInstance.GetDiagnostics(context.Background())
+
+	assert.Equal(t, "", diagnostics.Zabbix.Version)
+	assert.NotEmpty(t, diagnostics.Zabbix.Error)
+	assert.Nil(t, diagnostics.Capabilities)
+	if assert.NotNil(t, diagnostics.LastError) {
+		assert.Equal(t, "apiinfo.version", diagnostics.LastError.Method)
+		assert.Equal(t, diagnostics.Zabbix.Error, diagnostics.LastError.Error)
+	}
+}
+
+func TestGetDiagnosticsPinnedVersion(t *testing.T) {
+	// apiinfo.version is blocked by the gateway
+	dsInstance := MockZabbixDataSource(`{"error":{"code":-32601,"message":"Method not found.","data":"Incorrect API \"apiinfo\"."}}`, 200)
+	dsInstance.Settings.ZabbixVersion = "4.0"
+
+	diagnostics := dsInstance.GetDiagnostics(context.Background())
+	assert.Equal(t, DiagnosticsZabbix{Version: "4.0", Pinned: true}, diagnostics.Zabbix)
+	assert.True(t, diagnostics.Capabilities["applications"])
+	assert.Nil(t, diagnostics.LastError)
+
+	dsInstance.Settings.ZabbixVersion = "6.0"
+	_, err := dsInstance.getAllApps(context.Background(), []string{"10"})
+	assert.True(t, errors.Is(err, zabbixapi.ErrMethodNotFound))
+	assert.Nil(t, dsInstance.apiState.lastError)
+}
+
+func TestGetZabbixCapabilities(t *testing.T) {
+	tests := []struct {
+		version string
+		want    map[string]bool
+	}{
+		{version: "4.0.30", want: map[string]bool{"applications": true, "itemTags": false, "serviceSLA": true, "loginUsername": false}},
+		{version: "5.4.0", want: map[string]bool{"applications": false, "itemTags": true, "serviceSLA": true, "loginUsername": true}},
+		{version: "6.0.1", want: map[string]bool{"applications": false, "itemTags": true, "serviceSLA": false, "loginUsername": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			version, err := parseZabbixVersion(tt.version)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, getZabbixCapabilities(version))
+		})
+	}
+
+	_, err := parseZabbixVersion("latest")
+	assert.Error(t, err)
+}
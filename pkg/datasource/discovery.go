@@ -0,0 +1,173 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"golang.org/x/net/context"
+)
+
+// Filter matching all objects, used if filter isn't set in the discovery request
+const matchAllFilter = "/.*/"
+
+// Max number of the objects returned by the discovery resources in one page
+const maxPageLimit = 1000
+
+// DiscoveredObject is a group, host, application or item returned by the discovery resources for the query
+// editor. Name of the item is expanded with the key params. Parent is the name of the parent host group for
+// the nested groups like "Linux servers/DB".
+type DiscoveredObject struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Parent    string `json:"parent,omitempty"`
+	Host      string `json:"host,omitempty"`
+	Key       string `json:"key,omitempty"`
+	ValueType *int   `json:"valueType,omitempty"`
+}
+
+// Page is a part of the groups, hosts, applications or items set by the limit and offset params. Zero limit
+// returns all objects.
+type Page struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// parsePage reads limit and offset params. Limit is capped by maxPageLimit.
+func parsePage(params url.Values, defaultLimit int) (Page, error) {
+	limit, err := intParam(params, "limit", defaultLimit)
+	if err != nil {
+		return Page{}, err
+	}
+	offset, err := intParam(params, "offset", 0)
+	if err != nil {
+		return Page{}, err
+	}
+	if limit < 0 || offset < 0 {
+		return Page{}, fmt.Errorf("limit and offset must not be negative")
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return Page{Limit: limit, Offset: offset}, nil
+}
+
+// bounds returns start and end index of the page in the list of the given length
+func (p Page) bounds(length int) (int, int) {
+	if p.Offset >= length {
+		return length, length
+	}
+	if p.Limit > 0 && p.Offset+p.Limit < length {
+		return p.Offset, p.Offset + p.Limit
+	}
+	return p.Offset, length
+}
+
+func intParam(params url.Values, name string, defaultValue int) (int, error) {
+	value := params.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return result, nil
+}
+
+// discover returns page of the groups, hosts, apps or items matching the filters of the request params (group,
+// host, app, item and itemType) and total number of the matched objects. Objects are paged by the API requests,
+// see getItemsPage.
+func (ds *ZabbixDatasourceInstance) discover(ctx context.Context, resource string, params url.Values, page Page) ([]DiscoveredObject, int, error) {
+
+	switch resource {
+	case "groups":
+		groups, total, err := ds.getGroupsPage(ctx, nil, page, paramFilter(params, "group"))
+		if err != nil {
+			return nil, 0, err
+		}
+		objects := discoveredObjects(groups, "groupid")
+		for i := range objects {
+			objects[i].Parent = hostGroupParent(objects[i].Name)
+		}
+		return objects, total, nil
+	case "hosts":
+		hosts, total, err := ds.getHostsPage(ctx, nil, page, paramFilter(params, "group"), paramFilter(params, "host"))
+		if err != nil {
+			return nil, 0, err
+		}
+		objects := discoveredObjects(hosts, "hostid")
+		for i, host := range hosts {
+			objects[i].Host, _ = host["host"].(string)
+		}
+		return objects, total, nil
+	case "apps":
+		apps, total, err := ds.getAppsPage(ctx, nil, page, paramFilter(params, "group"), paramFilter(params, "host"), paramFilter(params, "app"))
+		// Apps not supported in Zabbix 5.4 and higher
+		if errors.Is(err, zabbixapi.ErrMethodNotFound) {
+			return []DiscoveredObject{}, 0, nil
+		} else if err != nil {
+			return nil, 0, err
+		}
+		return uniqueByName(discoveredObjects(apps, "applicationid")), total, nil
+	case "items":
+		items, total, err := ds.getItemsPage(ctx, nil, page, paramFilter(params, "group"), paramFilter(params, "host"), params.Get("app"), paramFilter(params, "item"), params.Get("itemType"))
+		if err != nil {
+			return nil, 0, err
+		}
+		return discoveredItems(items), total, nil
+	}
+	return nil, 0, fmt.Errorf("unknown discovery resource: %s", resource)
+}
+
+// paramFilter returns filter of the request param, matching all objects if the param isn't set
+func paramFilter(params url.Values, name string) string {
+	if value := params.Get(name); value != "" {
+		return value
+	}
+	return matchAllFilter
+}
+
+// discoveredItems returns items with the expanded names, sorted by name
+func discoveredItems(items Items) []DiscoveredObject {
+	objects := make([]DiscoveredObject, 0, len(items))
+	for _, item := range items {
+		valueType := item.ValueType
+		object := DiscoveredObject{ID: item.ID, Name: item.ExpandItem(), Key: item.Key, ValueType: &valueType}
+		if len(item.Hosts) > 0 {
+			object.Host = item.Hosts[0].Name
+		}
+		objects = append(objects, object)
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].Name < objects[j].Name
+	})
+	return objects
+}
+
+func discoveredObjects(objects []map[string]interface{}, idField string) []DiscoveredObject {
+	result := make([]DiscoveredObject, 0, len(objects))
+	for _, object := range objects {
+		id, _ := object[idField].(string)
+		name, _ := object["name"].(string)
+		result = append(result, DiscoveredObject{ID: id, Name: name})
+	}
+	return result
+}
+
+// uniqueByName returns objects with distinct names, like applications with the same name on different hosts
+func uniqueByName(objects []DiscoveredObject) []DiscoveredObject {
+	seen := map[string]bool{}
+	unique := make([]DiscoveredObject, 0, len(objects))
+	for _, object := range objects {
+		if seen[object.Name] {
+			continue
+		}
+		seen[object.Name] = true
+		unique = append(unique, DiscoveredObject{Name: object.Name})
+	}
+	return unique
+}
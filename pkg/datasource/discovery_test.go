@@ -0,0 +1,126 @@
+package datasource
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"groupid":"1","name":"Linux servers"},{"groupid":"2","name":"Windows servers"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	groups, total, err := dsInstance.discover(context.Background(), "groups", url.Values{}, Page{})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiscoveredObject{{ID: "1", Name: "Linux servers"}, {ID: "2", Name: "Windows servers"}}, groups)
+	assert.Equal(t, 2, total)
+
+	groups, total, err = dsInstance.discover(context.Background(), "groups", url.Values{"group": {"/Linux/"}}, Page{})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiscoveredObject{{ID: "1", Name: "Linux servers"}}, groups)
+	assert.Equal(t, 1, total)
+
+	groups, total, err = dsInstance.discover(context.Background(), "groups", url.Values{}, Page{Limit: 1, Offset: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiscoveredObject{{ID: "2", Name: "Windows servers"}}, groups)
+	assert.Equal(t, 2, total)
+
+	// Groups are cached, so only hosts are requested
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"result":[{"hostid":"10","name":"Web server","host":"web01"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	hosts, _, err := dsInstance.discover(context.Background(), "hosts", url.Values{"group": {"Linux servers"}}, Page{})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiscoveredObject{{ID: "10", Name: "Web server", Host: "web01"}}, hosts)
+
+	// Apps aren't supported in Zabbix 5.4 and higher
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"error":{"code":-32601,"message":"Method not found.","data":"Incorrect API \"application\"."}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	apps, _, err := dsInstance.discover(context.Background(), "apps", url.Values{"group": {"Linux servers"}}, Page{})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiscoveredObject{}, apps)
+
+	_, _, err = dsInstance.discover(context.Background(), "triggers", url.Values{}, Page{})
+	assert.EqualError(t, err, "unknown discovery resource: triggers")
+}
+
+func TestDiscoverPageLimit(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"groupid":"1","name":"Linux servers"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	_, err := dsInstance.getGroups(context.Background(), nil, "Linux servers")
+	assert.NoError(t, err)
+	// Count of the hosts is cached, so only the page is requested
+	count, _ := simplejson.NewJson([]byte(`"5"`))
+	dsInstance.queryCache.SetAPIRequest(&ZabbixAPIRequest{Method: "host.get", Params: ZabbixAPIParams{
+		"countOutput": true,
+		"groupids":    []string{"1"},
+	}}, count)
+
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"result":[
+		{"hostid":"10","name":"db01","host":"db01"},{"hostid":"11","name":"db02","host":"db02"},{"hostid":"12","name":"web01","host":"web01"}
+	]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	ctx, apiCalls := withAPICallsRecorder(context.Background())
+	hosts, total, err := dsInstance.discover(ctx, "hosts", url.Values{"group": {"Linux servers"}}, Page{Limit: 1, Offset: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiscoveredObject{{ID: "12", Name: "web01", Host: "web01"}}, hosts)
+	assert.Equal(t, 5, total)
+	calls := apiCalls.Calls()
+	assert.Equal(t, "host.get", calls[len(calls)-1].Method)
+	assert.Contains(t, calls[len(calls)-1].Params, `"limit":3`)
+
+	// Hosts filtered by name are paged after the request
+	ctx, apiCalls = withAPICallsRecorder(context.Background())
+	hosts, total, err = dsInstance.discover(ctx, "hosts", url.Values{"group": {"Linux servers"}, "host": {"/^db/"}}, Page{Limit: 1, Offset: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiscoveredObject{{ID: "11", Name: "db02", Host: "db02"}}, hosts)
+	assert.Equal(t, 2, total)
+	calls = apiCalls.Calls()
+	assert.NotContains(t, calls[len(calls)-1].Params, `"limit"`)
+}
+
+func TestUniqueByName(t *testing.T) {
+	objects := []DiscoveredObject{{ID: "1", Name: "CPU"}, {ID: "2", Name: "Memory"}, {ID: "3", Name: "CPU"}}
+	assert.Equal(t, []DiscoveredObject{{Name: "CPU"}, {Name: "Memory"}}, uniqueByName(objects))
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  url.Values
+		want    Page
+		wantErr string
+	}{
+		{name: "Default", params: url.Values{}, want: Page{Limit: 100}},
+		{name: "Limit and offset", params: url.Values{"limit": {"10"}, "offset": {"20"}}, want: Page{Limit: 10, Offset: 20}},
+		{name: "Max limit", params: url.Values{"limit": {"100000"}}, want: Page{Limit: maxPageLimit}},
+		{name: "Invalid limit", params: url.Values{"limit": {"all"}}, wantErr: "invalid limit: all"},
+		{name: "Negative offset", params: url.Values{"offset": {"-1"}}, wantErr: "limit and offset must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := parsePage(tt.params, 100)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, page)
+		})
+	}
+}
+
+func TestPageBounds(t *testing.T) {
+	bounds := func(page Page, length int) []int {
+		start, end := page.bounds(length)
+		return []int{start, end}
+	}
+	assert.Equal(t, []int{0, 3}, bounds(Page{}, 3))
+	assert.Equal(t, []int{1, 2}, bounds(Page{Limit: 1, Offset: 1}, 3))
+	assert.Equal(t, []int{2, 3}, bounds(Page{Limit: 5, Offset: 2}, 3))
+	assert.Equal(t, []int{3, 3}, bounds(Page{Offset: 3}, 3))
+	assert.Equal(t, []int{3, 3}, bounds(Page{Limit: 1, Offset: 5}, 3))
+}
@@ -0,0 +1,61 @@
+package datasource
+
+import (
+	"errors"
+	"net"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"golang.org/x/net/context"
+)
+
+// ErrorSource tells whether the query failed because of the plugin or because of Zabbix (downstream)
+type ErrorSource string
+
+const (
+	ErrorSourcePlugin     ErrorSource = "plugin"
+	ErrorSourceDownstream ErrorSource = "downstream"
+)
+
+// DownstreamError wraps error caused by Zabbix: API errors, unavailable server or request timeouts
+type DownstreamError struct {
+	Err error
+}
+
+func (e *DownstreamError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DownstreamError) Unwrap() error {
+	return e.Err
+}
+
+// GetErrorSource returns source of the error based on the error type
+func GetErrorSource(err error) ErrorSource {
+	var downstreamErr *DownstreamError
+	var apiErr *zabbixapi.APIError
+	var httpErr *zabbixapi.HTTPError
+	var responseErr *zabbixapi.ResponseError
+	var netErr net.Error
+	if errors.As(err, &downstreamErr) ||
+		errors.As(err, &apiErr) ||
+		errors.As(err, &httpErr) ||
+		errors.As(err, &responseErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return ErrorSourceDownstream
+	}
+	return ErrorSourcePlugin
+}
+
+// withErrorSource wraps downstream errors into DownstreamError so the source is kept when the error is
+// passed further, other errors are returned as is
+func withErrorSource(err error) error {
+	if err == nil {
+		return nil
+	}
+	var downstreamErr *DownstreamError
+	if !errors.As(err, &downstreamErr) && GetErrorSource(err) == ErrorSourceDownstream {
+		return &DownstreamError{Err: err}
+	}
+	return err
+}
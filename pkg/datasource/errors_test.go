@@ -0,0 +1,47 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestGetErrorSource(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorSource
+	}{
+		{name: "API error", err: &zabbixapi.APIError{Code: zabbixapi.ErrCodeApplication}, want: ErrorSourceDownstream},
+		{name: "HTTP error", err: fmt.Errorf("query: %w", &zabbixapi.HTTPError{StatusCode: 502}), want: ErrorSourceDownstream},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: ErrorSourceDownstream},
+		{name: "timeout", err: context.DeadlineExceeded, want: ErrorSourceDownstream},
+		{name: "downstream error", err: &DownstreamError{Err: errors.New("failed")}, want: ErrorSourceDownstream},
+		{name: "plugin error", err: errors.New("unsupported fill mode: none"), want: ErrorSourcePlugin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetErrorSource(tt.err))
+		})
+	}
+}
+
+func TestWithErrorSource(t *testing.T) {
+	assert.Nil(t, withErrorSource(nil))
+
+	pluginErr := errors.New("unsupported fill mode: none")
+	assert.Equal(t, pluginErr, withErrorSource(pluginErr))
+
+	apiErr := &zabbixapi.APIError{Code: zabbixapi.ErrCodeInvalidParams, Message: "Invalid params.", Data: "No permissions."}
+	err := withErrorSource(apiErr)
+	assert.Equal(t, &DownstreamError{Err: apiErr}, err)
+	assert.Equal(t, apiErr.Error(), err.Error())
+	assert.True(t, errors.Is(err, apiErr))
+	// Already wrapped errors are not wrapped again
+	assert.Equal(t, err, withErrorSource(err))
+}
@@ -0,0 +1,72 @@
+package datasource
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/net/context"
+)
+
+// eventsPageSize is a max number of the events requested at once. Larger results are requested by pages, so they
+// aren't cut by the result limits of the Zabbix API and the requests don't time out.
+const eventsPageSize = 10000
+
+// getEventsPaged requests events or problems by pages, newest first, using event ID as a cursor: each page is
+// requested with eventid_till set to the ID before the last event of the previous page. Requesting stops when
+// the limit is reached (all events are requested if limit is 0). Cursor is the event ID to start from, the
+// returned cursor is set if the limit is reached and more events may exist.
+func (ds *ZabbixDatasourceInstance) getEventsPaged(ctx context.Context, method string, params ZabbixAPIParams, limit int, cursor string) (Events, string, error) {
+	return paginateEvents(params, limit, cursor, eventsPageSize, func(pageParams ZabbixAPIParams) (Events, error) {
+		return ds.getEvents(ctx, method, pageParams)
+	})
+}
+
+func paginateEvents(params ZabbixAPIParams, limit int, cursor string, pageSize int, fetch func(ZabbixAPIParams) (Events, error)) (Events, string, error) {
+	events := Events{}
+	for {
+		pageLimit := pageSize
+		if limit > 0 && limit-len(events) < pageLimit {
+			pageLimit = limit - len(events)
+		}
+
+		pageParams := make(ZabbixAPIParams, len(params)+4)
+		for name, value := range params {
+			pageParams[name] = value
+		}
+		pageParams["sortfield"] = []string{"eventid"}
+		pageParams["sortorder"] = "DESC"
+		pageParams["limit"] = pageLimit
+		if cursor != "" {
+			pageParams["eventid_till"] = cursor
+		}
+
+		page, err := fetch(pageParams)
+		if err != nil {
+			return nil, "", err
+		}
+		events = append(events, page...)
+		if len(page) < pageLimit {
+			return events, "", nil
+		}
+
+		cursor, err = nextEventsCursor(page[len(page)-1].ID)
+		if err != nil || cursor == "" {
+			return events, "", err
+		}
+		if limit > 0 && len(events) >= limit {
+			return events, cursor, nil
+		}
+	}
+}
+
+// nextEventsCursor returns ID before the event ID, or empty string if it's the first event
+func nextEventsCursor(eventID string) (string, error) {
+	id, err := strconv.ParseUint(eventID, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid event id: %s", eventID)
+	}
+	if id <= 1 {
+		return "", nil
+	}
+	return strconv.FormatUint(id-1, 10), nil
+}
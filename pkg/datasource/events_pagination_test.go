@@ -0,0 +1,75 @@
+package datasource
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fetchEventsFrom returns fetch function serving events with IDs from 1 to total, and records params of the pages
+func fetchEventsFrom(total int, pages *[]ZabbixAPIParams) func(ZabbixAPIParams) (Events, error) {
+	return func(params ZabbixAPIParams) (Events, error) {
+		*pages = append(*pages, params)
+		till := total
+		if cursor, ok := params["eventid_till"].(string); ok {
+			till, _ = strconv.Atoi(cursor)
+		}
+		events := Events{}
+		for id := till; id > 0 && len(events) < params["limit"].(int); id-- {
+			events = append(events, Event{ID: strconv.Itoa(id)})
+		}
+		return events, nil
+	}
+}
+
+func TestPaginateEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      int
+		limit      int
+		cursor     string
+		wantFirst  string
+		wantLen    int
+		wantCursor string
+		wantPages  int
+	}{
+		{name: "Single page", total: 3, limit: 0, wantFirst: "3", wantLen: 3, wantPages: 1},
+		{name: "All pages", total: 7, limit: 0, wantFirst: "7", wantLen: 7, wantPages: 3},
+		{name: "Limited", total: 7, limit: 5, wantFirst: "7", wantLen: 5, wantCursor: "2", wantPages: 2},
+		{name: "Limit on page boundary", total: 7, limit: 4, wantFirst: "7", wantLen: 4, wantCursor: "3", wantPages: 2},
+		{name: "From cursor", total: 7, limit: 5, cursor: "2", wantFirst: "2", wantLen: 2, wantPages: 1},
+		{name: "Exact pages", total: 6, limit: 0, wantFirst: "6", wantLen: 6, wantPages: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages []ZabbixAPIParams
+			params := ZabbixAPIParams{"output": "extend"}
+			events, cursor, err := paginateEvents(params, tt.limit, tt.cursor, 3, fetchEventsFrom(tt.total, &pages))
+			assert.NoError(t, err)
+			assert.Len(t, events, tt.wantLen)
+			assert.Equal(t, tt.wantFirst, events[0].ID)
+			assert.Equal(t, tt.wantCursor, cursor)
+			assert.Len(t, pages, tt.wantPages)
+			assert.Equal(t, []string{"eventid"}, pages[0]["sortfield"])
+			assert.Equal(t, "DESC", pages[0]["sortorder"])
+			assert.Equal(t, "extend", pages[0]["output"])
+			// Params of the caller are not changed
+			assert.NotContains(t, params, "limit")
+		})
+	}
+}
+
+func TestPaginateEventsError(t *testing.T) {
+	_, _, err := paginateEvents(ZabbixAPIParams{}, 0, "", 3, func(ZabbixAPIParams) (Events, error) {
+		return nil, errors.New("request failed")
+	})
+	assert.EqualError(t, err, "request failed")
+
+	_, _, err = paginateEvents(ZabbixAPIParams{}, 0, "", 1, func(ZabbixAPIParams) (Events, error) {
+		return Events{{ID: "latest"}}, nil
+	})
+	assert.EqualError(t, err, "invalid event id: latest")
+}
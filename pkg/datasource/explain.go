@@ -0,0 +1,198 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/net/context"
+)
+
+// QueryExplanation describes how the query is executed for the time range, returned by the /query/explain
+// resource. Requests resolving the items are made (and cached), history and trends requests are only planned.
+type QueryExplanation struct {
+	Items      int             `json:"items"`
+	UseTrends  bool            `json:"useTrends"`
+	TrendsTill *time.Time      `json:"trendsTill,omitempty"`
+	ValueType  string          `json:"valueType,omitempty"`
+	Calls      []ExplainedCall `json:"calls"`
+}
+
+// ExplainedCall is a request of the query. Planned requests of the history aren't made by the explain request,
+// and may fetch a shorter range if history is cached by the previous queries.
+type ExplainedCall struct {
+	Method      string `json:"method"`
+	Params      string `json:"params,omitempty"`
+	Source      string `json:"source"`
+	Cached      bool   `json:"cached,omitempty"`
+	Planned     bool   `json:"planned"`
+	Downsampled bool   `json:"downsampled,omitempty"`
+}
+
+// QueryExplainResourceRequest is a request of the /query/explain resource with the query model and the panel
+// options. From, To and IntervalMs are in ms.
+type QueryExplainResourceRequest struct {
+	Query         json.RawMessage `json:"query"`
+	From          int64           `json:"from,omitempty"`
+	To            int64           `json:"to,omitempty"`
+	IntervalMs    int64           `json:"intervalMs,omitempty"`
+	MaxDataPoints int64           `json:"maxDataPoints,omitempty"`
+}
+
+// DataQuery returns the query as it's sent by Grafana
+func (r *QueryExplainResourceRequest) DataQuery() backend.DataQuery {
+	return backend.DataQuery{
+		JSON:          r.Query,
+		TimeRange:     resourceTimeRange(r.From, r.To),
+		Interval:      time.Duration(r.IntervalMs) * time.Millisecond,
+		MaxDataPoints: r.MaxDataPoints,
+	}
+}
+
+// explainQuery resolves items of the metrics, text or item ID query and returns requests made to get their history or
+// trends for the time range, so expensive queries can be understood and optimized
+func (ds *ZabbixDatasourceInstance) explainQuery(ctx context.Context, q backend.DataQuery) (*QueryExplanation, error) {
+	query, err := ReadQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := newQueryFilters(&query)
+	ctx, recorder := withAPICallsRecorder(ctx)
+	var items Items
+	switch query.Mode {
+	case QueryModeMetrics:
+		items, err = ds.getItems(ctx, filters, query.Group.Filter, query.Host.Filter, query.Application.Filter, query.Item.Filter, "num")
+	case QueryModeText:
+		items, err = ds.getItems(ctx, filters, query.Group.Filter, query.Host.Filter, query.Application.Filter, query.Item.Filter, "text")
+	case QueryModeItemID:
+		items, err = ds.getItemsByIDs(ctx, filters, query.ItemIDs, query.HostIDs)
+	default:
+		return nil, fmt.Errorf("explain isn't supported for the query mode %d", query.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &QueryExplanation{Items: len(items), Calls: []ExplainedCall{}}
+	for _, call := range recorder.Calls() {
+		explanation.Calls = append(explanation.Calls, ExplainedCall{
+			Method: call.Method,
+			Params: call.Params,
+			Source: HistorySourceAPI,
+			Cached: call.Cached,
+		})
+	}
+	if len(items) == 0 {
+		return explanation, nil
+	}
+
+	if query.Mode == QueryModeText {
+		explanation.Calls = append(explanation.Calls, ds.planHistoryCalls(query.TimeRange, items, false, "", false)...)
+		return explanation, nil
+	}
+	if query.Options.UseLastValue {
+		// Last values are requested by item.get bypassing the cache
+		explanation.Calls = append(explanation.Calls, ExplainedCall{
+			Method:  "item.get",
+			Params:  newAPICall(lastValuesRequest(items), 0).Params,
+			Source:  HistorySourceAPI,
+			Planned: true,
+		})
+		return explanation, nil
+	}
+
+	valueType := ds.getTrendValueType(&query)
+	consolidateBy := ds.getConsolidateBy(&query)
+	if consolidateBy == "" {
+		consolidateBy = valueType
+	} else {
+		valueType = consolidateBy
+	}
+
+	if err := applyFunctionsPre(&query); err != nil {
+		return nil, err
+	}
+	timeRanges := []backend.TimeRange{noDataFetchRange(&query)}
+	baseline, err := getBaseline(query.Functions)
+	if err != nil {
+		return nil, err
+	}
+	if baseline != nil {
+		timeRanges = append(timeRanges, backend.TimeRange{
+			From: query.TimeRange.From.Add(-baseline.period),
+			To:   query.TimeRange.To.Add(-baseline.period),
+		})
+	}
+
+	downsampling := newDBDownsampling(&query, consolidateBy)
+	for _, timeRange := range timeRanges {
+		if trendsTill, ok := ds.getTrendsStitchTime(timeRange); ok {
+			explanation.UseTrends = true
+			explanation.TrendsTill = &trendsTill
+			trendRange := backend.TimeRange{From: timeRange.From, To: trendsTill.Add(-time.Second)}
+			historyRange := backend.TimeRange{From: trendsTill, To: timeRange.To}
+			explanation.Calls = append(explanation.Calls, ds.planHistoryCalls(trendRange, items, true, valueType, false)...)
+			explanation.Calls = append(explanation.Calls, ds.planHistoryCalls(historyRange, items, false, valueType, false)...)
+			continue
+		}
+
+		useTrend := ds.isUseTrend(timeRange)
+		if useTrend {
+			explanation.UseTrends = true
+		}
+		downsampled := ds.getDBDownsampling(downsampling, timeRange, items, useTrend) != nil
+		explanation.Calls = append(explanation.Calls, ds.planHistoryCalls(timeRange, items, useTrend, valueType, downsampled)...)
+	}
+	if explanation.UseTrends {
+		explanation.ValueType = valueType
+	}
+	return explanation, nil
+}
+
+// planHistoryCalls returns requests of the history or trends of the items, one per value type, from the
+// direct DB connection if it's available or from the API otherwise
+func (ds *ZabbixDatasourceInstance) planHistoryCalls(timeRange backend.TimeRange, items Items, useTrend bool, trendValueType string, downsampled bool) []ExplainedCall {
+	source := HistorySourceAPI
+	if ds.dbConnector != nil && (!useTrend || ds.dbConnector.HasTrends()) {
+		if available, _ := ds.dbState.available(); available {
+			source = HistorySourceDB
+		}
+	}
+
+	groupedItems := map[int][]string{}
+	for _, item := range items {
+		groupedItems[item.ValueType] = append(groupedItems[item.ValueType], item.ID)
+	}
+	valueTypes := make([]int, 0, len(groupedItems))
+	for valueType := range groupedItems {
+		valueTypes = append(valueTypes, valueType)
+	}
+	sort.Ints(valueTypes)
+
+	calls := make([]ExplainedCall, 0, len(valueTypes))
+	for _, valueType := range valueTypes {
+		valueType := valueType
+		request := &ZabbixAPIRequest{Method: "history.get", Params: ZabbixAPIParams{
+			"itemids":   groupedItems[valueType],
+			"time_from": timeRange.From.Unix(),
+			"time_till": timeRange.To.Unix(),
+		}}
+		if useTrend {
+			request.Method = "trend.get"
+			request.Params["valueType"] = trendValueType
+		} else {
+			request.Params["history"] = &valueType
+		}
+		calls = append(calls, ExplainedCall{
+			Method:      request.Method,
+			Params:      newAPICall(request, 0).Params,
+			Source:      source,
+			Planned:     true,
+			Downsampled: downsampled && source == HistorySourceDB,
+		})
+	}
+	return calls
+}
@@ -0,0 +1,108 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainQuery(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name          string
+		query         string
+		timeRange     backend.TimeRange
+		wantTrends    bool
+		wantValueType string
+		wantPlanned   []string
+	}{
+		{
+			name:        "History",
+			query:       `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"/.*/"},"item":{"filter":"/.*/"}}`,
+			timeRange:   backend.TimeRange{From: now.Add(-time.Hour), To: now},
+			wantPlanned: []string{"history.get", "history.get"},
+		},
+		{
+			name:          "Trends",
+			query:         `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"/.*/"},"item":{"filter":"/.*/"}}`,
+			timeRange:     backend.TimeRange{From: now.Add(-30 * 24 * time.Hour), To: now},
+			wantTrends:    true,
+			wantValueType: "avg",
+			wantPlanned:   []string{"trend.get", "trend.get"},
+		},
+		{
+			name:          "Trends stitched with history",
+			query:         `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"/.*/"},"item":{"filter":"/.*/"},"functions":[{"def":{"name":"consolidateBy"},"params":["max"]}]}`,
+			timeRange:     backend.TimeRange{From: now.Add(-8 * 24 * time.Hour), To: now},
+			wantTrends:    true,
+			wantValueType: "max",
+			wantPlanned:   []string{"trend.get", "trend.get", "history.get", "history.get"},
+		},
+		{
+			name:        "Baseline",
+			query:       `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"/.*/"},"item":{"filter":"/.*/"},"functions":[{"def":{"name":"baseline"},"params":["1d"]}]}`,
+			timeRange:   backend.TimeRange{From: now.Add(-time.Hour), To: now},
+			wantPlanned: []string{"history.get", "history.get", "history.get", "history.get"},
+		},
+		{
+			name:        "Last value",
+			query:       `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"/.*/"},"item":{"filter":"/.*/"},"options":{"useLastValue":true}}`,
+			timeRange:   backend.TimeRange{From: now.Add(-time.Hour), To: now},
+			wantPlanned: []string{"item.get"},
+		},
+		{
+			name:      "No items",
+			query:     `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"/.*/"},"item":{"filter":"Memory"}}`,
+			timeRange: backend.TimeRange{From: now.Add(-time.Hour), To: now},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Same response is used for groups, hosts and items
+			dsInstance := MockZabbixDataSource(`{"result":[{"groupid":"1","hostid":"10","itemid":"100","name":"CPU","key_":"cpu","value_type":"0","status":"0"},{"groupid":"1","hostid":"10","itemid":"101","name":"Processes","key_":"proc","value_type":"3","status":"0"}]}`, 200)
+			dsInstance.zabbixAPI.SetAuth("secretauth")
+			dsInstance.Settings = &ZabbixDatasourceSettings{Trends: true, TrendsFrom: 7 * 24 * time.Hour, TrendsRange: 10 * 24 * time.Hour}
+
+			explanation, err := dsInstance.explainQuery(context.Background(), backend.DataQuery{JSON: []byte(tt.query), TimeRange: tt.timeRange})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTrends, explanation.UseTrends)
+			assert.Equal(t, tt.wantValueType, explanation.ValueType)
+
+			var planned []string
+			for _, call := range explanation.Calls {
+				if call.Planned {
+					assert.Equal(t, HistorySourceAPI, call.Source)
+					planned = append(planned, call.Method)
+				} else {
+					assert.NotEqual(t, "history.get", call.Method)
+				}
+			}
+			assert.Equal(t, tt.wantPlanned, planned)
+		})
+	}
+}
+
+func TestExplainQueryUnsupportedMode(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	_, err := dsInstance.explainQuery(context.Background(), backend.DataQuery{JSON: []byte(`{"mode":5}`)})
+	assert.EqualError(t, err, "explain isn't supported for the query mode 5")
+}
+
+func TestPlanHistoryCalls(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+	items := Items{{ID: "100", ValueType: 0}, {ID: "101", ValueType: 3}, {ID: "102", ValueType: 0}}
+
+	assert.Equal(t, []ExplainedCall{
+		{Method: "history.get", Params: `{"history":0,"itemids":["100","102"],"time_from":1600000000,"time_till":1600003600}`, Source: HistorySourceAPI, Planned: true},
+		{Method: "history.get", Params: `{"history":3,"itemids":["101"],"time_from":1600000000,"time_till":1600003600}`, Source: HistorySourceAPI, Planned: true},
+	}, dsInstance.planHistoryCalls(timeRange, items, false, "", false))
+
+	assert.Equal(t, []ExplainedCall{
+		{Method: "trend.get", Params: `{"itemids":["100","101","102"],"time_from":1600000000,"time_till":1600003600,"valueType":"max"}`, Source: HistorySourceAPI, Planned: true},
+	}, dsInstance.planHistoryCalls(timeRange, Items{{ID: "100"}, {ID: "101"}, {ID: "102"}}, true, "max", false))
+}
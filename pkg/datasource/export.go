@@ -0,0 +1,135 @@
+package datasource
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Formats of the exported query results
+const (
+	ExportFormatCSV = "csv"
+	// ExportFormatXLSX is not supported, the request fails with an error suggesting CSV, which spreadsheets open
+	// as well
+	ExportFormatXLSX = "xlsx"
+)
+
+// exportRefID is the ref ID of the exported query, the request contains a single query
+const exportRefID = "A"
+
+// exportFlushRows is a number of the rows written before the CSV writer is flushed, so large results are
+// streamed to the client instead of buffered
+const exportFlushRows = 1000
+
+// QueryExportResourceRequest is a body of the /query/export resource request: query with the time range like
+// in the explain request, and the result format (csv by default)
+type QueryExportResourceRequest struct {
+	QueryExplainResourceRequest
+	Format string `json:"format,omitempty"`
+}
+
+// exportQuery runs the query in the same way as the panel query and returns the result frames
+func (ds *ZabbixDatasource) exportQuery(ctx context.Context, pluginContext backend.PluginContext, q backend.DataQuery) ([]*data.Frame, error) {
+	q.RefID = exportRefID
+	response, err := ds.QueryData(ctx, &backend.QueryDataRequest{
+		PluginContext: pluginContext,
+		// Keep ID of the resource request for the query logs
+		Headers: map[string]string{requestid.Header: requestid.FromContext(ctx)},
+		Queries: []backend.DataQuery{q},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := response.Responses[exportRefID]
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return res.Frames, nil
+}
+
+// writeFramesCSV writes frames as CSV with a header row of the field names. Frames of the text queries or
+// different items have different fields, so each frame is written as a separate table after an empty line.
+func writeFramesCSV(w io.Writer, frames []*data.Frame) error {
+	writer := csv.NewWriter(w)
+	for i, frame := range frames {
+		if i > 0 {
+			if err := writer.Write(nil); err != nil {
+				return err
+			}
+		}
+
+		header := make([]string, 0, len(frame.Fields))
+		for _, field := range frame.Fields {
+			name := field.Name
+			if field.Config != nil && field.Config.DisplayNameFromDS != "" {
+				name = field.Config.DisplayNameFromDS
+			}
+			header = append(header, escapeCSVFormula(name))
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+
+		record := make([]string, len(frame.Fields))
+		rows, _ := frame.RowLen()
+		for row := 0; row < rows; row++ {
+			for j, field := range frame.Fields {
+				record[j] = formatCSVValue(field, row)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+			if (row+1)%exportFlushRows == 0 {
+				writer.Flush()
+				if err := writer.Error(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatCSVValue returns value of the field row as a string, empty for null values. Time is formatted as RFC 3339
+// and numbers without exponent, so the result can be read by spreadsheets.
+func formatCSVValue(field *data.Field, row int) string {
+	value, ok := field.ConcreteAt(row)
+	if !ok {
+		return ""
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case string:
+		return escapeCSVFormula(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// escapeCSVFormula prefixes text starting with a formula character with a quote, so spreadsheets show it as text
+// instead of evaluating it (item names and log values are set by Zabbix users). Numbers are formatted separately
+// and keep the minus sign.
+func escapeCSVFormula(text string) string {
+	if text == "" {
+		return text
+	}
+	switch text[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + text
+	}
+	return text
+}
@@ -0,0 +1,46 @@
+package datasource
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFramesCSV(t *testing.T) {
+	value := 0.5
+	series := data.NewField("web01: CPU utilization", data.Labels{"host": "web01"}, []*float64{&value, nil})
+	series.SetConfig(&data.FieldConfig{DisplayNameFromDS: "web01: CPU, %"})
+	history := data.NewFrame("History",
+		data.NewField("time", nil, []time.Time{time.Unix(1600000000, 0), time.Unix(1600000060, 0)}),
+		series,
+	)
+	logs := data.NewFrame("Logs",
+		data.NewField("time", nil, []time.Time{time.Unix(1600000000, 500000000)}),
+		data.NewField("message", nil, []string{`Error "disk full", retrying`}),
+		data.NewField("severity", nil, []int64{4}),
+	)
+	formulas := data.NewFrame("Formulas",
+		data.NewField("=HYPERLINK()", nil, []string{"=1+2", "+1", "-1", "@SUM(A1)", "a=b"}),
+		data.NewField("value", nil, []float64{-1, -0.5, 0, 1, 2}),
+	)
+
+	var buf bytes.Buffer
+	err := writeFramesCSV(&buf, []*data.Frame{history, logs, formulas})
+	assert.NoError(t, err)
+	assert.Equal(t, "time,\"web01: CPU, %\"\n"+
+		"2020-09-13T12:26:40Z,0.5\n"+
+		"2020-09-13T12:27:40Z,\n"+
+		"\n"+
+		"time,message,severity\n"+
+		"2020-09-13T12:26:40.5Z,\"Error \"\"disk full\"\", retrying\",4\n"+
+		"\n"+
+		"'=HYPERLINK(),value\n"+
+		"'=1+2,-1\n"+
+		"'+1,-0.5\n"+
+		"'-1,0\n"+
+		"'@SUM(A1),1\n"+
+		"a=b,2\n", buf.String())
+}
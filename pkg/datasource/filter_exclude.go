@@ -0,0 +1,211 @@
+package datasource
+
+import (
+	"regexp"
+)
+
+// Levels of the query filters
+const (
+	filterLevelGroup       = "group"
+	filterLevelHost        = "host"
+	filterLevelApplication = "application"
+	filterLevelItem        = "item"
+	filterLevelItemKey     = "item key"
+)
+
+// hostFilters are status, maintenance, inventory and tag filters of the hosts
+type hostFilters struct {
+	status       string
+	maintenance  string
+	inventory    []inventoryCondition
+	tags         []TagFilter
+	tagsEvalType string
+}
+
+// nameFilter matches names by regex or glob pattern, or exact name
+type nameFilter struct {
+	re   *regexp.Regexp
+	name string
+	glob bool
+}
+
+// newNameFilter returns filter of the names set as a regex, glob pattern or exact name (see parseFilter)
+func newNameFilter(filter string) (*nameFilter, error) {
+	re, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &nameFilter{re: re, name: filter, glob: isGlobFilter(filter)}, nil
+}
+
+// forNames returns filter matching the exact name instead of the glob pattern if one of the names equals the
+// pattern, so objects with * or ? in the name (like "Is host reachable?") are selected by the name
+func (f *nameFilter) forNames(names []string) *nameFilter {
+	if !f.glob {
+		return f
+	}
+	for _, name := range names {
+		if name == f.name {
+			return &nameFilter{name: f.name}
+		}
+	}
+	return f
+}
+
+func (f *nameFilter) match(name string) bool {
+	if f.re != nil {
+		return f.re.MatchString(name)
+	}
+	return name == f.name
+}
+
+// levelFilter is a query filter of the group, host, application or item level
+type levelFilter struct {
+	level  string
+	filter QueryFilter
+}
+
+// filtersByLevel returns group, host, application, item and item key filters of the query
+func (query *QueryModel) filtersByLevel() []levelFilter {
+	return []levelFilter{
+		{filterLevelGroup, query.Group},
+		{filterLevelHost, query.Host},
+		{filterLevelApplication, query.Application},
+		{filterLevelItem, query.Item},
+		{filterLevelItemKey, query.ItemKey},
+	}
+}
+
+// queryFilters are the filters of the query applied to the results of getGroups, getHosts, getApps and
+// getItems in addition to the name filters: excludes, item key filter, host filters and disabled items. Nil
+// filters don't restrict the results, like for the resource and variable requests.
+type queryFilters struct {
+	excludes          map[string]*nameFilter
+	itemKey           *nameFilter
+	showDisabledItems bool
+	// monitoredOnly requests enabled items of the monitored hosts only, set for the data queries unless disabled
+	// items are shown
+	monitoredOnly bool
+	hosts         *hostFilters
+}
+
+// newQueryFilters returns filters of the query. Patterns are validated by ReadQuery, so invalid ones are ignored.
+func newQueryFilters(query *QueryModel) *queryFilters {
+	// Disabled hosts have no monitored items
+	showDisabledItems := query.Options.ShowDisabledItems || query.Options.HostStatus == HostStatusDisabled
+	filters := &queryFilters{
+		excludes:          map[string]*nameFilter{},
+		showDisabledItems: showDisabledItems,
+		monitoredOnly:     !showDisabledItems,
+		hosts:             newHostFilters(query.Options),
+	}
+	for _, f := range query.filtersByLevel() {
+		if f.filter.Exclude == "" {
+			continue
+		}
+		exclude, err := newNameFilter(f.filter.Exclude)
+		if err != nil {
+			continue
+		}
+		filters.excludes[f.level] = exclude
+	}
+	if query.ItemKey.Filter != "" {
+		if itemKey, err := newNameFilter(query.ItemKey.Filter); err == nil {
+			filters.itemKey = itemKey
+		}
+	}
+	return filters
+}
+
+// excluder returns function checking if the name of the group, host, application or item matches exclude
+// pattern of the query, glob pattern is matched as the exact name if one of the names equals it
+func (f *queryFilters) excluder(level string, names []string) func(name string) bool {
+	if f == nil || f.excludes[level] == nil {
+		return func(string) bool { return false }
+	}
+	return f.excludes[level].forNames(names).match
+}
+
+// matchesAllItems returns true if the filters don't exclude items by name or key, so items of the hosts can be
+// limited by the API
+func (f *queryFilters) matchesAllItems() bool {
+	return f == nil || f.excludes[filterLevelItem] == nil && f.excludes[filterLevelItemKey] == nil && f.itemKey == nil
+}
+
+// matchesAllHosts returns true if the filters don't exclude hosts by name or inventory values matched after the
+// request, so hosts of the groups can be limited by the API
+func (f *queryFilters) matchesAllHosts() bool {
+	return f == nil || f.excludes[filterLevelHost] == nil && (f.hosts == nil || len(f.hosts.inventory) == 0)
+}
+
+// isShowDisabledItems returns true if disabled items and hosts should be returned for the query, like the
+// showDisabledItems query option. They're filtered out by the Zabbix API otherwise.
+func (f *queryFilters) isShowDisabledItems() bool {
+	return f != nil && f.showDisabledItems
+}
+
+// isMonitoredOnly returns true if only enabled items of the monitored hosts should be requested from the Zabbix
+// API. Resource and variable requests return disabled items and hosts too.
+func (f *queryFilters) isMonitoredOnly() bool {
+	return f != nil && f.monitoredOnly
+}
+
+// itemKeyMatcher returns function checking if the item key matches the item key filter of the query, or the
+// filter isn't set
+func (f *queryFilters) itemKeyMatcher(keys []string) func(key string) bool {
+	if f == nil || f.itemKey == nil {
+		return func(string) bool { return true }
+	}
+	return f.itemKey.forNames(keys).match
+}
+
+// matchHostFilters returns true if the host from the host.get response has exact inventory values of the host
+// filters
+func (f *queryFilters) matchHostFilters(host map[string]interface{}) bool {
+	return f == nil || f.hosts == nil || matchInventory(host, f.hosts.inventory)
+}
+
+// newHostFilters returns filters restricting results of getHosts to the hosts with the status (enabled or
+// disabled), maintenance state, inventory values and tags of the query options, or nil if none of them is set.
+// Hosts are filtered by the Zabbix API. Inventory filter is validated by ReadQuery, so invalid one is ignored.
+func newHostFilters(options QueryOptions) *hostFilters {
+	inventory, _ := parseInventoryFilter(options.HostInventory)
+	if options.HostStatus == "" && options.HostMaintenance == "" && len(inventory) == 0 && len(options.HostTags) == 0 {
+		return nil
+	}
+	return &hostFilters{
+		status:       options.HostStatus,
+		maintenance:  options.HostMaintenance,
+		inventory:    inventory,
+		tags:         options.HostTags,
+		tagsEvalType: options.HostTagsEvalType,
+	}
+}
+
+// setHostFiltersParams sets filter of the host.get request by the host status and maintenance state of the
+// host filters. Disabled hosts are requested even if disabled items aren't shown.
+func (f *queryFilters) setHostFiltersParams(params ZabbixAPIParams) {
+	if f == nil || f.hosts == nil {
+		return
+	}
+	filters := f.hosts
+	filter := map[string]interface{}{}
+	switch filters.status {
+	case HostStatusEnabled:
+		filter["status"] = 0
+	case HostStatusDisabled:
+		filter["status"] = 1
+		delete(params, "monitored_hosts")
+	}
+	switch filters.maintenance {
+	case HostMaintenanceIn:
+		filter["maintenance_status"] = 1
+	case HostMaintenanceOut:
+		filter["maintenance_status"] = 0
+	}
+	if len(filter) > 0 {
+		params["filter"] = filter
+	}
+	setInventoryParams(params, filters.inventory)
+	setTagsParam(params, tagFiltersParam(filters.tags), filters.tagsEvalType)
+}
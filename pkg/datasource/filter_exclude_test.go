@@ -0,0 +1,230 @@
+package datasource
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterExcludes(t *testing.T) {
+	// Same response is used for groups, hosts and items
+	body := `{"result":[
+		{"groupid":"1","hostid":"10","itemid":"100","name":"Traffic on eth0","key_":"net.if.in[eth0]","value_type":"3","status":"0"},
+		{"groupid":"1","hostid":"10","itemid":"101","name":"Traffic on lo","key_":"net.if.in[lo]","value_type":"3","status":"0"},
+		{"groupid":"1","hostid":"10","itemid":"102","name":"Traffic on eth1","key_":"net.if.in[eth1]","value_type":"3","status":"0"}
+	]}`
+
+	tests := []struct {
+		name      string
+		query     QueryModel
+		wantItems []string
+	}{
+		{
+			name:      "No excludes",
+			query:     QueryModel{Item: QueryFilter{Filter: "Traffic on *"}},
+			wantItems: []string{"Traffic on eth0", "Traffic on lo", "Traffic on eth1"},
+		},
+		{
+			name:      "Exact name",
+			query:     QueryModel{Item: QueryFilter{Filter: "Traffic on *", Exclude: "Traffic on lo"}},
+			wantItems: []string{"Traffic on eth0", "Traffic on eth1"},
+		},
+		{
+			name:      "Regex",
+			query:     QueryModel{Item: QueryFilter{Filter: "/Traffic/", Exclude: "/eth\\d$/"}},
+			wantItems: []string{"Traffic on lo"},
+		},
+		{
+			name:      "Glob",
+			query:     QueryModel{Item: QueryFilter{Filter: "/.*/", Exclude: "*eth1"}},
+			wantItems: []string{"Traffic on eth0", "Traffic on lo"},
+		},
+		{
+			name:      "Item key",
+			query:     QueryModel{Item: QueryFilter{Filter: "/.*/"}, ItemKey: QueryFilter{Filter: "net.if.in[eth*]"}},
+			wantItems: []string{"Traffic on eth0", "Traffic on eth1"},
+		},
+		{
+			name:      "Item key regex with name filter",
+			query:     QueryModel{Item: QueryFilter{Filter: "*eth0"}, ItemKey: QueryFilter{Filter: "/^net\\.if\\.in\\[/"}},
+			wantItems: []string{"Traffic on eth0"},
+		},
+		{
+			name:      "Excluded item key",
+			query:     QueryModel{Item: QueryFilter{Filter: "/.*/"}, ItemKey: QueryFilter{Exclude: "net.if.in[lo]"}},
+			wantItems: []string{"Traffic on eth0", "Traffic on eth1"},
+		},
+		{
+			name:      "Excluded hosts",
+			query:     QueryModel{Host: QueryFilter{Exclude: "Traffic*"}, Item: QueryFilter{Filter: "/.*/"}},
+			wantItems: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsInstance := MockZabbixDataSource(body, 200)
+			dsInstance.zabbixAPI.SetAuth("secretauth")
+
+			items, err := dsInstance.getItems(context.Background(), newQueryFilters(&tt.query), "/.*/", "/.*/", "", tt.query.Item.Filter, "")
+			assert.NoError(t, err)
+
+			names := []string{}
+			for _, item := range items {
+				names = append(names, item.Name)
+			}
+			assert.Equal(t, tt.wantItems, names)
+		})
+	}
+}
+
+func TestNameFilterForNames(t *testing.T) {
+	names := []string{"Is host reachable?", "Is host reachable!", "Is DB reachable?"}
+	tests := []struct {
+		filter    string
+		wantNames []string
+	}{
+		{filter: "Is host reachable?", wantNames: []string{"Is host reachable?"}},
+		{filter: "Is * reachable?", wantNames: []string{"Is host reachable?", "Is host reachable!", "Is DB reachable?"}},
+		{filter: "/reachable\\?$/", wantNames: []string{"Is host reachable?", "Is DB reachable?"}},
+		{filter: "Is host reachable", wantNames: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := newNameFilter(tt.filter)
+			assert.NoError(t, err)
+			filter = filter.forNames(names)
+			matched := []string{}
+			for _, name := range names {
+				if filter.match(name) {
+					matched = append(matched, name)
+				}
+			}
+			assert.Equal(t, tt.wantNames, matched)
+		})
+	}
+}
+
+func TestReadQueryInvalidExclude(t *testing.T) {
+	_, err := ReadQuery(backend.DataQuery{JSON: []byte(`{"host":{"filter":"/.*/","exclude":"/web(/"}}`)})
+	assert.EqualError(t, err, "invalid host exclude: error parsing regexp: missing closing ): `web(`")
+
+	_, err = ReadQuery(backend.DataQuery{JSON: []byte(`{"itemKey":{"filter":"/vfs[/"}}`)})
+	assert.EqualError(t, err, "invalid item key filter: error parsing regexp: missing closing ]: `[`")
+}
+
+func TestShowDisabledItems(t *testing.T) {
+	// Disabled items are returned by the API only if requested, so they aren't filtered out by the data source
+	body := `{"result":[
+		{"groupid":"1","hostid":"10","name":"CPU load","itemid":"100","key_":"system.cpu.load","value_type":"0","status":"1","hosts":[{"hostid":"10","name":"web01"}]}
+	]}`
+
+	tests := []struct {
+		name        string
+		filters     *queryFilters
+		wantFilters bool
+	}{
+		{name: "Hidden", filters: newQueryFilters(&QueryModel{}), wantFilters: true},
+		{name: "Shown", filters: newQueryFilters(&QueryModel{Options: QueryOptions{ShowDisabledItems: true}}), wantFilters: false},
+		// Resource and variable requests return disabled items too
+		{name: "No query", filters: nil, wantFilters: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsInstance := MockZabbixDataSource(body, 200)
+			dsInstance.zabbixAPI.SetAuth("secretauth")
+
+			ctx, apiCalls := withAPICallsRecorder(context.Background())
+			items, err := dsInstance.getItems(ctx, tt.filters, "/.*/", "/.*/", "", "/.*/", "num")
+			assert.NoError(t, err)
+			assert.Len(t, items, 1)
+
+			methods := []string{}
+			for _, call := range apiCalls.Calls() {
+				methods = append(methods, call.Method)
+				switch call.Method {
+				case "host.get":
+					assert.Equal(t, tt.wantFilters, strings.Contains(call.Params, `"monitored_hosts":true`))
+				case "item.get":
+					assert.Equal(t, tt.wantFilters, strings.Contains(call.Params, `"monitored":true`))
+				}
+			}
+			assert.Contains(t, methods, "host.get")
+			assert.Contains(t, methods, "item.get")
+		})
+	}
+}
+
+func TestSetHostFiltersParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    QueryOptions
+		wantFilter interface{}
+		monitored  bool
+	}{
+		{name: "Not set", options: QueryOptions{}, monitored: true},
+		{name: "Enabled", options: QueryOptions{HostStatus: HostStatusEnabled}, wantFilter: map[string]interface{}{"status": 0}, monitored: true},
+		{name: "Disabled", options: QueryOptions{HostStatus: HostStatusDisabled}, wantFilter: map[string]interface{}{"status": 1}},
+		{
+			name:       "Not in maintenance",
+			options:    QueryOptions{HostMaintenance: HostMaintenanceOut},
+			wantFilter: map[string]interface{}{"maintenance_status": 0},
+			monitored:  true,
+		},
+		{
+			name:       "Enabled in maintenance",
+			options:    QueryOptions{HostStatus: HostStatusEnabled, HostMaintenance: HostMaintenanceIn},
+			wantFilter: map[string]interface{}{"status": 0, "maintenance_status": 1},
+			monitored:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := ZabbixAPIParams{"monitored_hosts": true}
+			(&queryFilters{hosts: newHostFilters(tt.options)}).setHostFiltersParams(params)
+			assert.Equal(t, tt.wantFilter, params["filter"])
+			assert.Equal(t, tt.monitored, params["monitored_hosts"] == true)
+		})
+	}
+}
+
+func TestSetHostFiltersParamsTags(t *testing.T) {
+	params := ZabbixAPIParams{}
+	filters := &queryFilters{hosts: newHostFilters(QueryOptions{
+		HostTags:         []TagFilter{{Tag: "env", Operator: TagOperatorEquals, Value: "prod"}, {Tag: "team", Operator: TagOperatorExists}},
+		HostTagsEvalType: TagsEvalTypeOr,
+	})}
+	filters.setHostFiltersParams(params)
+	assert.Equal(t, []map[string]interface{}{
+		{"tag": "env", "operator": 1, "value": "prod"},
+		{"tag": "team", "operator": 4},
+	}, params["tags"])
+	assert.Equal(t, 2, params["evaltype"])
+	assert.Nil(t, params["filter"])
+}
+
+func TestReadQueryHostFilters(t *testing.T) {
+	query, err := ReadQuery(backend.DataQuery{JSON: []byte(`{"options":{"hostStatus":"enabled","hostMaintenance":"notInMaintenance"}}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, HostStatusEnabled, query.Options.HostStatus)
+	assert.Equal(t, HostMaintenanceOut, query.Options.HostMaintenance)
+
+	_, err = ReadQuery(backend.DataQuery{JSON: []byte(`{"options":{"hostStatus":"paused"}}`)})
+	assert.EqualError(t, err, "unsupported host status: paused")
+
+	_, err = ReadQuery(backend.DataQuery{JSON: []byte(`{"options":{"hostMaintenance":"soon"}}`)})
+	assert.EqualError(t, err, "unsupported host maintenance: soon")
+
+	query, err = ReadQuery(backend.DataQuery{JSON: []byte(`{"options":{"hostTags":[{"tag":"env","value":"prod"}]}}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, []TagFilter{{Tag: "env", Value: "prod"}}, query.Options.HostTags)
+
+	_, err = ReadQuery(backend.DataQuery{JSON: []byte(`{"options":{"hostTags":[{"tag":"env"}],"hostTagsEvalType":"and"}}`)})
+	assert.EqualError(t, err, "invalid host tags: unsupported tags evaluation type: and")
+}
@@ -0,0 +1,852 @@
+package datasource
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/gtime"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+)
+
+// DataProcessingFunc transforms a single series
+type DataProcessingFunc = func(series timeseries.TimeSeries, params ...interface{}) (timeseries.TimeSeries, error)
+
+// AggDataProcessingFunc processes the whole set of series, like filtering or aggregation
+type AggDataProcessingFunc = func(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error)
+
+// AliasFunc returns new series name based on the current one
+type AliasFunc = func(alias string, params ...interface{}) (string, error)
+
+var seriesFuncMap map[string]DataProcessingFunc
+
+var filterFuncMap map[string]AggDataProcessingFunc
+
+var aggFuncMap map[string]AggDataProcessingFunc
+
+var aliasFuncMap map[string]AliasFunc
+
+// Functions adding predicted series, applied to the final series
+var predictFuncMap map[string]AggDataProcessingFunc
+
+// Functions modifying query time range
+var timeFuncMap map[string]bool
+
+// Functions applied while fetching data (trends value, consolidation, etc)
+var skippedFuncMap map[string]bool
+
+var aggValueFuncMap map[string]timeseries.AggFunc
+
+func init() {
+	seriesFuncMap = map[string]DataProcessingFunc{
+		"removeAboveValue": applyRemoveAboveValue,
+		"removeBelowValue": applyRemoveBelowValue,
+		"transformNull":    applyTransformNull,
+		"anomalyScore":     applyAnomalyScore,
+		"resample":         applyResample,
+		"cumulativeSum":    applyCumulativeSum,
+		"integral":         applyIntegral,
+	}
+
+	filterFuncMap = map[string]AggDataProcessingFunc{
+		"top":        applyTop,
+		"bottom":     applyBottom,
+		"sortSeries": applySortSeries,
+	}
+
+	aggFuncMap = map[string]AggDataProcessingFunc{
+		"sumSeries":            applySumSeries,
+		"aggregateHosts":       applyAggregateHosts,
+		"aggregateByHostGroup": applyAggregateByHostGroup,
+	}
+
+	aliasFuncMap = map[string]AliasFunc{
+		"setAlias":        applySetAlias,
+		"setAliasByRegex": applySetAliasByRegex,
+		"replaceAlias":    applyReplaceAlias,
+	}
+
+	predictFuncMap = map[string]AggDataProcessingFunc{
+		"trendline": applyTrendline,
+		"forecast":  applyForecast,
+	}
+
+	timeFuncMap = map[string]bool{
+		"timeShift": true,
+	}
+
+	skippedFuncMap = map[string]bool{
+		"trendValue":    true,
+		"consolidateBy": true,
+		"baseline":      true,
+	}
+
+	aggValueFuncMap = map[string]timeseries.AggFunc{
+		"avg":     timeseries.AggAvg,
+		"min":     timeseries.AggMin,
+		"max":     timeseries.AggMax,
+		"sum":     timeseries.AggSum,
+		"count":   timeseries.AggCount,
+		"median":  timeseries.AggMedian,
+		"first":   timeseries.AggFirst,
+		"last":    timeseries.AggLast,
+		"current": timeseries.AggLast,
+	}
+}
+
+// applyFunctions applies query functions in the same order as the frontend does:
+// transform functions first, then filter, aggregation and alias functions. Predicted series
+// (trendline, forecast) are added to the final series.
+func applyFunctions(series []*timeseries.TimeSeriesData, functions []QueryFunction) ([]*timeseries.TimeSeriesData, error) {
+	for _, f := range functions {
+		if !isFunctionSupported(f.Def.Name) {
+			return nil, errFunctionNotSupported(f.Def.Name)
+		}
+	}
+
+	for _, f := range functions {
+		if applyFunc, ok := seriesFuncMap[f.Def.Name]; ok {
+			for _, s := range series {
+				result, err := applyFunc(s.TS, f.Params...)
+				if err != nil {
+					return nil, err
+				}
+				s.TS = result
+			}
+		}
+	}
+
+	for _, f := range functions {
+		if applyFilterFunc, ok := filterFuncMap[f.Def.Name]; ok {
+			result, err := applyFilterFunc(series, f.Params...)
+			if err != nil {
+				return nil, err
+			}
+			series = result
+		}
+	}
+
+	var lastAggFunc *QueryFunction
+	for i, f := range functions {
+		if applyAggFunc, ok := aggFuncMap[f.Def.Name]; ok {
+			result, err := applyAggFunc(series, f.Params...)
+			if err != nil {
+				return nil, err
+			}
+			series = result
+			lastAggFunc = &functions[i]
+		}
+	}
+
+	// Aggregated series without own name are named after the last aggregation function
+	if lastAggFunc != nil {
+		for _, s := range series {
+			if s.Meta.Name == "" {
+				s.Meta.Name = lastAggFunc.Text
+			}
+		}
+	}
+
+	for _, f := range functions {
+		if applyAliasFunc, ok := aliasFuncMap[f.Def.Name]; ok {
+			for _, s := range series {
+				alias, err := applyAliasFunc(s.Meta.Name, f.Params...)
+				if err != nil {
+					return nil, err
+				}
+				s.Meta.Name = alias
+			}
+		}
+	}
+
+	for _, f := range functions {
+		if applyPredictFunc, ok := predictFuncMap[f.Def.Name]; ok {
+			result, err := applyPredictFunc(series, f.Params...)
+			if err != nil {
+				return nil, err
+			}
+			series = result
+		}
+	}
+
+	return series, nil
+}
+
+func hasFunction(functions []QueryFunction, name string) bool {
+	for _, f := range functions {
+		if f.Def.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isFunctionSupported(name string) bool {
+	if _, ok := seriesFuncMap[name]; ok {
+		return true
+	}
+	if _, ok := filterFuncMap[name]; ok {
+		return true
+	}
+	if _, ok := aggFuncMap[name]; ok {
+		return true
+	}
+	if _, ok := aliasFuncMap[name]; ok {
+		return true
+	}
+	if _, ok := predictFuncMap[name]; ok {
+		return true
+	}
+	return timeFuncMap[name] || skippedFuncMap[name]
+}
+
+func applySetAlias(alias string, params ...interface{}) (string, error) {
+	newAlias, err := getStringParam(params, 0, alias)
+	if err != nil {
+		return "", errParsingFunctionParam(err)
+	}
+	return newAlias, nil
+}
+
+// applySetAliasByRegex sets alias to the part of the current one matching given regex
+func applySetAliasByRegex(alias string, params ...interface{}) (string, error) {
+	pattern, err := getStringParam(params, 0, "")
+	if err != nil {
+		return "", errParsingFunctionParam(err)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", errParsingFunctionParam(err)
+	}
+
+	if match := re.FindString(alias); match != "" {
+		return match, nil
+	}
+	return alias, nil
+}
+
+// applyReplaceAlias replaces first occurrence of the pattern (or all of them if regex has `g` flag) by the new
+// alias. Pattern is either plain string or regex in /pattern/flags form, new alias can refer to capture groups ($1).
+func applyReplaceAlias(alias string, params ...interface{}) (string, error) {
+	pattern, err := getStringParam(params, 0, "/(.*)/")
+	if err != nil {
+		return "", errParsingFunctionParam(err)
+	}
+	newAlias, err := getStringParam(params, 1, "$1")
+	if err != nil {
+		return "", errParsingFunctionParam(err)
+	}
+
+	global := false
+	if matches := regexFilterPattern.FindStringSubmatch(pattern); len(matches) > 2 && strings.Contains(matches[2], "g") {
+		global = true
+		pattern = fmt.Sprintf("/%s/%s", matches[1], strings.ReplaceAll(matches[2], "g", ""))
+	}
+
+	re, err := parseRegexFilter(pattern)
+	if err != nil {
+		return "", errParsingFunctionParam(err)
+	}
+	if re == nil {
+		return strings.Replace(alias, pattern, newAlias, 1), nil
+	}
+
+	if global {
+		return re.ReplaceAllString(alias, newAlias), nil
+	}
+
+	loc := re.FindStringSubmatchIndex(alias)
+	if loc == nil {
+		return alias, nil
+	}
+	replacement := re.ExpandString(nil, newAlias, alias, loc)
+	return alias[:loc[0]] + string(replacement) + alias[loc[1]:], nil
+}
+
+// applyFunctionsPre applies functions which should be applied to the query before data fetching,
+// like timeShift(), which shifts the requested time range.
+func applyFunctionsPre(query *QueryModel) error {
+	shift, err := getTimeShift(query.Functions)
+	if err != nil {
+		return err
+	}
+
+	query.TimeRange.From = query.TimeRange.From.Add(-shift)
+	query.TimeRange.To = query.TimeRange.To.Add(-shift)
+	return nil
+}
+
+// applyFunctionsPost moves series shifted by timeShift() back to the original query time range.
+func applyFunctionsPost(series []*timeseries.TimeSeriesData, functions []QueryFunction) ([]*timeseries.TimeSeriesData, error) {
+	shift, err := getTimeShift(functions)
+	if err != nil {
+		return nil, err
+	}
+
+	if shift != 0 {
+		for _, s := range series {
+			s.TS = s.TS.ShiftTime(shift)
+		}
+	}
+	return series, nil
+}
+
+// applyTextFunctionsPost moves text history shifted by timeShift() back to the original query time range, like
+// applyFunctionsPost does for series.
+func applyTextFunctionsPost(history TextHistory, functions []QueryFunction) (TextHistory, error) {
+	shift, err := getTimeShift(functions)
+	if err != nil {
+		return nil, err
+	}
+
+	if shift != 0 {
+		for i, point := range history {
+			shifted := time.Unix(point.Clock, point.NS).Add(shift)
+			history[i].Clock = shifted.Unix()
+			history[i].NS = int64(shifted.Nanosecond())
+		}
+	}
+	return history, nil
+}
+
+// getTimeShift returns time shift set by the first timeShift() function of the query
+func getTimeShift(functions []QueryFunction) (time.Duration, error) {
+	for _, f := range functions {
+		if f.Def.Name == "timeShift" {
+			interval, err := getStringParam(f.Params, 0, "24h")
+			if err != nil {
+				return 0, errParsingFunctionParam(err)
+			}
+			shift, err := parseTimeShiftInterval(interval)
+			if err != nil {
+				return 0, errParsingFunctionParam(err)
+			}
+			return shift, nil
+		}
+	}
+	return 0, nil
+}
+
+var timeShiftPattern = regexp.MustCompile(`^([+-]?)(\d+[yMwdhms])$`)
+
+// parseTimeShiftInterval parses interval like 24h, -7d or +1M. Positive duration shifts time range
+// into the past, so both "24h" and "-24h" mean the previous day, while "+24h" is the next one.
+func parseTimeShiftInterval(interval string) (time.Duration, error) {
+	matches := timeShiftPattern.FindStringSubmatch(interval)
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("invalid time shift interval %q", interval)
+	}
+
+	shift, err := gtime.ParseInterval(matches[2])
+	if err != nil {
+		return 0, err
+	}
+
+	if matches[1] == "+" {
+		return -shift, nil
+	}
+	return shift, nil
+}
+
+// Baseline modes: add shifted series only, or also the difference (absolute or percent) from it
+const (
+	BaselineModeSeries  = "series"
+	BaselineModeDelta   = "delta"
+	BaselineModePercent = "percent"
+)
+
+type baselineParams struct {
+	period time.Duration
+	mode   string
+}
+
+// getBaseline returns params of the baseline() function or nil if query has no such function
+func getBaseline(functions []QueryFunction) (*baselineParams, error) {
+	for _, f := range functions {
+		if f.Def.Name != "baseline" {
+			continue
+		}
+		periodParam, err := getStringParam(f.Params, 0, "7d")
+		if err != nil {
+			return nil, errParsingFunctionParam(err)
+		}
+		period, err := gtime.ParseInterval(periodParam)
+		if err != nil {
+			return nil, errParsingFunctionParam(err)
+		}
+		mode, err := getStringParam(f.Params, 1, BaselineModeSeries)
+		if err != nil {
+			return nil, errParsingFunctionParam(err)
+		}
+		if mode != BaselineModeSeries && mode != BaselineModeDelta && mode != BaselineModePercent {
+			return nil, fmt.Errorf("unsupported baseline mode: %s", mode)
+		}
+		return &baselineParams{period: period, mode: mode}, nil
+	}
+	return nil, nil
+}
+
+// addBaselineSeries adds baseline series (already shifted to the query time range) after the series with
+// the same name, and the deviation from it depending on the mode.
+func addBaselineSeries(series []*timeseries.TimeSeriesData, baselineSeries []*timeseries.TimeSeriesData, mode string) []*timeseries.TimeSeriesData {
+	baselineByName := make(map[string]*timeseries.TimeSeriesData, len(baselineSeries))
+	for _, s := range baselineSeries {
+		baselineByName[s.Meta.Name] = s
+	}
+
+	result := make([]*timeseries.TimeSeriesData, 0, len(series)*2)
+	for _, s := range series {
+		result = append(result, s)
+		baseline, ok := baselineByName[s.Meta.Name]
+		if !ok {
+			continue
+		}
+
+		baseline.Meta.Name = fmt.Sprintf("%s (baseline)", s.Meta.Name)
+		result = append(result, baseline)
+
+		if mode == BaselineModeDelta {
+			deviation := deviationSeries(s, baseline, func(value, base float64) *float64 {
+				delta := value - base
+				return &delta
+			})
+			deviation.Meta.Name = fmt.Sprintf("%s (delta)", s.Meta.Name)
+			result = append(result, deviation)
+		} else if mode == BaselineModePercent {
+			deviation := deviationSeries(s, baseline, func(value, base float64) *float64 {
+				if base == 0 {
+					return nil
+				}
+				percent := (value - base) / base * 100
+				return &percent
+			})
+			deviation.Meta.Name = fmt.Sprintf("%s (deviation %%)", s.Meta.Name)
+			result = append(result, deviation)
+		}
+	}
+	return result
+}
+
+// deviationSeries returns series with deviation of the series points from the baseline points having the same timestamp
+func deviationSeries(s *timeseries.TimeSeriesData, baseline *timeseries.TimeSeriesData, deviation func(value, base float64) *float64) *timeseries.TimeSeriesData {
+	baseValues := make(map[int64]*float64, baseline.Len())
+	for _, p := range baseline.TS {
+		baseValues[p.Time.UnixNano()] = p.Value
+	}
+
+	result := timeseries.NewTimeSeriesData()
+	result.Meta.Labels = s.Meta.Labels
+	for _, p := range s.TS {
+		var value *float64
+		if base := baseValues[p.Time.UnixNano()]; base != nil && p.Value != nil {
+			value = deviation(*p.Value, *base)
+		}
+		result.Add(timeseries.TimePoint{Time: p.Time, Value: value})
+	}
+	return result
+}
+
+// downsampleSeries consolidates series having more points than requested by the query, using
+// query interval and consolidateBy function.
+func downsampleSeries(series []*timeseries.TimeSeriesData, query *QueryModel, consolidateBy string) ([]*timeseries.TimeSeriesData, error) {
+	if query.MaxDataPoints <= 0 || query.Interval <= 0 {
+		return series, nil
+	}
+
+	aggFunc, ok := aggValueFuncMap[consolidateBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported consolidateBy function: %s", consolidateBy)
+	}
+
+	for _, s := range series {
+		if int64(s.Len()) > query.MaxDataPoints {
+			s.TS = s.TS.GroupByIn(query.Interval, aggFunc, query.Location)
+		}
+	}
+	return series, nil
+}
+
+func errFunctionNotSupported(name string) error {
+	return fmt.Errorf("function not supported: %s", name)
+}
+
+func errParsingFunctionParam(err error) error {
+	return fmt.Errorf("failed to parse function param: %s", err)
+}
+
+func applyRemoveAboveValue(series timeseries.TimeSeries, params ...interface{}) (timeseries.TimeSeries, error) {
+	threshold, err := getFloatParam(params, 0, 0)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	return series.Transform(timeseries.TransformRemoveAboveValue(threshold)), nil
+}
+
+func applyRemoveBelowValue(series timeseries.TimeSeries, params ...interface{}) (timeseries.TimeSeries, error) {
+	threshold, err := getFloatParam(params, 0, 0)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	return series.Transform(timeseries.TransformRemoveBelowValue(threshold)), nil
+}
+
+func applyTransformNull(series timeseries.TimeSeries, params ...interface{}) (timeseries.TimeSeries, error) {
+	nullValue, err := getFloatParam(params, 0, 0)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	return series.Transform(timeseries.TransformNull(nullValue)), nil
+}
+
+// applyResample aligns series to the shared timestamps with given interval, so points of different
+// series can be matched by time.
+func applyResample(series timeseries.TimeSeries, params ...interface{}) (timeseries.TimeSeries, error) {
+	intervalParam, err := getStringParam(params, 0, "1m")
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	interval, err := gtime.ParseInterval(intervalParam)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("resample interval should be positive, got %s", intervalParam)
+	}
+	method, err := getStringParam(params, 1, timeseries.ResampleLinear)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	if method != timeseries.ResampleLinear && method != timeseries.ResampleLast {
+		return nil, fmt.Errorf("unsupported resample method: %s", method)
+	}
+
+	return series.Resample(interval, method), nil
+}
+
+// applyAnomalyScore replaces series values by the outlier score relative to the preceding window of points,
+// using rolling z-score or MAD-based (modified z-score) method.
+func applyAnomalyScore(series timeseries.TimeSeries, params ...interface{}) (timeseries.TimeSeries, error) {
+	window, err := getIntParam(params, 0, 20)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	if window < 2 {
+		return nil, fmt.Errorf("anomaly score window should be at least 2 points, got %d", window)
+	}
+	method, err := getStringParam(params, 1, "zscore")
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+
+	switch method {
+	case "zscore":
+		return series.RollingZScore(window), nil
+	case "mad":
+		return series.RollingMADScore(window), nil
+	default:
+		return nil, fmt.Errorf("unsupported anomaly score method: %s", method)
+	}
+}
+
+func applyCumulativeSum(series timeseries.TimeSeries, params ...interface{}) (timeseries.TimeSeries, error) {
+	return series.Transform(timeseries.TransformCumulativeSum()), nil
+}
+
+func applyIntegral(series timeseries.TimeSeries, params ...interface{}) (timeseries.TimeSeries, error) {
+	return series.Transform(timeseries.TransformIntegral()), nil
+}
+
+func applyTop(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	return limitSeries(series, false, params...)
+}
+
+func applyBottom(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	return limitSeries(series, true, params...)
+}
+
+// limitSeries returns n series with the highest (or lowest if bottom is set) aggregated values.
+func limitSeries(series []*timeseries.TimeSeriesData, bottom bool, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	n, err := getIntParam(params, 0, 5)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	aggFunc, err := getAggFuncParam(params, 1, "avg")
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[*timeseries.TimeSeriesData]*float64, len(series))
+	for _, s := range series {
+		values[s] = aggFunc(s.TS)
+	}
+
+	sorted := make([]*timeseries.TimeSeriesData, len(series))
+	copy(sorted, series)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lessAggValues(values[sorted[i]], values[sorted[j]])
+	})
+
+	if n < 0 {
+		n = 0
+	}
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	if bottom {
+		return sorted[:n], nil
+	}
+	return sorted[len(sorted)-n:], nil
+}
+
+// applySortSeries sorts series by name (case insensitive) or by aggregated value in given direction (asc or desc).
+func applySortSeries(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	direction, err := getStringParam(params, 0, "asc")
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	if direction != "asc" && direction != "desc" {
+		return nil, fmt.Errorf("unsupported sort direction: %s", direction)
+	}
+	sortBy, err := getStringParam(params, 1, "name")
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+
+	var less func(a, b *timeseries.TimeSeriesData) bool
+	if sortBy == "name" {
+		less = func(a, b *timeseries.TimeSeriesData) bool {
+			return strings.ToLower(a.Meta.Name) < strings.ToLower(b.Meta.Name)
+		}
+	} else {
+		aggFunc, ok := aggValueFuncMap[sortBy]
+		if !ok {
+			return nil, fmt.Errorf("unsupported aggregation function: %s", sortBy)
+		}
+		values := make(map[*timeseries.TimeSeriesData]*float64, len(series))
+		for _, s := range series {
+			values[s] = aggFunc(s.TS)
+		}
+		less = func(a, b *timeseries.TimeSeriesData) bool {
+			return lessAggValues(values[a], values[b])
+		}
+	}
+
+	// Keep series with equal values ordered by name, so result is stable across refreshes
+	sort.SliceStable(series, func(i, j int) bool {
+		a, b := series[i], series[j]
+		if direction == "desc" {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return series[i].Meta.Name < series[j].Meta.Name
+	})
+	return series, nil
+}
+
+// lessAggValues compares aggregated values, series without values are treated as the lowest ones.
+func lessAggValues(a, b *float64) bool {
+	if a == nil {
+		return b != nil
+	}
+	if b == nil {
+		return false
+	}
+	return *a < *b
+}
+
+func applySumSeries(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	if len(series) == 0 {
+		return series, nil
+	}
+
+	tsList := make([]timeseries.TimeSeries, 0, len(series))
+	for _, s := range series {
+		tsList = append(tsList, s.TS)
+	}
+
+	sum := timeseries.NewTimeSeriesData()
+	sum.TS = timeseries.SumSeries(tsList)
+	return []*timeseries.TimeSeriesData{sum}, nil
+}
+
+// applyAggregateHosts aggregates the same item across all hosts into a single series per item.
+func applyAggregateHosts(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	aggFunc, err := getAggFuncParam(params, 0, "sum")
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregateSeriesByKey(series, aggFunc, func(s *timeseries.TimeSeriesData) []string {
+		return []string{s.Meta.Labels["item"]}
+	}), nil
+}
+
+// applyAggregateByHostGroup aggregates the same item across hosts of each host group. Series of the host
+// belonging to several groups are included into each of them.
+func applyAggregateByHostGroup(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	aggFunc, err := getAggFuncParam(params, 0, "sum")
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregateSeriesByKey(series, aggFunc, func(s *timeseries.TimeSeriesData) []string {
+		keys := make([]string, 0, len(s.Meta.HostGroups))
+		for _, group := range s.Meta.HostGroups {
+			keys = append(keys, fmt.Sprintf("%s: %s", group, s.Meta.Labels["item"]))
+		}
+		return keys
+	}), nil
+}
+
+// aggregateSeriesByKey groups series by the keys returned by getKeys and aggregates each group into
+// a series named after the key. Groups are returned in the order of first appearance.
+func aggregateSeriesByKey(series []*timeseries.TimeSeriesData, aggFunc timeseries.AggFunc, getKeys func(s *timeseries.TimeSeriesData) []string) []*timeseries.TimeSeriesData {
+	var keys []string
+	groups := make(map[string][]timeseries.TimeSeries)
+	for _, s := range series {
+		for _, key := range getKeys(s) {
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], s.TS)
+		}
+	}
+
+	result := make([]*timeseries.TimeSeriesData, 0, len(keys))
+	for _, key := range keys {
+		aggregated := timeseries.NewTimeSeriesData()
+		aggregated.TS = timeseries.AggregateSeries(groups[key], aggFunc)
+		aggregated.Meta.Name = key
+		result = append(result, aggregated)
+	}
+	return result
+}
+
+// Max number of points in the forecast series
+const maxForecastPoints = 1000
+
+// applyTrendline adds linear regression line of each series as a companion series.
+func applyTrendline(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	return addCompanionSeries(series, "trend", func(s *timeseries.TimeSeriesData) timeseries.TimeSeries {
+		return s.TS.Trendline()
+	}), nil
+}
+
+// applyForecast adds series predicted to the given horizon after the last point, using linear regression
+// or Holt-Winters method (with given season).
+func applyForecast(series []*timeseries.TimeSeriesData, params ...interface{}) ([]*timeseries.TimeSeriesData, error) {
+	horizonParam, err := getStringParam(params, 0, "1d")
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	horizon, err := gtime.ParseInterval(horizonParam)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	method, err := getStringParam(params, 1, "linear")
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	seasonParam, err := getStringParam(params, 2, "1d")
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	season, err := gtime.ParseInterval(seasonParam)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	if method != "linear" && method != "holtWinters" {
+		return nil, fmt.Errorf("unsupported forecast method: %s", method)
+	}
+
+	return addCompanionSeries(series, "forecast", func(s *timeseries.TimeSeriesData) timeseries.TimeSeries {
+		interval := s.TS.DetectInterval()
+		step := interval
+		if step > 0 && horizon/step > maxForecastPoints {
+			step = horizon / maxForecastPoints
+		}
+		if method == "holtWinters" {
+			return s.TS.ForecastHoltWinters(horizon, step, interval, season)
+		}
+		return s.TS.ForecastLinear(horizon, step)
+	}), nil
+}
+
+// addCompanionSeries adds series built by the getTS after each of given series. Companion series are named
+// like "name (suffix)", empty ones are skipped.
+func addCompanionSeries(series []*timeseries.TimeSeriesData, suffix string, getTS func(s *timeseries.TimeSeriesData) timeseries.TimeSeries) []*timeseries.TimeSeriesData {
+	result := make([]*timeseries.TimeSeriesData, 0, 2*len(series))
+	for _, s := range series {
+		result = append(result, s)
+		ts := getTS(s)
+		if ts.Len() == 0 {
+			continue
+		}
+		companion := timeseries.NewTimeSeriesData()
+		companion.TS = ts
+		companion.Meta.Name = fmt.Sprintf("%s (%s)", s.Meta.Name, suffix)
+		companion.Meta.Labels = s.Meta.Labels
+		companion.Meta.FieldConfig = s.Meta.FieldConfig
+		result = append(result, companion)
+	}
+	return result
+}
+
+func getAggFuncParam(params []interface{}, index int, defaultValue string) (timeseries.AggFunc, error) {
+	aggFuncName, err := getStringParam(params, index, defaultValue)
+	if err != nil {
+		return nil, errParsingFunctionParam(err)
+	}
+	aggFunc, ok := aggValueFuncMap[aggFuncName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported aggregation function: %s", aggFuncName)
+	}
+	return aggFunc, nil
+}
+
+func getStringParam(params []interface{}, index int, defaultValue string) (string, error) {
+	if index >= len(params) || params[index] == nil {
+		return defaultValue, nil
+	}
+
+	switch p := params[index].(type) {
+	case string:
+		return p, nil
+	case float64:
+		return strconv.FormatFloat(p, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unexpected param type %T", p)
+	}
+}
+
+func getFloatParam(params []interface{}, index int, defaultValue float64) (float64, error) {
+	if index >= len(params) || params[index] == nil {
+		return defaultValue, nil
+	}
+
+	switch p := params[index].(type) {
+	case float64:
+		return p, nil
+	case string:
+		return strconv.ParseFloat(p, 64)
+	default:
+		return 0, fmt.Errorf("unexpected param type %T", p)
+	}
+}
+
+func getIntParam(params []interface{}, index int, defaultValue int) (int, error) {
+	value, err := getFloatParam(params, index, float64(defaultValue))
+	if err != nil {
+		return 0, err
+	}
+	return int(value), nil
+}
@@ -0,0 +1,524 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockSeries(name string, values ...float64) *timeseries.TimeSeriesData {
+	s := timeseries.NewTimeSeriesData()
+	s.Meta.Name = name
+	for i, v := range values {
+		value := v
+		s.Add(timeseries.TimePoint{Time: time.Unix(int64(i*60), 0), Value: &value})
+	}
+	return s
+}
+
+func mockFunction(name string, params ...interface{}) QueryFunction {
+	return QueryFunction{
+		Def:    QueryFunctionDef{Name: name},
+		Params: params,
+		Text:   name,
+	}
+}
+
+func seriesNames(series []*timeseries.TimeSeriesData) []string {
+	names := make([]string, 0, len(series))
+	for _, s := range series {
+		names = append(names, s.Meta.Name)
+	}
+	return names
+}
+
+func TestApplyTopBottom(t *testing.T) {
+	tests := []struct {
+		name     string
+		function QueryFunction
+		expected []string
+	}{
+		{
+			name:     "top by avg",
+			function: mockFunction("top", float64(2), "avg"),
+			expected: []string{"b", "c"},
+		},
+		{
+			name:     "bottom by max",
+			function: mockFunction("bottom", float64(1), "max"),
+			expected: []string{"a"},
+		},
+		{
+			name:     "top by current",
+			function: mockFunction("top", "1", "current"),
+			expected: []string{"b"},
+		},
+		{
+			name:     "top more than series count",
+			function: mockFunction("top", float64(10), "avg"),
+			expected: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := []*timeseries.TimeSeriesData{
+				mockSeries("a", 1, 2, 3),
+				mockSeries("b", 5, 5, 5),
+				mockSeries("c", 10, 5, 1),
+			}
+			result, err := applyFunctions(series, []QueryFunction{tt.function})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, seriesNames(result))
+		})
+	}
+}
+
+func TestApplySumSeries(t *testing.T) {
+	series := []*timeseries.TimeSeriesData{
+		mockSeries("a", 1, 2, 3),
+		mockSeries("b", 5, 5, 5),
+	}
+	result, err := applyFunctions(series, []QueryFunction{mockFunction("sumSeries")})
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "sumSeries", result[0].Meta.Name)
+	for i, expected := range []float64{6, 7, 8} {
+		assert.Equal(t, expected, *result[0].TS[i].Value)
+	}
+}
+
+func TestApplyAggregateHosts(t *testing.T) {
+	mockHostSeries := func(host string, item string, groups []string, values ...float64) *timeseries.TimeSeriesData {
+		s := mockSeries(host+": "+item, values...)
+		s.Meta.Labels = data.Labels{"host": host, "item": item}
+		s.Meta.HostGroups = groups
+		return s
+	}
+	newSeries := func() []*timeseries.TimeSeriesData {
+		return []*timeseries.TimeSeriesData{
+			mockHostSeries("web01", "Connections", []string{"DC1"}, 1, 2, 3),
+			mockHostSeries("web02", "Connections", []string{"DC1"}, 3, 4, 5),
+			mockHostSeries("web03", "Connections", []string{"DC2"}, 10, 10, 10),
+			mockHostSeries("web01", "CPU load", []string{"DC1"}, 1, 1, 1),
+		}
+	}
+
+	result, err := applyFunctions(newSeries(), []QueryFunction{mockFunction("aggregateHosts", "sum")})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Connections", "CPU load"}, seriesNames(result))
+	assert.Equal(t, float64(14), *result[0].TS[0].Value)
+
+	result, err = applyFunctions(newSeries(), []QueryFunction{mockFunction("aggregateByHostGroup", "avg")})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"DC1: Connections", "DC2: Connections", "DC1: CPU load"}, seriesNames(result))
+	assert.Equal(t, float64(3), *result[0].TS[1].Value)
+	assert.Equal(t, float64(10), *result[1].TS[1].Value)
+
+	_, err = applyFunctions(newSeries(), []QueryFunction{mockFunction("aggregateHosts", "unknown")})
+	assert.NotNil(t, err)
+}
+
+func TestApplyTrendlineAndForecast(t *testing.T) {
+	result, err := applyFunctions([]*timeseries.TimeSeriesData{mockSeries("a", 1, 3, 5, 7)}, []QueryFunction{mockFunction("trendline")})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "a (trend)"}, seriesNames(result))
+	assert.InDelta(t, 1, *result[1].TS[0].Value, 1e-9)
+	assert.InDelta(t, 7, *result[1].TS[3].Value, 1e-9)
+
+	result, err = applyFunctions([]*timeseries.TimeSeriesData{mockSeries("a", 1, 3, 5, 7)}, []QueryFunction{mockFunction("forecast", "3m")})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "a (forecast)"}, seriesNames(result))
+	forecast := result[1].TS
+	assert.Equal(t, 3, forecast.Len())
+	assert.Equal(t, time.Unix(240, 0), forecast[0].Time)
+	assert.InDelta(t, 9, *forecast[0].Value, 1e-9)
+	assert.InDelta(t, 13, *forecast[2].Value, 1e-9)
+
+	// Seasonal series: 0, 10, 0, 10, ... with 2m season
+	seasonal := mockSeries("s", 0, 10, 0, 10, 0, 10, 0, 10)
+	result, err = applyFunctions([]*timeseries.TimeSeriesData{seasonal}, []QueryFunction{mockFunction("forecast", "2m", "holtWinters", "2m")})
+	assert.Nil(t, err)
+	forecast = result[1].TS
+	assert.Equal(t, 2, forecast.Len())
+	assert.Less(t, *forecast[0].Value, *forecast[1].Value)
+
+	_, err = applyFunctions([]*timeseries.TimeSeriesData{mockSeries("a", 1, 2)}, []QueryFunction{mockFunction("forecast", "1d", "unknown")})
+	assert.NotNil(t, err)
+}
+
+func TestAddBaselineSeries(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected []string
+	}{
+		{mode: BaselineModeSeries, expected: []string{"a", "a (baseline)", "b"}},
+		{mode: BaselineModeDelta, expected: []string{"a", "a (baseline)", "a (delta)", "b"}},
+		{mode: BaselineModePercent, expected: []string{"a", "a (baseline)", "a (deviation %)", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			series := []*timeseries.TimeSeriesData{mockSeries("a", 10, 15, 20), mockSeries("b", 1, 1, 1)}
+			baseline := []*timeseries.TimeSeriesData{mockSeries("a", 10, 10, 0)}
+
+			result := addBaselineSeries(series, baseline, tt.mode)
+			assert.Equal(t, tt.expected, seriesNames(result))
+			if tt.mode == BaselineModeDelta {
+				assert.Equal(t, float64(5), *result[2].TS[1].Value)
+			}
+			if tt.mode == BaselineModePercent {
+				assert.Equal(t, float64(50), *result[2].TS[1].Value)
+				assert.Nil(t, result[2].TS[2].Value)
+			}
+		})
+	}
+}
+
+func TestGetBaseline(t *testing.T) {
+	baseline, err := getBaseline([]QueryFunction{mockFunction("baseline", "1d", "percent")})
+	assert.Nil(t, err)
+	assert.Equal(t, &baselineParams{period: 24 * time.Hour, mode: BaselineModePercent}, baseline)
+
+	baseline, err = getBaseline([]QueryFunction{mockFunction("setAlias", "a")})
+	assert.Nil(t, err)
+	assert.Nil(t, baseline)
+
+	_, err = getBaseline([]QueryFunction{mockFunction("baseline", "1d", "ratio")})
+	assert.NotNil(t, err)
+}
+
+func TestApplyAnomalyScore(t *testing.T) {
+	tests := []struct {
+		method   string
+		expected []*float64
+	}{
+		{method: "zscore", expected: []*float64{nil, nil, floatPtr(0), floatPtr(-1.2247), floatPtr(23.2702)}},
+		{method: "mad", expected: []*float64{nil, nil, floatPtr(0), floatPtr(-0.6745), floatPtr(12.8155)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			series := []*timeseries.TimeSeriesData{mockSeries("a", 10, 12, 11, 10, 30)}
+			result, err := applyFunctions(series, []QueryFunction{mockFunction("anomalyScore", float64(3), tt.method)})
+			assert.Nil(t, err)
+			for i, expected := range tt.expected {
+				if expected == nil {
+					assert.Nil(t, result[0].TS[i].Value)
+				} else {
+					assert.InDelta(t, *expected, *result[0].TS[i].Value, 1e-4)
+				}
+			}
+		})
+	}
+
+	_, err := applyFunctions([]*timeseries.TimeSeriesData{mockSeries("a", 1)}, []QueryFunction{mockFunction("anomalyScore", float64(1))})
+	assert.NotNil(t, err)
+}
+
+func TestApplyResample(t *testing.T) {
+	newSeries := func() *timeseries.TimeSeriesData {
+		s := timeseries.NewTimeSeriesData()
+		s.Meta.Name = "a"
+		for _, p := range [][2]int64{{10, 1}, {70, 7}, {130, 1}} {
+			value := float64(p[1])
+			s.Add(timeseries.TimePoint{Time: time.Unix(p[0], 0), Value: &value})
+		}
+		return s
+	}
+
+	tests := []struct {
+		method   string
+		expected []*float64
+	}{
+		{method: "linear", expected: []*float64{nil, floatPtr(6), floatPtr(2)}},
+		{method: "last", expected: []*float64{nil, floatPtr(1), floatPtr(7)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			result, err := applyFunctions([]*timeseries.TimeSeriesData{newSeries()}, []QueryFunction{mockFunction("resample", "1m", tt.method)})
+			assert.Nil(t, err)
+			ts := result[0].TS
+			assert.Equal(t, len(tt.expected), ts.Len())
+			for i, expected := range tt.expected {
+				assert.Equal(t, time.Unix(int64(i*60), 0), ts[i].Time)
+				assert.Equal(t, expected, ts[i].Value)
+			}
+		})
+	}
+
+	_, err := applyFunctions([]*timeseries.TimeSeriesData{newSeries()}, []QueryFunction{mockFunction("resample", "1m", "cubic")})
+	assert.NotNil(t, err)
+}
+
+func TestApplyResampleEpochAligned(t *testing.T) {
+	newSeries := func(name string, timestamps ...int64) *timeseries.TimeSeriesData {
+		s := timeseries.NewTimeSeriesData()
+		s.Meta.Name = name
+		for _, ts := range timestamps {
+			value := float64(ts)
+			s.Add(timeseries.TimePoint{Time: time.Unix(ts, 0), Value: &value})
+		}
+		return s
+	}
+
+	// 7m doesn't divide a day, so the grid counted from the zero time is shifted from the epoch one
+	series := []*timeseries.TimeSeriesData{newSeries("a", 1000, 2000), newSeries("b", 1300, 2500)}
+	result, err := applyFunctions(series, []QueryFunction{mockFunction("resample", "7m", "last")})
+	assert.Nil(t, err)
+
+	expected := [][]int64{{840, 1260, 1680}, {1260, 1680, 2100}}
+	for i, s := range result {
+		timestamps := make([]int64, 0, s.TS.Len())
+		for _, p := range s.TS {
+			timestamps = append(timestamps, p.Time.Unix())
+		}
+		assert.Equal(t, expected[i], timestamps)
+	}
+}
+
+func TestApplyCumulativeSumAndIntegral(t *testing.T) {
+	newSeries := func() *timeseries.TimeSeriesData {
+		s := mockSeries("a", 1, 2, 0, 3)
+		s.TS[2].Value = nil
+		return s
+	}
+
+	result, err := applyFunctions([]*timeseries.TimeSeriesData{newSeries()}, []QueryFunction{mockFunction("cumulativeSum")})
+	assert.Nil(t, err)
+	assert.Equal(t, []*float64{floatPtr(1), floatPtr(3), nil, floatPtr(6)}, pointValues(result[0].TS))
+
+	result, err = applyFunctions([]*timeseries.TimeSeriesData{newSeries()}, []QueryFunction{mockFunction("integral")})
+	assert.Nil(t, err)
+	assert.Equal(t, []*float64{floatPtr(0), floatPtr(120), nil, floatPtr(480)}, pointValues(result[0].TS))
+}
+
+func pointValues(ts timeseries.TimeSeries) []*float64 {
+	values := make([]*float64, 0, len(ts))
+	for _, p := range ts {
+		values = append(values, p.Value)
+	}
+	return values
+}
+
+func TestApplyFunctionsNotSupported(t *testing.T) {
+	_, err := applyFunctions([]*timeseries.TimeSeriesData{}, []QueryFunction{mockFunction("unknownFunction")})
+	assert.Equal(t, errFunctionNotSupported("unknownFunction"), err)
+}
+
+func TestParseTimeShiftInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		expected time.Duration
+		err      bool
+	}{
+		{interval: "24h", expected: 24 * time.Hour},
+		{interval: "-24h", expected: 24 * time.Hour},
+		{interval: "+1h", expected: -time.Hour},
+		{interval: "30m", expected: 30 * time.Minute},
+		{interval: "1x", err: true},
+		{interval: "", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.interval, func(t *testing.T) {
+			shift, err := parseTimeShiftInterval(tt.interval)
+			if tt.err {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.expected, shift)
+			}
+		})
+	}
+}
+
+func TestApplyTimeShift(t *testing.T) {
+	from := time.Unix(86400*10, 0)
+	to := from.Add(time.Hour)
+	query := &QueryModel{
+		Functions: []QueryFunction{mockFunction("timeShift", "1d")},
+	}
+	query.TimeRange.From = from
+	query.TimeRange.To = to
+
+	err := applyFunctionsPre(query)
+	assert.Nil(t, err)
+	assert.Equal(t, from.Add(-24*time.Hour), query.TimeRange.From)
+	assert.Equal(t, to.Add(-24*time.Hour), query.TimeRange.To)
+
+	series := []*timeseries.TimeSeriesData{mockSeries("a", 1)}
+	series[0].TS[0].Time = query.TimeRange.From
+	series, err = applyFunctionsPost(series, query.Functions)
+	assert.Nil(t, err)
+	assert.Equal(t, from, series[0].TS[0].Time)
+
+	shifted := query.TimeRange.From.Add(500 * time.Millisecond)
+	history := TextHistory{{ItemID: "1", Clock: shifted.Unix(), NS: int64(shifted.Nanosecond()), Value: "started"}}
+	history, err = applyTextFunctionsPost(history, query.Functions)
+	assert.Nil(t, err)
+	assert.Equal(t, from.Add(500*time.Millisecond), time.Unix(history[0].Clock, history[0].NS))
+}
+
+func TestApplyAliasFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		function QueryFunction
+		expected string
+	}{
+		{
+			name:     "setAlias",
+			function: mockFunction("setAlias", "new alias"),
+			expected: "new alias",
+		},
+		{
+			name:     "setAliasByRegex",
+			function: mockFunction("setAliasByRegex", `CPU \w+`),
+			expected: "CPU user",
+		},
+		{
+			name:     "replaceAlias with regex",
+			function: mockFunction("replaceAlias", `/(.*): CPU (\w+) time/`, "$1 $2"),
+			expected: "backend01 user",
+		},
+		{
+			name:     "replaceAlias with string",
+			function: mockFunction("replaceAlias", "backend01", "frontend01"),
+			expected: "frontend01: CPU user time",
+		},
+		{
+			name:     "replaceAlias replaces first match only",
+			function: mockFunction("replaceAlias", "/e/", "E"),
+			expected: "backEnd01: CPU user time",
+		},
+		{
+			name:     "replaceAlias with global flag",
+			function: mockFunction("replaceAlias", "/e/g", "E"),
+			expected: "backEnd01: CPU usEr timE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := []*timeseries.TimeSeriesData{mockSeries("backend01: CPU user time", 1)}
+			result, err := applyFunctions(series, []QueryFunction{tt.function})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, result[0].Meta.Name)
+		})
+	}
+}
+
+func TestApplyValueFilterFunctions(t *testing.T) {
+	tests := []struct {
+		name      string
+		functions []QueryFunction
+		expected  []*float64
+	}{
+		{
+			name:      "removeAboveValue",
+			functions: []QueryFunction{mockFunction("removeAboveValue", float64(5))},
+			expected:  []*float64{floatPtr(1), floatPtr(5), nil},
+		},
+		{
+			name:      "removeBelowValue",
+			functions: []QueryFunction{mockFunction("removeBelowValue", "5")},
+			expected:  []*float64{nil, floatPtr(5), floatPtr(10)},
+		},
+		{
+			name: "transformNull after removeAboveValue",
+			functions: []QueryFunction{
+				mockFunction("removeAboveValue", float64(5)),
+				mockFunction("transformNull", float64(-1)),
+			},
+			expected: []*float64{floatPtr(1), floatPtr(5), floatPtr(-1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := []*timeseries.TimeSeriesData{mockSeries("a", 1, 5, 10)}
+			result, err := applyFunctions(series, tt.functions)
+			assert.Nil(t, err)
+			for i, p := range result[0].TS {
+				assert.Equal(t, tt.expected[i], p.Value)
+			}
+		})
+	}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func TestApplySortSeries(t *testing.T) {
+	tests := []struct {
+		name     string
+		function QueryFunction
+		expected []string
+	}{
+		{
+			name:     "sort by name asc",
+			function: mockFunction("sortSeries", "asc"),
+			expected: []string{"a", "B", "c"},
+		},
+		{
+			name:     "sort by name desc",
+			function: mockFunction("sortSeries", "desc"),
+			expected: []string{"c", "B", "a"},
+		},
+		{
+			name:     "sort by max desc",
+			function: mockFunction("sortSeries", "desc", "max"),
+			expected: []string{"c", "B", "a"},
+		},
+		{
+			name:     "sort by current asc",
+			function: mockFunction("sortSeries", "asc", "current"),
+			expected: []string{"c", "a", "B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := []*timeseries.TimeSeriesData{
+				mockSeries("c", 10, 5, 1),
+				mockSeries("a", 1, 2, 3),
+				mockSeries("B", 5, 5, 5),
+			}
+			result, err := applyFunctions(series, []QueryFunction{tt.function})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, seriesNames(result))
+		})
+	}
+}
+
+func TestDownsampleSeries(t *testing.T) {
+	query := &QueryModel{MaxDataPoints: 2, Interval: 2 * time.Minute}
+
+	series := []*timeseries.TimeSeriesData{mockSeries("a", 1, 3, 10, 20)}
+	result, err := downsampleSeries(series, query, "max")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result[0].Len())
+	assert.Equal(t, float64(3), *result[0].TS[0].Value)
+	assert.Equal(t, float64(20), *result[0].TS[1].Value)
+
+	series = []*timeseries.TimeSeriesData{mockSeries("a", 1, 3)}
+	result, err = downsampleSeries(series, query, "avg")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result[0].Len())
+}
+
+func TestTrendToHistory(t *testing.T) {
+	trend := Trend{{ItemID: "1", Clock: 3600, Num: "60", ValueMin: "1", ValueAvg: "2", ValueMax: "5"}}
+
+	assert.Equal(t, float64(1), trend.ToHistory("min")[0].Value)
+	assert.Equal(t, float64(5), trend.ToHistory("max")[0].Value)
+	assert.Equal(t, float64(2), trend.ToHistory("avg")[0].Value)
+	assert.Equal(t, float64(120), trend.ToHistory("sum")[0].Value)
+	assert.Equal(t, float64(60), trend.ToHistory("count")[0].Value)
+}
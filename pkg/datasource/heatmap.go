@@ -0,0 +1,98 @@
+package datasource
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// convertSeriesToHeatmap counts values of all series in the buckets by time (query interval) and value, and returns
+// frame in the time series buckets format: time field and a count field per bucket named by its upper bound.
+func convertSeriesToHeatmap(series []*timeseries.TimeSeriesData, query *QueryModel) *data.Frame {
+	interval := query.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	bounds := query.Options.HeatmapBuckets
+	if len(bounds) == 0 {
+		bounds = getHeatmapBounds(series, query.Options.HeatmapBucketCount)
+	} else {
+		bounds = append([]float64{}, bounds...)
+		sort.Float64s(bounds)
+		bounds = append(bounds, math.Inf(1))
+	}
+
+	counts := make(map[int64][]float64)
+	var timestamps []time.Time
+	for _, s := range series {
+		for _, p := range s.TS {
+			if p.Value == nil {
+				continue
+			}
+			bucket := sort.SearchFloat64s(bounds, *p.Value)
+			if bucket >= len(bounds) {
+				continue
+			}
+			t := p.Time.Truncate(interval)
+			row, ok := counts[t.UnixNano()]
+			if !ok {
+				row = make([]float64, len(bounds))
+				counts[t.UnixNano()] = row
+				timestamps = append(timestamps, t)
+			}
+			row[bucket]++
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+
+	frame := data.NewFrame("Heatmap", data.NewField("time", nil, timestamps))
+	for i, bound := range bounds {
+		values := make([]float64, len(timestamps))
+		for j, t := range timestamps {
+			values[j] = counts[t.UnixNano()][i]
+		}
+		frame.Fields = append(frame.Fields, data.NewField(formatBucketBound(bound), nil, values))
+	}
+	return frame
+}
+
+// getHeatmapBounds returns upper bounds of the bucketCount buckets of equal size between min and max value of the series.
+func getHeatmapBounds(series []*timeseries.TimeSeriesData, bucketCount int) []float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, p := range s.TS {
+			if p.Value != nil {
+				min = math.Min(min, *p.Value)
+				max = math.Max(max, *p.Value)
+			}
+		}
+	}
+
+	if math.IsInf(min, 1) {
+		return []float64{}
+	}
+	if min == max || bucketCount <= 1 {
+		return []float64{max}
+	}
+
+	step := (max - min) / float64(bucketCount)
+	bounds := make([]float64, 0, bucketCount)
+	for i := 1; i < bucketCount; i++ {
+		bounds = append(bounds, min+step*float64(i))
+	}
+	return append(bounds, max)
+}
+
+func formatBucketBound(bound float64) string {
+	if math.IsInf(bound, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(bound, 'f', -1, 64)
+}
@@ -0,0 +1,37 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertSeriesToHeatmap(t *testing.T) {
+	series := []*timeseries.TimeSeriesData{
+		mockSeries("a", 5, 15, 25),
+		mockSeries("b", 10, 40, 0),
+	}
+	series[1].TS[2].Value = nil
+
+	query := &QueryModel{
+		Interval: 2 * time.Minute,
+		Options:  QueryOptions{HeatmapBuckets: []float64{20, 10}},
+	}
+	frame := convertSeriesToHeatmap(series, query)
+	assert.Equal(t, []string{"time", "10", "20", "+Inf"}, fieldNames(frame))
+	assert.Equal(t, 2, frame.Rows())
+	assert.Equal(t, time.Unix(120, 0), frame.Fields[0].At(1))
+	// First 2 minutes: 5, 15, 10, 40
+	assert.Equal(t, float64(2), frame.Fields[1].At(0))
+	assert.Equal(t, float64(1), frame.Fields[2].At(0))
+	assert.Equal(t, float64(1), frame.Fields[3].At(0))
+	// Next interval: 25
+	assert.Equal(t, float64(1), frame.Fields[3].At(1))
+
+	query.Options = QueryOptions{HeatmapBucketCount: 4}
+	frame = convertSeriesToHeatmap(series, query)
+	assert.Equal(t, []string{"time", "13.75", "22.5", "31.25", "40"}, fieldNames(frame))
+	assert.Equal(t, float64(2), frame.Fields[1].At(0))
+}
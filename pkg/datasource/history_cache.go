@@ -0,0 +1,168 @@
+package datasource
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/net/context"
+)
+
+// historyCacheEntry is history of the items fetched for the time range. Entries are not modified after
+// written to cache, new entry is created on each update.
+type historyCacheEntry struct {
+	from    time.Time
+	to      time.Time
+	history History
+	written time.Time
+	// seq is an order of the writes, the least recently written entries are evicted first
+	seq uint64
+}
+
+// size returns number of the points counted against the cache limit, empty entries are counted as one point
+func (e *historyCacheEntry) size() int {
+	if len(e.history) == 0 {
+		return 1
+	}
+	return len(e.history)
+}
+
+// historyCache keeps history of the items fetched by the previous queries. Total number of the cached points
+// is limited, the least recently written entries are evicted first. Values may be written to the history with
+// delay (from proxies or by the history syncer), so the overlap (last part of the cached range) is requested
+// again on the next query.
+type historyCache struct {
+	mu        sync.Mutex
+	entries   map[string]*historyCacheEntry
+	points    int
+	maxPoints int
+	seq       uint64
+	ttl       time.Duration
+	overlap   time.Duration
+}
+
+// newHistoryCache returns cache of the history if it's enabled in the settings or nil otherwise
+func newHistoryCache(settings *ZabbixDatasourceSettings) *historyCache {
+	if !settings.HistoryCache {
+		return nil
+	}
+	return &historyCache{
+		entries:   map[string]*historyCacheEntry{},
+		maxPoints: settings.HistoryCacheMaxPoints,
+		ttl:       settings.CacheTTL,
+		overlap:   settings.HistoryCacheOverlap,
+	}
+}
+
+// get returns history of the items unless it's expired
+func (c *historyCache) get(key string) (*historyCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.ttl > 0 && time.Since(entry.written) > c.ttl {
+		c.remove(key)
+		return nil, false
+	}
+	return entry, true
+}
+
+// set writes history of the items, evicting the oldest entries if the points limit is exceeded. History larger
+// than the limit isn't cached.
+func (c *historyCache) set(key string, entry *historyCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key)
+	if entry.size() > c.maxPoints {
+		return
+	}
+	for c.points+entry.size() > c.maxPoints {
+		c.remove(c.oldestKey())
+	}
+	c.seq++
+	entry.written = time.Now()
+	entry.seq = c.seq
+	c.entries[key] = entry
+	c.points += entry.size()
+}
+
+func (c *historyCache) remove(key string) {
+	if entry, ok := c.entries[key]; ok {
+		c.points -= entry.size()
+		delete(c.entries, key)
+	}
+}
+
+func (c *historyCache) oldestKey() string {
+	var oldestKey string
+	var oldest uint64
+	for key, entry := range c.entries {
+		if oldestKey == "" || entry.seq < oldest {
+			oldestKey, oldest = key, entry.seq
+		}
+	}
+	return oldestKey
+}
+
+// getHistoryIncremental returns history of the items for the time range. If history of the same items was
+// fetched by a previous query for the range starting before and ending within the new one (like on refresh of
+// the dashboard), only values newer than the cached ones are requested and merged with the cached history.
+// History is requested entirely if the history cache is disabled in the settings.
+func (ds *ZabbixDatasourceInstance) getHistoryIncremental(ctx context.Context, timeRange backend.TimeRange, items Items) (History, error) {
+	if ds.historyCache == nil {
+		return ds.getHistotyOrTrend(ctx, timeRange, items, false, "")
+	}
+
+	key := historyCacheKey(items)
+	entry, ok := ds.historyCache.get(key)
+	if !ok || timeRange.From.Before(entry.from) || timeRange.To.Before(entry.to) || !timeRange.From.Before(entry.to) {
+		history, err := ds.getHistotyOrTrend(ctx, timeRange, items, false, "")
+		if err != nil {
+			return nil, err
+		}
+		ds.historyCache.set(key, &historyCacheEntry{from: timeRange.From, to: timeRange.To, history: history})
+		return history, nil
+	}
+
+	fetchFrom := entry.to.Add(-ds.historyCache.overlap)
+	if fetchFrom.Before(timeRange.From) {
+		fetchFrom = timeRange.From
+	}
+	requestid.Logger(ctx, ds.logger).Debug("Requesting new history values only", "from", fetchFrom, "cachedFrom", entry.from)
+
+	newHistory, err := ds.getHistotyOrTrend(ctx, backend.TimeRange{From: fetchFrom, To: timeRange.To}, items, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	history := mergeHistory(entry.history, newHistory, timeRange.From, fetchFrom)
+	ds.historyCache.set(key, &historyCacheEntry{from: timeRange.From, to: timeRange.To, history: history})
+	return history, nil
+}
+
+// mergeHistory returns cached values within [from, fetchFrom) followed by the values fetched since fetchFrom
+func mergeHistory(cached History, fetched History, from time.Time, fetchFrom time.Time) History {
+	merged := make(History, 0, len(cached)+len(fetched))
+	for _, point := range cached {
+		t := time.Unix(point.Clock, point.NS)
+		if !t.Before(from) && t.Before(fetchFrom) {
+			merged = append(merged, point)
+		}
+	}
+	return append(merged, fetched...)
+}
+
+// historyCacheKey returns key of the items history, the same for the same set of items in any order
+func historyCacheKey(items Items) string {
+	itemids := make([]string, 0, len(items))
+	for _, item := range items {
+		itemids = append(itemids, item.ID)
+	}
+	sort.Strings(itemids)
+	return strings.Join(itemids, ",")
+}
@@ -0,0 +1,98 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeHistory(t *testing.T) {
+	cached := History{
+		{ItemID: "1", Clock: 1600000000, Value: 1},
+		{ItemID: "1", Clock: 1600000060, Value: 2},
+		{ItemID: "1", Clock: 1600000120, Value: 3},
+	}
+	fetched := History{
+		{ItemID: "1", Clock: 1600000120, Value: 3},
+		{ItemID: "1", Clock: 1600000180, Value: 4},
+	}
+
+	merged := mergeHistory(cached, fetched, time.Unix(1600000060, 0), time.Unix(1600000120, 0))
+	assert.Equal(t, History{cached[1], fetched[0], fetched[1]}, merged)
+}
+
+func TestHistoryCacheKey(t *testing.T) {
+	assert.Equal(t, historyCacheKey(Items{{ID: "2"}, {ID: "1"}}), historyCacheKey(Items{{ID: "1"}, {ID: "2"}}))
+}
+
+func TestGetHistoryIncremental(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"itemid":"1","clock":"1600000000","value":"1"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	dsInstance.Settings.HistoryCache = true
+	dsInstance.historyCache = newHistoryCache(dsInstance.Settings)
+	items := Items{{ID: "1", ValueType: ValueTypeFloat}}
+
+	from := time.Unix(1600000000, 0)
+	to := time.Unix(1600003600, 0)
+	_, err := dsInstance.getHistoryIncremental(context.Background(), backend.TimeRange{From: from, To: to}, items)
+	assert.NoError(t, err)
+
+	// Refresh of the dashboard moves the time range forward, only new values are requested
+	ctx, recorder := withAPICallsRecorder(context.Background())
+	history, err := dsInstance.getHistoryIncremental(ctx, backend.TimeRange{From: from.Add(time.Minute), To: to.Add(time.Minute)}, items)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	calls := recorder.Calls()
+	assert.Len(t, calls, 1)
+	expectedFrom := to.Add(-dsInstance.Settings.HistoryCacheOverlap).Unix()
+	assert.True(t, strings.Contains(calls[0].Params, fmt.Sprintf(`"time_from":%d`, expectedFrom)), calls[0].Params)
+
+	// Time range before the cached one is requested entirely
+	ctx, recorder = withAPICallsRecorder(context.Background())
+	_, err = dsInstance.getHistoryIncremental(ctx, backend.TimeRange{From: from.Add(-time.Hour), To: to}, items)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(recorder.Calls()[0].Params, fmt.Sprintf(`"time_from":%d`, from.Add(-time.Hour).Unix())))
+}
+
+func TestGetHistoryIncrementalDisabled(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"itemid":"1","clock":"1600000000","value":"1"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	items := Items{{ID: "1", ValueType: ValueTypeFloat}}
+
+	from := time.Unix(1600000000, 0)
+	to := time.Unix(1600003600, 0)
+	_, err := dsInstance.getHistoryIncremental(context.Background(), backend.TimeRange{From: from, To: to}, items)
+	assert.NoError(t, err)
+
+	ctx, recorder := withAPICallsRecorder(context.Background())
+	_, err = dsInstance.getHistoryIncremental(ctx, backend.TimeRange{From: from.Add(time.Minute), To: to.Add(time.Minute)}, items)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(recorder.Calls()[0].Params, fmt.Sprintf(`"time_from":%d`, from.Add(time.Minute).Unix())))
+}
+
+func TestHistoryCacheMaxPoints(t *testing.T) {
+	c := newHistoryCache(&ZabbixDatasourceSettings{HistoryCache: true, HistoryCacheMaxPoints: 3})
+	c.set("1", &historyCacheEntry{history: History{{ItemID: "1"}, {ItemID: "1"}}})
+	c.set("2", &historyCacheEntry{history: History{{ItemID: "2"}}})
+	assert.Equal(t, 3, c.points)
+
+	// The oldest entry is evicted to fit the new one
+	c.set("3", &historyCacheEntry{history: History{{ItemID: "3"}}})
+	_, ok := c.get("1")
+	assert.False(t, ok)
+	_, ok = c.get("3")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.points)
+
+	// History larger than the limit isn't cached
+	c.set("4", &historyCacheEntry{history: History{{}, {}, {}, {}}})
+	_, ok = c.get("4")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.points)
+}
@@ -0,0 +1,175 @@
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Sources of the history and trends data
+const (
+	HistorySourceAPI = "api"
+	HistorySourceDB  = "db"
+)
+
+// After the direct DB connection fails, history is requested from the API for this period, so queries
+// don't wait for the unreachable DB each time
+const dbFallbackPeriod = time.Minute
+
+// dbState tracks failures of the direct DB connection
+type dbState struct {
+	mu               sync.Mutex
+	unavailableUntil time.Time
+	lastError        error
+}
+
+// setFailed marks DB unavailable for the fallback period
+func (s *dbState) setFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unavailableUntil = time.Now().Add(dbFallbackPeriod)
+	s.lastError = err
+}
+
+// available returns true if DB hasn't failed within the fallback period, or the last error otherwise
+func (s *dbState) available() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Now().Before(s.unavailableUntil) {
+		return false, s.lastError
+	}
+	return true, nil
+}
+
+type historySourcesRecorderKey struct{}
+
+// historySourcesRecorder collects sources of the history used by the query
+type historySourcesRecorder struct {
+	mu      sync.Mutex
+	sources map[string]bool
+}
+
+// withHistorySourcesRecorder returns context recording history sources used with it
+func withHistorySourcesRecorder(ctx context.Context) (context.Context, *historySourcesRecorder) {
+	recorder := &historySourcesRecorder{sources: map[string]bool{}}
+	return context.WithValue(ctx, historySourcesRecorderKey{}, recorder), recorder
+}
+
+// recordHistorySource adds source to the recorder of the context if any
+func recordHistorySource(ctx context.Context, source string) {
+	recorder, ok := ctx.Value(historySourcesRecorderKey{}).(*historySourcesRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.sources[source] = true
+}
+
+// Sources returns sorted list of the recorded sources
+func (r *historySourcesRecorder) Sources() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sources := make([]string, 0, len(r.sources))
+	for source := range r.sources {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// setHistorySourcesMeta attaches history sources to the custom meta of the frames
+func setHistorySourcesMeta(frames []*data.Frame, sources []string) {
+	if len(sources) == 0 {
+		return
+	}
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		custom, ok := frame.Meta.Custom.(map[string]interface{})
+		if !ok {
+			custom = map[string]interface{}{}
+		}
+		custom["historySources"] = sources
+		frame.Meta.Custom = custom
+	}
+}
+
+// getHistoryOrTrendWithFallback reads history or trends from the direct DB connection. If DB fails, data is
+// requested from the API and DB isn't used for the fallback period. Returns false if data should be
+// requested from the API. Values are downsampled by the database if downsampling is set.
+func (ds *ZabbixDatasourceInstance) getHistoryOrTrendWithFallback(ctx context.Context, timeRange backend.TimeRange, items Items, useTrend bool, trendValueType string, downsampling *dbDownsampling) (History, bool, error) {
+	if ds.dbConnector == nil || useTrend && !ds.dbConnector.HasTrends() {
+		return nil, false, nil
+	}
+
+	var history History
+	ok, err := ds.queryDBWithFallback(ctx, func() error {
+		var err error
+		history, err = ds.getHistoryOrTrendFromDB(ctx, timeRange, items, useTrend, trendValueType, downsampling)
+		return err
+	})
+	return history, ok, err
+}
+
+// getTextHistoryWithFallback reads history of the text items from the direct DB connection, see
+// getHistoryOrTrendWithFallback
+func (ds *ZabbixDatasourceInstance) getTextHistoryWithFallback(ctx context.Context, timeRange backend.TimeRange, items Items) (TextHistory, bool, error) {
+	if ds.dbConnector == nil {
+		return nil, false, nil
+	}
+
+	var history TextHistory
+	ok, err := ds.queryDBWithFallback(ctx, func() error {
+		var err error
+		history, err = ds.getTextHistoryFromDB(ctx, timeRange, items)
+		return err
+	})
+	return history, ok, err
+}
+
+// queryDBWithFallback runs the query unless DB has failed within the fallback period. Returns false if data
+// should be requested from the API. DB is marked unavailable only if connection is broken or connection test
+// fails, other errors fall back to the API for this query only. Error is returned if the request
+// is cancelled.
+func (ds *ZabbixDatasourceInstance) queryDBWithFallback(ctx context.Context, query func() error) (bool, error) {
+	if ok, lastErr := ds.dbState.available(); !ok {
+		addNotice(ctx, data.NoticeSeverityWarning, fmt.Sprintf("Direct DB connection (%s) is unavailable, data is requested from the API: %s", ds.dbConnector.Type(), lastErr))
+		return false, nil
+	}
+
+	err := query()
+	if err == nil {
+		recordHistorySource(ctx, HistorySourceDB)
+		return true, nil
+	}
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	logger := requestid.Logger(ctx, ds.logger)
+	if dbconnector.IsConnectionError(err) {
+		ds.dbState.setFailed(err)
+	} else if pingErr := ds.dbConnector.TestConnection(ctx); pingErr != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		ds.dbState.setFailed(pingErr)
+	} else {
+		logger.Warn("Direct DB query failed, falling back to the API", "type", ds.dbConnector.Type(), "error", err)
+		addNotice(ctx, data.NoticeSeverityWarning, fmt.Sprintf("Direct DB query (%s) failed, data is requested from the API: %s", ds.dbConnector.Type(), err))
+		return false, nil
+	}
+
+	logger.Warn("Direct DB connection failed, falling back to the API", "type", ds.dbConnector.Type(), "error", err)
+	addNotice(ctx, data.NoticeSeverityWarning, fmt.Sprintf("Direct DB connection (%s) failed, data is requested from the API: %s", ds.dbConnector.Type(), err))
+	return false, nil
+}
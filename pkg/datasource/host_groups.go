@@ -0,0 +1,84 @@
+package datasource
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Separator of the nested host group names, like "Linux servers/DB/Primary"
+const hostGroupSeparator = "/"
+
+// HostGroupNode is a host group in the tree of the nested groups. ID is empty if the group doesn't exist in Zabbix,
+// like "Linux servers" when only "Linux servers/DB" is created.
+type HostGroupNode struct {
+	ID       string           `json:"id,omitempty"`
+	Name     string           `json:"name"`
+	FullName string           `json:"fullName"`
+	Children []*HostGroupNode `json:"children"`
+}
+
+// hostGroupParent returns name of the parent group or empty string for the top level group
+func hostGroupParent(name string) string {
+	i := strings.LastIndex(name, hostGroupSeparator)
+	if i <= 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+// hostGroupPrefix returns the parent group of the prefix filter like "Linux servers/*", which matches the parent
+// group itself and all nested groups
+func hostGroupPrefix(filter string) (string, bool) {
+	parent := strings.TrimSuffix(filter, hostGroupSeparator+"*")
+	if parent == filter || parent == "" || strings.ContainsAny(parent, "*?") {
+		return "", false
+	}
+	return parent, true
+}
+
+// buildHostGroupTree returns top level groups with the nested groups as children, sorted by name
+func buildHostGroupTree(groups []DiscoveredObject) []*HostGroupNode {
+	root := &HostGroupNode{Children: []*HostGroupNode{}}
+	nodes := map[string]*HostGroupNode{"": root}
+
+	var getNode func(fullName string) *HostGroupNode
+	getNode = func(fullName string) *HostGroupNode {
+		if node, ok := nodes[fullName]; ok {
+			return node
+		}
+		parentName := hostGroupParent(fullName)
+		node := &HostGroupNode{
+			Name:     strings.TrimPrefix(fullName[len(parentName):], hostGroupSeparator),
+			FullName: fullName,
+			Children: []*HostGroupNode{},
+		}
+		parent := getNode(parentName)
+		parent.Children = append(parent.Children, node)
+		nodes[fullName] = node
+		return node
+	}
+
+	for _, group := range groups {
+		getNode(group.Name).ID = group.ID
+	}
+
+	for _, node := range nodes {
+		children := node.Children
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Name < children[j].Name
+		})
+	}
+	return root.Children
+}
+
+// getHostGroupTree returns tree of the host groups matching the filter, so pickers can show nested groups level
+// by level
+func (ds *ZabbixDatasourceInstance) getHostGroupTree(ctx context.Context, groupFilter string) ([]*HostGroupNode, error) {
+	groups, err := ds.getGroups(ctx, nil, groupFilter)
+	if err != nil {
+		return nil, err
+	}
+	return buildHostGroupTree(discoveredObjects(groups, "groupid")), nil
+}
@@ -0,0 +1,75 @@
+package datasource
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostGroupPrefix(t *testing.T) {
+	tests := []struct {
+		filter   string
+		parent   string
+		isPrefix bool
+	}{
+		{filter: "Linux servers/*", parent: "Linux servers", isPrefix: true},
+		{filter: "Linux servers/DB/*", parent: "Linux servers/DB", isPrefix: true},
+		{filter: "Linux servers", isPrefix: false},
+		{filter: "Linux*/*", isPrefix: false},
+		{filter: "/*", isPrefix: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			parent, isPrefix := hostGroupPrefix(tt.filter)
+			assert.Equal(t, tt.parent, parent)
+			assert.Equal(t, tt.isPrefix, isPrefix)
+		})
+	}
+}
+
+func TestBuildHostGroupTree(t *testing.T) {
+	tree := buildHostGroupTree([]DiscoveredObject{
+		{ID: "3", Name: "Linux servers/DB/Primary"},
+		{ID: "1", Name: "Linux servers"},
+		{ID: "4", Name: "Linux servers/App"},
+		{ID: "2", Name: "Discovered hosts"},
+	})
+
+	assert.Equal(t, []*HostGroupNode{
+		{ID: "2", Name: "Discovered hosts", FullName: "Discovered hosts", Children: []*HostGroupNode{}},
+		{ID: "1", Name: "Linux servers", FullName: "Linux servers", Children: []*HostGroupNode{
+			{ID: "4", Name: "App", FullName: "Linux servers/App", Children: []*HostGroupNode{}},
+			{Name: "DB", FullName: "Linux servers/DB", Children: []*HostGroupNode{
+				{ID: "3", Name: "Primary", FullName: "Linux servers/DB/Primary", Children: []*HostGroupNode{}},
+			}},
+		}},
+	}, tree)
+}
+
+func TestDiscoverNestedGroups(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[
+		{"groupid":"1","name":"Linux servers"},
+		{"groupid":"2","name":"Linux servers/DB"},
+		{"groupid":"3","name":"Linux servers/DB/Primary"},
+		{"groupid":"4","name":"Linux servers old"}
+	]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	groups, _, err := dsInstance.discover(context.Background(), "groups", url.Values{"group": {"Linux servers/*"}}, Page{})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiscoveredObject{
+		{ID: "1", Name: "Linux servers"},
+		{ID: "2", Name: "Linux servers/DB", Parent: "Linux servers"},
+		{ID: "3", Name: "Linux servers/DB/Primary", Parent: "Linux servers/DB"},
+	}, groups)
+
+	tree, err := dsInstance.getHostGroupTree(context.Background(), "Linux servers/DB/*")
+	assert.NoError(t, err)
+	assert.Len(t, tree, 1)
+	assert.Equal(t, "Linux servers", tree[0].Name)
+	assert.Empty(t, tree[0].ID)
+	assert.Equal(t, "2", tree[0].Children[0].ID)
+}
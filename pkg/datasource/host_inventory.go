@@ -0,0 +1,87 @@
+package datasource
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operators of the host inventory conditions: exact value or value containing the text (case insensitive)
+const (
+	inventoryOperatorEquals   = "="
+	inventoryOperatorContains = "~"
+)
+
+// inventoryConditionPattern matches condition like location="Frankfurt" or os~Ubuntu followed by a comma or the end
+var inventoryConditionPattern = regexp.MustCompile(`^\s*([a-z_0-9]+)\s*(=|~)\s*(?:"((?:[^"\\]|\\.)*)"|([^,"]*?))\s*(?:,|$)`)
+
+// inventoryCondition is a condition of the host inventory field value
+type inventoryCondition struct {
+	field    string
+	operator string
+	value    string
+}
+
+// parseInventoryFilter parses host inventory filter like `location="Frankfurt", os~"Ubuntu"`. All conditions
+// should match. Values with commas or quotes should be quoted.
+func parseInventoryFilter(filter string) ([]inventoryCondition, error) {
+	var conditions []inventoryCondition
+	fields := map[string]bool{}
+	rest := strings.TrimSpace(filter)
+	for rest != "" {
+		m := inventoryConditionPattern.FindStringSubmatchIndex(rest)
+		if m == nil {
+			return nil, fmt.Errorf("invalid inventory condition: %s", rest)
+		}
+		condition := inventoryCondition{field: rest[m[2]:m[3]], operator: rest[m[4]:m[5]]}
+		if m[6] >= 0 {
+			value, err := strconv.Unquote(`"` + rest[m[6]:m[7]] + `"`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid inventory value: %s", rest[m[6]:m[7]])
+			}
+			condition.value = value
+		} else {
+			condition.value = rest[m[8]:m[9]]
+		}
+		if fields[condition.field] {
+			return nil, fmt.Errorf("duplicate inventory field: %s", condition.field)
+		}
+		fields[condition.field] = true
+		conditions = append(conditions, condition)
+		rest = strings.TrimSpace(rest[m[1]:])
+	}
+	return conditions, nil
+}
+
+// setInventoryParams sets inventory search of the host.get request. Zabbix API only searches the inventory
+// values containing the text, so exact values are checked by matchInventory.
+func setInventoryParams(params ZabbixAPIParams, conditions []inventoryCondition) {
+	if len(conditions) == 0 {
+		return
+	}
+	search := make(map[string]string, len(conditions))
+	fields := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		search[condition.field] = condition.value
+		fields = append(fields, condition.field)
+	}
+	params["searchInventory"] = search
+	params["selectInventory"] = fields
+}
+
+// matchInventory returns true if the host inventory from the host.get response has exact values of the conditions
+// with the equals operator
+func matchInventory(host map[string]interface{}, conditions []inventoryCondition) bool {
+	// Inventory is an empty array if it's disabled for the host
+	inventory, _ := host["inventory"].(map[string]interface{})
+	for _, condition := range conditions {
+		if condition.operator != inventoryOperatorEquals {
+			continue
+		}
+		if value, _ := inventory[condition.field].(string); value != condition.value {
+			return false
+		}
+	}
+	return true
+}
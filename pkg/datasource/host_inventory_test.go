@@ -0,0 +1,67 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestParseInventoryFilter(t *testing.T) {
+	tests := []struct {
+		filter  string
+		want    []inventoryCondition
+		wantErr string
+	}{
+		{filter: "", want: nil},
+		{filter: `location="Frankfurt"`, want: []inventoryCondition{{field: "location", operator: "=", value: "Frankfurt"}}},
+		{
+			filter: ` os ~ Ubuntu , site_city="Frankfurt, \"Main\""`,
+			want: []inventoryCondition{
+				{field: "os", operator: "~", value: "Ubuntu"},
+				{field: "site_city", operator: "=", value: `Frankfurt, "Main"`},
+			},
+		},
+		{filter: `tag=`, want: []inventoryCondition{{field: "tag", operator: "=", value: ""}}},
+		{filter: `location:Frankfurt`, wantErr: "invalid inventory condition: location:Frankfurt"},
+		{filter: `os~Ubuntu, os~Debian`, wantErr: "duplicate inventory field: os"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			conditions, err := parseInventoryFilter(tt.filter)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, conditions)
+		})
+	}
+}
+
+func TestGetHostsInventoryFilter(t *testing.T) {
+	// Same response is used for groups and hosts. Zabbix API returns hosts with the inventory containing the
+	// values, exact values are checked by the data source.
+	body := `{"result":[
+		{"groupid":"1","hostid":"10","name":"web01","inventory":{"location":"Frankfurt","os":"Ubuntu 22.04"}},
+		{"groupid":"1","hostid":"11","name":"web02","inventory":{"location":"Frankfurt am Main","os":"Ubuntu 20.04"}},
+		{"groupid":"1","hostid":"12","name":"web03","inventory":[]}
+	]}`
+	dsInstance := MockZabbixDataSource(body, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	ctx, apiCalls := withAPICallsRecorder(context.Background())
+	filters := &queryFilters{hosts: newHostFilters(QueryOptions{HostInventory: `location="Frankfurt", os~Ubuntu`})}
+	hosts, err := dsInstance.getHosts(ctx, filters, "/.*/", "/.*/")
+	assert.NoError(t, err)
+	assert.Len(t, hosts, 1)
+	assert.Equal(t, "web01", hosts[0]["name"])
+
+	for _, call := range apiCalls.Calls() {
+		if call.Method == "host.get" {
+			assert.Contains(t, call.Params, `"searchInventory":{"location":"Frankfurt","os":"Ubuntu"}`)
+			assert.Contains(t, call.Params, `"selectInventory":["location","os"]`)
+		}
+	}
+}
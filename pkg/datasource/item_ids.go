@@ -0,0 +1,109 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// parseIDs splits comma separated list of the object IDs, like itemids of the query
+func parseIDs(ids string) ([]string, error) {
+	result := []string{}
+	for _, id := range strings.Split(ids, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid id: %s", id)
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+// queryItemIDs queries numeric data of the items set by IDs. Group, host, application and item filters aren't
+// resolved, so the query is faster and isn't affected by renames.
+func (ds *ZabbixDatasourceInstance) queryItemIDs(ctx context.Context, query *QueryModel) (*data.Frame, error) {
+	items, err := ds.getItemsByIDs(ctx, newQueryFilters(query), query.ItemIDs, query.HostIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Options.UseLastValue {
+		return ds.queryLastValues(ctx, query, items)
+	}
+	return ds.queryNumericDataForItems(ctx, query, items)
+}
+
+// getItemsByIDs returns items with the given IDs, belonging to the given hosts if hostids are set. Disabled items
+// are skipped unless the query shows them.
+func (ds *ZabbixDatasourceInstance) getItemsByIDs(ctx context.Context, filters *queryFilters, itemids string, hostids string) (Items, error) {
+	ids, err := parseIDs(itemids)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return Items{}, nil
+	}
+
+	params := ZabbixAPIParams{
+		"output":      []string{"itemid", "name", "key_", "value_type", "hostid", "status", "state", "error", "delay", "valuemapid", "lastvalue", "lastclock", "lastns"},
+		"itemids":     ids,
+		"webitems":    true,
+		"selectHosts": []string{"hostid", "name"},
+	}
+	if hostids != "" {
+		hostIDs, err := parseIDs(hostids)
+		if err != nil {
+			return nil, err
+		}
+		params["hostids"] = hostIDs
+	}
+
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "item.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+	itemsJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var items Items
+	if err := json.Unmarshal(itemsJSON, &items); err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+	enabledItems := Items{}
+	disabledItems := Items{}
+	unsupportedItems := Items{}
+	for _, item := range items {
+		found[item.ID] = true
+		if item.Status != "0" && !filters.isShowDisabledItems() {
+			disabledItems = append(disabledItems, item)
+			continue
+		}
+		if item.State == ItemStateNotSupported {
+			unsupportedItems = append(unsupportedItems, item)
+		}
+		enabledItems = append(enabledItems, item)
+	}
+
+	var missingIDs []string
+	for _, id := range ids {
+		if !found[id] {
+			missingIDs = append(missingIDs, id)
+		}
+	}
+	if len(missingIDs) > 0 {
+		addNotice(ctx, data.NoticeSeverityWarning, fmt.Sprintf("Items not found: %s", strings.Join(missingIDs, ", ")))
+	}
+	addItemsNotice(ctx, data.NoticeSeverityInfo, disabledItems, "disabled and skipped")
+	addItemsNotice(ctx, data.NoticeSeverityWarning, unsupportedItems, "not supported by Zabbix and may have no data")
+	return enabledItems, nil
+}
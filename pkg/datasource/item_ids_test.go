@@ -0,0 +1,84 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIDs(t *testing.T) {
+	tests := []struct {
+		ids     string
+		want    []string
+		wantErr string
+	}{
+		{ids: "", want: []string{}},
+		{ids: "100", want: []string{"100"}},
+		{ids: "100, 101,,102 ", want: []string{"100", "101", "102"}},
+		{ids: "100,$item", wantErr: "invalid id: $item"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ids, func(t *testing.T) {
+			ids, err := parseIDs(tt.ids)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestGetItemsByIDs(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[
+		{"itemid":"100","name":"CPU utilization","key_":"system.cpu.util","value_type":"0","status":"0"},
+		{"itemid":"101","name":"Load average","key_":"system.cpu.load","value_type":"0","status":"1"}
+	]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	ctx, notices := withNoticesRecorder(context.Background())
+	items, err := dsInstance.getItemsByIDs(ctx, nil, "100,101,102", "")
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "100", items[0].ID)
+	assert.Equal(t, []data.Notice{
+		{Severity: data.NoticeSeverityWarning, Text: "Items not found: 102"},
+		{Severity: data.NoticeSeverityInfo, Text: "1 item is disabled and skipped: Load average"},
+	}, notices.Notices())
+
+	items, err = dsInstance.getItemsByIDs(context.Background(), &queryFilters{showDisabledItems: true}, "100,101", "")
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	items, err = dsInstance.getItemsByIDs(context.Background(), nil, "", "")
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestGetLastValues(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"itemid":"100","lastvalue":"5.5","lastclock":"1600000000","lastns":"10"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	items := Items{{ID: "100", Name: "CPU", LastValue: "1", LastClock: 1500000000}, {ID: "101", Name: "Memory", LastValue: "2", LastClock: 1500000000}}
+
+	// Last values change faster than the cache TTL, so they're requested every time
+	ctx, apiCalls := withAPICallsRecorder(context.Background())
+	for i := 0; i < 2; i++ {
+		result, err := dsInstance.getLastValues(ctx, items)
+		assert.NoError(t, err)
+		assert.Equal(t, Items{
+			{ID: "100", Name: "CPU", LastValue: "5.5", LastClock: 1600000000, LastNS: 10},
+			{ID: "101", Name: "Memory"},
+		}, result)
+	}
+	calls := apiCalls.Calls()
+	assert.Len(t, calls, 2)
+	for _, call := range calls {
+		assert.Equal(t, "item.get", call.Method)
+		assert.False(t, call.Cached)
+	}
+	assert.Equal(t, "1", items[0].LastValue)
+}
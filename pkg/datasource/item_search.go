@@ -0,0 +1,90 @@
+package datasource
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Number of the items returned by the item search if limit isn't set
+const defaultItemSearchLimit = 100
+
+// Match ranks of the item search, lower is better
+const (
+	itemMatchPrefix = iota
+	itemMatchSubstring
+	itemMatchFuzzy
+)
+
+// ItemSearchResult is a page of the items matching the search, returned by the /zabbix-api/item-search resource
+type ItemSearchResult struct {
+	Page
+	Total int                `json:"total"`
+	Items []DiscoveredObject `json:"items"`
+}
+
+// searchItems returns page of the items of the hosts matching group, host and app filters, whose name or key
+// matches the search string. Items starting with the search string are returned first, then items containing
+// it and items matching it fuzzy (all characters in order). Items are ranked by the match, so all items of the
+// hosts are requested (through the data source cache) and the page is taken from the ranked items.
+func (ds *ZabbixDatasourceInstance) searchItems(ctx context.Context, params url.Values) (*ItemSearchResult, error) {
+	page, err := parsePage(params, defaultItemSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := ds.getItems(ctx, nil, paramFilter(params, "group"), paramFilter(params, "host"), params.Get("app"), matchAllFilter, params.Get("itemType"))
+	if err != nil {
+		return nil, err
+	}
+	objects := discoveredItems(items)
+
+	search := strings.ToLower(params.Get("search"))
+	type rankedObject struct {
+		object DiscoveredObject
+		rank   int
+	}
+	matched := make([]rankedObject, 0, len(objects))
+	for _, object := range objects {
+		nameRank, ok := matchItemSearch(strings.ToLower(object.Name), search)
+		if keyRank, keyOk := matchItemSearch(strings.ToLower(object.Key), search); keyOk && (!ok || keyRank < nameRank) {
+			nameRank, ok = keyRank, true
+		}
+		if ok {
+			matched = append(matched, rankedObject{object: object, rank: nameRank})
+		}
+	}
+	// Objects are sorted by name already
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].rank < matched[j].rank
+	})
+
+	start, end := page.bounds(len(matched))
+	pageObjects := make([]DiscoveredObject, 0, end-start)
+	for _, m := range matched[start:end] {
+		pageObjects = append(pageObjects, m.object)
+	}
+	return &ItemSearchResult{Total: len(matched), Page: page, Items: pageObjects}, nil
+}
+
+// matchItemSearch returns rank of the match of the lower case text and search string, or false if not matched.
+// Empty search matches all items.
+func matchItemSearch(text string, search string) (int, bool) {
+	switch {
+	case strings.HasPrefix(text, search):
+		return itemMatchPrefix, true
+	case strings.Contains(text, search):
+		return itemMatchSubstring, true
+	}
+
+	searchRunes := []rune(search)
+	i := 0
+	for _, r := range text {
+		if i < len(searchRunes) && r == searchRunes[i] {
+			i++
+		}
+	}
+	return itemMatchFuzzy, i == len(searchRunes)
+}
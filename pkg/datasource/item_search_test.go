@@ -0,0 +1,107 @@
+package datasource
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchItems(t *testing.T) {
+	// Same response is used for groups, hosts and items
+	dsInstance := MockZabbixDataSource(`{"result":[
+		{"groupid":"1","hostid":"10","itemid":"100","name":"CPU utilization","key_":"system.cpu.util","value_type":"0","status":"0"},
+		{"groupid":"1","hostid":"10","itemid":"101","name":"Free memory","key_":"vm.memory.size[free]","value_type":"3","status":"0"},
+		{"groupid":"1","hostid":"10","itemid":"102","name":"Load average","key_":"system.cpu.load","value_type":"0","status":"0"},
+		{"groupid":"1","hostid":"10","itemid":"103","name":"Memory utilization","key_":"vm.memory.util","value_type":"0","status":"0"}
+	]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	names := func(result *ItemSearchResult) []string {
+		names := []string{}
+		for _, item := range result.Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name      string
+		params    url.Values
+		wantTotal int
+		wantNames []string
+	}{
+		{
+			name:      "All items",
+			params:    url.Values{},
+			wantTotal: 4,
+			wantNames: []string{"CPU utilization", "Free memory", "Load average", "Memory utilization"},
+		},
+		{
+			name:      "Prefix first",
+			params:    url.Values{"search": {"memory"}},
+			wantTotal: 2,
+			wantNames: []string{"Memory utilization", "Free memory"},
+		},
+		{
+			name:      "Key",
+			params:    url.Values{"search": {"system.cpu"}},
+			wantTotal: 2,
+			wantNames: []string{"CPU utilization", "Load average"},
+		},
+		{
+			name:      "Fuzzy",
+			params:    url.Values{"search": {"cpuutl"}},
+			wantTotal: 1,
+			wantNames: []string{"CPU utilization"},
+		},
+		{
+			name:      "Page",
+			params:    url.Values{"limit": {"2"}, "offset": {"1"}},
+			wantTotal: 4,
+			wantNames: []string{"Free memory", "Load average"},
+		},
+		{
+			name:      "Offset out of range",
+			params:    url.Values{"offset": {"10"}},
+			wantTotal: 4,
+			wantNames: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := dsInstance.searchItems(context.Background(), tt.params)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTotal, result.Total)
+			assert.Equal(t, tt.wantNames, names(result))
+		})
+	}
+
+	_, err := dsInstance.searchItems(context.Background(), url.Values{"limit": {"all"}})
+	assert.EqualError(t, err, "invalid limit: all")
+}
+
+func TestMatchItemSearch(t *testing.T) {
+	tests := []struct {
+		text     string
+		search   string
+		wantRank int
+		wantOk   bool
+	}{
+		{text: "cpu utilization", search: "", wantRank: itemMatchPrefix, wantOk: true},
+		{text: "cpu utilization", search: "cpu", wantRank: itemMatchPrefix, wantOk: true},
+		{text: "cpu utilization", search: "util", wantRank: itemMatchSubstring, wantOk: true},
+		{text: "cpu utilization", search: "cutl", wantRank: itemMatchFuzzy, wantOk: true},
+		{text: "cpu utilization", search: "memory", wantRank: itemMatchFuzzy, wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.search, func(t *testing.T) {
+			rank, ok := matchItemSearch(tt.text, tt.search)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantRank, rank)
+		})
+	}
+}
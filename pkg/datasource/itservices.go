@@ -0,0 +1,241 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/gtime"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+const (
+	// Min SLA interval, too many intervals may cause significant load on the Zabbix database
+	minSLAInterval = time.Hour
+	// Ratio of the auto SLA interval to the query interval
+	slaIntervalResolutionRatio = 100
+)
+
+// queryITServices returns SLA property of the matching IT services as series (one series per service).
+func (ds *ZabbixDatasourceInstance) queryITServices(ctx context.Context, query *QueryModel) (*data.Frame, error) {
+	services, err := ds.getITServices(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyFunctionsPre(query)
+	if err != nil {
+		return nil, err
+	}
+
+	intervals, err := buildSLAIntervals(query)
+	if err != nil {
+		return nil, err
+	}
+
+	slaByService := map[string]ServiceSLA{}
+	if len(services) > 0 {
+		slaByService, err = ds.getSLA(ctx, services, intervals)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	series := make([]*timeseries.TimeSeriesData, 0, len(services))
+	for _, service := range services {
+		sla, ok := slaByService[service.ID]
+		if !ok {
+			continue
+		}
+		ts, err := convertSLAToTimeSeries(service, sla, query.SLAProperty)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, ts)
+	}
+
+	series, err = applyFunctions(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err = applyFunctionsPost(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertSeriesToFrame(series, query.Options), nil
+}
+
+// getITServices returns IT services with the name matching query filter (regex or exact name), or the
+// service selected by id in the old versions of the query editor
+func (ds *ZabbixDatasourceInstance) getITServices(ctx context.Context, query *QueryModel) (ITServices, error) {
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "service.get", Params: ZabbixAPIParams{"output": "extend"}})
+	if err != nil {
+		return nil, err
+	}
+
+	servicesJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var services ITServices
+	err = json.Unmarshal(servicesJSON, &services)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := ITServices{}
+	if query.ITServiceFilter == "" {
+		for _, service := range services {
+			if query.ITService.ID != "" && service.ID == query.ITService.ID {
+				filtered = append(filtered, service)
+			}
+		}
+		return filtered, nil
+	}
+
+	nameFilter, err := newNameFilter(query.ITServiceFilter)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(services))
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	nameFilter = nameFilter.forNames(names)
+	for _, service := range services {
+		if nameFilter.match(service.Name) {
+			filtered = append(filtered, service)
+		}
+	}
+	return filtered, nil
+}
+
+// getSLA returns SLA of the services for each interval, mapped by service id
+func (ds *ZabbixDatasourceInstance) getSLA(ctx context.Context, services ITServices, intervals []map[string]int64) (map[string]ServiceSLA, error) {
+	serviceids := make([]string, 0, len(services))
+	for _, service := range services {
+		serviceids = append(serviceids, service.ID)
+	}
+
+	params := ZabbixAPIParams{
+		"serviceids": serviceids,
+		"intervals":  intervals,
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "service.getsla", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	slaJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	slaByService := map[string]ServiceSLA{}
+	err = json.Unmarshal(slaJSON, &slaByService)
+	if err != nil {
+		return nil, err
+	}
+	return slaByService, nil
+}
+
+// buildSLAIntervals splits query time range into SLA intervals aligned to the interval size in the Zabbix server
+// timezone. Whole time range is used as a single interval if SLA interval is not set.
+func buildSLAIntervals(query *QueryModel) ([]map[string]int64, error) {
+	from := query.TimeRange.From.Unix()
+	to := query.TimeRange.To.Unix()
+
+	var interval time.Duration
+	switch query.SLAInterval {
+	case "", SLAIntervalNone:
+		return []map[string]int64{{"from": from, "to": to}}, nil
+	case SLAIntervalAuto:
+		interval = getSLAInterval(query.Interval)
+	default:
+		var err error
+		interval, err = gtime.ParseInterval(query.SLAInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SLA interval: %w", err)
+		}
+	}
+
+	step := int64(interval / time.Second)
+	if step <= 0 {
+		return nil, fmt.Errorf("invalid SLA interval: %s", query.SLAInterval)
+	}
+
+	// Intervals are aligned to the Zabbix server clock, so daily SLA matches the server days
+	start := timeseries.AlignTime(query.TimeRange.From, interval, query.Location)
+	end := query.TimeRange.To.Truncate(time.Second)
+
+	intervals := make([]map[string]int64, 0, (to-from)/step+1)
+	for t := start; t.Before(end); {
+		next := timeseries.NextTimeFrame(t, interval, query.Location)
+		intervals = append(intervals, map[string]int64{"from": t.Unix(), "to": next.Unix()})
+		t = next
+	}
+	return intervals, nil
+}
+
+// getSLAInterval returns auto SLA interval which is much bigger than the query interval to decrease
+// number of the resulting points
+func getSLAInterval(queryInterval time.Duration) time.Duration {
+	interval := (queryInterval * slaIntervalResolutionRatio).Round(time.Second)
+	if interval < minSLAInterval {
+		return minSLAInterval
+	}
+	return interval
+}
+
+// convertSLAToTimeSeries returns SLA property of the service as series. Status is returned as a single point
+// at the end of the last interval, other properties have a point at the start of the first interval and
+// at the end of each interval.
+func convertSLAToTimeSeries(service ITService, sla ServiceSLA, property SLAProperty) (*timeseries.TimeSeriesData, error) {
+	ts := timeseries.NewTimeSeriesData()
+	ts.Meta.Name = fmt.Sprintf("%s %s", service.Name, property.Name)
+	if len(sla.SLA) == 0 {
+		return ts, nil
+	}
+
+	if property.Property == "status" {
+		status, err := sla.Status.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse status of the service %s: %w", service.Name, err)
+		}
+		last := sla.SLA[len(sla.SLA)-1]
+		ts.Add(timeseries.TimePoint{Time: time.Unix(last.To, 0), Value: &status})
+		return ts, nil
+	}
+
+	for i, interval := range sla.SLA {
+		value, err := slaPropertyValue(interval, property.Property)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			ts.Add(timeseries.TimePoint{Time: time.Unix(interval.From, 0), Value: &value})
+		}
+		intervalValue := value
+		ts.Add(timeseries.TimePoint{Time: time.Unix(interval.To, 0), Value: &intervalValue})
+	}
+	return ts, nil
+}
+
+func slaPropertyValue(interval SLAInterval, property string) (float64, error) {
+	switch property {
+	case "sla":
+		return interval.SLA, nil
+	case "okTime":
+		return interval.OKTime, nil
+	case "problemTime":
+		return interval.ProblemTime, nil
+	case "downtimeTime":
+		return interval.DowntimeTime, nil
+	}
+	return 0, fmt.Errorf("unsupported SLA property: %s", property)
+}
@@ -0,0 +1,135 @@
+package datasource
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSLAIntervals(t *testing.T) {
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600010000, 0)}
+
+	tests := []struct {
+		name        string
+		slaInterval string
+		interval    time.Duration
+		want        []map[string]int64
+		wantErr     bool
+	}{
+		{
+			name:        "no interval",
+			slaInterval: SLAIntervalNone,
+			want:        []map[string]int64{{"from": 1600000000, "to": 1600010000}},
+		},
+		{
+			name:        "fixed interval",
+			slaInterval: "1h",
+			want: []map[string]int64{
+				{"from": 1599998400, "to": 1600002000},
+				{"from": 1600002000, "to": 1600005600},
+				{"from": 1600005600, "to": 1600009200},
+				{"from": 1600009200, "to": 1600012800},
+			},
+		},
+		{
+			name:        "auto interval",
+			slaInterval: SLAIntervalAuto,
+			interval:    time.Minute,
+			want: []map[string]int64{
+				{"from": 1599996000, "to": 1600002000},
+				{"from": 1600002000, "to": 1600008000},
+				{"from": 1600008000, "to": 1600014000},
+			},
+		},
+		{
+			name:        "invalid interval",
+			slaInterval: "1x",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := &QueryModel{TimeRange: timeRange, SLAInterval: tt.slaInterval, Interval: tt.interval}
+			intervals, err := buildSLAIntervals(query)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, intervals)
+		})
+	}
+}
+
+func TestBuildSLAIntervalsTimezone(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Riga")
+	assert.NoError(t, err)
+
+	// Daylight saving time starts on March 29, 2020
+	query := &QueryModel{
+		TimeRange:   backend.TimeRange{From: time.Date(2020, 3, 28, 12, 0, 0, 0, time.UTC), To: time.Date(2020, 3, 30, 12, 0, 0, 0, time.UTC)},
+		SLAInterval: "1d",
+		Location:    location,
+	}
+	intervals, err := buildSLAIntervals(query)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]int64{
+		{"from": time.Date(2020, 3, 28, 0, 0, 0, 0, location).Unix(), "to": time.Date(2020, 3, 29, 0, 0, 0, 0, location).Unix()},
+		{"from": time.Date(2020, 3, 29, 0, 0, 0, 0, location).Unix(), "to": time.Date(2020, 3, 30, 0, 0, 0, 0, location).Unix()},
+		{"from": time.Date(2020, 3, 30, 0, 0, 0, 0, location).Unix(), "to": time.Date(2020, 3, 31, 0, 0, 0, 0, location).Unix()},
+	}, intervals)
+
+	// Weekly intervals start on Monday
+	query.SLAInterval = "1w"
+	intervals, err = buildSLAIntervals(query)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]int64{
+		{"from": time.Date(2020, 3, 23, 0, 0, 0, 0, location).Unix(), "to": time.Date(2020, 3, 30, 0, 0, 0, 0, location).Unix()},
+		{"from": time.Date(2020, 3, 30, 0, 0, 0, 0, location).Unix(), "to": time.Date(2020, 4, 6, 0, 0, 0, 0, location).Unix()},
+	}, intervals)
+}
+
+func TestGetSLAInterval(t *testing.T) {
+	assert.Equal(t, time.Hour, getSLAInterval(10*time.Second))
+	assert.Equal(t, 100*time.Minute, getSLAInterval(time.Minute))
+}
+
+func TestConvertSLAToTimeSeries(t *testing.T) {
+	var slaByService map[string]ServiceSLA
+	err := json.Unmarshal([]byte(`{
+		"1": {"status": "2", "sla": [
+			{"from": 1600000000, "to": 1600003600, "sla": 99.5, "okTime": 3582, "problemTime": 18, "downtimeTime": 0},
+			{"from": 1600003600, "to": 1600007200, "sla": 100, "okTime": 3600, "problemTime": 0, "downtimeTime": 0}
+		]},
+		"2": {"status": 0, "sla": []}
+	}`), &slaByService)
+	assert.NoError(t, err)
+	service := ITService{ID: "1", Name: "Website"}
+
+	ts, err := convertSLAToTimeSeries(service, slaByService["1"], SLAProperty{Name: "SLA", Property: "sla"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Website SLA", ts.Meta.Name)
+	assert.Equal(t, 3, ts.Len())
+	assert.Equal(t, time.Unix(1600000000, 0), ts.TS[0].Time)
+	assert.Equal(t, []*float64{floatPtr(99.5), floatPtr(99.5), floatPtr(100)}, pointValues(ts.TS))
+
+	ts, err = convertSLAToTimeSeries(service, slaByService["1"], SLAProperty{Name: "Problem time", Property: "problemTime"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*float64{floatPtr(18), floatPtr(18), floatPtr(0)}, pointValues(ts.TS))
+
+	ts, err = convertSLAToTimeSeries(service, slaByService["1"], SLAProperty{Name: "Status", Property: "status"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ts.Len())
+	assert.Equal(t, time.Unix(1600007200, 0), ts.TS[0].Time)
+	assert.Equal(t, float64(2), *ts.TS[0].Value)
+
+	ts, err = convertSLAToTimeSeries(service, slaByService["2"], SLAProperty{Name: "Status", Property: "status"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ts.Len())
+
+	_, err = convertSLAToTimeSeries(service, slaByService["1"], SLAProperty{Name: "Unknown", Property: "unknown"})
+	assert.Error(t, err)
+}
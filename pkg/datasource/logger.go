@@ -0,0 +1,152 @@
+package datasource
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// Log levels of the data source, messages below the level are dropped
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+var logLevels = map[string]int{
+	LogLevelDebug: 0,
+	LogLevelInfo:  1,
+	LogLevelWarn:  2,
+	LogLevelError: 3,
+}
+
+const redacted = "[REDACTED]"
+
+// Secrets shorter than this are not replaced, since they match unrelated parts of the messages
+const minSecretLength = 4
+
+// Names of the log args, JSON fields and URL params holding credentials
+const sensitiveNamesPattern = `password|passwd|auth|token|secret|api[_-]?key`
+
+var (
+	sensitiveKeyRegex   = regexp.MustCompile(`(?i)(` + sensitiveNamesPattern + `)`)
+	sensitiveJSONRegex  = regexp.MustCompile(`(?i)("(?:[\w-]*(?:` + sensitiveNamesPattern + `)[\w-]*)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	sensitiveParamRegex = regexp.MustCompile(`(?i)\b((?:[\w-]*(?:` + sensitiveNamesPattern + `)[\w-]*)=)[^&\s"',]+`)
+	authHeaderRegex     = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[\w.~+/=-]+`)
+	urlUserInfoRegex    = regexp.MustCompile(`(://[^:/@\s]+):[^/?#\s]+@`)
+)
+
+// redactingLogger drops messages below the data source log level and replaces credentials in the messages and
+// args. Secrets are values known to be sensitive wherever they appear, like secure settings, and auth returns
+// the current API auth token, which changes on login.
+type redactingLogger struct {
+	logger  log.Logger
+	level   int
+	secrets []string
+	auth    func() string
+}
+
+// newRedactingLogger returns logger of the given level ("" logs all messages passed by Grafana). Secrets shorter
+// than minSecretLength are ignored, auth may be nil.
+func newRedactingLogger(logger log.Logger, level string, secrets []string, auth func() string) log.Logger {
+	l := &redactingLogger{
+		logger: logger,
+		level:  logLevels[level],
+		auth:   auth,
+	}
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			l.secrets = append(l.secrets, secret)
+		}
+	}
+	return l
+}
+
+func (l *redactingLogger) Debug(msg string, args ...interface{}) {
+	if l.level <= logLevels[LogLevelDebug] {
+		l.logger.Debug(l.redact(msg), l.redactArgs(args)...)
+	}
+}
+
+func (l *redactingLogger) Info(msg string, args ...interface{}) {
+	if l.level <= logLevels[LogLevelInfo] {
+		l.logger.Info(l.redact(msg), l.redactArgs(args)...)
+	}
+}
+
+func (l *redactingLogger) Warn(msg string, args ...interface{}) {
+	if l.level <= logLevels[LogLevelWarn] {
+		l.logger.Warn(l.redact(msg), l.redactArgs(args)...)
+	}
+}
+
+func (l *redactingLogger) Error(msg string, args ...interface{}) {
+	l.logger.Error(l.redact(msg), l.redactArgs(args)...)
+}
+
+// redactArgs replaces values of the sensitive keys and credentials within other values. Values other than
+// numbers and booleans are formatted before redacting, so structs and errors can't leak credentials.
+func (l *redactingLogger) redactArgs(args []interface{}) []interface{} {
+	result := make([]interface{}, len(args))
+	for i, arg := range args {
+		if i%2 == 1 {
+			if key, ok := args[i-1].(string); ok && sensitiveKeyRegex.MatchString(key) {
+				result[i] = redacted
+				continue
+			}
+		}
+
+		switch value := arg.(type) {
+		case nil, bool, int, int64, float64, uint64:
+			result[i] = value
+		case string:
+			result[i] = l.redact(value)
+		case []byte:
+			result[i] = l.redact(string(value))
+		case error:
+			result[i] = l.redact(value.Error())
+		default:
+			result[i] = l.redact(fmt.Sprintf("%+v", value))
+		}
+	}
+	return result
+}
+
+func (l *redactingLogger) redact(s string) string {
+	for _, secret := range l.secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	if l.auth != nil {
+		if auth := l.auth(); len(auth) >= minSecretLength {
+			s = strings.Replace(s, auth, redacted, -1)
+		}
+	}
+	return redactCredentials(s)
+}
+
+// redactCredentials replaces credentials in JSON fields, URL params, auth headers and URL user info
+func redactCredentials(s string) string {
+	s = sensitiveJSONRegex.ReplaceAllString(s, `$1"`+redacted+`"`)
+	s = sensitiveParamRegex.ReplaceAllString(s, `${1}`+redacted)
+	s = authHeaderRegex.ReplaceAllString(s, `$1 `+redacted)
+	s = urlUserInfoRegex.ReplaceAllString(s, `$1:`+redacted+`@`)
+	return s
+}
+
+// secureSettingsValues returns values of the secure settings and the password stored in the plain settings by
+// the old plugin versions
+func secureSettingsValues(settings *backend.DataSourceInstanceSettings) []string {
+	values := make([]string, 0, len(settings.DecryptedSecureJSONData)+1)
+	for _, value := range settings.DecryptedSecureJSONData {
+		values = append(values, value)
+	}
+	if jsonData, err := simplejson.NewJson(settings.JSONData); err == nil {
+		values = append(values, jsonData.Get("password").MustString())
+	}
+	return values
+}
@@ -0,0 +1,285 @@
+package datasource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/net/context"
+)
+
+// Names of the map element types set in the feature properties
+var mapElementTypes = map[int]string{
+	MapElementHost:      "host",
+	MapElementMap:       "map",
+	MapElementTrigger:   "trigger",
+	MapElementHostGroup: "hostgroup",
+	MapElementImage:     "image",
+}
+
+// Fields of the object IDs in the map elements
+var mapElementIDFields = map[int]string{
+	MapElementHost:      "hostid",
+	MapElementMap:       "sysmapid",
+	MapElementTrigger:   "triggerid",
+	MapElementHostGroup: "groupid",
+}
+
+// MapLayer is a GeoJSON feature collection of the map elements, consumable by the geomap panel
+type MapLayer struct {
+	Type     string       `json:"type"`
+	Features []MapFeature `json:"features"`
+}
+
+// MapFeature is a map element. Geometry is a point from the host inventory location, or null if the element
+// isn't a host or the host has no location. Position of the element on the Zabbix map is set in the
+// properties, so the canvas panel can use it instead.
+type MapFeature struct {
+	Type       string               `json:"type"`
+	Geometry   *MapGeometry         `json:"geometry"`
+	Properties MapFeatureProperties `json:"properties"`
+}
+
+// MapGeometry is a GeoJSON point with longitude and latitude coordinates
+type MapGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// MapFeatureProperties are properties of the map element with the current problems of the element objects:
+// number of the triggers in problem state and max severity of them
+type MapFeatureProperties struct {
+	SelementID  string   `json:"selementid"`
+	Name        string   `json:"name"`
+	ElementType string   `json:"elementType"`
+	ElementIDs  []string `json:"elementIds"`
+	X           int      `json:"x"`
+	Y           int      `json:"y"`
+	Problems    int      `json:"problems"`
+	MaxSeverity *int     `json:"maxSeverity,omitempty"`
+}
+
+// mapHost is a host of the map element with the inventory location
+type mapHost struct {
+	name     string
+	location *MapGeometry
+}
+
+// getMapLayer converts elements of the Zabbix map into GeoJSON features with live problem counts
+func (ds *ZabbixDatasourceInstance) getMapLayer(ctx context.Context, sysmapid string) (*MapLayer, error) {
+	if sysmapid == "" {
+		return nil, errors.New("sysmapid is required")
+	}
+
+	sysMap, err := ds.getMap(ctx, sysmapid)
+	if err != nil {
+		return nil, err
+	}
+
+	elementIDs := map[int][]string{}
+	for _, element := range sysMap.Elements {
+		elementIDs[element.ElementType] = append(elementIDs[element.ElementType], element.objectIDs()...)
+	}
+
+	hosts, err := ds.getMapHosts(ctx, elementIDs[MapElementHost])
+	if err != nil {
+		return nil, err
+	}
+
+	// Problem triggers of the hosts, host groups and triggers of the map, by trigger id
+	triggers := map[string]Trigger{}
+	for _, elementType := range []int{MapElementHost, MapElementHostGroup, MapElementTrigger} {
+		ids := elementIDs[elementType]
+		if len(ids) == 0 {
+			continue
+		}
+		problemTriggers, err := ds.getMapProblemTriggers(ctx, mapElementIDFields[elementType]+"s", ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, trigger := range problemTriggers {
+			triggers[trigger.ID] = trigger
+		}
+	}
+
+	layer := &MapLayer{Type: "FeatureCollection", Features: make([]MapFeature, 0, len(sysMap.Elements))}
+	for _, element := range sysMap.Elements {
+		ids := element.objectIDs()
+		feature := MapFeature{
+			Type: "Feature",
+			Properties: MapFeatureProperties{
+				SelementID:  element.ID,
+				Name:        element.Label,
+				ElementType: mapElementTypes[element.ElementType],
+				ElementIDs:  ids,
+				X:           element.X,
+				Y:           element.Y,
+			},
+		}
+		if element.ElementType == MapElementHost && len(ids) == 1 {
+			if host, ok := hosts[ids[0]]; ok {
+				feature.Properties.Name = host.name
+				feature.Geometry = host.location
+			}
+		}
+
+		for _, trigger := range triggers {
+			if !elementHasTrigger(element.ElementType, ids, trigger) {
+				continue
+			}
+			feature.Properties.Problems++
+			if feature.Properties.MaxSeverity == nil || trigger.Priority > *feature.Properties.MaxSeverity {
+				severity := trigger.Priority
+				feature.Properties.MaxSeverity = &severity
+			}
+		}
+		layer.Features = append(layer.Features, feature)
+	}
+	return layer, nil
+}
+
+// objectIDs returns IDs of the hosts, host groups, triggers or maps of the element
+func (e MapElement) objectIDs() []string {
+	idField, ok := mapElementIDFields[e.ElementType]
+	if !ok {
+		return []string{}
+	}
+	ids := []string{}
+	for _, element := range e.Elements {
+		if id := element[idField]; id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 && e.ElementID != "" && e.ElementID != "0" {
+		ids = append(ids, e.ElementID)
+	}
+	return ids
+}
+
+// elementHasTrigger returns whether the trigger belongs to the objects of the element
+func elementHasTrigger(elementType int, ids []string, trigger Trigger) bool {
+	for _, id := range ids {
+		switch elementType {
+		case MapElementHost:
+			for _, host := range trigger.Hosts {
+				if host.ID == id {
+					return true
+				}
+			}
+		case MapElementHostGroup:
+			for _, group := range trigger.Groups {
+				if group.ID == id {
+					return true
+				}
+			}
+		case MapElementTrigger:
+			if trigger.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (ds *ZabbixDatasourceInstance) getMap(ctx context.Context, sysmapid string) (*SysMap, error) {
+	params := ZabbixAPIParams{
+		"output":          []string{"sysmapid", "name"},
+		"sysmapids":       []string{sysmapid},
+		"selectSelements": "extend",
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "map.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	mapsJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var maps []SysMap
+	err = json.Unmarshal(mapsJSON, &maps)
+	if err != nil {
+		return nil, err
+	}
+	if len(maps) == 0 {
+		return nil, fmt.Errorf("map not found: %s", sysmapid)
+	}
+	return &maps[0], nil
+}
+
+// getMapHosts returns hosts with the location from the inventory, by host id
+func (ds *ZabbixDatasourceInstance) getMapHosts(ctx context.Context, hostids []string) (map[string]mapHost, error) {
+	hosts := map[string]mapHost{}
+	if len(hostids) == 0 {
+		return hosts, nil
+	}
+
+	params := ZabbixAPIParams{
+		"output":          []string{"hostid", "name"},
+		"hostids":         hostids,
+		"selectInventory": []string{"location_lat", "location_lon"},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "host.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range response.MustArray() {
+		host, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hostid, _ := host["hostid"].(string)
+		name, _ := host["name"].(string)
+		// Inventory is an empty array if it's disabled for the host
+		inventory, _ := host["inventory"].(map[string]interface{})
+		hosts[hostid] = mapHost{name: name, location: inventoryLocation(inventory)}
+	}
+	return hosts, nil
+}
+
+// inventoryLocation returns point of the host inventory latitude and longitude or nil if they are not set
+func inventoryLocation(inventory map[string]interface{}) *MapGeometry {
+	latValue, _ := inventory["location_lat"].(string)
+	lonValue, _ := inventory["location_lon"].(string)
+	lat, err := strconv.ParseFloat(latValue, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil
+	}
+	lon, err := strconv.ParseFloat(lonValue, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil
+	}
+	return &MapGeometry{Type: "Point", Coordinates: []float64{lon, lat}}
+}
+
+// getMapProblemTriggers returns monitored triggers in problem state of the hosts, host groups or triggers
+func (ds *ZabbixDatasourceInstance) getMapProblemTriggers(ctx context.Context, idField string, ids []string) (Triggers, error) {
+	params := ZabbixAPIParams{
+		"output":        []string{"triggerid", "priority"},
+		idField:         ids,
+		"filter":        map[string]interface{}{"value": 1},
+		"monitored":     true,
+		"skipDependent": true,
+		"selectHosts":   []string{"hostid"},
+		"selectGroups":  []string{"groupid"},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "trigger.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	triggersJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	triggers := Triggers{}
+	err = json.Unmarshal(triggersJSON, &triggers)
+	if err != nil {
+		return nil, err
+	}
+	return triggers, nil
+}
@@ -0,0 +1,91 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapElementObjectIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		element MapElement
+		want    []string
+	}{
+		{
+			name:    "Host",
+			element: MapElement{ElementType: MapElementHost, Elements: []map[string]string{{"hostid": "10"}}},
+			want:    []string{"10"},
+		},
+		{
+			name:    "Triggers",
+			element: MapElement{ElementType: MapElementTrigger, Elements: []map[string]string{{"triggerid": "1"}, {"triggerid": "2"}}},
+			want:    []string{"1", "2"},
+		},
+		{
+			name:    "Legacy element ID",
+			element: MapElement{ElementType: MapElementHostGroup, ElementID: "5"},
+			want:    []string{"5"},
+		},
+		{
+			name:    "Image",
+			element: MapElement{ElementType: MapElementImage, ElementID: "0"},
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.element.objectIDs())
+		})
+	}
+}
+
+func TestInventoryLocation(t *testing.T) {
+	assert.Equal(t, &MapGeometry{Type: "Point", Coordinates: []float64{13.4, 52.52}}, inventoryLocation(map[string]interface{}{
+		"location_lat": "52.52",
+		"location_lon": "13.4",
+	}))
+	assert.Nil(t, inventoryLocation(map[string]interface{}{"location_lat": "", "location_lon": ""}))
+	assert.Nil(t, inventoryLocation(map[string]interface{}{"location_lat": "95", "location_lon": "13.4"}))
+	assert.Nil(t, inventoryLocation(nil))
+}
+
+func TestGetMapLayer(t *testing.T) {
+	// Same response is used for the map, hosts and problem triggers
+	dsInstance := MockZabbixDataSource(`{"result":[{
+		"sysmapid":"1","name":"Core",
+		"selements":[
+			{"selementid":"11","elementtype":"0","elements":[{"hostid":"10"}],"label":"{HOST.NAME}","x":"100","y":"50"},
+			{"selementid":"12","elementtype":"4","elements":[],"label":"Logo","x":"0","y":"0"}
+		],
+		"hostid":"10","inventory":{"location_lat":"52.52","location_lon":"13.4"},
+		"triggerid":"100","priority":"4","hosts":[{"hostid":"10"}]
+	}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	layer, err := dsInstance.getMapLayer(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "FeatureCollection", layer.Type)
+	assert.Len(t, layer.Features, 2)
+
+	host := layer.Features[0]
+	assert.Equal(t, &MapGeometry{Type: "Point", Coordinates: []float64{13.4, 52.52}}, host.Geometry)
+	assert.Equal(t, "Core", host.Properties.Name)
+	assert.Equal(t, "host", host.Properties.ElementType)
+	assert.Equal(t, 100, host.Properties.X)
+	assert.Equal(t, 1, host.Properties.Problems)
+	if assert.NotNil(t, host.Properties.MaxSeverity) {
+		assert.Equal(t, 4, *host.Properties.MaxSeverity)
+	}
+
+	image := layer.Features[1]
+	assert.Nil(t, image.Geometry)
+	assert.Equal(t, "Logo", image.Properties.Name)
+	assert.Equal(t, 0, image.Properties.Problems)
+	assert.Nil(t, image.Properties.MaxSeverity)
+
+	_, err = dsInstance.getMapLayer(context.Background(), "")
+	assert.EqualError(t, err, "sysmapid is required")
+}
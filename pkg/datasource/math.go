@@ -0,0 +1,509 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// queryMath evaluates query expression over the series returned by other queries (referenced by $RefID).
+// Series of the different queries are matched by the query matchBy label.
+func queryMath(query *QueryModel, responses map[string]backend.DataResponse) (*data.Frame, error) {
+	expr, err := parseMathExpression(query.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluator := &mathEvaluator{matchBy: query.MatchBy, responses: responses}
+	result, err := evaluator.eval(expr)
+	if err != nil {
+		return nil, err
+	}
+	if result.series == nil {
+		return nil, errors.New("math expression should reference at least one query")
+	}
+
+	series, err := applyFunctions(result.series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+	return convertSeriesToFrame(series, query.Options), nil
+}
+
+// sortMathQueries orders math queries so queries referenced by other math queries are evaluated first. Queries
+// having circular references are returned with the errors by the RefID, they can't be evaluated.
+func sortMathQueries(queries []backend.DataQuery) ([]backend.DataQuery, map[string]error) {
+	byRefID := make(map[string]backend.DataQuery, len(queries))
+	refs := make(map[string][]string, len(queries))
+	for _, q := range queries {
+		byRefID[q.RefID] = q
+		// Invalid queries don't depend on others, parsing error is returned by queryMath
+		query, err := ReadQuery(q)
+		if err != nil {
+			continue
+		}
+		if expr, err := parseMathExpression(query.Expression); err == nil {
+			refs[q.RefID] = mathExpressionRefs(expr, nil)
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(queries))
+	sorted := make([]backend.DataQuery, 0, len(queries))
+	circularRefs := map[string]error{}
+	var visit func(refID string, path []string)
+	visit = func(refID string, path []string) {
+		switch state[refID] {
+		case visiting:
+			for i, pathRefID := range path {
+				if pathRefID == refID {
+					cycle := append(append([]string{}, path[i:]...), refID)
+					for _, cycleRefID := range path[i:] {
+						circularRefs[cycleRefID] = fmt.Errorf("circular reference in math expression: %s", strings.Join(cycle, " -> "))
+					}
+					break
+				}
+			}
+			return
+		case visited:
+			return
+		}
+
+		state[refID] = visiting
+		path = append(path, refID)
+		for _, ref := range refs[refID] {
+			if _, ok := byRefID[ref]; ok {
+				visit(ref, path)
+			}
+		}
+		state[refID] = visited
+		sorted = append(sorted, byRefID[refID])
+	}
+	for _, q := range queries {
+		visit(q.RefID, nil)
+	}
+	return sorted, circularRefs
+}
+
+// mathExpressionRefs returns query references of the expression appended to refs
+func mathExpressionRefs(node *mathNode, refs []string) []string {
+	if node == nil {
+		return refs
+	}
+	if node.nodeType == mathNodeRef {
+		return append(refs, node.ref)
+	}
+	return mathExpressionRefs(node.right, mathExpressionRefs(node.left, refs))
+}
+
+type mathNodeType int
+
+const (
+	mathNodeNumber mathNodeType = iota
+	mathNodeRef
+	mathNodeUnary
+	mathNodeBinary
+)
+
+type mathNode struct {
+	nodeType mathNodeType
+	value    float64
+	ref      string
+	op       rune
+	left     *mathNode
+	right    *mathNode
+}
+
+// parseMathExpression parses arithmetic expression with numbers, query references ($A),
+// +, -, *, / operators and parentheses.
+func parseMathExpression(expression string) (*mathNode, error) {
+	p := &mathParser{input: []rune(expression)}
+	node, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d in math expression", p.input[p.pos], p.pos)
+	}
+	return node, nil
+}
+
+type mathParser struct {
+	input []rune
+	pos   int
+}
+
+func (p *mathParser) parseExpression() (*mathNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '+' && p.input[p.pos] != '-') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &mathNode{nodeType: mathNodeBinary, op: op, left: left, right: right}
+	}
+}
+
+func (p *mathParser) parseTerm() (*mathNode, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '*' && p.input[p.pos] != '/') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &mathNode{nodeType: mathNodeBinary, op: op, left: left, right: right}
+	}
+}
+
+func (p *mathParser) parseFactor() (*mathNode, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return nil, errors.New("unexpected end of math expression")
+	}
+
+	r := p.input[p.pos]
+	switch {
+	case r == '-':
+		p.pos++
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &mathNode{nodeType: mathNodeUnary, op: '-', left: operand}, nil
+	case r == '(':
+		p.pos++
+		node, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return nil, errors.New("missing closing parenthesis in math expression")
+		}
+		p.pos++
+		return node, nil
+	case r == '$':
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '_') {
+			p.pos++
+		}
+		if start == p.pos {
+			return nil, fmt.Errorf("missing query reference at position %d in math expression", start)
+		}
+		return &mathNode{nodeType: mathNodeRef, ref: string(p.input[start:p.pos])}, nil
+	case unicode.IsDigit(r) || r == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number in math expression: %w", err)
+		}
+		return &mathNode{nodeType: mathNodeNumber, value: value}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d in math expression", r, p.pos)
+	}
+}
+
+func (p *mathParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// mathValue is a result of the expression evaluation: either scalar or set of series
+type mathValue struct {
+	scalar float64
+	series []*timeseries.TimeSeriesData
+}
+
+type mathEvaluator struct {
+	matchBy   string
+	responses map[string]backend.DataResponse
+}
+
+func (e *mathEvaluator) eval(node *mathNode) (mathValue, error) {
+	switch node.nodeType {
+	case mathNodeNumber:
+		return mathValue{scalar: node.value}, nil
+	case mathNodeRef:
+		response, ok := e.responses[node.ref]
+		if !ok {
+			return mathValue{}, fmt.Errorf("query %s referenced in math expression not found", node.ref)
+		}
+		if response.Error != nil {
+			return mathValue{}, fmt.Errorf("query %s referenced in math expression failed: %w", node.ref, response.Error)
+		}
+		return mathValue{series: framesToSeries(response.Frames)}, nil
+	case mathNodeUnary:
+		operand, err := e.eval(node.left)
+		if err != nil {
+			return mathValue{}, err
+		}
+		return e.binary('*', operand, mathValue{scalar: -1}), nil
+	default:
+		left, err := e.eval(node.left)
+		if err != nil {
+			return mathValue{}, err
+		}
+		right, err := e.eval(node.right)
+		if err != nil {
+			return mathValue{}, err
+		}
+		return e.binary(node.op, left, right), nil
+	}
+}
+
+// binary applies operator to the values. Series sets are matched by the label if both have several series,
+// otherwise single series (or scalar) is applied to each series of the other set.
+func (e *mathEvaluator) binary(op rune, left mathValue, right mathValue) mathValue {
+	if left.series == nil && right.series == nil {
+		return mathValue{scalar: applyMathOp(op, left.scalar, right.scalar)}
+	}
+
+	result := make([]*timeseries.TimeSeriesData, 0)
+	switch {
+	case right.series == nil:
+		for _, s := range left.series {
+			result = append(result, mathSeriesScalar(s, func(v float64) float64 { return applyMathOp(op, v, right.scalar) }))
+		}
+	case left.series == nil:
+		for _, s := range right.series {
+			result = append(result, mathSeriesScalar(s, func(v float64) float64 { return applyMathOp(op, left.scalar, v) }))
+		}
+	case len(right.series) == 1:
+		for _, s := range left.series {
+			result = append(result, mathSeriesSeries(op, s, right.series[0], s))
+		}
+	case len(left.series) == 1:
+		for _, s := range right.series {
+			result = append(result, mathSeriesSeries(op, left.series[0], s, s))
+		}
+	default:
+		rightByLabel := make(map[string]*timeseries.TimeSeriesData, len(right.series))
+		for _, s := range right.series {
+			key := s.Meta.Labels[e.matchBy]
+			if _, ok := rightByLabel[key]; !ok {
+				rightByLabel[key] = s
+			}
+		}
+		for _, s := range left.series {
+			if matched, ok := rightByLabel[s.Meta.Labels[e.matchBy]]; ok {
+				result = append(result, mathSeriesSeries(op, s, matched, s))
+			}
+		}
+	}
+	return mathValue{series: result}
+}
+
+// applyMathOp returns the result of operation, division by zero gives NaN (converted to null in series).
+func applyMathOp(op rune, a float64, b float64) float64 {
+	switch op {
+	case '+':
+		return a + b
+	case '-':
+		return a - b
+	case '*':
+		return a * b
+	default:
+		if b == 0 {
+			return math.NaN()
+		}
+		return a / b
+	}
+}
+
+func mathSeriesScalar(s *timeseries.TimeSeriesData, f func(v float64) float64) *timeseries.TimeSeriesData {
+	result := newMathResultSeries(s)
+	for _, p := range s.TS {
+		var value *float64
+		if p.Value != nil {
+			value = nonNaN(f(*p.Value))
+		}
+		result.Add(timeseries.TimePoint{Time: p.Time, Value: value})
+	}
+	return result
+}
+
+// mathSeriesSeries applies operator to the points of the series with the same timestamps. Result series
+// gets the name and labels of the meta series.
+func mathSeriesSeries(op rune, left *timeseries.TimeSeriesData, right *timeseries.TimeSeriesData, meta *timeseries.TimeSeriesData) *timeseries.TimeSeriesData {
+	rightValues := make(map[int64]*float64, right.Len())
+	for _, p := range right.TS {
+		rightValues[p.Time.UnixNano()] = p.Value
+	}
+
+	result := newMathResultSeries(meta)
+	for _, p := range left.TS {
+		var value *float64
+		if rightValue := rightValues[p.Time.UnixNano()]; p.Value != nil && rightValue != nil {
+			value = nonNaN(applyMathOp(op, *p.Value, *rightValue))
+		}
+		result.Add(timeseries.TimePoint{Time: p.Time, Value: value})
+	}
+	return result
+}
+
+func newMathResultSeries(meta *timeseries.TimeSeriesData) *timeseries.TimeSeriesData {
+	result := timeseries.NewTimeSeriesData()
+	result.Meta.Name = meta.Meta.Name
+	result.Meta.Labels = meta.Meta.Labels
+	return result
+}
+
+func nonNaN(value float64) *float64 {
+	if math.IsNaN(value) {
+		return nil
+	}
+	return &value
+}
+
+// framesToSeries converts numeric fields of the time series frames into series. Field display name
+// is used as a series name.
+func framesToSeries(frames []*data.Frame) []*timeseries.TimeSeriesData {
+	series := make([]*timeseries.TimeSeriesData, 0)
+	for _, frame := range frames {
+		var timeField *data.Field
+		for _, field := range frame.Fields {
+			if field.Type() == data.FieldTypeTime || field.Type() == data.FieldTypeNullableTime {
+				timeField = field
+				break
+			}
+		}
+		if timeField == nil {
+			continue
+		}
+
+		if isLongFrame(frame) {
+			series = append(series, longFrameToSeries(frame, timeField)...)
+			continue
+		}
+
+		for _, field := range frame.Fields {
+			if !field.Type().Numeric() {
+				continue
+			}
+			s := timeseries.NewTimeSeriesData()
+			s.Meta.Name = field.Name
+			if field.Config != nil && field.Config.DisplayNameFromDS != "" {
+				s.Meta.Name = field.Config.DisplayNameFromDS
+			}
+			s.Meta.Labels = field.Labels
+			for i := 0; i < field.Len(); i++ {
+				t, ok := timeField.ConcreteAt(i)
+				if !ok {
+					continue
+				}
+				var value *float64
+				if _, ok := field.ConcreteAt(i); ok {
+					if v, err := field.FloatAt(i); err == nil {
+						value = &v
+					}
+				}
+				s.Add(timeseries.TimePoint{Time: t.(time.Time), Value: value})
+			}
+			series = append(series, s)
+		}
+	}
+	return series
+}
+
+func isLongFrame(frame *data.Frame) bool {
+	for _, field := range frame.Fields {
+		if field.Type() == data.FieldTypeString {
+			return true
+		}
+	}
+	return false
+}
+
+// longFrameToSeries groups rows of the long frame by the values of its string fields (used as labels).
+// Each numeric field of the group becomes a series.
+func longFrameToSeries(frame *data.Frame, timeField *data.Field) []*timeseries.TimeSeriesData {
+	var labelFields, valueFields []*data.Field
+	for _, field := range frame.Fields {
+		if field.Type() == data.FieldTypeString {
+			labelFields = append(labelFields, field)
+		} else if field.Type().Numeric() {
+			valueFields = append(valueFields, field)
+		}
+	}
+
+	var keys []string
+	seriesByKey := make(map[string][]*timeseries.TimeSeriesData)
+	for i := 0; i < frame.Rows(); i++ {
+		t, ok := timeField.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+
+		labels := data.Labels{}
+		for _, field := range labelFields {
+			labels[field.Name] = field.At(i).(string)
+		}
+		key := labels.String()
+		group, ok := seriesByKey[key]
+		if !ok {
+			name := labels["item"]
+			if labels["host"] != "" {
+				name = fmt.Sprintf("%s: %s", labels["host"], labels["item"])
+			}
+			for range valueFields {
+				s := timeseries.NewTimeSeriesData()
+				s.Meta.Name = name
+				s.Meta.Labels = labels
+				group = append(group, s)
+			}
+			seriesByKey[key] = group
+			keys = append(keys, key)
+		}
+
+		for j, field := range valueFields {
+			var value *float64
+			if _, ok := field.ConcreteAt(i); ok {
+				if v, err := field.FloatAt(i); err == nil {
+					value = &v
+				}
+			}
+			group[j].Add(timeseries.TimePoint{Time: t.(time.Time), Value: value})
+		}
+	}
+
+	series := make([]*timeseries.TimeSeriesData, 0, len(keys))
+	for _, key := range keys {
+		series = append(series, seriesByKey[key]...)
+	}
+	return series
+}
@@ -0,0 +1,115 @@
+package datasource
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMathExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        bool
+	}{
+		{expression: "$A / $B * 100"},
+		{expression: "-($A + 2.5) * $B_1"},
+		{expression: "$A +", err: true},
+		{expression: "($A + $B", err: true},
+		{expression: "$A $B", err: true},
+		{expression: "$ + 1", err: true},
+		{expression: "$A % 2", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := parseMathExpression(tt.expression)
+			assert.Equal(t, tt.err, err != nil)
+		})
+	}
+}
+
+func TestQueryMath(t *testing.T) {
+	mockHostSeries := func(host string, item string, values ...float64) *timeseries.TimeSeriesData {
+		s := mockSeries(host+": "+item, values...)
+		s.Meta.Labels = data.Labels{"host": host, "item": item}
+		return s
+	}
+	responses := map[string]backend.DataResponse{
+		"A": {Frames: []*data.Frame{convertTimeSeriesToDataFrame([]*timeseries.TimeSeriesData{
+			mockHostSeries("web01", "hits", 50, 30),
+			mockHostSeries("web02", "hits", 10, 0),
+		}, FillModeNull)}},
+		"B": {Frames: []*data.Frame{convertTimeSeriesToDataFrame([]*timeseries.TimeSeriesData{
+			mockHostSeries("web02", "requests", 20, 0),
+			mockHostSeries("web01", "requests", 100, 60),
+		}, FillModeNull)}},
+		"C": {Frames: []*data.Frame{convertTimeSeriesToDataFrame([]*timeseries.TimeSeriesData{
+			mockHostSeries("db01", "total", 2, 4),
+		}, FillModeNull)}},
+	}
+
+	query := &QueryModel{Expression: "$A / $B * 100", MatchBy: "host", Options: QueryOptions{FillMode: FillModeNull}}
+	frame, err := queryMath(query, responses)
+	assert.Nil(t, err)
+	assert.Len(t, frame.Fields, 3)
+	assert.Equal(t, "web01: hits", frame.Fields[1].Config.DisplayNameFromDS)
+	assert.Equal(t, floatPtr(50), frame.Fields[1].At(0))
+	assert.Equal(t, floatPtr(50), frame.Fields[1].At(1))
+	assert.Equal(t, floatPtr(50), frame.Fields[2].At(0))
+	assert.Nil(t, frame.Fields[2].At(1))
+
+	query.Expression = "1 - $A / $C"
+	frame, err = queryMath(query, responses)
+	assert.Nil(t, err)
+	assert.Equal(t, floatPtr(-24), frame.Fields[1].At(0))
+	assert.Equal(t, floatPtr(-6.5), frame.Fields[1].At(1))
+
+	longFrame := convertSeriesToFrame([]*timeseries.TimeSeriesData{
+		mockHostSeries("web01", "requests", 100, 60),
+		mockHostSeries("web02", "requests", 20, 0),
+	}, QueryOptions{FrameFormat: FrameFormatLong})
+	responses["L"] = backend.DataResponse{Frames: []*data.Frame{longFrame}}
+	query.Expression = "$A / $L"
+	frame, err = queryMath(query, responses)
+	assert.Nil(t, err)
+	assert.Len(t, frame.Fields, 3)
+	assert.Equal(t, floatPtr(0.5), frame.Fields[2].At(0))
+
+	query.Expression = "$D * 2"
+	_, err = queryMath(query, responses)
+	assert.NotNil(t, err)
+
+	query.Expression = "2 * 2"
+	_, err = queryMath(query, responses)
+	assert.NotNil(t, err)
+}
+
+func TestSortMathQueries(t *testing.T) {
+	mathQuery := func(refID string, expression string) backend.DataQuery {
+		return backend.DataQuery{RefID: refID, JSON: []byte(`{"mode":` + fmt.Sprint(QueryModeMath) + `,"expression":"` + expression + `"}`)}
+	}
+	queries := []backend.DataQuery{
+		mathQuery("A", "$B + $C"),
+		mathQuery("B", "$C * 2"),
+		mathQuery("D", "$E"),
+		mathQuery("E", "$D / 2"),
+		mathQuery("F", "$F"),
+		mathQuery("G", "$D + 1"),
+		mathQuery("H", "$"),
+	}
+
+	sorted, circularRefs := sortMathQueries(queries)
+	refIDs := make([]string, 0, len(sorted))
+	for _, q := range sorted {
+		refIDs = append(refIDs, q.RefID)
+	}
+	assert.Equal(t, []string{"B", "A", "E", "D", "F", "G", "H"}, refIDs)
+	assert.Len(t, circularRefs, 3)
+	assert.EqualError(t, circularRefs["D"], "circular reference in math expression: D -> E -> D")
+	assert.EqualError(t, circularRefs["E"], "circular reference in math expression: D -> E -> D")
+	assert.EqualError(t, circularRefs["F"], "circular reference in math expression: F -> F")
+}
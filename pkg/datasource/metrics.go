@@ -0,0 +1,128 @@
+package datasource
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace of the plugin metrics, metrics are exposed by the plugin SDK and scraped by Grafana. Metrics are
+// labeled by the data source UID, which is kept when the data source is renamed.
+const metricsNamespace = "grafana_plugin_zabbix"
+
+// Reasons of the failed logins
+const (
+	loginFailureInvalidCredentials = "invalid_credentials"
+	loginFailureNoPermission       = "no_permission"
+	loginFailureTooManyRequests    = "too_many_requests"
+	loginFailureDBDown             = "db_down"
+	loginFailureNotAPIEndpoint     = "not_api_endpoint"
+	loginFailureAPIError           = "api_error"
+	loginFailureHTTPError          = "http_error"
+	loginFailureNetwork            = "network"
+)
+
+// Reasons of the repeated API requests
+const (
+	retryReasonRelogin         = "relogin"
+	retryReasonTooManyRequests = "too_many_requests"
+)
+
+var (
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Duration of the Zabbix API requests by data source and method",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"datasource_uid", "method"})
+
+	apiRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_request_errors_total",
+		Help:      "Number of the failed Zabbix API requests by data source and method",
+	}, []string{"datasource_uid", "method"})
+
+	apiRequestRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_request_retries_total",
+		Help:      "Number of the repeated Zabbix API requests by data source, method and reason",
+	}, []string{"datasource_uid", "method", "reason"})
+
+	apiActiveRequests = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_active_requests",
+		Help:      "Number of the Zabbix API requests in progress by data source",
+	}, []string{"datasource_uid"})
+
+	loginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "login_attempts_total",
+		Help:      "Number of the Zabbix API login attempts by data source",
+	}, []string{"datasource_uid"})
+
+	loginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "login_failures_total",
+		Help:      "Number of the failed Zabbix API logins by data source and reason",
+	}, []string{"datasource_uid", "reason"})
+
+	sessionRelogins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "session_relogins_total",
+		Help:      "Number of the Zabbix API logins repeated because the session expired, by data source",
+	}, []string{"datasource_uid"})
+
+	lastLoginSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_login_success_timestamp_seconds",
+		Help:      "Time of the last successful Zabbix API login by data source",
+	}, []string{"datasource_uid"})
+)
+
+func init() {
+	prometheus.MustRegister(apiRequestDuration, apiRequestErrors, apiRequestRetries, apiActiveRequests)
+	prometheus.MustRegister(loginAttempts, loginFailures, sessionRelogins, lastLoginSuccess)
+}
+
+// observeAPIRequest makes request updating the metrics of the data source API requests. Failed request is kept
+// as the last error of the data source.
+func (ds *ZabbixDatasourceInstance) observeAPIRequest(method string, request func() (*simplejson.Json, error)) (*simplejson.Json, error) {
+	activeRequests := apiActiveRequests.WithLabelValues(ds.dsInfo.UID)
+	activeRequests.Inc()
+	defer activeRequests.Dec()
+
+	start := time.Now()
+	result, err := request()
+	apiRequestDuration.WithLabelValues(ds.dsInfo.UID, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		apiRequestErrors.WithLabelValues(ds.dsInfo.UID, method).Inc()
+		ds.apiState.setError(method, err)
+	}
+	return result, err
+}
+
+// loginFailureReason returns category of the login error used as the metric label
+func loginFailureReason(err error) string {
+	var apiErr *zabbixapi.APIError
+	var httpErr *zabbixapi.HTTPError
+	switch {
+	case errors.Is(err, zabbixapi.ErrInvalidCredentials):
+		return loginFailureInvalidCredentials
+	case errors.Is(err, zabbixapi.ErrNoPermission):
+		return loginFailureNoPermission
+	case errors.Is(err, zabbixapi.ErrTooManyRequests):
+		return loginFailureTooManyRequests
+	case errors.Is(err, zabbixapi.ErrDBDown):
+		return loginFailureDBDown
+	case errors.Is(err, zabbixapi.ErrNotAPIEndpoint):
+		return loginFailureNotAPIEndpoint
+	case errors.As(err, &apiErr):
+		return loginFailureAPIError
+	case errors.As(err, &httpErr):
+		return loginFailureHTTPError
+	}
+	return loginFailureNetwork
+}
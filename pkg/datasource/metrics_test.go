@@ -0,0 +1,99 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIRequestMetrics(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	dsInfo := *dsInstance.dsInfo
+	dsInfo.UID = "TestMetrics"
+	dsInstance.dsInfo = &dsInfo
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	_, err := dsInstance.ZabbixRequest(context.Background(), "host.get", ZabbixAPIParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, testutil.ToFloat64(apiRequestErrors.WithLabelValues("TestMetrics", "host.get")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(apiActiveRequests.WithLabelValues("TestMetrics")))
+
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"error":{"code":-32602,"message":"Invalid params."}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	_, err = dsInstance.ZabbixRequest(context.Background(), "host.get", ZabbixAPIParams{})
+	assert.Error(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiRequestErrors.WithLabelValues("TestMetrics", "host.get")))
+}
+
+func TestTooManyRequestsRetry(t *testing.T) {
+	retryDelay := tooManyRequestsRetryDelay
+	tooManyRequestsRetryDelay = 0
+	defer func() { tooManyRequestsRetryDelay = retryDelay }()
+
+	dsInstance := MockZabbixDataSource(`{}`, 429)
+	dsInfo := *dsInstance.dsInfo
+	dsInfo.UID = "TestTooManyRequests"
+	dsInstance.dsInfo = &dsInfo
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	_, err := dsInstance.ZabbixRequest(context.Background(), "history.get", ZabbixAPIParams{})
+	assert.True(t, errors.Is(err, zabbixapi.ErrTooManyRequests))
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiRequestRetries.WithLabelValues("TestTooManyRequests", "history.get", retryReasonTooManyRequests)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(apiRequestErrors.WithLabelValues("TestTooManyRequests", "history.get")))
+}
+
+func TestLoginMetrics(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"error":{"code":-32602,"message":"Invalid params.","data":"Incorrect user name or password or account is temporarily blocked."}}`, 200)
+	dsInfo := *dsInstance.dsInfo
+	dsInfo.UID = "TestLoginMetrics"
+	dsInstance.dsInfo = &dsInfo
+
+	for i := 0; i < 2; i++ {
+		err := dsInstance.login(context.Background())
+		assert.True(t, errors.Is(err, zabbixapi.ErrInvalidCredentials))
+	}
+	assert.Equal(t, 2.0, testutil.ToFloat64(loginAttempts.WithLabelValues("TestLoginMetrics")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(loginFailures.WithLabelValues("TestLoginMetrics", loginFailureInvalidCredentials)))
+	assert.Equal(t, 2, dsInstance.apiState.loginFailures)
+
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"result":"secretauth"}`, 200)
+	err := dsInstance.login(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, testutil.ToFloat64(loginAttempts.WithLabelValues("TestLoginMetrics")))
+	assert.Equal(t, 0, dsInstance.apiState.loginFailures)
+	assert.NotZero(t, testutil.ToFloat64(lastLoginSuccess.WithLabelValues("TestLoginMetrics")))
+
+	// Expired session is re-logged in once, login fails with the same error
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"error":{"code":-32602,"message":"Invalid params.","data":"Session terminated, re-login, please."}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	_, err = dsInstance.ZabbixRequest(context.Background(), "host.get", ZabbixAPIParams{})
+	assert.True(t, errors.Is(err, zabbixapi.ErrNotAuthorized))
+	assert.Equal(t, 1.0, testutil.ToFloat64(sessionRelogins.WithLabelValues("TestLoginMetrics")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(loginFailures.WithLabelValues("TestLoginMetrics", loginFailureAPIError)))
+}
+
+func TestLoginFailureReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "no permission", err: &zabbixapi.APIError{Kind: zabbixapi.ErrNoPermission}, want: loginFailureNoPermission},
+		{name: "db down", err: &zabbixapi.APIError{Kind: zabbixapi.ErrDBDown}, want: loginFailureDBDown},
+		{name: "unknown api error", err: &zabbixapi.APIError{Code: zabbixapi.ErrCodeInternal}, want: loginFailureAPIError},
+		{name: "rate limited", err: &zabbixapi.HTTPError{StatusCode: 429}, want: loginFailureTooManyRequests},
+		{name: "bad gateway", err: &zabbixapi.HTTPError{StatusCode: 502}, want: loginFailureHTTPError},
+		{name: "wrong api path", err: &zabbixapi.HTTPError{StatusCode: 404}, want: loginFailureNotAPIEndpoint},
+		{name: "html response", err: &zabbixapi.ResponseError{Err: errors.New("invalid character '<'")}, want: loginFailureNotAPIEndpoint},
+		{name: "network", err: errors.New("connection refused"), want: loginFailureNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, loginFailureReason(tt.err))
+		})
+	}
+}
@@ -3,6 +3,7 @@ package datasource
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -10,24 +11,137 @@ import (
 
 // ZabbixDatasourceSettingsDTO model
 type ZabbixDatasourceSettingsDTO struct {
+	Username string `json:"username"`
+	// Password stored in the plain settings by the old plugin versions, secure settings (password) are used if set
+	Password string `json:"password"`
+
 	Trends      bool   `json:"trends"`
 	TrendsFrom  string `json:"trendsFrom"`
 	TrendsRange string `json:"trendsRange"`
 	CacheTTL    string `json:"cacheTTL"`
-	Timeout     string `json:"timeout"`
+	// Timeout of the Zabbix API requests in seconds. Config editor saves it as a string, provisioning files may
+	// set a number.
+	Timeout settingsNumber `json:"timeout"`
+
+	// History of the items is cached and only values newer than the cached ones are requested on refresh of
+	// the dashboard. Overlap is a last part of the cached range requested again, since values may be written to
+	// the history with delay. Total number of the cached points is limited by historyCacheMaxPoints.
+	HistoryCache          bool   `json:"historyCache"`
+	HistoryCacheOverlap   string `json:"historyCacheOverlap"`
+	HistoryCacheMaxPoints int    `json:"historyCacheMaxPoints"`
 
+	DisableDataAlignment    bool `json:"disableDataAlignment"`
 	DisableReadOnlyUsersAck bool `json:"disableReadOnlyUsersAck"`
+	AlertAcknowledge        bool `json:"alertAcknowledge"`
+
+	// Direct DB connection: history and trends are read from the Zabbix database, password is set in the
+	// secure settings (dbPassword). Schema is a layout of the ClickHouse history tables. Partitioning is a
+	// period of the history tables partitions (daily, weekly). SSL settings are used by PostgreSQL connection:
+	// sslmode and paths to the CA, client certificate and key files.
+	DBConnectionType string `json:"dbConnectionType"`
+	DBHost           string `json:"dbHost"`
+	DBName           string `json:"dbName"`
+	DBUser           string `json:"dbUser"`
+	DBSchema         string `json:"dbSchema"`
+	DBPartitioning   string `json:"dbPartitioning"`
+	DBSSLMode        string `json:"dbSslMode"`
+	DBSSLRootCert    string `json:"dbSslRootCert"`
+	DBSSLCert        string `json:"dbSslCert"`
+	DBSSLKey         string `json:"dbSslKey"`
+
+	// Grafana SQL data source used for the direct DB queries instead of the own connection settings. Grafana
+	// API token is set in the secure settings (grafanaToken).
+	DBDatasourceUID string `json:"dbDatasourceUid"`
+	GrafanaURL      string `json:"grafanaUrl"`
+
+	// DB connection pool, max lifetime of the connection is set in seconds
+	DBMaxOpenConns    int `json:"dbMaxOpenConns"`
+	DBMaxIdleConns    int `json:"dbMaxIdleConns"`
+	DBConnMaxLifetime int `json:"dbConnMaxLifetime"`
+
+	// Minimal level of the data source log messages (debug, info, warn, error), all messages are logged if not set
+	LogLevel string `json:"logLevel"`
+	// Queries taking longer are logged with the summary of the API requests, slow query logging is disabled if
+	// not set
+	SlowQueryThreshold string `json:"slowQueryThreshold"`
+	// Zabbix version like 6.0 used instead of the apiinfo.version response, for the servers behind gateways
+	// blocking that method or reporting patched versions
+	ZabbixVersion string `json:"zabbixVersion"`
+	// URL of the Zabbix frontend, like https://zabbix.example.com/zabbix, data links to the frontend pages are
+	// added to the query results if set
+	ZabbixWebURL string `json:"zabbixWebUrl"`
+	// Timezone of the Zabbix server, like Europe/Riga. SLA intervals, downsampling buckets and flexible update
+	// intervals are aligned to it. Detected from the frontend default timezone if not set.
+	Timezone string `json:"timezone"`
+	// Names and colors of the severities used instead of the Zabbix defaults in the problem and trigger frames,
+	// like [{"severity": 5, "name": "Critical", "color": "#C4162A"}]
+	Severities []SeveritySettings `json:"severities"`
 }
 
 // ZabbixDatasourceSettings model
 type ZabbixDatasourceSettings struct {
+	Username string
+	Password string
+
 	Trends      bool
 	TrendsFrom  time.Duration
 	TrendsRange time.Duration
 	CacheTTL    time.Duration
 	Timeout     time.Duration
 
+	HistoryCache          bool
+	HistoryCacheOverlap   time.Duration
+	HistoryCacheMaxPoints int
+
+	DisableDataAlignment    bool `json:"disableDataAlignment"`
 	DisableReadOnlyUsersAck bool `json:"disableReadOnlyUsersAck"`
+	AlertAcknowledge        bool `json:"alertAcknowledge"`
+
+	DBConnectionType string
+	DBHost           string
+	DBName           string
+	DBUser           string
+	DBSchema         string
+	DBPartitioning   string
+	DBSSLMode        string
+	DBSSLRootCert    string
+	DBSSLCert        string
+	DBSSLKey         string
+	DBDatasourceUID  string
+	GrafanaURL       string
+
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+
+	LogLevel           string
+	SlowQueryThreshold time.Duration
+	ZabbixVersion      string
+	ZabbixWebURL       string
+	// Timezone is nil if not set in the settings
+	Timezone   *time.Location
+	Severities Severities
+}
+
+// settingsNumber is a number of the settings saved either as a JSON number or as a string
+type settingsNumber string
+
+func (n *settingsNumber) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+		*n = ""
+	case string:
+		*n = settingsNumber(v)
+	case float64:
+		*n = settingsNumber(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("expected number, got %s", b)
+	}
+	return nil
 }
 
 type ZabbixAPIResourceRequest struct {
@@ -36,6 +150,27 @@ type ZabbixAPIResourceRequest struct {
 	Params       map[string]interface{} `json:"params,omitempty"`
 }
 
+type TriggerConditionsResourceRequest struct {
+	DatasourceId int64    `json:"datasourceId"`
+	TriggerIDs   []string `json:"triggerids"`
+}
+
+type AlertRulesResourceRequest struct {
+	DatasourceId  int64    `json:"datasourceId"`
+	DatasourceUID string   `json:"datasourceUid"`
+	OrgID         int64    `json:"orgId"`
+	TriggerIDs    []string `json:"triggerids"`
+	Folder        string   `json:"folder"`
+	Interval      string   `json:"interval"`
+}
+
+// DashboardResourceRequest is a request of the dashboard generated from the graphs of the host or template
+type DashboardResourceRequest struct {
+	DatasourceUID string `json:"datasourceUid"`
+	HostID        string `json:"hostid,omitempty"`
+	TemplateID    string `json:"templateid,omitempty"`
+}
+
 type ZabbixAPIRequest struct {
 	Method string          `json:"method"`
 	Params ZabbixAPIParams `json:"params,omitempty"`
@@ -52,6 +187,18 @@ type ZabbixAPIResourceResponse struct {
 	Result interface{} `json:"result,omitempty"`
 }
 
+// Query modes
+const (
+	QueryModeMetrics      = 0
+	QueryModeITService    = 1
+	QueryModeText         = 2
+	QueryModeItemID       = 3
+	QueryModeTriggers     = 4
+	QueryModeProblems     = 5
+	QueryModeMath         = 6
+	QueryModeTriggerState = 7
+)
+
 // QueryModel model
 type QueryModel struct {
 	Mode        int64           `json:"mode"`
@@ -59,27 +206,243 @@ type QueryModel struct {
 	Host        QueryFilter     `json:"host"`
 	Application QueryFilter     `json:"application"`
 	Item        QueryFilter     `json:"item"`
+	ItemKey     QueryFilter     `json:"itemKey"`
 	Functions   []QueryFunction `json:"functions,omitempty"`
 	Options     QueryOptions    `json:"options"`
 
+	// Text mode
+	TextFilter       string `json:"textFilter"`
+	UseCaptureGroups bool   `json:"useCaptureGroups"`
+
+	// Triggers mode
+	Triggers QueryTriggers `json:"triggers"`
+
+	// Item ID mode: comma separated item IDs, and optional host IDs items are restricted to
+	ItemIDs string `json:"itemids"`
+	HostIDs string `json:"hostids"`
+
+	// Trigger state mode: trigger selected by id, or triggers of the hosts matching Trigger filter
+	TriggerID string `json:"triggerid"`
+
+	// Problems mode: problems (default), recent, history or activeInRange
+	ShowProblems string      `json:"showProblems"`
+	Trigger      QueryFilter `json:"trigger"`
+	Tags         QueryFilter `json:"tags"`
+	// Tag filters with operators combined with the tags filter, evaluated as And/Or (default) or Or
+	TagFilters   []TagFilter `json:"tagFilters,omitempty"`
+	TagsEvalType string      `json:"tagsEvalType,omitempty"`
+
+	// IT service mode. ITService is set by the old versions of the query editor instead of the filter.
+	ITServiceFilter string         `json:"itServiceFilter"`
+	ITService       QueryITService `json:"itservice"`
+	SLAProperty     SLAProperty    `json:"slaProperty"`
+	SLAInterval     string         `json:"slaInterval"`
+
+	// Result format: time_series (default), table, heatmap or annotations (problems mode)
+	ResultFormat string `json:"resultFormat"`
+
+	// Math mode: expression over the results of other queries, like "$A / $B * 100"
+	Expression string `json:"expression"`
+	// Label used to match series of different queries (host by default)
+	MatchBy string `json:"matchBy"`
+
 	// Direct from the gRPC interfaces
-	TimeRange backend.TimeRange `json:"-"`
+	TimeRange     backend.TimeRange `json:"-"`
+	Interval      time.Duration     `json:"-"`
+	MaxDataPoints int64             `json:"-"`
+
+	// Timezone of the Zabbix server time frames are aligned to, UTC if not set
+	Location *time.Location `json:"-"`
 }
 
 // QueryOptions model
 type QueryFilter struct {
 	Filter string `json:"filter"`
+	// Objects matching the filter are excluded if their names match this pattern (regex, glob or exact name)
+	Exclude string `json:"exclude,omitempty"`
 }
 
 // QueryOptions model
 type QueryOptions struct {
-	ShowDisabledItems bool `json:"showDisabledItems"`
+	ShowDisabledItems     bool   `json:"showDisabledItems"`
+	DisableDataAlignment  bool   `json:"disableDataAlignment"`
+	UseZabbixValueMapping bool   `json:"useZabbixValueMapping"`
+	FillMode              string `json:"fillMode,omitempty"`
+	FrameFormat           string `json:"frameFormat,omitempty"`
+
+	// Show constant thresholds of the item triggers as field thresholds
+	UseTriggerThresholds bool `json:"useTriggerThresholds"`
+	// Add constant series of the item trigger thresholds, drawn as dashed lines
+	TriggerThresholdSeries bool `json:"triggerThresholdSeries"`
+
+	// Return last values of the items (from item.get) instead of history
+	UseLastValue bool `json:"useLastValue"`
+
+	// Host filters: enabled or disabled hosts, hosts in or not in maintenance, and inventory conditions like
+	// `location="Frankfurt", os~"Ubuntu"`. Any hosts are matched if not set.
+	HostStatus      string `json:"hostStatus,omitempty"`
+	HostMaintenance string `json:"hostMaintenance,omitempty"`
+	HostInventory   string `json:"hostInventory,omitempty"`
+	// Host tag filters, evaluated as And/Or (default) or Or
+	HostTags         []TagFilter `json:"hostTags,omitempty"`
+	HostTagsEvalType string      `json:"hostTagsEvalType,omitempty"`
+
+	// Return only numeric wide frames with the same label keys, for server-side expressions
+	NumericOnly bool `json:"numericOnly"`
+
+	// Streaming: push changes of the query result over Grafana Live with the stream interval, like 5s. Supported
+	// in the metrics (new values of the items), problems and trigger state (state transitions) modes.
+	Stream         bool   `json:"stream"`
+	StreamInterval string `json:"streamInterval,omitempty"`
+
+	// Text mode: extract numbers from the text values using textFilter and return numeric series
+	ExtractNumericValues bool `json:"extractNumericValues"`
+
+	// Skip items without values in the table format, series without values and their trailing empty points in
+	// the time series format
+	SkipEmptyValues bool `json:"skipEmptyValues"`
+	// Table format: function used to get the value from the series
+	TableAggregation string `json:"tableAggregation,omitempty"`
+
+	// Heatmap format: bucket upper bounds, or number of buckets between min and max value if bounds not set
+	HeatmapBuckets     []float64 `json:"heatmapBuckets,omitempty"`
+	HeatmapBucketCount int       `json:"heatmapBucketCount,omitempty"`
+
+	// Return whether items delivered data within the period (like Zabbix nodata()) instead of values
+	NoDataPeriod string `json:"nodataPeriod,omitempty"`
+	NoDataMode   string `json:"nodataMode,omitempty"`
+
+	// Problems mode filters. Acknowledged is not set to return both acknowledged and unacknowledged problems.
+	// AcknowledgedByMe returns only problems acknowledged by the Zabbix user with the Grafana user login,
+	// UnacknowledgedOlderThan returns only unacknowledged problems started earlier than the period ago.
+	MinSeverity             int    `json:"minSeverity"`
+	Severities              []int  `json:"severities,omitempty"`
+	Acknowledged            *int   `json:"acknowledged,omitempty"`
+	AcknowledgedByMe        bool   `json:"acknowledgedByMe"`
+	UnacknowledgedOlderThan string `json:"unacknowledgedOlderThan,omitempty"`
+	Limit                   int    `json:"limit,omitempty"`
+	UseTimeRange            bool   `json:"useTimeRange"`
+	// Hide problems suppressed by maintenances, they're returned with the suppressing maintenance by default
+	HideSuppressed bool `json:"hideSuppressed"`
+	// Sort of the problems applied before the limit. All matching problems are requested then, since the API
+	// can't sort problems by severity or host.
+	SortProblems string `json:"sortProblems,omitempty"`
+	// Event ID the problems are requested from, newest first. Set to the nextCursor from the custom meta of the
+	// previous page frame to get the next page of the limited result.
+	Cursor string `json:"cursor,omitempty"`
+
+	// Annotations format: names of the event tags converted to annotation tags (all tags if not set), names of
+	// the skipped tags, and whether names of the problem hosts are added as tags
+	AnnotationTags        []string `json:"annotationTags,omitempty"`
+	AnnotationTagsExclude []string `json:"annotationTagsExclude,omitempty"`
+	AnnotationHostTags    bool     `json:"annotationHostTags"`
 }
 
+// Frame formats of the time series: a field per series or time, value and label columns
+const (
+	FrameFormatWide = "wide"
+	FrameFormatLong = "long"
+)
+
+// Result formats
+const (
+	ResultFormatTimeSeries = "time_series"
+	ResultFormatTable      = "table"
+	ResultFormatHeatmap    = "heatmap"
+	// Problems as annotations with the event tags
+	ResultFormatAnnotations = "annotations"
+)
+
+// Problems to show: active problems, active and recently resolved problems, all problems started within time
+// range or all problems active at any point within time range
+const (
+	ShowProblemsProblems      = "problems"
+	ShowProblemsRecent        = "recent"
+	ShowProblemsHistory       = "history"
+	ShowProblemsActiveInRange = "activeInRange"
+)
+
+// Host status filter values
+const (
+	HostStatusEnabled  = "enabled"
+	HostStatusDisabled = "disabled"
+)
+
+// Host maintenance filter values
+const (
+	HostMaintenanceIn  = "inMaintenance"
+	HostMaintenanceOut = "notInMaintenance"
+)
+
+// Problems sort: most severe first, oldest first or by host name. Problems are sorted by event ID (newest first)
+// if not set.
+const (
+	ProblemsSortSeverity = "severity"
+	ProblemsSortAge      = "age"
+	ProblemsSortHost     = "host"
+)
+
+// Default number of the heatmap buckets if bucket bounds are not set
+const defaultHeatmapBucketCount = 10
+
+// NoData modes: 1/0 flag like Zabbix nodata() or percentage of missing points
+const (
+	NoDataModeBool    = "bool"
+	NoDataModePercent = "percent"
+)
+
+// Fill modes define how missing values are represented in the returned frame
+const (
+	FillModeNull     = "null"
+	FillModeZero     = "zero"
+	FillModePrevious = "previous"
+	FillModeLinear   = "linear"
+)
+
+// QueryITService model
+type QueryITService struct {
+	ID string `json:"serviceid"`
+}
+
+// SLAProperty model. Property is one of status, sla, okTime, problemTime or downtimeTime.
+type SLAProperty struct {
+	Name     string `json:"name"`
+	Property string `json:"property"`
+}
+
+// SLA intervals: single interval for the whole time range or calculated from the query interval
+const (
+	SLAIntervalNone = "none"
+	SLAIntervalAuto = "auto"
+)
+
+// QueryTriggers model
+type QueryTriggers struct {
+	MinSeverity  int  `json:"minSeverity"`
+	Acknowledged int  `json:"acknowledged"`
+	Count        bool `json:"count"`
+
+	// Current problems count: split counts by host or host group in addition to severity
+	GroupBy string `json:"groupBy,omitempty"`
+}
+
+// Labels current problems count can be split by
+const (
+	TriggersGroupByHost  = "host"
+	TriggersGroupByGroup = "group"
+)
+
+// Acknowledged filter values of the triggers query
+const (
+	AckFilterUnacknowledged = 0
+	AckFilterAcknowledged   = 1
+	AckFilterAll            = 2
+)
+
 // QueryOptions model
 type QueryFunction struct {
 	Def    QueryFunctionDef `json:"def"`
-	Params []string         `json:"params"`
+	Params []interface{}    `json:"params"`
 	Text   string           `json:"text"`
 }
 
@@ -96,6 +459,128 @@ func ReadQuery(query backend.DataQuery) (QueryModel, error) {
 		return model, fmt.Errorf("could not read query: %w", err)
 	}
 
+	switch model.Options.FillMode {
+	case "":
+		model.Options.FillMode = FillModeNull
+	case FillModeNull, FillModeZero, FillModePrevious, FillModeLinear:
+	default:
+		return model, fmt.Errorf("unsupported fill mode: %s", model.Options.FillMode)
+	}
+
+	switch model.Options.NoDataMode {
+	case "":
+		model.Options.NoDataMode = NoDataModeBool
+	case NoDataModeBool, NoDataModePercent:
+	default:
+		return model, fmt.Errorf("unsupported nodata mode: %s", model.Options.NoDataMode)
+	}
+
+	switch model.Options.FrameFormat {
+	case "":
+		model.Options.FrameFormat = FrameFormatWide
+	case FrameFormatWide, FrameFormatLong:
+	default:
+		return model, fmt.Errorf("unsupported frame format: %s", model.Options.FrameFormat)
+	}
+
+	if model.ResultFormat == "" {
+		model.ResultFormat = ResultFormatTimeSeries
+	}
+	if model.Options.HeatmapBucketCount <= 0 {
+		model.Options.HeatmapBucketCount = defaultHeatmapBucketCount
+	}
+	if model.Options.TableAggregation == "" {
+		model.Options.TableAggregation = "last"
+	} else if _, ok := aggValueFuncMap[model.Options.TableAggregation]; !ok {
+		return model, fmt.Errorf("unsupported table aggregation: %s", model.Options.TableAggregation)
+	}
+
+	if _, err := parseFilter(model.ItemKey.Filter); err != nil {
+		return model, fmt.Errorf("invalid item key filter: %w", err)
+	}
+	for _, f := range model.filtersByLevel() {
+		if _, err := parseFilter(f.filter.Exclude); err != nil {
+			return model, fmt.Errorf("invalid %s exclude: %w", f.level, err)
+		}
+	}
+
+	if model.Mode == QueryModeItemID {
+		if _, err := parseIDs(model.ItemIDs); err != nil {
+			return model, fmt.Errorf("invalid item ids: %w", err)
+		}
+		if _, err := parseIDs(model.HostIDs); err != nil {
+			return model, fmt.Errorf("invalid host ids: %w", err)
+		}
+	}
+
+	if _, err := parseStreamInterval(model.Options.StreamInterval); err != nil {
+		return model, fmt.Errorf("invalid stream interval: %w", err)
+	}
+	if _, ok := streamKinds[model.Mode]; model.Options.Stream && !ok {
+		return model, fmt.Errorf("streaming is not supported by the query mode %d", model.Mode)
+	}
+
+	switch model.Triggers.GroupBy {
+	case "", TriggersGroupByHost, TriggersGroupByGroup:
+	default:
+		return model, fmt.Errorf("unsupported triggers group by: %s", model.Triggers.GroupBy)
+	}
+
+	switch model.ShowProblems {
+	case "":
+		model.ShowProblems = ShowProblemsProblems
+	case ShowProblemsProblems, ShowProblemsRecent, ShowProblemsHistory, ShowProblemsActiveInRange:
+	default:
+		return model, fmt.Errorf("unsupported problems type: %s", model.ShowProblems)
+	}
+	if err := model.Options.validateAckFilters(); err != nil {
+		return model, err
+	}
+	if err := model.validateTagFilters(); err != nil {
+		return model, err
+	}
+	switch model.Options.HostStatus {
+	case "", HostStatusEnabled, HostStatusDisabled:
+	default:
+		return model, fmt.Errorf("unsupported host status: %s", model.Options.HostStatus)
+	}
+	switch model.Options.HostMaintenance {
+	case "", HostMaintenanceIn, HostMaintenanceOut:
+	default:
+		return model, fmt.Errorf("unsupported host maintenance: %s", model.Options.HostMaintenance)
+	}
+	if _, err := parseInventoryFilter(model.Options.HostInventory); err != nil {
+		return model, fmt.Errorf("invalid host inventory filter: %w", err)
+	}
+	if err := validateTags(model.Options.HostTags, model.Options.HostTagsEvalType); err != nil {
+		return model, fmt.Errorf("invalid host tags: %w", err)
+	}
+	if model.Options.Cursor != "" {
+		if _, err := strconv.ParseUint(model.Options.Cursor, 10, 64); err != nil {
+			return model, fmt.Errorf("invalid cursor: %s, expected event id", model.Options.Cursor)
+		}
+	}
+	switch model.Options.SortProblems {
+	case "", ProblemsSortSeverity, ProblemsSortAge, ProblemsSortHost:
+	default:
+		return model, fmt.Errorf("unsupported problems sort: %s", model.Options.SortProblems)
+	}
+
+	if model.Mode == QueryModeITService {
+		if model.SLAProperty.Property == "" {
+			model.SLAProperty = SLAProperty{Name: "SLA", Property: "sla"}
+		}
+		if model.SLAInterval == "" {
+			model.SLAInterval = SLAIntervalNone
+		}
+	}
+
+	if model.Mode == QueryModeMath && model.MatchBy == "" {
+		model.MatchBy = "host"
+	}
+
 	model.TimeRange = query.TimeRange
+	model.Interval = query.Interval
+	model.MaxDataPoints = query.MaxDataPoints
 	return model, nil
 }
@@ -0,0 +1,93 @@
+package datasource
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Max number of item names listed in the notice text
+const noticeMaxItemNames = 5
+
+type noticesRecorderKey struct{}
+
+// noticesRecorder collects notices explaining why the query result differs from the raw data
+// (trends used, items skipped, result truncated).
+type noticesRecorder struct {
+	mu      sync.Mutex
+	notices []data.Notice
+}
+
+// withNoticesRecorder returns context collecting notices added with it
+func withNoticesRecorder(ctx context.Context) (context.Context, *noticesRecorder) {
+	recorder := &noticesRecorder{}
+	return context.WithValue(ctx, noticesRecorderKey{}, recorder), recorder
+}
+
+// addNotice adds notice to the recorder of the context if any. Duplicated notices are skipped.
+func addNotice(ctx context.Context, severity data.NoticeSeverity, text string) {
+	recorder, ok := ctx.Value(noticesRecorderKey{}).(*noticesRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	for _, notice := range recorder.notices {
+		if notice.Text == text {
+			return
+		}
+	}
+	recorder.notices = append(recorder.notices, data.Notice{Severity: severity, Text: text})
+}
+
+// Notices returns added notices
+func (r *noticesRecorder) Notices() []data.Notice {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notices := make([]data.Notice, len(r.notices))
+	copy(notices, r.notices)
+	return notices
+}
+
+// setNotices attaches notices to the frames meta
+func setNotices(frames []*data.Frame, notices []data.Notice) {
+	if len(notices) == 0 {
+		return
+	}
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.Notices = append(frame.Meta.Notices, notices...)
+	}
+}
+
+// addItemsNotice adds notice listing names of the items, like "2 items are disabled and skipped: CPU load, Memory".
+// Error text is added to the names of the not supported items.
+func addItemsNotice(ctx context.Context, severity data.NoticeSeverity, items Items, message string) {
+	if len(items) == 0 {
+		return
+	}
+
+	names := make([]string, 0, noticeMaxItemNames)
+	for i, item := range items {
+		if i == noticeMaxItemNames {
+			names = append(names, fmt.Sprintf("and %d more", len(items)-noticeMaxItemNames))
+			break
+		}
+		name := item.ExpandItem()
+		if item.State == ItemStateNotSupported && item.Error != "" {
+			name = fmt.Sprintf("%s (%s)", name, item.Error)
+		}
+		names = append(names, name)
+	}
+
+	subject := "items are"
+	if len(items) == 1 {
+		subject = "item is"
+	}
+	addNotice(ctx, severity, fmt.Sprintf("%d %s %s: %s", len(items), subject, message, strings.Join(names, ", ")))
+}
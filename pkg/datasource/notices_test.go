@@ -0,0 +1,75 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddNotice(t *testing.T) {
+	ctx, recorder := withNoticesRecorder(context.Background())
+	addNotice(ctx, data.NoticeSeverityInfo, "Trends are used")
+	addNotice(ctx, data.NoticeSeverityInfo, "Trends are used")
+	addNotice(ctx, data.NoticeSeverityWarning, "Result is limited")
+	// Notices without recorder are ignored
+	addNotice(context.Background(), data.NoticeSeverityWarning, "Ignored")
+
+	assert.Equal(t, []data.Notice{
+		{Severity: data.NoticeSeverityInfo, Text: "Trends are used"},
+		{Severity: data.NoticeSeverityWarning, Text: "Result is limited"},
+	}, recorder.Notices())
+}
+
+func TestAddItemsNotice(t *testing.T) {
+	tests := []struct {
+		name  string
+		items Items
+		want  []data.Notice
+	}{
+		{
+			name:  "no items",
+			items: Items{},
+			want:  []data.Notice{},
+		},
+		{
+			name:  "single item",
+			items: Items{{Name: "CPU load"}},
+			want:  []data.Notice{{Severity: data.NoticeSeverityInfo, Text: "1 item is disabled and skipped: CPU load"}},
+		},
+		{
+			name:  "not supported item with error",
+			items: Items{{Name: "CPU load", State: ItemStateNotSupported, Error: "Permission denied"}, {Name: "Memory", Error: "Stale error"}},
+			want:  []data.Notice{{Severity: data.NoticeSeverityInfo, Text: "2 items are disabled and skipped: CPU load (Permission denied), Memory"}},
+		},
+		{
+			name: "too many items",
+			items: Items{
+				{Name: "Item 1"}, {Name: "Item 2"}, {Name: "Item 3"}, {Name: "Item 4"}, {Name: "Item 5"}, {Name: "Item 6"}, {Name: "Item 7"},
+			},
+			want: []data.Notice{{
+				Severity: data.NoticeSeverityInfo,
+				Text:     "7 items are disabled and skipped: Item 1, Item 2, Item 3, Item 4, Item 5, and 2 more",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, recorder := withNoticesRecorder(context.Background())
+			addItemsNotice(ctx, data.NoticeSeverityInfo, tt.items, "disabled and skipped")
+			assert.Equal(t, tt.want, recorder.Notices())
+		})
+	}
+}
+
+func TestSetNotices(t *testing.T) {
+	frame := data.NewFrame("test")
+	frame.Meta = &data.FrameMeta{Notices: []data.Notice{{Text: "Existing"}}}
+	setNotices([]*data.Frame{frame}, []data.Notice{{Text: "New"}})
+	assert.Equal(t, []data.Notice{{Text: "Existing"}, {Text: "New"}}, frame.Meta.Notices)
+
+	emptyFrame := data.NewFrame("empty")
+	setNotices([]*data.Frame{emptyFrame}, nil)
+	assert.Nil(t, emptyFrame.Meta)
+}
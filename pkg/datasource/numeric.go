@@ -0,0 +1,109 @@
+package datasource
+
+import (
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// convertToNumericFrames converts query result into numeric frames which can be consumed by server-side
+// expressions. Time series frames (wide or long) are joined into a single wide frame of float64 fields.
+// Table frames become a frame per row and numeric column, with string columns of the row set as labels.
+// Other fields (text, bool) are dropped. Label sets are made the same for all series by adding missing
+// labels with empty values.
+func convertToNumericFrames(frames []*data.Frame) []*data.Frame {
+	var timeSeriesFrames []*data.Frame
+	var numericFrames []*data.Frame
+	for _, frame := range frames {
+		if hasTimeField(frame) {
+			timeSeriesFrames = append(timeSeriesFrames, frame)
+		} else {
+			numericFrames = append(numericFrames, tableToNumericFrames(frame)...)
+		}
+	}
+
+	var series []*timeseries.TimeSeriesData
+	if len(timeSeriesFrames) > 0 {
+		series = framesToSeries(timeSeriesFrames)
+	}
+
+	labels := make([]data.Labels, 0, len(series)+len(numericFrames))
+	for _, s := range series {
+		if s.Meta.Labels == nil {
+			s.Meta.Labels = data.Labels{}
+		}
+		labels = append(labels, s.Meta.Labels)
+	}
+	for _, frame := range numericFrames {
+		labels = append(labels, frame.Fields[0].Labels)
+	}
+	unifyLabels(labels)
+
+	result := make([]*data.Frame, 0, len(numericFrames)+1)
+	if len(series) > 0 {
+		result = append(result, convertTimeSeriesToDataFrame(series, FillModeNull))
+	}
+	return append(result, numericFrames...)
+}
+
+// tableToNumericFrames returns frame with a single value for each row and numeric column of the table
+func tableToNumericFrames(frame *data.Frame) []*data.Frame {
+	var labelFields, valueFields []*data.Field
+	for _, field := range frame.Fields {
+		if field.Type() == data.FieldTypeString || field.Type() == data.FieldTypeNullableString {
+			labelFields = append(labelFields, field)
+		} else if field.Type().Numeric() {
+			valueFields = append(valueFields, field)
+		}
+	}
+
+	frames := make([]*data.Frame, 0, frame.Rows()*len(valueFields))
+	for i := 0; i < frame.Rows(); i++ {
+		for _, valueField := range valueFields {
+			labels := data.Labels{}
+			for _, labelField := range labelFields {
+				if value, ok := labelField.ConcreteAt(i); ok {
+					labels[labelField.Name] = value.(string)
+				} else {
+					labels[labelField.Name] = ""
+				}
+			}
+
+			var value *float64
+			if _, ok := valueField.ConcreteAt(i); ok {
+				if v, err := valueField.FloatAt(i); err == nil {
+					value = &v
+				}
+			}
+			numericField := data.NewField(valueField.Name, labels, []*float64{value})
+			numericField.Config = valueField.Config
+			frames = append(frames, data.NewFrame(frame.Name, numericField))
+		}
+	}
+	return frames
+}
+
+// unifyLabels adds labels missing in some of the sets with empty values, so all sets have the same keys
+func unifyLabels(labels []data.Labels) {
+	keys := make(map[string]bool)
+	for _, l := range labels {
+		for key := range l {
+			keys[key] = true
+		}
+	}
+	for _, l := range labels {
+		for key := range keys {
+			if _, ok := l[key]; !ok {
+				l[key] = ""
+			}
+		}
+	}
+}
+
+func hasTimeField(frame *data.Frame) bool {
+	for _, field := range frame.Fields {
+		if field.Type() == data.FieldTypeTime || field.Type() == data.FieldTypeNullableTime {
+			return true
+		}
+	}
+	return false
+}
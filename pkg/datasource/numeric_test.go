@@ -0,0 +1,52 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertToNumericFrames(t *testing.T) {
+	t0 := time.Unix(1600000000, 0)
+	t1 := t0.Add(time.Minute)
+
+	wide := data.NewFrame("History",
+		data.NewField("time", nil, []time.Time{t0, t1}),
+		data.NewField("CPU", data.Labels{"host": "web01", "item": "CPU"}, []*float64{floatPtr(1), floatPtr(2)}),
+	)
+	long := data.NewFrame("History",
+		data.NewField("time", nil, []time.Time{t0, t1}),
+		data.NewField("value", nil, []*float64{floatPtr(10), floatPtr(20)}),
+		data.NewField("host", nil, []string{"db01", "db01"}),
+		data.NewField("item", nil, []string{"Memory", "Memory"}),
+	)
+	table := data.NewFrame("Table",
+		data.NewField("Host", nil, []string{"web01", "web02"}),
+		data.NewField("Value", nil, []*float64{floatPtr(5), nil}),
+		data.NewField("Acknowledged", nil, []bool{true, false}),
+		data.NewField("Severity", nil, []int64{4, 5}),
+	)
+
+	frames := convertToNumericFrames([]*data.Frame{wide, long, table})
+	assert.Len(t, frames, 5)
+
+	series := frames[0]
+	assert.Equal(t, []string{"time", "CPU", "db01: Memory"}, fieldNames(series))
+	assert.Equal(t, data.Labels{"host": "web01", "item": "CPU", "Host": ""}, series.Fields[1].Labels)
+	assert.Equal(t, data.Labels{"host": "db01", "item": "Memory", "Host": ""}, series.Fields[2].Labels)
+	assert.Equal(t, floatPtr(20), series.Fields[2].At(1))
+
+	for _, frame := range frames[1:] {
+		assert.Len(t, frame.Fields, 1)
+		assert.Equal(t, data.FieldTypeNullableFloat64, frame.Fields[0].Type())
+	}
+	assert.Equal(t, "Value", frames[1].Fields[0].Name)
+	assert.Equal(t, data.Labels{"Host": "web01", "host": "", "item": ""}, frames[1].Fields[0].Labels)
+	assert.Equal(t, floatPtr(5), frames[1].Fields[0].At(0))
+	assert.Equal(t, "Severity", frames[2].Fields[0].Name)
+	assert.Equal(t, floatPtr(4), frames[2].Fields[0].At(0))
+	assert.Nil(t, frames[3].Fields[0].At(0))
+	assert.Equal(t, data.Labels{"Host": "web02", "host": "", "item": ""}, frames[4].Fields[0].Labels)
+}
@@ -0,0 +1,517 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Statuses of the problem event
+const (
+	ProblemStatusProblem  = "PROBLEM"
+	ProblemStatusResolved = "RESOLVED"
+)
+
+// Labels set from the problem and its trigger, tags with the same names are skipped
+var problemReservedLabels = map[string]bool{
+	"host":      true,
+	"group":     true,
+	"problem":   true,
+	"trigger":   true,
+	"eventid":   true,
+	"triggerid": true,
+}
+
+// queryProblems returns problems of the matching hosts as a table frame
+func (ds *ZabbixDatasourceInstance) queryProblems(ctx context.Context, query *QueryModel) (*data.Frame, error) {
+	hosts, err := ds.getHosts(ctx, newQueryFilters(query), query.Group.Filter, query.Host.Filter)
+	if err != nil {
+		return nil, err
+	}
+	var hostids []string
+	for _, host := range hosts {
+		hostids = append(hostids, host["hostid"].(string))
+	}
+
+	if len(hostids) == 0 {
+		if query.ResultFormat == ResultFormatAnnotations {
+			return convertProblemsToAnnotationsFrame(Events{}, nil, query.Options), nil
+		}
+		return convertProblemsToFrame(Events{}, nil, nil, ds.Settings.Severities, time.Now()), nil
+	}
+
+	var problems Events
+	var nextCursor string
+	if query.ShowProblems == ShowProblemsHistory || query.ShowProblems == ShowProblemsActiveInRange {
+		problems, nextCursor, err = ds.getHistoryProblems(ctx, query, hostids)
+	} else {
+		problems, nextCursor, err = ds.getProblems(ctx, query, hostids)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Options.Limit > 0 && query.Options.SortProblems == "" && len(problems) >= query.Options.Limit {
+		addNotice(ctx, data.NoticeSeverityWarning, fmt.Sprintf("Result is limited to %d problems, some problems may be missing", query.Options.Limit))
+	}
+
+	problems, err = filterProblems(problems, query.Trigger.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if query.Options.AcknowledgedByMe {
+		problems, err = ds.filterAcknowledgedByMe(ctx, problems)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	triggers, err := ds.getProblemTriggers(ctx, problems)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Options.SortProblems != "" {
+		sortProblems(problems, triggers, query.Options.SortProblems)
+		if query.Options.Limit > 0 && len(problems) > query.Options.Limit {
+			addNotice(ctx, data.NoticeSeverityWarning, fmt.Sprintf("Result is limited to %d problems, some problems may be missing", query.Options.Limit))
+			problems = problems[:query.Options.Limit]
+		}
+	}
+
+	if query.ResultFormat == ResultFormatAnnotations {
+		return convertProblemsToAnnotationsFrame(problems, triggers, query.Options), nil
+	}
+
+	triggersList := make(Triggers, 0, len(triggers))
+	for _, trigger := range triggers {
+		triggersList = append(triggersList, trigger)
+	}
+	macros, err := ds.getTriggersMacros(ctx, triggersList)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Options.NumericOnly {
+		return convertProblemsToAlertFrame(problems, triggers), nil
+	}
+	if err := ds.setProblemsSuppressedBy(ctx, problems); err != nil {
+		return nil, err
+	}
+	frame := convertProblemsToFrame(problems, triggers, macros, ds.Settings.Severities, time.Now())
+	if nextCursor != "" {
+		frame.Meta.Custom = map[string]interface{}{"nextCursor": nextCursor}
+	}
+	if ds.Settings.ZabbixWebURL != "" {
+		setProblemDataLinks(frame, ds.Settings.ZabbixWebURL)
+	}
+	return frame, nil
+}
+
+// getProblems returns active (and recently resolved if requested) problems of the hosts using problem.get, and
+// the cursor of the next page if the result is limited
+func (ds *ZabbixDatasourceInstance) getProblems(ctx context.Context, query *QueryModel, hostids []string) (Events, string, error) {
+	params := problemsQueryParams(query, hostids)
+	params["output"] = "extend"
+	params["selectTags"] = "extend"
+	if query.ShowProblems == ShowProblemsRecent {
+		params["recent"] = true
+	}
+	if query.Options.UseTimeRange {
+		params["time_from"] = query.TimeRange.From.Unix()
+		params["time_till"] = query.TimeRange.To.Unix()
+	}
+	setUnacknowledgedOlderThan(params, query.Options, time.Now())
+
+	return ds.getEventsPaged(ctx, "problem.get", params, problemsLimit(query), query.Options.Cursor)
+}
+
+// getHistoryProblems returns all problem events of the hosts within query time range using event.get, and the
+// cursor of the next page if the result is limited. Problems started within the time range are returned in the
+// history mode, and problems in the problem state at any point of the time range (including ones started
+// earlier) in the active in range mode. Clock of the recovery event is requested separately since event.get
+// doesn't return it.
+func (ds *ZabbixDatasourceInstance) getHistoryProblems(ctx context.Context, query *QueryModel, hostids []string) (Events, string, error) {
+	params := problemsQueryParams(query, hostids)
+	params["output"] = "extend"
+	params["selectTags"] = "extend"
+	params["value"] = 1
+	if query.ShowProblems == ShowProblemsActiveInRange {
+		params["problem_time_from"] = query.TimeRange.From.Unix()
+		params["problem_time_till"] = query.TimeRange.To.Unix()
+	} else {
+		params["time_from"] = query.TimeRange.From.Unix()
+		params["time_till"] = query.TimeRange.To.Unix()
+	}
+	setUnacknowledgedOlderThan(params, query.Options, time.Now())
+
+	problems, nextCursor, err := ds.getEventsPaged(ctx, "event.get", params, problemsLimit(query), query.Options.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var recoveryIDs []string
+	for _, problem := range problems {
+		if problem.REventID != "" && problem.REventID != "0" {
+			recoveryIDs = append(recoveryIDs, problem.REventID)
+		}
+	}
+	if len(recoveryIDs) == 0 {
+		return problems, nextCursor, nil
+	}
+
+	recoveries, err := ds.getEvents(ctx, "event.get", ZabbixAPIParams{
+		"output":   []string{"eventid", "clock", "ns"},
+		"eventids": recoveryIDs,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	recoveryClocks := make(map[string]int64, len(recoveries))
+	for _, recovery := range recoveries {
+		recoveryClocks[recovery.ID] = recovery.Clock
+	}
+	for i := range problems {
+		problems[i].RClock = recoveryClocks[problems[i].REventID]
+	}
+	return problems, nextCursor, nil
+}
+
+// problemsQueryParams returns params of the problems request shared by problem.get and event.get
+func problemsQueryParams(query *QueryModel, hostids []string) ZabbixAPIParams {
+	severities := query.Options.Severities
+	if len(severities) == 0 {
+		for severity := query.Options.MinSeverity; severity <= SeverityDisaster; severity++ {
+			severities = append(severities, severity)
+		}
+	}
+
+	params := ZabbixAPIParams{
+		"source":                0,
+		"object":                0,
+		"hostids":               hostids,
+		"severities":            severities,
+		"selectSuppressionData": []string{"maintenanceid", "suppress_until"},
+	}
+	if query.Options.HideSuppressed {
+		params["suppressed"] = false
+	}
+	if query.Options.Acknowledged != nil && *query.Options.Acknowledged != AckFilterAll {
+		params["acknowledged"] = *query.Options.Acknowledged == AckFilterAcknowledged
+	}
+	if query.Options.AcknowledgedByMe {
+		params["acknowledged"] = true
+		params["selectAcknowledges"] = []string{"userid", "action"}
+	}
+	if query.Options.UnacknowledgedOlderThan != "" {
+		params["acknowledged"] = false
+	}
+	setTagsParams(params, query)
+	return params
+}
+
+// problemsLimit returns number of the problems requested from the API. Sorted problems are limited after sorting,
+// so all of them are requested.
+func problemsLimit(query *QueryModel) int {
+	if query.Options.SortProblems != "" {
+		return 0
+	}
+	return query.Options.Limit
+}
+
+// sortProblems sorts problems by severity (most severe first), age (oldest first) or names of the trigger hosts.
+// Problems with the same sort value keep the order returned by the API.
+func sortProblems(problems Events, triggers map[string]Trigger, sortBy string) {
+	var less func(a, b Event) bool
+	switch sortBy {
+	case ProblemsSortSeverity:
+		less = func(a, b Event) bool { return a.Severity > b.Severity }
+	case ProblemsSortAge:
+		less = func(a, b Event) bool { return a.Clock < b.Clock || a.Clock == b.Clock && a.NS < b.NS }
+	case ProblemsSortHost:
+		hosts := make(map[string]string, len(triggers))
+		for id, trigger := range triggers {
+			hosts[id] = triggerHostLabels(trigger)["host"]
+		}
+		less = func(a, b Event) bool { return hosts[a.ObjectID] < hosts[b.ObjectID] }
+	default:
+		return
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return less(problems[i], problems[j])
+	})
+}
+
+func (ds *ZabbixDatasourceInstance) getEvents(ctx context.Context, method string, params ZabbixAPIParams) (Events, error) {
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	eventsJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	events := Events{}
+	err = json.Unmarshal(eventsJSON, &events)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// getProblemTriggers returns triggers of the problems with their hosts, mapped by trigger id
+func (ds *ZabbixDatasourceInstance) getProblemTriggers(ctx context.Context, problems Events) (map[string]Trigger, error) {
+	triggers := make(map[string]Trigger)
+	if len(problems) == 0 {
+		return triggers, nil
+	}
+
+	var triggerids []string
+	for _, problem := range problems {
+		triggerids = append(triggerids, problem.ObjectID)
+	}
+
+	params := ZabbixAPIParams{
+		"output":           []string{"triggerid", "description", "expression", "priority"},
+		"triggerids":       triggerids,
+		"expandExpression": true,
+		"selectHosts":      []string{"hostid", "name"},
+		"selectGroups":     []string{"groupid", "name"},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "trigger.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	triggersJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var result Triggers
+	err = json.Unmarshal(triggersJSON, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trigger := range result {
+		triggers[trigger.ID] = trigger
+	}
+	return triggers, nil
+}
+
+// filterProblems returns problems with the name matching trigger filter (regex or exact name), or all
+// problems if filter is empty
+func filterProblems(problems Events, filter string) (Events, error) {
+	if filter == "" {
+		return problems, nil
+	}
+
+	nameFilter, err := newNameFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		names = append(names, problem.Name)
+	}
+	nameFilter = nameFilter.forNames(names)
+
+	filtered := Events{}
+	for _, problem := range problems {
+		if nameFilter.match(problem.Name) {
+			filtered = append(filtered, problem)
+		}
+	}
+	return filtered, nil
+}
+
+// parseTags parses tags filter like "service:web, scope" into the Zabbix API tags param
+func parseTags(filter string) []map[string]string {
+	var tags []map[string]string
+	for _, tagFilter := range strings.Split(filter, ",") {
+		tagFilter = strings.TrimSpace(tagFilter)
+		if tagFilter == "" {
+			continue
+		}
+		tag := map[string]string{"tag": tagFilter}
+		if i := strings.Index(tagFilter, ":"); i >= 0 {
+			tag["tag"] = strings.TrimSpace(tagFilter[:i])
+			tag["value"] = strings.TrimSpace(tagFilter[i+1:])
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// convertProblemsToFrame returns problems as a table frame. Severity is numeric with mapping to the severity
+// name and colored cell, age is the problem duration in seconds (until recovery or now for active problems),
+// suppressed by is the names of the maintenances suppressing the problem and tags are set as JSON object.
+// Conditions are comparisons of the trigger expression with constants and user macros resolved using given macros.
+func convertProblemsToFrame(problems Events, triggers map[string]Trigger, macros *userMacros, severities Severities, now time.Time) *data.Frame {
+	timeField := data.NewFieldFromFieldType(data.FieldTypeTime, 0)
+	timeField.Name = "Time"
+	hostField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	hostField.Name = "Host"
+	problemField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	problemField.Name = "Problem"
+	severityField := data.NewFieldFromFieldType(data.FieldTypeInt64, 0)
+	severityField.Name = "Severity"
+	severityField.Config = severityFieldConfig(severities)
+	ackField := data.NewFieldFromFieldType(data.FieldTypeBool, 0)
+	ackField.Name = "Acknowledged"
+	ageField := data.NewFieldFromFieldType(data.FieldTypeFloat64, 0)
+	ageField.Name = "Age"
+	ageField.Config = &data.FieldConfig{Unit: "dtdurations"}
+	statusField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	statusField.Name = "Status"
+	suppressedByField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	suppressedByField.Name = "Suppressed by"
+	tagsField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	tagsField.Name = "Tags"
+	conditionsField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	conditionsField.Name = "Conditions"
+	eventIDField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	eventIDField.Name = problemEventIDField
+	triggerIDField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	triggerIDField.Name = problemTriggerIDField
+
+	for _, problem := range problems {
+		start := time.Unix(problem.Clock, problem.NS)
+		end := now
+		status := ProblemStatusProblem
+		if problem.REventID != "" && problem.REventID != "0" {
+			status = ProblemStatusResolved
+			if problem.RClock > 0 {
+				end = time.Unix(problem.RClock, 0)
+			}
+		}
+
+		trigger := triggers[problem.ObjectID]
+		var hostNames []string
+		for _, host := range trigger.Hosts {
+			hostNames = append(hostNames, host.Name)
+		}
+		sort.Strings(hostNames)
+
+		tags, _ := json.Marshal(tagsToLabels(problem.Tags))
+
+		timeField.Append(start)
+		hostField.Append(strings.Join(hostNames, ", "))
+		problemField.Append(problem.Name)
+		severityField.Append(int64(problem.Severity))
+		ackField.Append(problem.Acknowledged == "1")
+		ageField.Append(end.Sub(start).Seconds())
+		statusField.Append(status)
+		suppressedByField.Append(strings.Join(problem.SuppressedBy, ", "))
+		tagsField.Append(string(tags))
+		conditionsField.Append(formatTriggerConditions(parseTriggerConditions(trigger.Expression, macros, triggerHostIDs(trigger))))
+		eventIDField.Append(problem.ID)
+		triggerIDField.Append(problem.ObjectID)
+	}
+
+	frame := data.NewFrame("problems", timeField, hostField, problemField, severityField, ackField, ageField,
+		statusField, suppressedByField, tagsField, conditionsField, eventIDField, triggerIDField)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
+
+// convertProblemsToAlertFrame returns problems as a long frame with the severity value and labels columns,
+// so each problem becomes a series with labels of the problem, see problemLabels. Used for alerting, where
+// alert instances inherit the labels and can be routed by them.
+func convertProblemsToAlertFrame(problems Events, triggers map[string]Trigger) *data.Frame {
+	problemsLabels := make([]data.Labels, 0, len(problems))
+	labelNames := make(map[string]bool)
+	for _, problem := range problems {
+		labels := problemLabels(problem, triggers[problem.ObjectID])
+		for name := range labels {
+			labelNames[name] = true
+		}
+		problemsLabels = append(problemsLabels, labels)
+	}
+	names := make([]string, 0, len(labelNames))
+	for name := range labelNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	timeField := data.NewFieldFromFieldType(data.FieldTypeTime, 0)
+	timeField.Name = "Time"
+	severityField := data.NewFieldFromFieldType(data.FieldTypeInt64, 0)
+	severityField.Name = "Severity"
+	fields := []*data.Field{timeField, severityField}
+	for _, name := range names {
+		field := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+		field.Name = name
+		fields = append(fields, field)
+	}
+
+	for i, problem := range problems {
+		timeField.Append(time.Unix(problem.Clock, problem.NS))
+		severityField.Append(int64(problem.Severity))
+		for j, name := range names {
+			fields[j+2].Append(problemsLabels[i][name])
+		}
+	}
+
+	return data.NewFrame("problems", fields...)
+}
+
+// problemLabels returns labels of the problem: host and host group names of the trigger, problem name, event
+// and trigger ids, and the problem tags
+func problemLabels(problem Event, trigger Trigger) data.Labels {
+	labels := tagLabels(problem.Tags, problemReservedLabels)
+	labels["problem"] = problem.Name
+	labels["eventid"] = problem.ID
+	labels["triggerid"] = problem.ObjectID
+	for name, value := range triggerHostLabels(trigger) {
+		labels[name] = value
+	}
+	return labels
+}
+
+// triggerHostLabels returns sorted names of the trigger hosts and host groups as host and group labels
+func triggerHostLabels(trigger Trigger) data.Labels {
+	hostNames := make([]string, 0, len(trigger.Hosts))
+	for _, host := range trigger.Hosts {
+		hostNames = append(hostNames, host.Name)
+	}
+	sort.Strings(hostNames)
+
+	groupNames := make([]string, 0, len(trigger.Groups))
+	for _, group := range trigger.Groups {
+		groupNames = append(groupNames, group.Name)
+	}
+	sort.Strings(groupNames)
+
+	return data.Labels{"host": strings.Join(hostNames, ", "), "group": strings.Join(groupNames, ", ")}
+}
+
+// severityFieldConfig maps severity values to names and colors the cell by the severity
+func severityFieldConfig(severities Severities) *data.FieldConfig {
+	names := make(data.ValueMapper, len(SeverityNames))
+	steps := make([]data.Threshold, 0, len(SeverityColors))
+	for severity := range SeverityNames {
+		names[fmt.Sprint(severity)] = data.ValueMappingResult{Text: severities.Name(severity), Index: severity}
+		value := float64(severity)
+		if severity == SeverityNotClassified {
+			value = math.Inf(-1)
+		}
+		steps = append(steps, data.NewThreshold(value, severities.Color(severity), ""))
+	}
+
+	return &data.FieldConfig{
+		Mappings:   data.ValueMappings{names},
+		Thresholds: &data.ThresholdsConfig{Mode: data.ThresholdsModeAbsolute, Steps: steps},
+		Custom:     map[string]interface{}{"displayMode": "color-background"},
+	}
+}
@@ -0,0 +1,133 @@
+package datasource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/gtime"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Bit of the acknowledge action flags set when the problem is acknowledged
+const ackActionAcknowledge = 2
+
+type grafanaUserKey struct{}
+
+// withGrafanaUser returns context with the Grafana user of the request, used by the "acknowledged by me" filter
+func withGrafanaUser(ctx context.Context, user *backend.User) context.Context {
+	return context.WithValue(ctx, grafanaUserKey{}, user)
+}
+
+// grafanaUser returns the Grafana user of the request or nil if it's not set
+func grafanaUser(ctx context.Context) *backend.User {
+	user, _ := ctx.Value(grafanaUserKey{}).(*backend.User)
+	return user
+}
+
+// validateAckFilters checks the acknowledged by me and unacknowledged older than filters of the problems query
+func (options QueryOptions) validateAckFilters() error {
+	if options.UnacknowledgedOlderThan == "" {
+		return nil
+	}
+	if options.AcknowledgedByMe {
+		return errors.New("acknowledgedByMe and unacknowledgedOlderThan filters can't be used together")
+	}
+	period, err := gtime.ParseInterval(options.UnacknowledgedOlderThan)
+	if err != nil {
+		return fmt.Errorf("invalid unacknowledgedOlderThan: %w", err)
+	}
+	if period <= 0 {
+		return fmt.Errorf("unacknowledgedOlderThan should be positive, got %s", options.UnacknowledgedOlderThan)
+	}
+	return nil
+}
+
+// setUnacknowledgedOlderThan limits the problems request to the problems started before the unacknowledged older
+// than period, if it's set. Time till of the query time range is kept if it's earlier.
+func setUnacknowledgedOlderThan(params ZabbixAPIParams, options QueryOptions, now time.Time) {
+	if options.UnacknowledgedOlderThan == "" {
+		return
+	}
+	// Validated by ReadQuery
+	period, err := gtime.ParseInterval(options.UnacknowledgedOlderThan)
+	if err != nil {
+		return
+	}
+	till := now.Add(-period).Unix()
+	if current, ok := params["time_till"].(int64); ok && current < till {
+		till = current
+	}
+	params["time_till"] = till
+}
+
+// filterAcknowledgedByMe returns problems acknowledged by the Zabbix user with the same name as the Grafana user of
+// the request. Problems should be requested with the acknowledges.
+func (ds *ZabbixDatasourceInstance) filterAcknowledgedByMe(ctx context.Context, problems Events) (Events, error) {
+	user := grafanaUser(ctx)
+	if user == nil || user.Login == "" {
+		return nil, errors.New("acknowledgedByMe filter requires a signed in Grafana user")
+	}
+
+	userid, err := ds.getZabbixUserID(ctx, user.Login)
+	if err != nil {
+		return nil, err
+	}
+	if userid == "" {
+		addNotice(ctx, data.NoticeSeverityWarning, fmt.Sprintf("Zabbix user %s not found, no problems acknowledged by the user", user.Login))
+		return Events{}, nil
+	}
+
+	filtered := Events{}
+	for _, problem := range problems {
+		for _, acknowledge := range problem.Acknowledges {
+			if acknowledge.UserID == userid && acknowledge.Action&ackActionAcknowledge != 0 {
+				filtered = append(filtered, problem)
+				break
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// getZabbixUserID returns ID of the Zabbix user with the name, or empty string if there's no such user. User name
+// field is "username" since Zabbix 5.4 and "alias" before.
+func (ds *ZabbixDatasourceInstance) getZabbixUserID(ctx context.Context, name string) (string, error) {
+	version, _, err := ds.getZabbixVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	nameField := "alias"
+	if v, err := parseZabbixVersion(version); err == nil && getZabbixCapabilities(v)["loginUsername"] {
+		nameField = "username"
+	}
+
+	params := ZabbixAPIParams{
+		"output": []string{"userid"},
+		"filter": map[string]interface{}{nameField: name},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "user.get", Params: params})
+	if err != nil {
+		return "", err
+	}
+
+	usersJSON, err := response.MarshalJSON()
+	if err != nil {
+		return "", fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var users []struct {
+		ID string `json:"userid"`
+	}
+	err = json.Unmarshal(usersJSON, &users)
+	if err != nil {
+		return "", err
+	}
+	if len(users) == 0 {
+		return "", nil
+	}
+	return users[0].ID, nil
+}
@@ -0,0 +1,127 @@
+package datasource
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestValidateAckFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		options QueryOptions
+		wantErr string
+	}{
+		{name: "Not set", options: QueryOptions{}},
+		{name: "Acknowledged by me", options: QueryOptions{AcknowledgedByMe: true}},
+		{name: "Older than", options: QueryOptions{UnacknowledgedOlderThan: "30m"}},
+		{name: "Invalid period", options: QueryOptions{UnacknowledgedOlderThan: "soon"}, wantErr: "invalid unacknowledgedOlderThan"},
+		{name: "Zero period", options: QueryOptions{UnacknowledgedOlderThan: "0m"}, wantErr: "should be positive"},
+		{name: "Both", options: QueryOptions{AcknowledgedByMe: true, UnacknowledgedOlderThan: "30m"}, wantErr: "can't be used together"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.validateAckFilters()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProblemsQueryParamsAckFilters(t *testing.T) {
+	params := problemsQueryParams(&QueryModel{Options: QueryOptions{AcknowledgedByMe: true}}, []string{"1"})
+	assert.Equal(t, true, params["acknowledged"])
+	assert.Equal(t, []string{"userid", "action"}, params["selectAcknowledges"])
+
+	params = problemsQueryParams(&QueryModel{Options: QueryOptions{UnacknowledgedOlderThan: "30m"}}, []string{"1"})
+	assert.Equal(t, false, params["acknowledged"])
+	assert.NotContains(t, params, "selectAcknowledges")
+}
+
+func TestSetUnacknowledgedOlderThan(t *testing.T) {
+	now := time.Unix(1600003600, 0)
+	options := QueryOptions{UnacknowledgedOlderThan: "30m"}
+
+	params := ZabbixAPIParams{}
+	setUnacknowledgedOlderThan(params, options, now)
+	assert.Equal(t, int64(1600001800), params["time_till"])
+
+	params = ZabbixAPIParams{"time_till": int64(1600003000)}
+	setUnacknowledgedOlderThan(params, options, now)
+	assert.Equal(t, int64(1600001800), params["time_till"])
+
+	params = ZabbixAPIParams{"time_till": int64(1600000000)}
+	setUnacknowledgedOlderThan(params, options, now)
+	assert.Equal(t, int64(1600000000), params["time_till"])
+
+	params = ZabbixAPIParams{}
+	setUnacknowledgedOlderThan(params, QueryOptions{}, now)
+	assert.NotContains(t, params, "time_till")
+}
+
+func TestFilterAcknowledgedByMe(t *testing.T) {
+	problems := Events{
+		{ID: "1", Acknowledges: []EventAcknowledge{{UserID: "5", Action: 2}}},
+		{ID: "2", Acknowledges: []EventAcknowledge{{UserID: "7", Action: 6}}},
+		// Message without acknowledge
+		{ID: "3", Acknowledges: []EventAcknowledge{{UserID: "5", Action: 4}}},
+		{ID: "4"},
+	}
+
+	t.Run("Acknowledged by user", func(t *testing.T) {
+		dsInstance := MockZabbixDataSource(`{"result":[{"userid":"5"}]}`, 200)
+		dsInstance.zabbixAPI.SetAuth("secretauth")
+		dsInstance.Settings.ZabbixVersion = "6.0"
+
+		ctx, apiCalls := withAPICallsRecorder(withGrafanaUser(context.Background(), &backend.User{Login: "admin"}))
+		filtered, err := dsInstance.filterAcknowledgedByMe(ctx, problems)
+		assert.NoError(t, err)
+		assert.Len(t, filtered, 1)
+		assert.Equal(t, "1", filtered[0].ID)
+
+		calls := apiCalls.Calls()
+		assert.Len(t, calls, 1)
+		assert.Equal(t, "user.get", calls[0].Method)
+		assert.True(t, strings.Contains(calls[0].Params, `"username":"admin"`))
+	})
+
+	t.Run("Alias before 5.4", func(t *testing.T) {
+		dsInstance := MockZabbixDataSource(`{"result":[{"userid":"7"}]}`, 200)
+		dsInstance.zabbixAPI.SetAuth("secretauth")
+		dsInstance.Settings.ZabbixVersion = "5.0"
+
+		ctx, apiCalls := withAPICallsRecorder(withGrafanaUser(context.Background(), &backend.User{Login: "admin"}))
+		filtered, err := dsInstance.filterAcknowledgedByMe(ctx, problems)
+		assert.NoError(t, err)
+		assert.Len(t, filtered, 1)
+		assert.Equal(t, "2", filtered[0].ID)
+		assert.True(t, strings.Contains(apiCalls.Calls()[0].Params, `"alias":"admin"`))
+	})
+
+	t.Run("Zabbix user not found", func(t *testing.T) {
+		dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+		dsInstance.zabbixAPI.SetAuth("secretauth")
+		dsInstance.Settings.ZabbixVersion = "6.0"
+
+		ctx, notices := withNoticesRecorder(withGrafanaUser(context.Background(), &backend.User{Login: "viewer"}))
+		filtered, err := dsInstance.filterAcknowledgedByMe(ctx, problems)
+		assert.NoError(t, err)
+		assert.Empty(t, filtered)
+		assert.Len(t, notices.Notices(), 1)
+	})
+
+	t.Run("No Grafana user", func(t *testing.T) {
+		dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+		_, err := dsInstance.filterAcknowledgedByMe(context.Background(), problems)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,84 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// Name of the suppression without maintenance, when the problem is suppressed by the user
+const manualSuppressionName = "Manual suppression"
+
+// setProblemsSuppressedBy sets names of the maintenances suppressing the problems, requested by the maintenance IDs
+// from the suppression data of the problems
+func (ds *ZabbixDatasourceInstance) setProblemsSuppressedBy(ctx context.Context, problems Events) error {
+	var maintenanceIDs []string
+	seen := map[string]bool{}
+	for _, problem := range problems {
+		for _, suppression := range problem.SuppressionData {
+			id := suppression.MaintenanceID
+			if id == "" || id == "0" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			maintenanceIDs = append(maintenanceIDs, id)
+		}
+	}
+
+	names, err := ds.getMaintenanceNames(ctx, maintenanceIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range problems {
+		var suppressedBy []string
+		for _, suppression := range problems[i].SuppressionData {
+			name := manualSuppressionName
+			if suppression.MaintenanceID != "" && suppression.MaintenanceID != "0" {
+				name = names[suppression.MaintenanceID]
+				if name == "" {
+					name = suppression.MaintenanceID
+				}
+			}
+			suppressedBy = append(suppressedBy, name)
+		}
+		problems[i].SuppressedBy = suppressedBy
+	}
+	return nil
+}
+
+// getMaintenanceNames returns names of the maintenances by maintenance ID
+func (ds *ZabbixDatasourceInstance) getMaintenanceNames(ctx context.Context, maintenanceIDs []string) (map[string]string, error) {
+	names := map[string]string{}
+	if len(maintenanceIDs) == 0 {
+		return names, nil
+	}
+
+	params := ZabbixAPIParams{
+		"output":         []string{"maintenanceid", "name"},
+		"maintenanceids": maintenanceIDs,
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "maintenance.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	maintenancesJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var maintenances []struct {
+		ID   string `json:"maintenanceid"`
+		Name string `json:"name"`
+	}
+	err = json.Unmarshal(maintenancesJSON, &maintenances)
+	if err != nil {
+		return nil, err
+	}
+	for _, maintenance := range maintenances {
+		names[maintenance.ID] = maintenance.Name
+	}
+	return names, nil
+}
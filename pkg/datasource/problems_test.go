@@ -0,0 +1,269 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestConvertProblemsToFrame(t *testing.T) {
+	now := time.Unix(1600001000, 0)
+	problems := Events{
+		{
+			ID: "10", ObjectID: "100", Clock: 1600000000, Severity: SeverityHigh, Acknowledged: "1", Name: "CPU is high",
+			Tags: []ItemTag{{Tag: "service", Value: "web"}, {Tag: "scope", Value: "performance"}},
+		},
+		{
+			ID: "11", ObjectID: "101", Clock: 1600000500, Severity: SeverityWarning, Acknowledged: "0", Name: "Disk is low", REventID: "12", RClock: 1600000800,
+			SuppressedBy: []string{"Weekly backup", "Manual suppression"},
+		},
+	}
+	triggers := map[string]Trigger{
+		"100": {ID: "100", Expression: "last(/web01/system.cpu.util)>{$CPU.MAX}", Hosts: []ItemHost{{ID: "1", Name: "web01"}}},
+		"101": {ID: "101", Hosts: []ItemHost{{ID: "2", Name: "db02"}, {ID: "1", Name: "db01"}}},
+	}
+
+	macros := &userMacros{host: map[string]map[string]string{"1": {"{$CPU.MAX}": "90"}}}
+
+	severities, err := newSeverities([]SeveritySettings{{Severity: SeverityDisaster, Name: "Critical", Color: "#C4162A"}})
+	assert.NoError(t, err)
+
+	frame := convertProblemsToFrame(problems, triggers, macros, severities, now)
+	assert.Equal(t, []string{"Time", "Host", "Problem", "Severity", "Acknowledged", "Age", "Status", "Suppressed by", "Tags", "Conditions", "Event ID", "Trigger ID"}, fieldNames(frame))
+	assert.Equal(t, data.VisType(data.VisTypeTable), frame.Meta.PreferredVisualization)
+	assert.Equal(t, 2, frame.Rows())
+
+	assert.Equal(t, time.Unix(1600000000, 0), frame.Fields[0].At(0))
+	assert.Equal(t, "web01", frame.Fields[1].At(0))
+	assert.Equal(t, "db01, db02", frame.Fields[1].At(1))
+	assert.Equal(t, int64(SeverityHigh), frame.Fields[3].At(0))
+	assert.Equal(t, true, frame.Fields[4].At(0))
+	assert.Equal(t, false, frame.Fields[4].At(1))
+	assert.Equal(t, float64(1000), frame.Fields[5].At(0))
+	assert.Equal(t, float64(300), frame.Fields[5].At(1))
+	assert.Equal(t, ProblemStatusProblem, frame.Fields[6].At(0))
+	assert.Equal(t, ProblemStatusResolved, frame.Fields[6].At(1))
+	assert.Equal(t, "", frame.Fields[7].At(0))
+	assert.Equal(t, "Weekly backup, Manual suppression", frame.Fields[7].At(1))
+	assert.Equal(t, `{"scope":"performance","service":"web"}`, frame.Fields[8].At(0))
+	assert.Equal(t, `{}`, frame.Fields[8].At(1))
+	assert.Equal(t, "> {$CPU.MAX} (90)", frame.Fields[9].At(0))
+	assert.Equal(t, "", frame.Fields[9].At(1))
+	assert.Equal(t, "11", frame.Fields[10].At(1))
+	assert.Equal(t, "101", frame.Fields[11].At(1))
+
+	severityConfig := frame.Fields[3].Config
+	assert.Len(t, severityConfig.Mappings, 1)
+	severityNames := severityConfig.Mappings[0].(data.ValueMapper)
+	assert.Len(t, severityNames, len(SeverityNames))
+	assert.Equal(t, "High", severityNames["4"].Text)
+	assert.Equal(t, SeverityColors[SeverityHigh], severityConfig.Thresholds.Steps[SeverityHigh].Color)
+	assert.Equal(t, "Critical", severityNames["5"].Text)
+	assert.Equal(t, "#C4162A", severityConfig.Thresholds.Steps[SeverityDisaster].Color)
+	assert.Equal(t, "dtdurations", frame.Fields[5].Config.Unit)
+}
+
+func TestConvertProblemsToAlertFrame(t *testing.T) {
+	problems := Events{
+		{
+			ID: "10", ObjectID: "100", Clock: 1600000000, Severity: SeverityHigh, Name: "CPU is high",
+			Tags: []ItemTag{{Tag: "service", Value: "web"}, {Tag: "host", Value: "ignored"}},
+		},
+		{ID: "11", ObjectID: "101", Clock: 1600000500, Severity: SeverityWarning, Name: "Disk is low"},
+	}
+	triggers := map[string]Trigger{
+		"100": {
+			ID:     "100",
+			Hosts:  []ItemHost{{ID: "1", Name: "web01"}},
+			Groups: []TriggerGroup{{ID: "2", Name: "Web servers"}, {ID: "1", Name: "Linux servers"}},
+		},
+		"101": {ID: "101", Hosts: []ItemHost{{ID: "2", Name: "db01"}}},
+	}
+
+	frame := convertProblemsToAlertFrame(problems, triggers)
+	assert.Equal(t, []string{"Time", "Severity", "eventid", "group", "host", "problem", "service", "triggerid"}, fieldNames(frame))
+
+	series := framesToSeries([]*data.Frame{frame})
+	assert.Len(t, series, 2)
+	assert.Equal(t, data.Labels{
+		"eventid":   "10",
+		"group":     "Linux servers, Web servers",
+		"host":      "web01",
+		"problem":   "CPU is high",
+		"service":   "web",
+		"triggerid": "100",
+	}, series[0].Meta.Labels)
+	assert.Equal(t, "", series[1].Meta.Labels["service"])
+	assert.Equal(t, float64(SeverityWarning), *series[1].TS[0].Value)
+}
+
+func TestFilterProblems(t *testing.T) {
+	problems := Events{{ID: "1", Name: "CPU is high"}, {ID: "2", Name: "Disk is low"}}
+
+	tests := []struct {
+		name    string
+		filter  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty filter", filter: "", want: []string{"1", "2"}},
+		{name: "exact name", filter: "Disk is low", want: []string{"2"}},
+		{name: "partial name", filter: "Disk", want: []string{}},
+		{name: "regex", filter: "/cpu/i", want: []string{"1"}},
+		{name: "invalid regex", filter: "/cpu/x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := filterProblems(problems, tt.filter)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			ids := []string{}
+			for _, problem := range filtered {
+				ids = append(ids, problem.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	assert.Nil(t, parseTags(""))
+	assert.Equal(t, []map[string]string{
+		{"tag": "service", "value": "web"},
+		{"tag": "scope"},
+	}, parseTags("service: web, scope,"))
+}
+
+func TestProblemsQueryParamsAcknowledged(t *testing.T) {
+	ack := func(value int) *int { return &value }
+
+	params := problemsQueryParams(&QueryModel{Options: QueryOptions{Acknowledged: ack(AckFilterAll)}}, []string{"1"})
+	assert.NotContains(t, params, "acknowledged")
+
+	params = problemsQueryParams(&QueryModel{Options: QueryOptions{Acknowledged: ack(AckFilterAcknowledged)}}, []string{"1"})
+	assert.Equal(t, true, params["acknowledged"])
+
+	params = problemsQueryParams(&QueryModel{Options: QueryOptions{Acknowledged: ack(AckFilterUnacknowledged)}}, []string{"1"})
+	assert.Equal(t, false, params["acknowledged"])
+}
+
+func TestProblemsQueryParamsSuppressed(t *testing.T) {
+	params := problemsQueryParams(&QueryModel{}, []string{"1"})
+	assert.NotContains(t, params, "suppressed")
+	assert.Contains(t, params, "selectSuppressionData")
+
+	params = problemsQueryParams(&QueryModel{Options: QueryOptions{HideSuppressed: true}}, []string{"1"})
+	assert.Equal(t, false, params["suppressed"])
+}
+
+func TestSetProblemsSuppressedBy(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"maintenanceid":"3","name":"Weekly backup"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	problems := Events{
+		{ID: "1"},
+		{ID: "2", Suppressed: "1", SuppressionData: []EventSuppressionData{{MaintenanceID: "3", SuppressUntil: 1600000000}}},
+		{ID: "3", Suppressed: "1", SuppressionData: []EventSuppressionData{{MaintenanceID: "3"}, {MaintenanceID: "0"}}},
+	}
+	ctx, apiCalls := withAPICallsRecorder(context.Background())
+	err := dsInstance.setProblemsSuppressedBy(ctx, problems)
+	assert.NoError(t, err)
+	assert.Nil(t, problems[0].SuppressedBy)
+	assert.Equal(t, []string{"Weekly backup"}, problems[1].SuppressedBy)
+	assert.Equal(t, []string{"Weekly backup", manualSuppressionName}, problems[2].SuppressedBy)
+
+	calls := apiCalls.Calls()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "maintenance.get", calls[0].Method)
+}
+
+func TestSortProblems(t *testing.T) {
+	problems := Events{
+		{ID: "4", ObjectID: "100", Clock: 1600000400, Severity: SeverityWarning},
+		{ID: "3", ObjectID: "101", Clock: 1600000300, Severity: SeverityDisaster},
+		{ID: "2", ObjectID: "102", Clock: 1600000100, Severity: SeverityWarning},
+		{ID: "1", ObjectID: "100", Clock: 1600000200, Severity: SeverityHigh},
+	}
+	triggers := map[string]Trigger{
+		"100": {ID: "100", Hosts: []ItemHost{{Name: "web01"}}},
+		"101": {ID: "101", Hosts: []ItemHost{{Name: "db01"}}},
+		"102": {ID: "102", Hosts: []ItemHost{{Name: "web02"}, {Name: "app01"}}},
+	}
+
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{sortBy: "", want: []string{"4", "3", "2", "1"}},
+		{sortBy: ProblemsSortSeverity, want: []string{"3", "1", "4", "2"}},
+		{sortBy: ProblemsSortAge, want: []string{"2", "1", "3", "4"}},
+		{sortBy: ProblemsSortHost, want: []string{"2", "3", "4", "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			sorted := append(Events{}, problems...)
+			sortProblems(sorted, triggers, tt.sortBy)
+			ids := []string{}
+			for _, problem := range sorted {
+				ids = append(ids, problem.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestProblemsLimit(t *testing.T) {
+	assert.Equal(t, 20, problemsLimit(&QueryModel{Options: QueryOptions{Limit: 20}}))
+	assert.Equal(t, 0, problemsLimit(&QueryModel{Options: QueryOptions{Limit: 20, SortProblems: ProblemsSortSeverity}}))
+}
+
+func TestGetHistoryProblemsTimeRange(t *testing.T) {
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+	tests := []struct {
+		showProblems string
+		wantParams   []string
+		skipParams   []string
+	}{
+		{
+			showProblems: ShowProblemsHistory,
+			wantParams:   []string{`"time_from":1600000000`, `"time_till":1600003600`},
+			skipParams:   []string{"problem_time_from", "problem_time_till"},
+		},
+		{
+			showProblems: ShowProblemsActiveInRange,
+			wantParams:   []string{`"problem_time_from":1600000000`, `"problem_time_till":1600003600`},
+			skipParams:   []string{`"time_from"`, `"time_till"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.showProblems, func(t *testing.T) {
+			dsInstance := MockZabbixDataSource(`{"result":[{"eventid":"10","objectid":"100","clock":"1599990000","ns":"0","severity":"4","r_eventid":"0"}]}`, 200)
+			dsInstance.zabbixAPI.SetAuth("secretauth")
+
+			ctx, apiCalls := withAPICallsRecorder(context.Background())
+			query := &QueryModel{ShowProblems: tt.showProblems, TimeRange: timeRange}
+			problems, _, err := dsInstance.getHistoryProblems(ctx, query, []string{"1"})
+			assert.NoError(t, err)
+			assert.Len(t, problems, 1)
+
+			calls := apiCalls.Calls()
+			assert.Len(t, calls, 1)
+			assert.Equal(t, "event.get", calls[0].Method)
+			for _, param := range tt.wantParams {
+				assert.Contains(t, calls[0].Params, param)
+			}
+			for _, param := range tt.skipParams {
+				assert.NotContains(t, calls[0].Params, param)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package datasource
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	// Number of the latest queries statistics are calculated for
+	queryStatsWindow = 1000
+	// Number of the top methods and slowest queries returned
+	queryStatsTopCount = 10
+)
+
+// queryStatsEntry is a summary of the executed query kept for the statistics
+type queryStatsEntry struct {
+	time       time.Time
+	refID      string
+	query      string
+	durationMs float64
+	calls      []APICall
+	err        string
+}
+
+// queryStats keeps summaries of the latest queries in a ring buffer
+type queryStats struct {
+	mu      sync.Mutex
+	entries []queryStatsEntry
+	next    int
+}
+
+// QueryStats are statistics of the latest data source queries returned by the /query-stats resource
+type QueryStats struct {
+	Queries        int              `json:"queries"`
+	Since          *time.Time       `json:"since,omitempty"`
+	ErrorRate      float64          `json:"errorRate"`
+	APICalls       int              `json:"apiCalls"`
+	CacheHitRate   float64          `json:"cacheHitRate"`
+	TopMethods     []MethodStats    `json:"topMethods"`
+	SlowestQueries []SlowQueryStats `json:"slowestQueries"`
+}
+
+// MethodStats are statistics of the API method, cached calls aren't included into the time
+type MethodStats struct {
+	Method      string  `json:"method"`
+	Calls       int     `json:"calls"`
+	CachedCalls int     `json:"cachedCalls"`
+	Errors      int     `json:"errors"`
+	TotalTimeMs float64 `json:"totalTimeMs"`
+	AvgTimeMs   float64 `json:"avgTimeMs"`
+	MaxTimeMs   float64 `json:"maxTimeMs"`
+}
+
+// SlowQueryStats is a summary of the slow query
+type SlowQueryStats struct {
+	Time       time.Time `json:"time"`
+	RefID      string    `json:"refId"`
+	Query      string    `json:"query"`
+	DurationMs float64   `json:"durationMs"`
+	APICalls   int       `json:"apiCalls"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func newQueryStatsEntry(q backend.DataQuery, query *QueryModel, start time.Time, duration time.Duration, calls []APICall, err error) queryStatsEntry {
+	entry := queryStatsEntry{
+		time:       start,
+		refID:      q.RefID,
+		query:      queryFilterSummary(query),
+		durationMs: float64(duration) / float64(time.Millisecond),
+		calls:      calls,
+	}
+	if err != nil {
+		entry.err = err.Error()
+	}
+	return entry
+}
+
+// add records query replacing the oldest one if window is full
+func (s *queryStats) add(entry queryStatsEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) < queryStatsWindow {
+		s.entries = append(s.entries, entry)
+		return
+	}
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % queryStatsWindow
+}
+
+// Stats calculates statistics of the recorded queries
+func (s *queryStats) Stats() *QueryStats {
+	s.mu.Lock()
+	entries := make([]queryStatsEntry, len(s.entries))
+	copy(entries, s.entries)
+	s.mu.Unlock()
+
+	stats := &QueryStats{
+		Queries:        len(entries),
+		TopMethods:     []MethodStats{},
+		SlowestQueries: []SlowQueryStats{},
+	}
+	if len(entries) == 0 {
+		return stats
+	}
+
+	methods := map[string]*MethodStats{}
+	failedQueries := 0
+	cachedCalls := 0
+	var since time.Time
+	for _, entry := range entries {
+		if since.IsZero() || entry.time.Before(since) {
+			since = entry.time
+		}
+		if entry.err != "" {
+			failedQueries++
+		}
+		for _, call := range entry.calls {
+			method, ok := methods[call.Method]
+			if !ok {
+				method = &MethodStats{Method: call.Method}
+				methods[call.Method] = method
+			}
+			method.Calls++
+			stats.APICalls++
+			if call.Cached {
+				method.CachedCalls++
+				cachedCalls++
+				continue
+			}
+			if call.Error != "" {
+				method.Errors++
+			}
+			method.TotalTimeMs += call.DurationMs
+			if call.DurationMs > method.MaxTimeMs {
+				method.MaxTimeMs = call.DurationMs
+			}
+		}
+		stats.SlowestQueries = append(stats.SlowestQueries, SlowQueryStats{
+			Time:       entry.time,
+			RefID:      entry.refID,
+			Query:      entry.query,
+			DurationMs: entry.durationMs,
+			APICalls:   len(entry.calls),
+			Error:      entry.err,
+		})
+	}
+
+	stats.Since = &since
+	stats.ErrorRate = float64(failedQueries) / float64(len(entries))
+	if stats.APICalls > 0 {
+		stats.CacheHitRate = float64(cachedCalls) / float64(stats.APICalls)
+	}
+
+	for _, method := range methods {
+		if requested := method.Calls - method.CachedCalls; requested > 0 {
+			method.AvgTimeMs = method.TotalTimeMs / float64(requested)
+		}
+		stats.TopMethods = append(stats.TopMethods, *method)
+	}
+	sort.Slice(stats.TopMethods, func(i, j int) bool {
+		if stats.TopMethods[i].TotalTimeMs != stats.TopMethods[j].TotalTimeMs {
+			return stats.TopMethods[i].TotalTimeMs > stats.TopMethods[j].TotalTimeMs
+		}
+		return stats.TopMethods[i].Method < stats.TopMethods[j].Method
+	})
+	if len(stats.TopMethods) > queryStatsTopCount {
+		stats.TopMethods = stats.TopMethods[:queryStatsTopCount]
+	}
+
+	sort.SliceStable(stats.SlowestQueries, func(i, j int) bool {
+		return stats.SlowestQueries[i].DurationMs > stats.SlowestQueries[j].DurationMs
+	})
+	if len(stats.SlowestQueries) > queryStatsTopCount {
+		stats.SlowestQueries = stats.SlowestQueries[:queryStatsTopCount]
+	}
+	return stats
+}
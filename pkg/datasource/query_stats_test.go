@@ -0,0 +1,58 @@
+package datasource
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryStats(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := &queryStats{}
+	assert.Equal(t, &QueryStats{TopMethods: []MethodStats{}, SlowestQueries: []SlowQueryStats{}}, stats.Stats())
+
+	stats.add(newQueryStatsEntry(backend.DataQuery{RefID: "A"}, &QueryModel{Host: QueryFilter{Filter: "web"}}, start, 300*time.Millisecond, []APICall{
+		{Method: "host.get", DurationMs: 1, Cached: true},
+		{Method: "item.get", DurationMs: 20},
+		{Method: "history.get", DurationMs: 250},
+	}, nil))
+	stats.add(newQueryStatsEntry(backend.DataQuery{RefID: "B"}, &QueryModel{Host: QueryFilter{Filter: "db"}}, start.Add(time.Minute), time.Second, []APICall{
+		{Method: "host.get", DurationMs: 30},
+		{Method: "item.get", DurationMs: 40, Error: "Invalid params."},
+	}, errors.New("Invalid params.")))
+
+	assert.Equal(t, &QueryStats{
+		Queries:      2,
+		Since:        &start,
+		ErrorRate:    0.5,
+		APICalls:     5,
+		CacheHitRate: 0.2,
+		TopMethods: []MethodStats{
+			{Method: "history.get", Calls: 1, TotalTimeMs: 250, AvgTimeMs: 250, MaxTimeMs: 250},
+			{Method: "item.get", Calls: 2, Errors: 1, TotalTimeMs: 60, AvgTimeMs: 30, MaxTimeMs: 40},
+			{Method: "host.get", Calls: 2, CachedCalls: 1, TotalTimeMs: 30, AvgTimeMs: 30, MaxTimeMs: 30},
+		},
+		SlowestQueries: []SlowQueryStats{
+			{Time: start.Add(time.Minute), RefID: "B", Query: "host=db", DurationMs: 1000, APICalls: 2, Error: "Invalid params."},
+			{Time: start, RefID: "A", Query: "host=web", DurationMs: 300, APICalls: 3},
+		},
+	}, stats.Stats())
+}
+
+func TestQueryStatsWindow(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := &queryStats{}
+	for i := 0; i < queryStatsWindow+5; i++ {
+		stats.add(queryStatsEntry{time: start.Add(time.Duration(i) * time.Second), durationMs: float64(i)})
+	}
+
+	result := stats.Stats()
+	assert.Equal(t, queryStatsWindow, result.Queries)
+	// Oldest queries are replaced
+	assert.Equal(t, start.Add(5*time.Second), *result.Since)
+	assert.Len(t, result.SlowestQueries, queryStatsTopCount)
+	assert.Equal(t, float64(queryStatsWindow+4), result.SlowestQueries[0].DurationMs)
+}
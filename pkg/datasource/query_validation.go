@@ -0,0 +1,147 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/net/context"
+)
+
+// Severity of the query validation issue
+const (
+	ValidationSeverityError   = "error"
+	ValidationSeverityWarning = "warning"
+)
+
+// QueryValidation is a result of the query dry-run returned by the /query/validate resource: number of the
+// groups, hosts, applications and items the query filters match. History isn't requested.
+type QueryValidation struct {
+	Valid  bool                   `json:"valid"`
+	Groups *int                   `json:"groups,omitempty"`
+	Hosts  *int                   `json:"hosts,omitempty"`
+	Apps   *int                   `json:"apps,omitempty"`
+	Items  *int                   `json:"items,omitempty"`
+	Issues []QueryValidationIssue `json:"issues"`
+}
+
+// QueryValidationIssue is a problem of the query field, like invalid regex or filter matching nothing
+type QueryValidationIssue struct {
+	Field    string `json:"field,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (v *QueryValidation) addIssue(field string, severity string, message string) {
+	v.Issues = append(v.Issues, QueryValidationIssue{Field: field, Severity: severity, Message: message})
+	if severity == ValidationSeverityError {
+		v.Valid = false
+	}
+}
+
+// validateQuery reads the query model and resolves its filters into groups, hosts, applications and items, so
+// wildcard queries can be checked before saving dashboards or alert rules. Errors of the Zabbix API requests
+// are returned, problems of the query are reported as issues.
+func (ds *ZabbixDatasourceInstance) validateQuery(ctx context.Context, queryJSON []byte) (*QueryValidation, error) {
+	validation := &QueryValidation{Valid: true, Issues: []QueryValidationIssue{}}
+
+	query, err := ReadQuery(backend.DataQuery{JSON: queryJSON})
+	if err != nil {
+		validation.addIssue("", ValidationSeverityError, err.Error())
+		return validation, nil
+	}
+
+	var itemType string
+	switch query.Mode {
+	case QueryModeMetrics:
+		itemType = "num"
+	case QueryModeText:
+		itemType = "text"
+		if query.TextFilter != "" {
+			if _, err := regexp.Compile(query.TextFilter); err != nil {
+				validation.addIssue("textFilter", ValidationSeverityError, err.Error())
+			}
+		}
+	case QueryModeItemID:
+		items, err := ds.getItemsByIDs(ctx, newQueryFilters(&query), query.ItemIDs, query.HostIDs)
+		if err != nil {
+			return nil, err
+		}
+		validation.setCount(&validation.Items, len(items), "itemids", "items")
+		return validation, nil
+	case QueryModeTriggers, QueryModeProblems:
+	default:
+		// Other modes don't use group, host, application and item filters
+		return validation, nil
+	}
+
+	nameFilters := []struct {
+		field  string
+		filter string
+	}{
+		{"group", query.Group.Filter},
+		{"host", query.Host.Filter},
+		{"application", query.Application.Filter},
+		{"item", query.Item.Filter},
+	}
+	for _, f := range nameFilters {
+		if _, err := parseFilter(f.filter); err != nil {
+			validation.addIssue(f.field, ValidationSeverityError, fmt.Sprintf("invalid regex %s: %s", f.filter, err))
+		}
+	}
+	if !validation.Valid {
+		return validation, nil
+	}
+
+	filters := newQueryFilters(&query)
+
+	groups, err := ds.getGroups(ctx, filters, query.Group.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if !validation.setCount(&validation.Groups, len(groups), "group", "groups") {
+		return validation, nil
+	}
+
+	hosts, err := ds.getHosts(ctx, filters, query.Group.Filter, query.Host.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if !validation.setCount(&validation.Hosts, len(hosts), "host", "hosts") {
+		return validation, nil
+	}
+
+	// Application is optional, and not supported in Zabbix 5.4 and higher
+	if query.Application.Filter != "" {
+		apps, err := ds.getApps(ctx, filters, query.Group.Filter, query.Host.Filter, query.Application.Filter)
+		if err != nil && !errors.Is(err, zabbixapi.ErrMethodNotFound) {
+			return nil, err
+		} else if err == nil && !validation.setCount(&validation.Apps, len(uniqueByName(discoveredObjects(apps, "applicationid"))), "application", "applications") {
+			return validation, nil
+		}
+	}
+
+	if query.Mode == QueryModeTriggers || query.Mode == QueryModeProblems {
+		return validation, nil
+	}
+
+	items, err := ds.getItems(ctx, filters, query.Group.Filter, query.Host.Filter, query.Application.Filter, query.Item.Filter, itemType)
+	if err != nil {
+		return nil, err
+	}
+	validation.setCount(&validation.Items, len(items), "item", "items")
+	return validation, nil
+}
+
+// setCount sets number of the matched objects and adds a warning if there are none. Returns false if nothing
+// matched, so the next filters can't match anything either.
+func (v *QueryValidation) setCount(count **int, matched int, field string, objects string) bool {
+	*count = &matched
+	if matched == 0 {
+		v.addIssue(field, ValidationSeverityWarning, fmt.Sprintf("%s filter matches no %s", field, objects))
+		return false
+	}
+	return true
+}
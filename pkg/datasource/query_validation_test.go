@@ -0,0 +1,94 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateQuery(t *testing.T) {
+	one := 1
+	zero := 0
+	tests := []struct {
+		name  string
+		query string
+		want  *QueryValidation
+	}{
+		{
+			name:  "Items matched",
+			query: `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"web01"},"application":{"filter":""},"item":{"filter":"/web/"}}`,
+			want:  &QueryValidation{Valid: true, Groups: &one, Hosts: &one, Items: &one, Issues: []QueryValidationIssue{}},
+		},
+		{
+			name:  "No items",
+			query: `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"web01"},"item":{"filter":"Memory"}}`,
+			want: &QueryValidation{Valid: true, Groups: &one, Hosts: &one, Items: &zero, Issues: []QueryValidationIssue{
+				{Field: "item", Severity: ValidationSeverityWarning, Message: "item filter matches no items"},
+			}},
+		},
+		{
+			name:  "No hosts",
+			query: `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"db01"},"item":{"filter":"/.*/"}}`,
+			want: &QueryValidation{Valid: true, Groups: &one, Hosts: &zero, Issues: []QueryValidationIssue{
+				{Field: "host", Severity: ValidationSeverityWarning, Message: "host filter matches no hosts"},
+			}},
+		},
+		{
+			name:  "Problems",
+			query: `{"mode":5,"group":{"filter":"/.*/"},"host":{"filter":"/.*/"}}`,
+			want:  &QueryValidation{Valid: true, Groups: &one, Hosts: &one, Issues: []QueryValidationIssue{}},
+		},
+		{
+			name:  "Invalid regex",
+			query: `{"mode":0,"group":{"filter":"/.*/"},"host":{"filter":"/web(/"},"item":{"filter":"/.*/x"}}`,
+			want: &QueryValidation{Valid: false, Issues: []QueryValidationIssue{
+				{Field: "host", Severity: ValidationSeverityError, Message: "invalid regex /web(/: error parsing regexp: missing closing ): `web(`"},
+				{Field: "item", Severity: ValidationSeverityError, Message: "invalid regex /.*/x: error parsing regexp: unsupported flags `x` (expected [imsU])"},
+			}},
+		},
+		{
+			name:  "Invalid text filter",
+			query: `{"mode":2,"group":{"filter":"/.*/"},"host":{"filter":"web01"},"item":{"filter":"/.*/"},"textFilter":"("}`,
+			want: &QueryValidation{Valid: false, Issues: []QueryValidationIssue{
+				{Field: "textFilter", Severity: ValidationSeverityError, Message: "error parsing regexp: missing closing ): `(`"},
+			}},
+		},
+		{
+			name:  "Invalid query",
+			query: `{"mode":0,"options":{"fillMode":"unknown"}}`,
+			want: &QueryValidation{Valid: false, Issues: []QueryValidationIssue{
+				{Severity: ValidationSeverityError, Message: "unsupported fill mode: unknown"},
+			}},
+		},
+		{
+			name:  "Item IDs",
+			query: `{"mode":3,"itemids":"100"}`,
+			want:  &QueryValidation{Valid: true, Items: &one, Issues: []QueryValidationIssue{}},
+		},
+		{
+			name:  "Invalid item IDs",
+			query: `{"mode":3,"itemids":"100,$item"}`,
+			want: &QueryValidation{Valid: false, Issues: []QueryValidationIssue{
+				{Severity: ValidationSeverityError, Message: "invalid item ids: invalid id: $item"},
+			}},
+		},
+		{
+			name:  "Mode without filters",
+			query: `{"mode":1,"itservice":{"name":"Web"}}`,
+			want:  &QueryValidation{Valid: true, Issues: []QueryValidationIssue{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Same response is used for groups, hosts and items
+			dsInstance := MockZabbixDataSource(`{"result":[{"groupid":"1","hostid":"10","itemid":"100","name":"web01","key_":"system.cpu.util","value_type":"0","status":"0"}]}`, 200)
+			dsInstance.zabbixAPI.SetAuth("secretauth")
+
+			validation, err := dsInstance.validateQuery(context.Background(), []byte(tt.query))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, validation)
+		})
+	}
+}
@@ -1,19 +1,39 @@
 package datasource
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"path"
+	"strconv"
 
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 )
 
 // Resource handler describes handlers for the resources populated by plugin in plugin.go, like:
 // mux.HandleFunc("/", ds.RootHandler)
 // mux.HandleFunc("/zabbix-api", ds.ZabbixAPIHandler)
+// mux.HandleFunc("/trigger-conditions", ds.TriggerConditionsHandler)
+// mux.HandleFunc("/alert-webhook", ds.AlertWebhookHandler)
+// mux.HandleFunc("/alert-rules", ds.AlertRulesHandler)
+// mux.HandleFunc("/dashboard", ds.DashboardHandler)
+// mux.HandleFunc("/health/details", ds.HealthDetailsHandler)
+// mux.HandleFunc("/query-stats", ds.QueryStatsHandler)
+// mux.HandleFunc("/zabbix-api/groups", ds.DiscoveryHandler)
+// mux.HandleFunc("/zabbix-api/group-tree", ds.GroupTreeHandler)
+// mux.HandleFunc("/zabbix-api/item-search", ds.ItemSearchHandler)
+// mux.HandleFunc("/zabbix-api/map-layer", ds.MapLayerHandler)
+// mux.HandleFunc("/variable-query", ds.VariableQueryHandler)
+// mux.HandleFunc("/query/validate", ds.QueryValidateHandler)
+// mux.HandleFunc("/query/explain", ds.QueryExplainHandler)
+// mux.HandleFunc("/query/export", ds.QueryExportHandler)
 
 func (ds *ZabbixDatasource) RootHandler(rw http.ResponseWriter, req *http.Request) {
-	ds.logger.Debug("Received resource call", "url", req.URL.String(), "method", req.Method)
+	requestid.Logger(requestContext(req), ds.logger).Debug("Received resource call", "url", req.URL.String(), "method", req.Method)
 
 	rw.Write([]byte("Hello from Zabbix data source!"))
 	rw.WriteHeader(http.StatusOK)
@@ -24,6 +44,9 @@ func (ds *ZabbixDatasource) ZabbixAPIHandler(rw http.ResponseWriter, req *http.R
 		return
 	}
 
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
 	body, err := ioutil.ReadAll(req.Body)
 	defer req.Body.Close()
 	if err != nil || len(body) == 0 {
@@ -34,24 +57,24 @@ func (ds *ZabbixDatasource) ZabbixAPIHandler(rw http.ResponseWriter, req *http.R
 	var reqData ZabbixAPIResourceRequest
 	err = json.Unmarshal(body, &reqData)
 	if err != nil {
-		ds.logger.Error("Cannot unmarshal request", "error", err.Error())
+		logger.Error("Cannot unmarshal request", "error", err.Error())
 		writeError(rw, http.StatusInternalServerError, err)
 		return
 	}
 
-	pluginCxt := httpadapter.PluginConfigFromContext(req.Context())
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
 	dsInstance, err := ds.getDSInstance(pluginCxt)
 	if err != nil {
-		ds.logger.Error("Error loading datasource", "error", err)
+		logger.Error("Error loading datasource", "error", err)
 		writeError(rw, http.StatusInternalServerError, err)
 		return
 	}
 
 	apiReq := &ZabbixAPIRequest{Method: reqData.Method, Params: reqData.Params}
 
-	result, err := dsInstance.ZabbixAPIQuery(req.Context(), apiReq)
+	result, err := dsInstance.ZabbixAPIQuery(ctx, apiReq)
 	if err != nil {
-		ds.logger.Error("Zabbix API request error", "error", err)
+		logger.Error("Zabbix API request error", "error", err)
 		writeError(rw, http.StatusInternalServerError, err)
 		return
 	}
@@ -59,10 +82,544 @@ func (ds *ZabbixDatasource) ZabbixAPIHandler(rw http.ResponseWriter, req *http.R
 	writeResponse(rw, result)
 }
 
+// TriggerConditionsHandler returns comparisons of the triggers expressions with constants and user macros,
+// so alert conditions matching Zabbix triggers can be built.
+func (ds *ZabbixDatasource) TriggerConditionsHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	body, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil || len(body) == 0 {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	var reqData TriggerConditionsResourceRequest
+	err = json.Unmarshal(body, &reqData)
+	if err != nil {
+		logger.Error("Cannot unmarshal request", "error", err.Error())
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	conditions, err := dsInstance.getTriggerConditions(ctx, reqData.TriggerIDs)
+	if err != nil {
+		logger.Error("Zabbix API request error", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: conditions})
+}
+
+// AlertWebhookHandler receives notifications of the Grafana webhook contact point and acknowledges
+// corresponding Zabbix events. It works only if enabled in the data source settings, Grafana user of the contact
+// point should be allowed to acknowledge problems.
+func (ds *ZabbixDatasource) AlertWebhookHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(rw, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	body, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil || len(body) == 0 {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload AlertWebhookPayload
+	err = json.Unmarshal(body, &payload)
+	if err != nil {
+		logger.Error("Cannot unmarshal request", "error", err.Error())
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !dsInstance.Settings.AlertAcknowledge {
+		writeError(rw, http.StatusForbidden, errors.New("acknowledging Zabbix events from alerts is disabled in the data source settings"))
+		return
+	}
+	if !dsInstance.canAcknowledge(pluginCxt.User) {
+		writeError(rw, http.StatusForbidden, errors.New("acknowledging Zabbix events is disabled for read-only users"))
+		return
+	}
+
+	results := dsInstance.acknowledgeAlerts(ctx, &payload)
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: results})
+}
+
+// AlertRulesHandler converts Zabbix triggers into Grafana alert rules provisioning
+func (ds *ZabbixDatasource) AlertRulesHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	body, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil || len(body) == 0 {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	var reqData AlertRulesResourceRequest
+	err = json.Unmarshal(body, &reqData)
+	if err != nil {
+		logger.Error("Cannot unmarshal request", "error", err.Error())
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	export, err := dsInstance.exportAlertRules(ctx, &reqData)
+	if err != nil {
+		logger.Error("Zabbix API request error", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: export})
+}
+
+// DashboardHandler generates a dashboard with panels mirroring the graphs of the Zabbix host or template
+func (ds *ZabbixDatasource) DashboardHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	body, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil || len(body) == 0 {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	var reqData DashboardResourceRequest
+	err = json.Unmarshal(body, &reqData)
+	if err != nil {
+		logger.Error("Cannot unmarshal request", "error", err.Error())
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	dashboard, err := dsInstance.generateDashboard(ctx, &reqData)
+	if err != nil {
+		logger.Error("Error generating dashboard", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: dashboard})
+}
+
+// HealthDetailsHandler returns diagnostics of the data source instance: Zabbix version and capabilities, auth
+// token age, cache stats, state of the direct DB connection and the last error.
+func (ds *ZabbixDatasource) HealthDetailsHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: dsInstance.GetDiagnostics(ctx)})
+}
+
+// QueryStatsHandler returns statistics of the latest data source queries: top API methods by time, slowest
+// queries, cache hit rate and error rate.
+func (ds *ZabbixDatasource) QueryStatsHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: dsInstance.queryStats.Stats()})
+}
+
+// Header of the discovery response with total number of the matched objects
+const totalCountHeader = "X-Total-Count"
+
+// DiscoveryHandler returns groups, hosts, applications or items (by the last path element) matching the filters
+// of the query params, so the query editor can list them without requesting Zabbix API from the browser. Page
+// is set by the limit and offset params, total number of the matched objects is returned in the header.
+func (ds *ZabbixDatasource) DiscoveryHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	page, err := parsePage(req.URL.Query(), 0)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	objects, total, err := dsInstance.discover(ctx, path.Base(req.URL.Path), req.URL.Query(), page)
+	if err != nil {
+		logger.Error("Zabbix API request error", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	rw.Header().Set(totalCountHeader, strconv.Itoa(total))
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: objects})
+}
+
+// GroupTreeHandler returns host groups matching the group query param as a tree of the "/"-separated nested
+// groups, so the query editor can show cascading pickers
+func (ds *ZabbixDatasource) GroupTreeHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	groupFilter := req.URL.Query().Get("group")
+	if groupFilter == "" {
+		groupFilter = matchAllFilter
+	}
+	tree, err := dsInstance.getHostGroupTree(ctx, groupFilter)
+	if err != nil {
+		logger.Error("Zabbix API request error", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: tree})
+}
+
+// VariableQueryHandler returns values of the template variable query, so variables can be resolved without
+// requesting Zabbix API from the browser.
+func (ds *ZabbixDatasource) VariableQueryHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	body, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil || len(body) == 0 {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	var reqData VariableQueryResourceRequest
+	err = json.Unmarshal(body, &reqData)
+	if err != nil {
+		logger.Error("Cannot unmarshal request", "error", err.Error())
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	query, err := parseVariableQuery(reqData.Query)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	values, err := dsInstance.queryVariable(ctx, query, resourceTimeRange(reqData.From, reqData.To))
+	if err != nil {
+		logger.Error("Zabbix API request error", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: values})
+}
+
+// QueryValidateHandler takes a query model and returns number of the groups, hosts, applications and items it
+// matches, with issues like invalid regexes or empty matches. History isn't requested.
+func (ds *ZabbixDatasource) QueryValidateHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	body, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil || len(body) == 0 {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	validation, err := dsInstance.validateQuery(ctx, body)
+	if err != nil {
+		logger.Error("Zabbix API request error", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: validation})
+}
+
+// QueryExplainHandler returns API calls made by the query for the time range and whether trends or history
+// are used. History isn't requested.
+func (ds *ZabbixDatasource) QueryExplainHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	body, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil || len(body) == 0 {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	var reqData QueryExplainResourceRequest
+	err = json.Unmarshal(body, &reqData)
+	if err != nil {
+		logger.Error("Cannot unmarshal request", "error", err.Error())
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	explanation, err := dsInstance.explainQuery(ctx, reqData.DataQuery())
+	if err != nil {
+		logger.Error("Error explaining query", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: explanation})
+}
+
+// QueryExportHandler runs the query and streams the result as CSV, so reporting tools can export large results
+// without the browser
+func (ds *ZabbixDatasource) QueryExportHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	body, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil || len(body) == 0 {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	var reqData QueryExportResourceRequest
+	err = json.Unmarshal(body, &reqData)
+	if err != nil {
+		logger.Error("Cannot unmarshal request", "error", err.Error())
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	if reqData.Format == ExportFormatXLSX {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("xlsx export is not supported, use csv format"))
+		return
+	}
+	if reqData.Format != "" && reqData.Format != ExportFormatCSV {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("unsupported export format: %s", reqData.Format))
+		return
+	}
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	frames, err := ds.exportQuery(ctx, pluginCxt, reqData.DataQuery())
+	if err != nil {
+		logger.Error("Error exporting query", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	rw.Header().Add("Content-Type", "text/csv; charset=utf-8")
+	rw.Header().Add("Content-Disposition", `attachment; filename="export.csv"`)
+	rw.WriteHeader(http.StatusOK)
+	if err := writeFramesCSV(rw, frames); err != nil {
+		logger.Error("Error writing export", "error", err)
+	}
+}
+
+// ItemSearchHandler returns a page of the items matching the search string by name or key, with total number
+// of the matched items, so the query editor can show a pageable list instead of a truncated dropdown.
+func (ds *ZabbixDatasource) ItemSearchHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	result, err := dsInstance.searchItems(ctx, req.URL.Query())
+	if err != nil {
+		logger.Error("Error searching items", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(rw, &ZabbixAPIResourceResponse{Result: result})
+}
+
+// MapLayerHandler returns elements of the Zabbix map as GeoJSON with the host inventory locations and current
+// problems, so the geomap panel can load the map as a layer by URL
+func (ds *ZabbixDatasource) MapLayerHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		return
+	}
+
+	ctx := requestContext(req)
+	logger := requestid.Logger(ctx, ds.logger)
+
+	pluginCxt := httpadapter.PluginConfigFromContext(ctx)
+	dsInstance, err := ds.getDSInstance(pluginCxt)
+	if err != nil {
+		logger.Error("Error loading datasource", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	layer, err := dsInstance.getMapLayer(ctx, req.URL.Query().Get("sysmapid"))
+	if err != nil {
+		logger.Error("Error getting map layer", "error", err)
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	layerJSON, err := json.Marshal(layer)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	rw.Header().Add("Content-Type", "application/geo+json")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(layerJSON)
+}
+
+// requestContext returns context of the resource request with ID of the Grafana request
+func requestContext(req *http.Request) context.Context {
+	return requestid.With(req.Context(), requestid.FromHeaders(req.Header.Get))
+}
+
 func writeResponse(rw http.ResponseWriter, result *ZabbixAPIResourceResponse) {
 	resultJson, err := json.Marshal(*result)
 	if err != nil {
 		writeError(rw, http.StatusInternalServerError, err)
+		return
 	}
 
 	rw.Header().Add("Content-Type", "application/json")
@@ -70,19 +627,24 @@ func writeResponse(rw http.ResponseWriter, result *ZabbixAPIResourceResponse) {
 	rw.Write(resultJson)
 }
 
+// writeError writes error response with the status code. Message is the status text if there is no error, like
+// for the empty request body.
 func writeError(rw http.ResponseWriter, statusCode int, err error) {
 	data := make(map[string]interface{})
 
-	data["error"] = "Internal Server Error"
-	data["message"] = err.Error()
+	data["error"] = http.StatusText(statusCode)
+	data["message"] = http.StatusText(statusCode)
+	if err != nil {
+		data["message"] = err.Error()
+	}
 
-	var b []byte
-	if b, err = json.Marshal(data); err != nil {
+	b, err := json.Marshal(data)
+	if err != nil {
 		rw.WriteHeader(statusCode)
 		return
 	}
 
 	rw.Header().Add("Content-Type", "application/json")
-	rw.WriteHeader(http.StatusInternalServerError)
+	rw.WriteHeader(statusCode)
 	rw.Write(b)
 }
@@ -0,0 +1,64 @@
+package datasource
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		err        error
+		wantBody   string
+	}{
+		{
+			name:       "Forbidden",
+			statusCode: http.StatusForbidden,
+			err:        errors.New("acknowledging is disabled"),
+			wantBody:   `{"error":"Forbidden","message":"acknowledging is disabled"}`,
+		},
+		{
+			name:       "No error",
+			statusCode: http.StatusBadRequest,
+			wantBody:   `{"error":"Bad Request","message":"Bad Request"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			writeError(rw, tt.statusCode, tt.err)
+			assert.Equal(t, tt.statusCode, rw.Code)
+			assert.JSONEq(t, tt.wantBody, rw.Body.String())
+		})
+	}
+}
+
+func TestAlertWebhookHandlerInvalidRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		statusCode int
+	}{
+		{name: "GET request", method: http.MethodGet, statusCode: http.StatusMethodNotAllowed},
+		{name: "Empty body", method: http.MethodPost, statusCode: http.StatusBadRequest},
+		{name: "Invalid JSON", method: http.MethodPost, body: `{"alerts":`, statusCode: http.StatusBadRequest},
+	}
+
+	ds := &ZabbixDatasource{logger: log.New()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			ds.AlertWebhookHandler(rw, httptest.NewRequest(tt.method, "/alert-webhook", strings.NewReader(tt.body)))
+			assert.Equal(t, tt.statusCode, rw.Code)
+		})
+	}
+}
@@ -2,48 +2,459 @@ package datasource
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/gtime"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
-func convertHistory(history History, items Items) *data.Frame {
-	timeFileld := data.NewFieldFromFieldType(data.FieldTypeTime, 0)
-	timeFileld.Name = "time"
-	frame := data.NewFrame("History", timeFileld)
+func convertHistoryToTimeSeries(history History, items Items) []*timeseries.TimeSeriesData {
+	seriesMap := make(map[string]*timeseries.TimeSeriesData, len(items))
+	series := make([]*timeseries.TimeSeriesData, 0, len(items))
 
 	for _, item := range items {
-		field := data.NewFieldFromFieldType(data.FieldTypeNullableFloat64, 0)
+		s := timeseries.NewTimeSeriesData()
+		itemName := item.ExpandItem()
+		s.Meta.Labels = data.Labels{"item": itemName, "key": item.Key}
 		if len(item.Hosts) > 0 {
-			field.Name = fmt.Sprintf("%s: %s", item.Hosts[0].Name, item.ExpandItem())
+			s.Meta.Name = fmt.Sprintf("%s: %s", item.Hosts[0].Name, itemName)
+			s.Meta.Labels["host"] = item.Hosts[0].Name
 		} else {
-			field.Name = item.ExpandItem()
+			s.Meta.Name = itemName
 		}
-		frame.Fields = append(frame.Fields, field)
+		if interval, err := parseItemUpdateInterval(item.Delay); err == nil {
+			s.Meta.Interval = interval
+		}
+		s.Meta.ItemState = item.StateName()
+		s.Meta.ItemError = item.Error
+		seriesMap[item.ID] = s
+		series = append(series, s)
 	}
 
 	for _, point := range history {
-		for columnIndex, field := range frame.Fields {
-			if columnIndex == 0 {
-				ts := time.Unix(point.Clock, point.NS)
-				field.Append(ts)
-			} else {
-				item := items[columnIndex-1]
-				if point.ItemID == item.ID {
-					value := point.Value
-					field.Append(&value)
-				} else {
-					field.Append(nil)
-				}
+		s, ok := seriesMap[point.ItemID]
+		if !ok {
+			continue
+		}
+		value := point.Value
+		s.Add(timeseries.TimePoint{
+			Time:  time.Unix(point.Clock, point.NS),
+			Value: &value,
+		})
+	}
+
+	for _, s := range series {
+		s.TS.SortByTime()
+	}
+
+	return series
+}
+
+// convertLastValuesToTimeSeries returns series with the single point built from the item last value.
+// Items without value (never updated or not numeric) get empty series.
+func convertLastValuesToTimeSeries(items Items) []*timeseries.TimeSeriesData {
+	history := make(History, 0, len(items))
+	for _, item := range items {
+		if item.LastClock == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(item.LastValue, 64)
+		if err != nil {
+			continue
+		}
+		history = append(history, HistoryPoint{ItemID: item.ID, Clock: item.LastClock, NS: item.LastNS, Value: value})
+	}
+	return convertHistoryToTimeSeries(history, items)
+}
+
+// stitchTrendAndHistory joins trend and history series of the same items (series should be in the same order).
+// Stitched series are aligned by hour before trendsTill and by the item update interval after it.
+func stitchTrendAndHistory(trendSeries []*timeseries.TimeSeriesData, historySeries []*timeseries.TimeSeriesData, trendsTill time.Time) []*timeseries.TimeSeriesData {
+	for i, s := range trendSeries {
+		if i >= len(historySeries) {
+			break
+		}
+		history := historySeries[i]
+		s.TS = append(s.TS, history.TS...)
+		s.Meta.Interval = stitchedInterval{trendsTill: trendsTill, history: seriesUpdateInterval(history)}
+	}
+	return trendSeries
+}
+
+// stitchedInterval is an update interval of the series built from trends (hourly) followed by history.
+type stitchedInterval struct {
+	trendsTill time.Time
+	history    timeseries.UpdateInterval
+}
+
+func (i stitchedInterval) IntervalAt(t time.Time) time.Duration {
+	if t.Before(i.trendsTill) {
+		return time.Hour
+	}
+	return i.history.IntervalAt(t)
+}
+
+func (i stitchedInterval) IsZero() bool {
+	return false
+}
+
+// parseNoDataPeriod returns the nodata period of the query
+func parseNoDataPeriod(query *QueryModel) (time.Duration, error) {
+	period, err := gtime.ParseInterval(query.Options.NoDataPeriod)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing nodata period: %w", err)
+	}
+	if period <= 0 {
+		return 0, fmt.Errorf("nodata period should be positive, got %s", query.Options.NoDataPeriod)
+	}
+	return period, nil
+}
+
+// noDataFetchRange returns time range of the history fetched for the query. Windows of the nodata series at the
+// start of the range need points of the nodata period before it, so the range is extended by the period. Points
+// before the query range are dropped by convertToNoDataSeries.
+func noDataFetchRange(query *QueryModel) backend.TimeRange {
+	if query.Options.NoDataPeriod == "" {
+		return query.TimeRange
+	}
+	period, err := parseNoDataPeriod(query)
+	if err != nil {
+		// Error is returned by convertToNoDataSeries
+		return query.TimeRange
+	}
+	return backend.TimeRange{From: query.TimeRange.From.Add(-period), To: query.TimeRange.To}
+}
+
+// convertToNoDataSeries replaces values of the series with the nodata flag (or percentage of missing points)
+// computed for each query interval within the query time range. Series should include points of the nodata
+// period before the time range, see noDataFetchRange.
+func convertToNoDataSeries(series []*timeseries.TimeSeriesData, query *QueryModel) error {
+	period, err := parseNoDataPeriod(query)
+	if err != nil {
+		return err
+	}
+
+	step := query.Interval
+	if step <= 0 {
+		step = time.Minute
+	}
+
+	from, to := query.TimeRange.From, query.TimeRange.To
+	for _, s := range series {
+		if query.Options.NoDataMode == NoDataModePercent {
+			s.TS = s.TS.NoDataPercent(from, to, step, period, seriesUpdateInterval(s))
+		} else {
+			s.TS = s.TS.NoData(from, to, step, period)
+		}
+		s.Meta.Interval = timeseries.FixedInterval(step)
+		s.Meta.FieldConfig = nil
+	}
+	return nil
+}
+
+// alignSeriesData aligns points to the item update interval (detected from data if unknown) and fills
+// missing points with nulls, so series don't get connected over gaps. Trends are aligned by hour.
+func alignSeriesData(series []*timeseries.TimeSeriesData, useTrend bool) {
+	for _, s := range series {
+		if useTrend {
+			s.TS = s.TS.Align(time.Hour)
+		} else {
+			s.TS = s.TS.AlignBy(seriesUpdateInterval(s))
+		}
+	}
+}
+
+// seriesUpdateInterval returns update interval of the series item, or the interval detected from the data if the
+// item has no fixed update interval
+func seriesUpdateInterval(s *timeseries.TimeSeriesData) timeseries.UpdateInterval {
+	if s.Meta.Interval != nil && !s.Meta.Interval.IsZero() {
+		return s.Meta.Interval
+	}
+	return timeseries.FixedInterval(s.TS.DetectInterval())
+}
+
+// convertSeriesToFrame converts series into the wide or long frame depending on the query options.
+// Empty series are skipped if the query option is set.
+func convertSeriesToFrame(series []*timeseries.TimeSeriesData, options QueryOptions) *data.Frame {
+	if options.SkipEmptyValues {
+		series = skipEmptySeries(series)
+	}
+	if options.FrameFormat == FrameFormatLong {
+		return convertTimeSeriesToLongFrame(series, options.FillMode)
+	}
+	return convertTimeSeriesToDataFrame(series, options.FillMode)
+}
+
+// skipEmptySeries drops series without values and trims empty points at the end of the other series, so
+// items which stopped sending data don't add trailing nulls to the frame
+func skipEmptySeries(series []*timeseries.TimeSeriesData) []*timeseries.TimeSeriesData {
+	result := make([]*timeseries.TimeSeriesData, 0, len(series))
+	for _, s := range series {
+		last := len(s.TS) - 1
+		for last >= 0 && s.TS[last].Value == nil {
+			last--
+		}
+		if last < 0 {
+			continue
+		}
+		s.TS = s.TS[:last+1]
+		result = append(result, s)
+	}
+	return result
+}
+
+// convertTimeSeriesToLongFrame builds long frame with time, value, host and item columns, sorted by time.
+// Item column contains series name for the series not related to a single item (aggregations, etc).
+func convertTimeSeriesToLongFrame(series []*timeseries.TimeSeriesData, fillMode string) *data.Frame {
+	type row struct {
+		time  time.Time
+		value *float64
+		host  string
+		item  string
+	}
+
+	rows := make([]row, 0)
+	for _, s := range series {
+		timestamps := make([]time.Time, 0, s.Len())
+		values := make([]*float64, 0, s.Len())
+		for _, p := range s.TS {
+			timestamps = append(timestamps, p.Time)
+			values = append(values, p.Value)
+		}
+		fillMissingValues(values, timestamps, fillMode)
+
+		host := s.Meta.Labels["host"]
+		item := s.Meta.Labels["item"]
+		if item == "" {
+			item = s.Meta.Name
+		}
+		for i, t := range timestamps {
+			rows = append(rows, row{time: t, value: values[i], host: host, item: item})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].time.Before(rows[j].time)
+	})
+
+	timeField := data.NewField("time", nil, make([]time.Time, 0, len(rows)))
+	valueField := data.NewField("value", nil, make([]*float64, 0, len(rows)))
+	hostField := data.NewField("host", nil, make([]string, 0, len(rows)))
+	itemField := data.NewField("item", nil, make([]string, 0, len(rows)))
+	for _, r := range rows {
+		timeField.Append(r.time)
+		valueField.Append(r.value)
+		hostField.Append(r.host)
+		itemField.Append(r.item)
+	}
+	return data.NewFrame("History", timeField, valueField, hostField, itemField)
+}
+
+// convertTimeSeriesToDataFrame builds wide data frame with shared time field and value field for each series.
+// Missing values are filled according to the fill mode.
+func convertTimeSeriesToDataFrame(series []*timeseries.TimeSeriesData, fillMode string) *data.Frame {
+	timestampSet := make(map[int64]time.Time)
+	for _, s := range series {
+		for _, p := range s.TS {
+			timestampSet[p.Time.UnixNano()] = p.Time
+		}
+	}
+
+	timestamps := make([]time.Time, 0, len(timestampSet))
+	for _, t := range timestampSet {
+		timestamps = append(timestamps, t)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+
+	timeFileld := data.NewField("time", nil, timestamps)
+	frame := data.NewFrame("History", timeFileld)
+
+	rowIndex := make(map[int64]int, len(timestamps))
+	for i, t := range timestamps {
+		rowIndex[t.UnixNano()] = i
+	}
+
+	for _, s := range series {
+		values := make([]*float64, len(timestamps))
+		for _, p := range s.TS {
+			values[rowIndex[p.Time.UnixNano()]] = p.Value
+		}
+		fillMissingValues(values, timestamps, fillMode)
+		field := data.NewField(s.Meta.Name, s.Meta.Labels, values)
+
+		// Keep series name as display name, so labels are available for matching series but not added to the legend
+		config := &data.FieldConfig{}
+		if s.Meta.FieldConfig != nil {
+			configCopy := *s.Meta.FieldConfig
+			config = &configCopy
+		}
+		config.DisplayNameFromDS = s.Meta.Name
+		field.SetConfig(config)
+		frame.Fields = append(frame.Fields, field)
+	}
+
+	return frame
+}
+
+// convertValueMappings converts Zabbix value map to Grafana value mappings: exact values are mapped by a single
+// value mapper, followed by a range mapper per range. Only exact value and range mappings are supported by
+// Grafana, other mapping types are skipped.
+func convertValueMappings(valueMap ValueMap) data.ValueMappings {
+	mappings := data.ValueMappings{}
+	values := data.ValueMapper{}
+	for i, m := range valueMap.Mappings {
+		result := data.ValueMappingResult{Text: m.NewValue, Index: i}
+
+		switch m.Type {
+		case "", ValueMapTypeEqual:
+			values[m.Value] = result
+		case ValueMapTypeRange:
+			// Range is set like 1-10, only single range is supported
+			from, to, ok := splitValueMapRange(m.Value)
+			if !ok {
+				continue
 			}
+			mappings = append(mappings, data.RangeValueMapper{From: &from, To: &to, Result: result})
 		}
 	}
+	if len(values) > 0 {
+		mappings = append(data.ValueMappings{values}, mappings...)
+	}
+	return mappings
+}
 
-	wideFrame, err := data.LongToWide(frame, &data.FillMissing{Mode: data.FillModeNull})
+var valueMapRangePattern = regexp.MustCompile(`^\s*(-?[\d.]+)\s*-\s*(-?[\d.]+)\s*$`)
+
+func splitValueMapRange(value string) (data.ConfFloat64, data.ConfFloat64, bool) {
+	matches := valueMapRangePattern.FindStringSubmatch(value)
+	if len(matches) != 3 {
+		return 0, 0, false
+	}
+	from, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	to, err := strconv.ParseFloat(matches[2], 64)
 	if err != nil {
-		backend.Logger.Debug("Error converting data frame to the wide format", "error", err)
-		return frame
+		return 0, 0, false
+	}
+	return data.ConfFloat64(from), data.ConfFloat64(to), true
+}
+
+// convertTextHistoryToLogsFrames builds logs frame for each item with time, line and detected log level fields.
+// Host and item are set as labels of the line field.
+func convertTextHistoryToLogsFrames(history TextHistory, items Items, tags map[string][]ItemTag) []*data.Frame {
+	historyByItem := make(map[string]TextHistory, len(items))
+	for _, point := range history {
+		historyByItem[point.ItemID] = append(historyByItem[point.ItemID], point)
+	}
+
+	frames := make([]*data.Frame, 0, len(items))
+	for _, item := range items {
+		itemHistory := historyByItem[item.ID]
+		sort.SliceStable(itemHistory, func(i, j int) bool {
+			return itemHistory[i].Clock < itemHistory[j].Clock ||
+				itemHistory[i].Clock == itemHistory[j].Clock && itemHistory[i].NS < itemHistory[j].NS
+		})
+
+		labels := tagsToLabels(tags[item.ID])
+		labels["item"] = item.ExpandItem()
+		labels["key"] = item.Key
+		if len(item.Hosts) > 0 {
+			labels["host"] = item.Hosts[0].Name
+		}
+
+		timeField := data.NewField("time", nil, make([]time.Time, 0, len(itemHistory)))
+		lineField := data.NewField("line", labels, make([]string, 0, len(itemHistory)))
+		levelField := data.NewField("level", nil, make([]string, 0, len(itemHistory)))
+		for _, point := range itemHistory {
+			timeField.Append(time.Unix(point.Clock, point.NS))
+			lineField.Append(point.Value)
+			levelField.Append(detectLogLevel(point, item.ValueType))
+		}
+
+		frame := data.NewFrame(item.ExpandItem(), timeField, lineField, levelField)
+		frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeLogs}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+var logLevelPatterns = []struct {
+	level   string
+	pattern *regexp.Regexp
+}{
+	{level: "critical", pattern: regexp.MustCompile(`(?i)\b(crit|critical|fatal|emerg|emergency|alert|panic)\b`)},
+	{level: "error", pattern: regexp.MustCompile(`(?i)\b(err|error|failed|failure)\b`)},
+	{level: "warning", pattern: regexp.MustCompile(`(?i)\b(warn|warning)\b`)},
+	{level: "debug", pattern: regexp.MustCompile(`(?i)\b(debug|trace)\b`)},
+	{level: "info", pattern: regexp.MustCompile(`(?i)\b(info|notice|information)\b`)},
+}
+
+// detectLogLevel returns level of the log line. Severity of the log items (Windows event log level: 1 - information,
+// 2 - warning, 4 - error, 7 - failure audit, 8 - success audit, 9 - critical, 10 - verbose) is used if set,
+// otherwise level is detected from the line text.
+func detectLogLevel(point TextHistoryPoint, valueType int) string {
+	if valueType == ValueTypeLog && point.Severity > 0 {
+		switch point.Severity {
+		case 1, 8:
+			return "info"
+		case 2, 7:
+			return "warning"
+		case 4:
+			return "error"
+		case 9:
+			return "critical"
+		case 10:
+			return "debug"
+		}
+	}
+
+	for _, p := range logLevelPatterns {
+		if p.pattern.MatchString(point.Value) {
+			return p.level
+		}
+	}
+	return "unknown"
+}
+
+func fillMissingValues(values []*float64, timestamps []time.Time, fillMode string) {
+	switch fillMode {
+	case FillModeZero:
+		for i := range values {
+			if values[i] == nil {
+				zero := 0.0
+				values[i] = &zero
+			}
+		}
+	case FillModePrevious:
+		for i := 1; i < len(values); i++ {
+			if values[i] == nil {
+				values[i] = values[i-1]
+			}
+		}
+	case FillModeLinear:
+		// Fill gaps between known values, leading and trailing nulls are kept
+		prev := -1
+		for i := range values {
+			if values[i] == nil {
+				continue
+			}
+			if prev >= 0 && i-prev > 1 {
+				left, right := *values[prev], *values[i]
+				span := float64(timestamps[i].Sub(timestamps[prev]))
+				for j := prev + 1; j < i; j++ {
+					value := left + (right-left)*float64(timestamps[j].Sub(timestamps[prev]))/span
+					values[j] = &value
+				}
+			}
+			prev = i
+		}
 	}
-	return wideFrame
 }
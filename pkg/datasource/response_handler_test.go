@@ -0,0 +1,196 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlignSeriesData(t *testing.T) {
+	series := timeseries.NewTimeSeriesData()
+	series.Meta.Interval = timeseries.FixedInterval(time.Minute)
+	for _, sec := range []int64{5, 65, 185} {
+		value := float64(sec)
+		series.Add(timeseries.TimePoint{Time: time.Unix(sec, 0), Value: &value})
+	}
+
+	alignSeriesData([]*timeseries.TimeSeriesData{series}, false)
+
+	assert.Equal(t, 4, series.Len())
+	assert.Equal(t, time.Unix(0, 0), series.TS[0].Time)
+	assert.Equal(t, float64(5), *series.TS[0].Value)
+	assert.Equal(t, time.Unix(120, 0), series.TS[2].Time)
+	assert.Nil(t, series.TS[2].Value)
+	assert.Equal(t, time.Unix(180, 0), series.TS[3].Time)
+}
+
+func TestStitchTrendAndHistory(t *testing.T) {
+	addPoints := func(s *timeseries.TimeSeriesData, seconds ...int64) {
+		for _, sec := range seconds {
+			value := float64(sec)
+			s.Add(timeseries.TimePoint{Time: time.Unix(sec, 0), Value: &value})
+		}
+	}
+	trend := timeseries.NewTimeSeriesData()
+	addPoints(trend, 0, 3600)
+	history := timeseries.NewTimeSeriesData()
+	history.Meta.Interval = timeseries.FixedInterval(time.Minute)
+	addPoints(history, 7205, 7265, 7385)
+
+	series := stitchTrendAndHistory([]*timeseries.TimeSeriesData{trend}, []*timeseries.TimeSeriesData{history}, time.Unix(7200, 0))
+	alignSeriesData(series, false)
+
+	var times []int64
+	for _, p := range series[0].TS {
+		times = append(times, p.Time.Unix())
+	}
+	assert.Equal(t, []int64{0, 3600, 7200, 7260, 7320, 7380}, times)
+	assert.Nil(t, series[0].TS[4].Value)
+	assert.Equal(t, float64(7205), *series[0].TS[2].Value)
+}
+
+func TestConvertToNoDataSeries(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected []float64
+	}{
+		{mode: NoDataModeBool, expected: []float64{0, 0, 0, 0, 1, 1}},
+		{mode: NoDataModePercent, expected: []float64{0, 0, 0, 50, 100, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			// Points of the nodata period before the range are fetched too
+			series := mockSeries("a", 1, 1, 1, 1, 1)
+			for i := range series.TS {
+				series.TS[i].Time = series.TS[i].Time.Add(-2 * time.Minute)
+			}
+			series.Meta.Interval = timeseries.FixedInterval(time.Minute)
+			query := &QueryModel{
+				Options:   QueryOptions{NoDataPeriod: "2m", NoDataMode: tt.mode},
+				TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(300, 0)},
+				Interval:  time.Minute,
+			}
+			assert.Equal(t, backend.TimeRange{From: time.Unix(-120, 0), To: time.Unix(300, 0)}, noDataFetchRange(query))
+
+			err := convertToNoDataSeries([]*timeseries.TimeSeriesData{series}, query)
+			assert.Nil(t, err)
+			assert.Equal(t, len(tt.expected), series.Len())
+			for i, expected := range tt.expected {
+				assert.Equal(t, expected, *series.TS[i].Value)
+			}
+		})
+	}
+}
+
+func TestConvertLastValuesToTimeSeries(t *testing.T) {
+	items := Items{
+		{ID: "1", Name: "CPU", Hosts: []ItemHost{{ID: "10", Name: "web01"}}, LastValue: "12.5", LastClock: 1600000000},
+		{ID: "2", Name: "Memory", Hosts: []ItemHost{{ID: "10", Name: "web01"}}, LastValue: "0", LastClock: 0, State: ItemStateNotSupported, Error: "Permission denied"},
+	}
+
+	series := convertLastValuesToTimeSeries(items)
+	assert.Len(t, series, 2)
+	assert.Equal(t, "web01: CPU", series[0].Meta.Name)
+	assert.Equal(t, 1, series[0].Len())
+	assert.Equal(t, time.Unix(1600000000, 0), series[0].TS[0].Time)
+	assert.Equal(t, 12.5, *series[0].TS[0].Value)
+	assert.Equal(t, 0, series[1].Len())
+	assert.Equal(t, "Not supported", series[1].Meta.ItemState)
+	assert.Equal(t, "Permission denied", series[1].Meta.ItemError)
+}
+
+func TestConvertTimeSeriesToDataFrameFillMode(t *testing.T) {
+	tests := []struct {
+		fillMode string
+		expected []*float64
+	}{
+		{fillMode: FillModeNull, expected: []*float64{nil, floatPtr(1), nil, floatPtr(3)}},
+		{fillMode: FillModeZero, expected: []*float64{floatPtr(0), floatPtr(1), floatPtr(0), floatPtr(3)}},
+		{fillMode: FillModePrevious, expected: []*float64{nil, floatPtr(1), floatPtr(1), floatPtr(3)}},
+		{fillMode: FillModeLinear, expected: []*float64{nil, floatPtr(1), floatPtr(2), floatPtr(3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fillMode, func(t *testing.T) {
+			a := mockSeries("a", 0, 0, 0, 0)
+			b := timeseries.NewTimeSeriesData()
+			b.Meta.Name = "b"
+			b.Add(timeseries.TimePoint{Time: a.TS[1].Time, Value: floatPtr(1)})
+			b.Add(timeseries.TimePoint{Time: a.TS[3].Time, Value: floatPtr(3)})
+
+			frame := convertTimeSeriesToDataFrame([]*timeseries.TimeSeriesData{a, b}, tt.fillMode)
+			field := frame.Fields[2]
+			assert.Equal(t, len(tt.expected), field.Len())
+			for i, expected := range tt.expected {
+				assert.Equal(t, expected, field.At(i))
+			}
+		})
+	}
+}
+
+func TestConvertSeriesToLongFrame(t *testing.T) {
+	a := mockSeries("web01: CPU", 1, 2)
+	a.Meta.Labels = data.Labels{"host": "web01", "item": "CPU"}
+	b := mockSeries("sumSeries", 3, 0)
+	b.TS[1].Value = nil
+
+	frame := convertSeriesToFrame([]*timeseries.TimeSeriesData{a, b}, QueryOptions{FrameFormat: FrameFormatLong, FillMode: FillModeZero})
+	assert.Equal(t, []string{"time", "value", "host", "item"}, fieldNames(frame))
+	assert.Equal(t, 4, frame.Rows())
+	assert.Equal(t, time.Unix(0, 0), frame.Fields[0].At(1))
+	assert.Equal(t, floatPtr(3), frame.Fields[1].At(1))
+	assert.Equal(t, "", frame.Fields[2].At(1))
+	assert.Equal(t, "sumSeries", frame.Fields[3].At(1))
+	assert.Equal(t, floatPtr(0), frame.Fields[1].At(3))
+	assert.Equal(t, "web01", frame.Fields[2].At(2))
+
+	frame = convertSeriesToFrame([]*timeseries.TimeSeriesData{a, b}, QueryOptions{FrameFormat: FrameFormatWide})
+	assert.Equal(t, []string{"time", "web01: CPU", "sumSeries"}, fieldNames(frame))
+}
+
+func TestConvertSeriesToFrameSkipEmptyValues(t *testing.T) {
+	a := mockSeries("web01: CPU", 1, 0, 0)
+	a.TS[1].Value = nil
+	a.TS[2].Value = nil
+	b := mockSeries("web01: Memory", 0, 0)
+	b.TS[0].Value = nil
+	b.TS[1].Value = nil
+	c := mockSeries("web01: Disk", 0, 3, 4)
+	c.TS[0].Value = nil
+
+	frame := convertSeriesToFrame([]*timeseries.TimeSeriesData{a, b, c}, QueryOptions{FrameFormat: FrameFormatWide, SkipEmptyValues: true})
+	assert.Equal(t, []string{"time", "web01: CPU", "web01: Disk"}, fieldNames(frame))
+	assert.Equal(t, 3, frame.Rows())
+	assert.Len(t, a.TS, 1)
+	// Empty points inside of the series are kept
+	assert.Len(t, c.TS, 3)
+
+	frame = convertSeriesToFrame([]*timeseries.TimeSeriesData{b}, QueryOptions{FrameFormat: FrameFormatLong, SkipEmptyValues: true})
+	assert.Equal(t, 0, frame.Rows())
+}
+
+func TestConvertValueMappings(t *testing.T) {
+	valueMap := ValueMap{
+		ID: "1",
+		Mappings: []ValueMapMapping{
+			{Value: "0", NewValue: "Down"},
+			{Type: ValueMapTypeEqual, Value: "1", NewValue: "Up"},
+			{Type: ValueMapTypeRange, Value: "2-10", NewValue: "Degraded"},
+			{Type: "4", Value: "^5.*", NewValue: "Regex"},
+		},
+	}
+
+	mappings := convertValueMappings(valueMap)
+	assert.Len(t, mappings, 2)
+	assert.Equal(t, data.ValueMapper{
+		"0": {Text: "Down", Index: 0},
+		"1": {Text: "Up", Index: 1},
+	}, mappings[0])
+	from, to := data.ConfFloat64(2), data.ConfFloat64(10)
+	assert.Equal(t, data.RangeValueMapper{From: &from, To: &to, Result: data.ValueMappingResult{Text: "Degraded", Index: 2}}, mappings[1])
+}
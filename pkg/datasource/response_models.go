@@ -1,21 +1,47 @@
 package datasource
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
+// Item states of the Zabbix API, error text is set for the not supported items
+const (
+	ItemStateNormal       = "0"
+	ItemStateNotSupported = "1"
+)
+
 type Items []Item
 
 type Item struct {
-	ID        string     `json:"itemid,omitempty"`
-	Key       string     `json:"key_,omitempty"`
-	Name      string     `json:"name,omitempty"`
-	ValueType int        `json:"value_type,omitempty,string"`
-	HostID    string     `json:"hostid,omitempty"`
-	Hosts     []ItemHost `json:"hosts,omitempty"`
-	Status    string     `json:"status,omitempty"`
-	State     string     `json:"state,omitempty"`
+	ID         string     `json:"itemid,omitempty"`
+	Key        string     `json:"key_,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	ValueType  int        `json:"value_type,omitempty,string"`
+	HostID     string     `json:"hostid,omitempty"`
+	Hosts      []ItemHost `json:"hosts,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	State      string     `json:"state,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Delay      string     `json:"delay,omitempty"`
+	ValueMapID string     `json:"valuemapid,omitempty"`
+	LastValue  string     `json:"lastvalue,omitempty"`
+	LastClock  int64      `json:"lastclock,omitempty,string"`
+	LastNS     int64      `json:"lastns,omitempty,string"`
+	Units      string     `json:"units,omitempty"`
+}
+
+// StateName returns the item state as shown in the Zabbix frontend
+func (item *Item) StateName() string {
+	switch item.State {
+	case ItemStateNormal:
+		return "Normal"
+	case ItemStateNotSupported:
+		return "Not supported"
+	}
+	return item.State
 }
 
 func (item *Item) ExpandItem() string {
@@ -69,6 +95,11 @@ func splitKeyParams(paramStr string) []string {
 	return params
 }
 
+type ItemTag struct {
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
 type ItemHost struct {
 	ID   string `json:"hostid,omitempty"`
 	Name string `json:"name,omitempty"`
@@ -85,6 +116,47 @@ type TrendPoint struct {
 	ValueMax string `json:"value_max,omitempty"`
 }
 
+// ToHistory converts trend points to history ones, taking value from the column matching
+// given value type (avg, min, max, sum or count).
+func (t Trend) ToHistory(valueType string) History {
+	history := make(History, 0, len(t))
+	for _, point := range t {
+		value, err := point.GetValue(valueType)
+		if err != nil {
+			continue
+		}
+		history = append(history, HistoryPoint{
+			ItemID: point.ItemID,
+			Clock:  point.Clock,
+			Value:  value,
+		})
+	}
+	return history
+}
+
+func (p *TrendPoint) GetValue(valueType string) (float64, error) {
+	switch valueType {
+	case "min":
+		return strconv.ParseFloat(p.ValueMin, 64)
+	case "max":
+		return strconv.ParseFloat(p.ValueMax, 64)
+	case "sum":
+		avg, err := strconv.ParseFloat(p.ValueAvg, 64)
+		if err != nil {
+			return 0, err
+		}
+		num, err := strconv.ParseFloat(p.Num, 64)
+		if err != nil {
+			return 0, err
+		}
+		return avg * num, nil
+	case "count":
+		return strconv.ParseFloat(p.Num, 64)
+	default:
+		return strconv.ParseFloat(p.ValueAvg, 64)
+	}
+}
+
 type History []HistoryPoint
 
 type HistoryPoint struct {
@@ -93,3 +165,240 @@ type HistoryPoint struct {
 	Value  float64 `json:"value,omitempty,string"`
 	NS     int64   `json:"ns,omitempty,string"`
 }
+
+type ValueMap struct {
+	ID       string            `json:"valuemapid,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Mappings []ValueMapMapping `json:"mappings,omitempty"`
+}
+
+// ValueMapMapping is a single value mapping. Type is available since Zabbix 5.4, older versions
+// support exact value mappings only.
+type ValueMapMapping struct {
+	Type     string `json:"type,omitempty"`
+	Value    string `json:"value"`
+	NewValue string `json:"newvalue"`
+}
+
+// Value map mapping types
+const (
+	ValueMapTypeEqual = "0"
+	ValueMapTypeRange = "3"
+)
+
+type TextHistory []TextHistoryPoint
+
+type TextHistoryPoint struct {
+	ItemID string `json:"itemid,omitempty"`
+	Clock  int64  `json:"clock,omitempty,string"`
+	Value  string `json:"value,omitempty"`
+	NS     int64  `json:"ns,omitempty,string"`
+
+	// Log items only
+	Severity   int    `json:"severity,omitempty,string"`
+	Source     string `json:"source,omitempty"`
+	LogEventID string `json:"logeventid,omitempty"`
+}
+
+// Item value types
+const (
+	ValueTypeFloat = 0
+	ValueTypeChar  = 1
+	ValueTypeLog   = 2
+	ValueTypeUint  = 3
+	ValueTypeText  = 4
+)
+
+type Events []Event
+
+type Event struct {
+	ID           string    `json:"eventid"`
+	ObjectID     string    `json:"objectid,omitempty"`
+	Clock        int64     `json:"clock,string"`
+	NS           int64     `json:"ns,string"`
+	Severity     int       `json:"severity,string"`
+	Value        int       `json:"value,omitempty,string"`
+	Acknowledged string    `json:"acknowledged,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	REventID     string    `json:"r_eventid,omitempty"`
+	RClock       int64     `json:"r_clock,omitempty,string"`
+	Tags         []ItemTag `json:"tags,omitempty"`
+
+	Acknowledges []EventAcknowledge `json:"acknowledges,omitempty"`
+
+	// Suppressed is "1" if the problem is suppressed by a maintenance. Names of the maintenances are set by
+	// setProblemsSuppressedBy from the suppression data.
+	Suppressed      string                 `json:"suppressed,omitempty"`
+	SuppressionData []EventSuppressionData `json:"suppression_data,omitempty"`
+	SuppressedBy    []string               `json:"-"`
+}
+
+// EventSuppressionData is a maintenance suppressing the problem, maintenance ID is 0 if the problem is suppressed
+// manually (Zabbix 6.2+)
+type EventSuppressionData struct {
+	MaintenanceID string `json:"maintenanceid"`
+	SuppressUntil int64  `json:"suppress_until,string"`
+}
+
+// EventAcknowledge is an update of the problem by the user, action is a bitmask of the update actions
+type EventAcknowledge struct {
+	UserID string `json:"userid"`
+	Action int    `json:"action,string"`
+}
+
+// Trigger severities
+const (
+	SeverityNotClassified = 0
+	SeverityInformation   = 1
+	SeverityWarning       = 2
+	SeverityAverage       = 3
+	SeverityHigh          = 4
+	SeverityDisaster      = 5
+)
+
+// SeverityNames contains default names of the trigger severities
+var SeverityNames = []string{"Not classified", "Information", "Warning", "Average", "High", "Disaster"}
+
+// SeverityColors contains default colors of the trigger severities
+var SeverityColors = []string{
+	"rgb(108, 108, 108)",
+	"rgb(120, 158, 183)",
+	"rgb(175, 180, 36)",
+	"rgb(255, 137, 30)",
+	"rgb(255, 101, 72)",
+	"rgb(215, 0, 0)",
+}
+
+type Triggers []Trigger
+
+type Trigger struct {
+	ID                 string            `json:"triggerid"`
+	Description        string            `json:"description,omitempty"`
+	Expression         string            `json:"expression,omitempty"`
+	Priority           int               `json:"priority,string"`
+	Value              int               `json:"value,omitempty,string"`
+	LastChange         int64             `json:"lastchange,omitempty,string"`
+	RecoveryMode       int               `json:"recovery_mode,omitempty,string"`
+	RecoveryExpression string            `json:"recovery_expression,omitempty"`
+	Comments           string            `json:"comments,omitempty"`
+	Items              []TriggerItem     `json:"items,omitempty"`
+	Hosts              []ItemHost        `json:"hosts,omitempty"`
+	Groups             []TriggerGroup    `json:"groups,omitempty"`
+	LastEvent          *TriggerLastEvent `json:"lastEvent,omitempty"`
+	Tags               []ItemTag         `json:"tags,omitempty"`
+}
+
+// Graph is a Zabbix graph with its items, graph items define how items are drawn
+type Graph struct {
+	ID         string      `json:"graphid"`
+	Name       string      `json:"name"`
+	GraphType  int         `json:"graphtype,string"`
+	GraphItems []GraphItem `json:"gitems,omitempty"`
+	Items      Items       `json:"items,omitempty"`
+}
+
+type GraphItem struct {
+	ItemID    string `json:"itemid"`
+	Color     string `json:"color"`
+	DrawType  int    `json:"drawtype,string"`
+	SortOrder int    `json:"sortorder,string"`
+	YAxisSide int    `json:"yaxisside,string"`
+}
+
+// Graph types
+const (
+	GraphTypeNormal   = 0
+	GraphTypeStacked  = 1
+	GraphTypePie      = 2
+	GraphTypeExploded = 3
+)
+
+// Draw styles of the graph items
+const (
+	GraphItemDrawLine       = 0
+	GraphItemDrawFilled     = 1
+	GraphItemDrawBoldLine   = 2
+	GraphItemDrawDot        = 3
+	GraphItemDrawDashedLine = 4
+	GraphItemDrawGradient   = 5
+)
+
+// SysMap is a Zabbix network map with its elements
+type SysMap struct {
+	ID       string       `json:"sysmapid"`
+	Name     string       `json:"name"`
+	Elements []MapElement `json:"selements,omitempty"`
+}
+
+// MapElement is an element of the map: host, host group, trigger, sub-map or image. Elements are objects
+// with the ID (hostid, groupid, etc), Zabbix before 3.4 sets the ID as elementid.
+type MapElement struct {
+	ID          string              `json:"selementid"`
+	ElementType int                 `json:"elementtype,string"`
+	ElementID   string              `json:"elementid,omitempty"`
+	Elements    []map[string]string `json:"elements,omitempty"`
+	Label       string              `json:"label,omitempty"`
+	X           int                 `json:"x,string"`
+	Y           int                 `json:"y,string"`
+}
+
+// Types of the map elements
+const (
+	MapElementHost      = 0
+	MapElementMap       = 1
+	MapElementTrigger   = 2
+	MapElementHostGroup = 3
+	MapElementImage     = 4
+)
+
+type TriggerItem struct {
+	ID        string `json:"itemid"`
+	Name      string `json:"name,omitempty"`
+	Key       string `json:"key_,omitempty"`
+	ValueType int    `json:"value_type,omitempty,string"`
+	HostID    string `json:"hostid,omitempty"`
+}
+
+type TriggerGroup struct {
+	ID   string `json:"groupid"`
+	Name string `json:"name"`
+}
+
+// TriggerLastEvent is the last event of the trigger. Zabbix returns empty array instead of object
+// if trigger has no events.
+type TriggerLastEvent struct {
+	ID           string `json:"eventid"`
+	Acknowledged string `json:"acknowledged"`
+}
+
+func (e *TriggerLastEvent) UnmarshalJSON(b []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(b)), "[") {
+		*e = TriggerLastEvent{}
+		return nil
+	}
+	type lastEvent TriggerLastEvent
+	return json.Unmarshal(b, (*lastEvent)(e))
+}
+
+type ITServices []ITService
+
+type ITService struct {
+	ID   string `json:"serviceid"`
+	Name string `json:"name"`
+}
+
+// ServiceSLA is the service.getsla result for the single service. Status is returned either as a number
+// or a string depending on the Zabbix version.
+type ServiceSLA struct {
+	Status json.Number   `json:"status"`
+	SLA    []SLAInterval `json:"sla"`
+}
+
+type SLAInterval struct {
+	From         int64   `json:"from"`
+	To           int64   `json:"to"`
+	SLA          float64 `json:"sla"`
+	OKTime       float64 `json:"okTime"`
+	ProblemTime  float64 `json:"problemTime"`
+	DowntimeTime float64 `json:"downtimeTime"`
+}
@@ -0,0 +1,62 @@
+package datasource
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SeveritySettings overrides name and color of the severity, empty values keep the defaults
+type SeveritySettings struct {
+	Severity int    `json:"severity"`
+	Name     string `json:"name,omitempty"`
+	Color    string `json:"color,omitempty"`
+}
+
+// Severities contains names and colors of the trigger severities set in the data source settings. Default names
+// and colors are used for the severities which are not set.
+type Severities struct {
+	Names  []string
+	Colors []string
+}
+
+// newSeverities returns default severities with the names and colors overridden by the settings
+func newSeverities(settings []SeveritySettings) (Severities, error) {
+	severities := Severities{
+		Names:  append([]string{}, SeverityNames...),
+		Colors: append([]string{}, SeverityColors...),
+	}
+	for _, s := range settings {
+		if s.Severity < SeverityNotClassified || s.Severity > SeverityDisaster {
+			return severities, fmt.Errorf("invalid severity: %d, expected %d-%d", s.Severity, SeverityNotClassified, SeverityDisaster)
+		}
+		if s.Name != "" {
+			severities.Names[s.Severity] = s.Name
+		}
+		if s.Color != "" {
+			severities.Colors[s.Severity] = s.Color
+		}
+	}
+	return severities, nil
+}
+
+// Name returns name of the severity, or the severity number if it's unknown
+func (s Severities) Name(severity int) string {
+	if severity >= 0 && severity < len(s.Names) {
+		return s.Names[severity]
+	}
+	if severity >= 0 && severity < len(SeverityNames) {
+		return SeverityNames[severity]
+	}
+	return strconv.Itoa(severity)
+}
+
+// Color returns color of the severity, or the color of not classified severity if it's unknown
+func (s Severities) Color(severity int) string {
+	if severity >= 0 && severity < len(s.Colors) {
+		return s.Colors[severity]
+	}
+	if severity >= 0 && severity < len(SeverityColors) {
+		return SeverityColors[severity]
+	}
+	return s.Color(SeverityNotClassified)
+}
@@ -0,0 +1,33 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSeverities(t *testing.T) {
+	severities, err := newSeverities([]SeveritySettings{
+		{Severity: SeverityInformation, Name: "Info"},
+		{Severity: SeverityDisaster, Name: "Critical", Color: "#C4162A"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Info", severities.Name(SeverityInformation))
+	assert.Equal(t, SeverityColors[SeverityInformation], severities.Color(SeverityInformation))
+	assert.Equal(t, "Critical", severities.Name(SeverityDisaster))
+	assert.Equal(t, "#C4162A", severities.Color(SeverityDisaster))
+	assert.Equal(t, "High", severities.Name(SeverityHigh))
+	// Defaults aren't changed
+	assert.Equal(t, "Disaster", SeverityNames[SeverityDisaster])
+
+	_, err = newSeverities([]SeveritySettings{{Severity: -1, Name: "Unknown"}})
+	assert.EqualError(t, err, "invalid severity: -1, expected 0-5")
+}
+
+func TestSeveritiesDefaults(t *testing.T) {
+	var severities Severities
+	assert.Equal(t, "Average", severities.Name(SeverityAverage))
+	assert.Equal(t, SeverityColors[SeverityAverage], severities.Color(SeverityAverage))
+	assert.Equal(t, "7", severities.Name(7))
+	assert.Equal(t, SeverityColors[SeverityNotClassified], severities.Color(7))
+}
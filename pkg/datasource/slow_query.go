@@ -0,0 +1,130 @@
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// logSlowQuery logs warning with the query summary if it takes longer than the configured threshold, so queries
+// loading Zabbix can be found. Summary includes API methods and DB queries made by the query and number of
+// returned values.
+func (ds *ZabbixDatasourceInstance) logSlowQuery(ctx context.Context, q backend.DataQuery, query *QueryModel, duration time.Duration, res backend.DataResponse, calls []APICall, dbQueries []dbconnector.ExecutedQuery) {
+	threshold := ds.Settings.SlowQueryThreshold
+	if threshold <= 0 || duration < threshold {
+		return
+	}
+
+	args := []interface{}{
+		"refId", q.RefID,
+		"durationMs", float64(duration) / float64(time.Millisecond),
+		"mode", query.Mode,
+		"query", queryFilterSummary(query),
+		"from", q.TimeRange.From.UTC().Format(time.RFC3339),
+		"to", q.TimeRange.To.UTC().Format(time.RFC3339),
+		"interval", q.Interval.String(),
+		"maxDataPoints", q.MaxDataPoints,
+		"apiCalls", len(calls),
+		"apiMethods", apiMethodsSummary(calls),
+	}
+	if len(dbQueries) > 0 {
+		dbDurationMs := 0.0
+		for _, dbQuery := range dbQueries {
+			dbDurationMs += dbQuery.DurationMs
+		}
+		args = append(args, "dbQueries", len(dbQueries), "dbDurationMs", dbDurationMs)
+	}
+	args = append(args, "frames", len(res.Frames), "points", framesPointsCount(res.Frames))
+	if res.Error != nil {
+		args = append(args, "error", res.Error)
+	}
+	requestid.Logger(ctx, ds.logger).Warn("Slow query", args...)
+}
+
+// queryFilterSummary returns non-empty filters of the query, like "group=Linux host=/web.*/ item=CPU load"
+func queryFilterSummary(query *QueryModel) string {
+	filters := []struct {
+		name  string
+		value string
+	}{
+		{"group", query.Group.Filter},
+		{"host", query.Host.Filter},
+		{"application", query.Application.Filter},
+		{"item", query.Item.Filter},
+		{"trigger", query.Trigger.Filter},
+		{"tags", query.Tags.Filter},
+		{"itservice", query.ITServiceFilter},
+		{"textFilter", query.TextFilter},
+		{"expression", query.Expression},
+	}
+
+	parts := make([]string, 0)
+	for _, filter := range filters {
+		if filter.value != "" {
+			parts = append(parts, filter.name+"="+filter.value)
+		}
+	}
+	for _, fn := range query.Functions {
+		parts = append(parts, "function="+fn.Def.Name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// apiMethodsSummary returns number of calls and total duration of each API method, slowest first, like
+// "history.get x4 (1250.3 ms), item.get (15.2 ms)". Cached calls aren't counted.
+func apiMethodsSummary(calls []APICall) string {
+	type methodStats struct {
+		method     string
+		count      int
+		durationMs float64
+	}
+	statsByMethod := map[string]*methodStats{}
+	methods := make([]*methodStats, 0)
+	for _, call := range calls {
+		if call.Cached {
+			continue
+		}
+		stats, ok := statsByMethod[call.Method]
+		if !ok {
+			stats = &methodStats{method: call.Method}
+			statsByMethod[call.Method] = stats
+			methods = append(methods, stats)
+		}
+		stats.count++
+		stats.durationMs += call.DurationMs
+	}
+	sort.SliceStable(methods, func(i, j int) bool {
+		return methods[i].durationMs > methods[j].durationMs
+	})
+
+	parts := make([]string, 0, len(methods))
+	for _, stats := range methods {
+		if stats.count > 1 {
+			parts = append(parts, fmt.Sprintf("%s x%d (%.1f ms)", stats.method, stats.count, stats.durationMs))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s (%.1f ms)", stats.method, stats.durationMs))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// framesPointsCount returns number of values in the frames, time fields aren't counted
+func framesPointsCount(frames []*data.Frame) int {
+	count := 0
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			if field.Type() == data.FieldTypeTime || field.Type() == data.FieldTypeNullableTime {
+				continue
+			}
+			count += field.Len()
+		}
+	}
+	return count
+}
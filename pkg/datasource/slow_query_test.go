@@ -0,0 +1,96 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogSlowQuery(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := backend.DataQuery{
+		RefID:         "A",
+		TimeRange:     backend.TimeRange{From: from, To: from.Add(24 * time.Hour)},
+		Interval:      time.Minute,
+		MaxDataPoints: 1000,
+	}
+	query := &QueryModel{
+		Group: QueryFilter{Filter: "Linux servers"},
+		Host:  QueryFilter{Filter: "/.*/"},
+		Item:  QueryFilter{Filter: "CPU load"},
+	}
+	res := backend.DataResponse{Frames: []*data.Frame{
+		data.NewFrame("",
+			data.NewField("time", nil, []time.Time{from, from.Add(time.Minute)}),
+			data.NewField("value", nil, []float64{1, 2}),
+		),
+	}}
+	calls := []APICall{
+		{Method: "item.get", DurationMs: 20},
+		{Method: "history.get", DurationMs: 500},
+		{Method: "history.get", DurationMs: 700},
+		{Method: "host.get", DurationMs: 1, Cached: true},
+	}
+	dbQueries := []dbconnector.ExecutedQuery{{DurationMs: 10}, {DurationMs: 15}}
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		duration  time.Duration
+		want      []logEntry
+	}{
+		{name: "disabled", threshold: 0, duration: time.Minute, want: nil},
+		{name: "fast query", threshold: 5 * time.Second, duration: time.Second, want: nil},
+		{
+			name:      "slow query",
+			threshold: 5 * time.Second,
+			duration:  6 * time.Second,
+			want: []logEntry{{
+				level: "warn",
+				msg:   "Slow query",
+				args: []interface{}{
+					"refId", "A",
+					"durationMs", 6000.0,
+					"mode", int64(0),
+					"query", "group=Linux servers host=/.*/ item=CPU load",
+					"from", "2021-01-01T00:00:00Z",
+					"to", "2021-01-02T00:00:00Z",
+					"interval", "1m0s",
+					"maxDataPoints", int64(1000),
+					"apiCalls", 4,
+					"apiMethods", "history.get x2 (1200.0 ms), item.get (20.0 ms)",
+					"dbQueries", 2,
+					"dbDurationMs", 25.0,
+					"frames", 1,
+					"points", 2,
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &testLogger{}
+			ds := &ZabbixDatasourceInstance{
+				Settings: &ZabbixDatasourceSettings{SlowQueryThreshold: tt.threshold},
+				logger:   out,
+			}
+			ds.logSlowQuery(context.Background(), q, query, tt.duration, res, calls, dbQueries)
+			assert.Equal(t, tt.want, out.entries)
+		})
+	}
+}
+
+func TestQueryFilterSummary(t *testing.T) {
+	query := &QueryModel{
+		Group:     QueryFilter{Filter: "Linux servers"},
+		Trigger:   QueryFilter{Filter: "/CPU/"},
+		Functions: []QueryFunction{{Def: QueryFunctionDef{Name: "groupBy"}}},
+	}
+	assert.Equal(t, "group=Linux servers trigger=/CPU/ function=groupBy", queryFilterSummary(query))
+	assert.Equal(t, "", queryFilterSummary(&QueryModel{}))
+}
@@ -0,0 +1,430 @@
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/gtime"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Interval of polling Zabbix by the streams, if not set by the query
+const defaultStreamInterval = 10 * time.Second
+
+// StreamSender sends frame to the stream subscribers
+type StreamSender func(frame *data.Frame) error
+
+// problemsStream keeps problems sent to the stream and their host names, so only changes are sent on the next
+// poll and hosts of the resolved problems are known without requesting their triggers again
+type problemsStream struct {
+	problems map[string]Event
+	hosts    map[string]string
+}
+
+func newProblemsStream() *problemsStream {
+	return &problemsStream{problems: make(map[string]Event), hosts: make(map[string]string)}
+}
+
+// diff returns problems which are new since the last sent ones and problems which are not active anymore
+// (resolved or closed)
+func (s *problemsStream) diff(current Events) (Events, Events) {
+	active := make(map[string]bool, len(current))
+	added := Events{}
+	for _, problem := range current {
+		active[problem.ID] = true
+		if _, ok := s.problems[problem.ID]; !ok {
+			added = append(added, problem)
+		}
+	}
+
+	resolved := Events{}
+	for id, problem := range s.problems {
+		if _, ok := active[id]; !ok {
+			resolved = append(resolved, problem)
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].ID < resolved[j].ID
+	})
+	return added, resolved
+}
+
+// set saves sent problems, so they are not sent again on the next poll
+func (s *problemsStream) set(current Events, resolved Events) {
+	s.problems = make(map[string]Event, len(current))
+	for _, problem := range current {
+		s.problems[problem.ID] = problem
+	}
+	for _, problem := range resolved {
+		delete(s.hosts, problem.ID)
+	}
+}
+
+// streamProblems polls problems of the query hosts with the given interval and sends new and resolved problems
+// until context is done. The first poll sends all active problems.
+func (ds *ZabbixDatasourceInstance) streamProblems(ctx context.Context, query *QueryModel, interval time.Duration, send StreamSender) error {
+	if interval <= 0 {
+		interval = defaultStreamInterval
+	}
+
+	// Stream shows active problems only, recent and history modes make no sense for it
+	streamQuery := *query
+	streamQuery.ShowProblems = ShowProblemsProblems
+	streamQuery.Options.UseTimeRange = false
+
+	stream := newProblemsStream()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := ds.pollProblems(ctx, &streamQuery, stream, send)
+		if err != nil {
+			requestid.Logger(ctx, ds.logger).Warn("Cannot poll problems for the stream", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ds *ZabbixDatasourceInstance) pollProblems(ctx context.Context, query *QueryModel, stream *problemsStream, send StreamSender) error {
+	hosts, err := ds.getHosts(ctx, newQueryFilters(query), query.Group.Filter, query.Host.Filter)
+	if err != nil {
+		return err
+	}
+	var hostids []string
+	for _, host := range hosts {
+		hostids = append(hostids, host["hostid"].(string))
+	}
+
+	problems := Events{}
+	if len(hostids) > 0 {
+		problems, _, err = ds.getProblems(ctx, query, hostids)
+		if err != nil {
+			return err
+		}
+		problems, err = filterProblems(problems, query.Trigger.Filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Stream state is saved only after the changes are sent, so failed poll is retried next time
+	added, resolved := stream.diff(problems)
+	if len(added) == 0 && len(resolved) == 0 {
+		return nil
+	}
+
+	triggers, err := ds.getProblemTriggers(ctx, added)
+	if err != nil {
+		return err
+	}
+	for _, problem := range added {
+		stream.hosts[problem.ID] = triggerHostLabels(triggers[problem.ObjectID])["host"]
+	}
+
+	err = send(convertProblemChangesToFrame(added, resolved, stream.hosts, ds.Settings.Severities, time.Now()))
+	if err != nil {
+		return err
+	}
+	stream.set(problems, resolved)
+	return nil
+}
+
+// convertProblemChangesToFrame returns frame with a row for each new and resolved problem. Time of the new
+// problem is the problem start, time of the resolved one is the time it was found resolved. Hosts are host
+// names of the problems mapped by event id.
+func convertProblemChangesToFrame(added Events, resolved Events, hosts map[string]string, severities Severities, now time.Time) *data.Frame {
+	timeField := data.NewFieldFromFieldType(data.FieldTypeTime, 0)
+	timeField.Name = "Time"
+	eventIDField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	eventIDField.Name = "Event ID"
+	hostField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	hostField.Name = "Host"
+	problemField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	problemField.Name = "Problem"
+	severityField := data.NewFieldFromFieldType(data.FieldTypeInt64, 0)
+	severityField.Name = "Severity"
+	severityField.Config = severityFieldConfig(severities)
+	statusField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	statusField.Name = "Status"
+
+	for _, problem := range added {
+		timeField.Append(time.Unix(problem.Clock, problem.NS))
+		eventIDField.Append(problem.ID)
+		hostField.Append(hosts[problem.ID])
+		problemField.Append(problem.Name)
+		severityField.Append(int64(problem.Severity))
+		statusField.Append(ProblemStatusProblem)
+	}
+
+	for _, problem := range resolved {
+		timeField.Append(now)
+		eventIDField.Append(problem.ID)
+		hostField.Append(hosts[problem.ID])
+		problemField.Append(problem.Name)
+		severityField.Append(int64(problem.Severity))
+		statusField.Append(ProblemStatusResolved)
+	}
+
+	return data.NewFrame("problems", timeField, eventIDField, hostField, problemField, severityField, statusField)
+}
+
+// itemsStream keeps time of the last value sent for each item, so only newer values are sent
+type itemsStream struct {
+	lastTimes map[string]time.Time
+}
+
+func newItemsStream(items Items, from time.Time) *itemsStream {
+	lastTimes := make(map[string]time.Time, len(items))
+	for _, item := range items {
+		lastTimes[item.ID] = from
+	}
+	return &itemsStream{lastTimes: lastTimes}
+}
+
+// since returns time of the oldest last sent value, values after it should be requested
+func (s *itemsStream) since() time.Time {
+	var since time.Time
+	for _, t := range s.lastTimes {
+		if since.IsZero() || t.Before(since) {
+			since = t
+		}
+	}
+	return since
+}
+
+// newValues returns values newer than the last sent values of the items
+func (s *itemsStream) newValues(history History) History {
+	newHistory := History{}
+	for _, point := range history {
+		t := time.Unix(point.Clock, point.NS)
+		if lastTime, ok := s.lastTimes[point.ItemID]; ok && t.After(lastTime) {
+			newHistory = append(newHistory, point)
+		}
+	}
+	return newHistory
+}
+
+// set saves time of the sent values
+func (s *itemsStream) set(history History) {
+	for _, point := range history {
+		if t := time.Unix(point.Clock, point.NS); t.After(s.lastTimes[point.ItemID]) {
+			s.lastTimes[point.ItemID] = t
+		}
+	}
+}
+
+// streamItems polls history of the query items with the stream interval of the query and sends new values as
+// long frame (time, value, host and item columns), so frames of all polls have the same schema and can be
+// appended to each other. Items are selected once when stream starts.
+func (ds *ZabbixDatasourceInstance) streamItems(ctx context.Context, query *QueryModel, send StreamSender) error {
+	interval, err := parseStreamInterval(query.Options.StreamInterval)
+	if err != nil {
+		return err
+	}
+
+	items, err := ds.getItems(ctx, newQueryFilters(query), query.Group.Filter, query.Host.Filter, query.Application.Filter, query.Item.Filter, "num")
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	stream := newItemsStream(items, time.Now())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		err := ds.pollItems(ctx, items, stream, send)
+		if err != nil {
+			requestid.Logger(ctx, ds.logger).Warn("Cannot poll items for the stream", "error", err)
+		}
+	}
+}
+
+func (ds *ZabbixDatasourceInstance) pollItems(ctx context.Context, items Items, stream *itemsStream, send StreamSender) error {
+	timeRange := backend.TimeRange{From: stream.since(), To: time.Now()}
+	history, err := ds.getHistotyOrTrend(ctx, timeRange, items, false, "")
+	if err != nil {
+		return err
+	}
+
+	history = stream.newValues(history)
+	if len(history) == 0 {
+		return nil
+	}
+
+	series := convertHistoryToTimeSeries(history, items)
+	err = send(convertTimeSeriesToLongFrame(series, FillModeNull))
+	if err != nil {
+		return err
+	}
+	stream.set(history)
+	return nil
+}
+
+// parseStreamInterval returns interval of the stream, or the default one if not set
+func parseStreamInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultStreamInterval, nil
+	}
+	interval, err := gtime.ParseInterval(value)
+	if err != nil {
+		return 0, err
+	}
+	if interval < time.Second {
+		return 0, fmt.Errorf("stream interval should be at least 1s, got %s", value)
+	}
+	return interval, nil
+}
+
+// Changes of the trigger sent by the trigger states stream
+const (
+	TriggerChangeState        = "state"
+	TriggerChangeSeverity     = "severity"
+	TriggerChangeAcknowledged = "acknowledged"
+)
+
+type triggerChange struct {
+	trigger Trigger
+	change  string
+}
+
+// triggerStatesStream keeps last sent state of the triggers, so only transitions are sent on the next poll
+type triggerStatesStream struct {
+	triggers map[string]Trigger
+}
+
+func newTriggerStatesStream() *triggerStatesStream {
+	return &triggerStatesStream{triggers: make(map[string]Trigger)}
+}
+
+// diff returns state, severity and acknowledgement changes of the triggers since the last sent state. Triggers
+// not sent before are returned as state changes, so the first poll sends current state of all triggers.
+func (s *triggerStatesStream) diff(current Triggers) []triggerChange {
+	changes := make([]triggerChange, 0)
+	for _, trigger := range current {
+		previous, ok := s.triggers[trigger.ID]
+		if !ok || previous.Value != trigger.Value {
+			changes = append(changes, triggerChange{trigger: trigger, change: TriggerChangeState})
+			continue
+		}
+		if previous.Priority != trigger.Priority {
+			changes = append(changes, triggerChange{trigger: trigger, change: TriggerChangeSeverity})
+		}
+		if isTriggerAcknowledged(trigger) && !isTriggerAcknowledged(previous) {
+			changes = append(changes, triggerChange{trigger: trigger, change: TriggerChangeAcknowledged})
+		}
+	}
+	return changes
+}
+
+// set saves sent state of the triggers
+func (s *triggerStatesStream) set(current Triggers) {
+	s.triggers = make(map[string]Trigger, len(current))
+	for _, trigger := range current {
+		s.triggers[trigger.ID] = trigger
+	}
+}
+
+// isTriggerAcknowledged checks if the last problem event of the trigger in problem state is acknowledged
+func isTriggerAcknowledged(trigger Trigger) bool {
+	return trigger.Value == TriggerStateProblem && trigger.LastEvent != nil && trigger.LastEvent.Acknowledged == "1"
+}
+
+// streamTriggerStates polls triggers of the query (see getStateTriggers) with the given interval and sends
+// their state transitions, severity changes and acknowledgements until context is done
+func (ds *ZabbixDatasourceInstance) streamTriggerStates(ctx context.Context, query *QueryModel, interval time.Duration, send StreamSender) error {
+	if interval <= 0 {
+		interval = defaultStreamInterval
+	}
+
+	stream := newTriggerStatesStream()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := ds.pollTriggerStates(ctx, query, stream, send)
+		if err != nil {
+			requestid.Logger(ctx, ds.logger).Warn("Cannot poll triggers for the stream", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ds *ZabbixDatasourceInstance) pollTriggerStates(ctx context.Context, query *QueryModel, stream *triggerStatesStream, send StreamSender) error {
+	triggers, err := ds.getStateTriggers(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	changes := stream.diff(triggers)
+	if len(changes) > 0 {
+		err = send(convertTriggerChangesToFrame(changes, ds.Settings.Severities, time.Now()))
+		if err != nil {
+			return err
+		}
+	}
+	stream.set(triggers)
+	return nil
+}
+
+// convertTriggerChangesToFrame returns frame with a row for each trigger change. Time of the state change is
+// the time of the trigger last change, time of the other changes is the time they were found.
+func convertTriggerChangesToFrame(changes []triggerChange, severities Severities, now time.Time) *data.Frame {
+	timeField := data.NewFieldFromFieldType(data.FieldTypeTime, 0)
+	timeField.Name = "Time"
+	triggerIDField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	triggerIDField.Name = "Trigger ID"
+	hostField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	hostField.Name = "Host"
+	triggerField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	triggerField.Name = "Trigger"
+	changeField := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	changeField.Name = "Change"
+	stateField := data.NewFieldFromFieldType(data.FieldTypeInt64, 0)
+	stateField.Name = "State"
+	severityField := data.NewFieldFromFieldType(data.FieldTypeInt64, 0)
+	severityField.Name = "Severity"
+	severityField.Config = severityFieldConfig(severities)
+	ackField := data.NewFieldFromFieldType(data.FieldTypeBool, 0)
+	ackField.Name = "Acknowledged"
+
+	for _, change := range changes {
+		t := now
+		if change.change == TriggerChangeState && change.trigger.LastChange > 0 {
+			t = time.Unix(change.trigger.LastChange, 0)
+		}
+		timeField.Append(t)
+		triggerIDField.Append(change.trigger.ID)
+		hostField.Append(triggerHostLabels(change.trigger)["host"])
+		triggerField.Append(change.trigger.Description)
+		changeField.Append(change.change)
+		stateField.Append(int64(change.trigger.Value))
+		severityField.Append(int64(change.trigger.Priority))
+		ackField.Append(isTriggerAcknowledged(change.trigger))
+	}
+
+	return data.NewFrame("triggers", timeField, triggerIDField, hostField, triggerField, changeField, stateField,
+		severityField, ackField)
+}
@@ -0,0 +1,121 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// Kinds of the streams, the first part of the stream channel path
+const (
+	streamKindProblems      = "problems"
+	streamKindItems         = "items"
+	streamKindTriggerStates = "triggerStates"
+)
+
+// streamKinds are kinds of the streams by mode of the streamed query
+var streamKinds = map[int64]string{
+	QueryModeProblems:     streamKindProblems,
+	QueryModeMetrics:      streamKindItems,
+	QueryModeTriggerState: streamKindTriggerStates,
+}
+
+// setStreamChannel saves the query and sets channel of its stream to the first frame, so Grafana subscribes to
+// the stream and appends the sent frames to the query result. Grafana Live only passes channel path to the stream
+// handler and limits its length, so the path contains hash of the query, and the query is kept in the cache.
+// Results of the same query differ by user (e.g. "acknowledged by me" filter), so the user is a part of the hash.
+func (ds *ZabbixDatasourceInstance) setStreamChannel(frames []*data.Frame, query *QueryModel, rawQuery json.RawMessage, user *backend.User) {
+	kind, ok := streamKinds[query.Mode]
+	if !ok || len(frames) == 0 {
+		return
+	}
+
+	key := string(rawQuery)
+	login := streamUserLogin(user)
+	if login != "" {
+		key = login + "\n" + key
+	}
+	path := kind + "/" + HashString(key)
+	ds.queryCache.SetStreamQuery(path, StreamQuery{Query: *query, User: login})
+
+	if frames[0].Meta == nil {
+		frames[0].Meta = &data.FrameMeta{}
+	}
+	channel := live.Channel{Scope: live.ScopeDatasource, Namespace: ds.dsInfo.UID, Path: path}
+	frames[0].Meta.Channel = channel.String()
+}
+
+// getStreamQuery returns kind and query of the stream, saved by setStreamChannel for the same user
+func (ds *ZabbixDatasourceInstance) getStreamQuery(path string, user *backend.User) (string, *QueryModel, bool) {
+	query, ok := ds.queryCache.GetStreamQuery(path)
+	if !ok || query.User != streamUserLogin(user) {
+		return "", nil, false
+	}
+	return strings.SplitN(path, "/", 2)[0], &query.Query, true
+}
+
+func streamUserLogin(user *backend.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.Login
+}
+
+// runStream polls Zabbix and sends changes to the stream until context is done
+func (ds *ZabbixDatasourceInstance) runStream(ctx context.Context, path string, user *backend.User, send StreamSender) error {
+	kind, query, ok := ds.getStreamQuery(path, user)
+	if !ok {
+		return fmt.Errorf("stream not found: %s", path)
+	}
+	interval, err := parseStreamInterval(query.Options.StreamInterval)
+	if err != nil {
+		return err
+	}
+
+	ctx = withGrafanaUser(ctx, user)
+	switch kind {
+	case streamKindProblems:
+		return ds.streamProblems(ctx, query, interval, send)
+	case streamKindItems:
+		return ds.streamItems(ctx, query, send)
+	case streamKindTriggerStates:
+		return ds.streamTriggerStates(ctx, query, interval, send)
+	}
+	return fmt.Errorf("unsupported stream: %s", kind)
+}
+
+// SubscribeStream allows subscribing to the streams of the queries run before, see setStreamChannel
+func (ds *ZabbixDatasource) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	dsInstance, err := ds.getDSInstance(req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok := dsInstance.getStreamQuery(req.Path, req.PluginContext.User); !ok {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream denies publishing, streams are sent by the plugin only
+func (ds *ZabbixDatasource) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream sends changes to the stream until Grafana cancels the context, when the stream has no subscribers
+func (ds *ZabbixDatasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	ctx = requestid.With(ctx, requestid.New())
+	dsInstance, err := ds.getDSInstance(req.PluginContext)
+	if err != nil {
+		return err
+	}
+
+	return dsInstance.runStream(ctx, req.Path, req.PluginContext.User, func(frame *data.Frame) error {
+		return sender.SendFrame(frame, data.IncludeAll)
+	})
+}
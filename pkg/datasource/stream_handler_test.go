@@ -0,0 +1,144 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetStreamChannel(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	dsInstance.dsInfo = &backend.DataSourceInstanceSettings{UID: "zabbix"}
+
+	rawQuery := json.RawMessage(`{"mode":5,"options":{"stream":true}}`)
+	query := QueryModel{Mode: QueryModeProblems, Options: QueryOptions{Stream: true}}
+	frames := []*data.Frame{data.NewFrame("Problems")}
+	dsInstance.setStreamChannel(frames, &query, rawQuery, nil)
+
+	path := streamKindProblems + "/" + HashString(string(rawQuery))
+	assert.Equal(t, "ds/zabbix/"+path, frames[0].Meta.Channel)
+	kind, streamQuery, ok := dsInstance.getStreamQuery(path, nil)
+	assert.True(t, ok)
+	assert.Equal(t, streamKindProblems, kind)
+	assert.Equal(t, &query, streamQuery)
+
+	_, _, ok = dsInstance.getStreamQuery(streamKindProblems+"/unknown", nil)
+	assert.False(t, ok)
+
+	// Streams of the same query are separate for each user
+	user := &backend.User{Login: "admin"}
+	frames = []*data.Frame{data.NewFrame("Problems")}
+	dsInstance.setStreamChannel(frames, &query, rawQuery, user)
+	userPath := streamKindProblems + "/" + HashString("admin\n"+string(rawQuery))
+	assert.Equal(t, "ds/zabbix/"+userPath, frames[0].Meta.Channel)
+	_, _, ok = dsInstance.getStreamQuery(userPath, user)
+	assert.True(t, ok)
+	_, _, ok = dsInstance.getStreamQuery(userPath, &backend.User{Login: "viewer"})
+	assert.False(t, ok)
+	_, _, ok = dsInstance.getStreamQuery(path, user)
+	assert.False(t, ok)
+
+	// Query modes without streams get no channel
+	frames = []*data.Frame{data.NewFrame("Text")}
+	dsInstance.setStreamChannel(frames, &QueryModel{Mode: QueryModeText}, rawQuery, nil)
+	assert.Nil(t, frames[0].Meta)
+}
+
+func TestRunStreamProblems(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{
+		"groupid":"1","hostid":"10","name":"web01",
+		"eventid":"1","objectid":"100","clock":"1600000000","ns":"0","severity":"4",
+		"triggerid":"100","description":"CPU is high","priority":"4","hosts":[{"hostid":"10","name":"web01"}]
+	}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	dsInstance.Settings.ZabbixVersion = "6.0"
+	dsInstance.dsInfo = &backend.DataSourceInstanceSettings{UID: "zabbix"}
+
+	query := QueryModel{Mode: QueryModeProblems, Group: QueryFilter{Filter: "/.*/"}, Host: QueryFilter{Filter: "/.*/"}}
+	frames := []*data.Frame{data.NewFrame("Problems")}
+	dsInstance.setStreamChannel(frames, &query, json.RawMessage(`{"mode":5}`), nil)
+	path := streamKindProblems + "/" + HashString(`{"mode":5}`)
+
+	// Stream stops when context is done, after the first poll
+	ctx, cancel := context.WithCancel(context.Background())
+	var sent []*data.Frame
+	err := dsInstance.runStream(ctx, path, nil, func(frame *data.Frame) error {
+		sent = append(sent, frame)
+		cancel()
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, 1, sent[0].Rows())
+	assert.Equal(t, "web01", sent[0].Fields[2].At(0))
+
+	err = dsInstance.runStream(context.Background(), streamKindProblems+"/unknown", nil, nil)
+	assert.EqualError(t, err, "stream not found: problems/unknown")
+}
+
+func TestRunStreamItems(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"groupid":"1","hostid":"10","itemid":"1000","name":"web01"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	dsInstance.Settings.ZabbixVersion = "6.0"
+	dsInstance.dsInfo = &backend.DataSourceInstanceSettings{UID: "zabbix"}
+
+	query := QueryModel{
+		Mode:  QueryModeMetrics,
+		Group: QueryFilter{Filter: "/.*/"},
+		Host:  QueryFilter{Filter: "/.*/"},
+		Item:  QueryFilter{Filter: "CPU"},
+	}
+	frames := []*data.Frame{data.NewFrame("Metrics")}
+	dsInstance.setStreamChannel(frames, &query, json.RawMessage(`{"mode":0}`), nil)
+	assert.Equal(t, "ds/zabbix/items/"+HashString(`{"mode":0}`), frames[0].Meta.Channel)
+
+	// Stream of the query without items stops right away
+	ctx, apiCalls := withAPICallsRecorder(context.Background())
+	err := dsInstance.runStream(ctx, streamKindItems+"/"+HashString(`{"mode":0}`), nil, nil)
+	assert.NoError(t, err)
+	calls := apiCalls.Calls()
+	assert.Equal(t, "item.get", calls[len(calls)-1].Method)
+}
+
+func TestRunStreamTriggerStates(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{
+		"triggerid":"100","description":"CPU is high","priority":"4","value":"1","lastchange":"1600000000",
+		"hosts":[{"hostid":"10","name":"web01"}]
+	}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	dsInstance.Settings.ZabbixVersion = "6.0"
+	dsInstance.dsInfo = &backend.DataSourceInstanceSettings{UID: "zabbix"}
+
+	query := QueryModel{Mode: QueryModeTriggerState, TriggerID: "100"}
+	frames := []*data.Frame{data.NewFrame("Trigger state")}
+	dsInstance.setStreamChannel(frames, &query, json.RawMessage(`{"mode":7}`), nil)
+	path := streamKindTriggerStates + "/" + HashString(`{"mode":7}`)
+	assert.Equal(t, "ds/zabbix/"+path, frames[0].Meta.Channel)
+
+	// The first poll sends current state of the triggers
+	ctx, cancel := context.WithCancel(context.Background())
+	var sent []*data.Frame
+	err := dsInstance.runStream(ctx, path, nil, func(frame *data.Frame) error {
+		sent = append(sent, frame)
+		cancel()
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, 1, sent[0].Rows())
+	assert.Equal(t, "100", sent[0].Fields[1].At(0))
+	assert.Equal(t, TriggerChangeState, sent[0].Fields[4].At(0))
+}
+
+func TestReadQueryStream(t *testing.T) {
+	query, err := ReadQuery(backend.DataQuery{JSON: []byte(`{"mode":5,"options":{"stream":true,"streamInterval":"5s"}}`)})
+	assert.NoError(t, err)
+	assert.True(t, query.Options.Stream)
+
+	_, err = ReadQuery(backend.DataQuery{JSON: []byte(`{"mode":2,"options":{"stream":true}}`)})
+	assert.EqualError(t, err, "streaming is not supported by the query mode 2")
+}
@@ -0,0 +1,122 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblemsStreamDiff(t *testing.T) {
+	stream := newProblemsStream()
+
+	problems := Events{{ID: "1", Name: "CPU is high"}, {ID: "2", Name: "Disk is low"}}
+	added, resolved := stream.diff(problems)
+	assert.Equal(t, problems, added)
+	assert.Equal(t, Events{}, resolved)
+
+	// Changes are sent again until saved
+	added, _ = stream.diff(problems)
+	assert.Len(t, added, 2)
+	stream.set(problems, resolved)
+
+	problems = Events{{ID: "2", Name: "Disk is low"}, {ID: "3", Name: "Host is down"}}
+	added, resolved = stream.diff(problems)
+	assert.Equal(t, Events{{ID: "3", Name: "Host is down"}}, added)
+	assert.Equal(t, Events{{ID: "1", Name: "CPU is high"}}, resolved)
+}
+
+func TestConvertProblemChangesToFrame(t *testing.T) {
+	now := time.Unix(1600001000, 0)
+	added := Events{{ID: "3", Clock: 1600000000, Severity: SeverityHigh, Name: "Host is down"}}
+	resolved := Events{{ID: "1", Clock: 1500000000, Severity: SeverityWarning, Name: "CPU is high"}}
+	hosts := map[string]string{"1": "web01", "3": "db01"}
+
+	frame := convertProblemChangesToFrame(added, resolved, hosts, Severities{}, now)
+	assert.Equal(t, []string{"Time", "Event ID", "Host", "Problem", "Severity", "Status"}, fieldNames(frame))
+	assert.Equal(t, 2, frame.Rows())
+	assert.Equal(t, time.Unix(1600000000, 0), frame.Fields[0].At(0))
+	assert.Equal(t, "db01", frame.Fields[2].At(0))
+	assert.Equal(t, ProblemStatusProblem, frame.Fields[5].At(0))
+	assert.Equal(t, now, frame.Fields[0].At(1))
+	assert.Equal(t, "web01", frame.Fields[2].At(1))
+	assert.Equal(t, ProblemStatusResolved, frame.Fields[5].At(1))
+}
+
+func TestItemsStreamNewValues(t *testing.T) {
+	from := time.Unix(1600000000, 0)
+	stream := newItemsStream(Items{{ID: "1"}, {ID: "2"}}, from)
+	assert.Equal(t, from, stream.since())
+
+	history := History{
+		{ItemID: "1", Clock: 1600000000, Value: 1},
+		{ItemID: "1", Clock: 1600000010, Value: 2},
+		{ItemID: "2", Clock: 1600000005, Value: 3},
+		{ItemID: "3", Clock: 1600000010, Value: 4},
+	}
+	newValues := stream.newValues(history)
+	assert.Equal(t, History{history[1], history[2]}, newValues)
+
+	stream.set(newValues)
+	assert.Equal(t, time.Unix(1600000005, 0), stream.since())
+	assert.Equal(t, History{}, stream.newValues(history))
+}
+
+func TestParseStreamInterval(t *testing.T) {
+	interval, err := parseStreamInterval("")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStreamInterval, interval)
+
+	interval, err = parseStreamInterval("5s")
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, interval)
+
+	_, err = parseStreamInterval("100ms")
+	assert.Error(t, err)
+}
+
+func TestTriggerStatesStreamDiff(t *testing.T) {
+	stream := newTriggerStatesStream()
+	triggers := Triggers{
+		{ID: "1", Value: TriggerStateOK, Priority: SeverityHigh},
+		{ID: "2", Value: TriggerStateProblem, Priority: SeverityWarning, LastEvent: &TriggerLastEvent{ID: "10", Acknowledged: "0"}},
+	}
+
+	changes := stream.diff(triggers)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, TriggerChangeState, changes[0].change)
+	stream.set(triggers)
+	assert.Len(t, stream.diff(triggers), 0)
+
+	updated := Triggers{
+		{ID: "1", Value: TriggerStateProblem, Priority: SeverityHigh},
+		{ID: "2", Value: TriggerStateProblem, Priority: SeverityAverage, LastEvent: &TriggerLastEvent{ID: "10", Acknowledged: "1"}},
+	}
+	changes = stream.diff(updated)
+	assert.Equal(t, []triggerChange{
+		{trigger: updated[0], change: TriggerChangeState},
+		{trigger: updated[1], change: TriggerChangeSeverity},
+		{trigger: updated[1], change: TriggerChangeAcknowledged},
+	}, changes)
+}
+
+func TestConvertTriggerChangesToFrame(t *testing.T) {
+	now := time.Unix(1600001000, 0)
+	trigger := Trigger{
+		ID: "1", Description: "CPU is high", Value: TriggerStateProblem, Priority: SeverityHigh, LastChange: 1600000000,
+		Hosts: []ItemHost{{ID: "1", Name: "web01"}}, LastEvent: &TriggerLastEvent{ID: "10", Acknowledged: "1"},
+	}
+	changes := []triggerChange{
+		{trigger: trigger, change: TriggerChangeState},
+		{trigger: trigger, change: TriggerChangeAcknowledged},
+	}
+
+	frame := convertTriggerChangesToFrame(changes, Severities{}, now)
+	assert.Equal(t, []string{"Time", "Trigger ID", "Host", "Trigger", "Change", "State", "Severity", "Acknowledged"}, fieldNames(frame))
+	assert.Equal(t, time.Unix(1600000000, 0), frame.Fields[0].At(0))
+	assert.Equal(t, now, frame.Fields[0].At(1))
+	assert.Equal(t, "web01", frame.Fields[2].At(0))
+	assert.Equal(t, TriggerChangeAcknowledged, frame.Fields[4].At(1))
+	assert.Equal(t, int64(TriggerStateProblem), frame.Fields[5].At(0))
+	assert.Equal(t, true, frame.Fields[7].At(0))
+}
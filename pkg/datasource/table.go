@@ -0,0 +1,267 @@
+package datasource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Labels set from the item itself, item tags with the same names are skipped
+var reservedLabels = map[string]bool{"host": true, "item": true, "key": true}
+
+// tableRow is a row of the items table, value field is built separately
+type tableRow struct {
+	host      string
+	item      string
+	key       string
+	state     string
+	errorText string
+	tags      map[string]string
+}
+
+// convertSeriesToTable builds table with a row per series (host, item, key, value, item state and tag columns).
+// Value is the series aggregated with the query table aggregation function.
+func convertSeriesToTable(series []*timeseries.TimeSeriesData, options QueryOptions) *data.Frame {
+	aggFunc, ok := aggValueFuncMap[options.TableAggregation]
+	if !ok {
+		aggFunc = timeseries.AggLast
+	}
+
+	rows := make([]tableRow, 0, len(series))
+	values := make([]*float64, 0, len(series))
+	for _, s := range series {
+		value := aggFunc(s.TS)
+		if value == nil && options.SkipEmptyValues {
+			continue
+		}
+		values = append(values, value)
+		rows = append(rows, seriesTableRow(s))
+	}
+	return buildTableFrame(rows, data.NewField("Value", nil, values))
+}
+
+// convertTextHistoryToTable builds table with the last text value of each item.
+func convertTextHistoryToTable(history TextHistory, items Items, tags map[string][]ItemTag, skipEmptyValues bool) *data.Frame {
+	lastPoints := make(map[string]TextHistoryPoint, len(items))
+	for _, point := range history {
+		last, ok := lastPoints[point.ItemID]
+		if !ok || point.Clock > last.Clock || point.Clock == last.Clock && point.NS >= last.NS {
+			lastPoints[point.ItemID] = point
+		}
+	}
+
+	rows := make([]tableRow, 0, len(items))
+	values := make([]*string, 0, len(items))
+	for _, item := range items {
+		var value *string
+		if point, ok := lastPoints[item.ID]; ok {
+			value = &point.Value
+		}
+		if skipEmptyValues && (value == nil || *value == "") {
+			continue
+		}
+		values = append(values, value)
+
+		row := tableRow{item: item.ExpandItem(), key: item.Key, state: item.StateName(), errorText: item.Error, tags: map[string]string{}}
+		if len(item.Hosts) > 0 {
+			row.host = item.Hosts[0].Name
+		}
+		for name, value := range tagsToLabels(tags[item.ID]) {
+			row.tags[name] = value
+		}
+		rows = append(rows, row)
+	}
+	return buildTableFrame(rows, data.NewField("Value", nil, values))
+}
+
+func seriesTableRow(s *timeseries.TimeSeriesData) tableRow {
+	row := tableRow{
+		host:      s.Meta.Labels["host"],
+		item:      s.Meta.Labels["item"],
+		key:       s.Meta.Labels["key"],
+		state:     s.Meta.ItemState,
+		errorText: s.Meta.ItemError,
+		tags:      map[string]string{},
+	}
+	if row.item == "" {
+		row.item = s.Meta.Name
+	}
+	for name, value := range s.Meta.Labels {
+		if !reservedLabels[name] {
+			row.tags[name] = value
+		}
+	}
+	return row
+}
+
+// buildTableFrame returns frame with Host, Item, Key, Value, State and Error columns followed by a column per tag
+// (sorted by tag name). State and Error show the not supported items and the reasons, like permission denied.
+func buildTableFrame(rows []tableRow, valueField *data.Field) *data.Frame {
+	tagSet := make(map[string]bool)
+	for _, row := range rows {
+		for name := range row.tags {
+			tagSet[name] = true
+		}
+	}
+	tagNames := make([]string, 0, len(tagSet))
+	for name := range tagSet {
+		tagNames = append(tagNames, name)
+	}
+	sort.Strings(tagNames)
+
+	hostField := data.NewField("Host", nil, make([]string, 0, len(rows)))
+	itemField := data.NewField("Item", nil, make([]string, 0, len(rows)))
+	keyField := data.NewField("Key", nil, make([]string, 0, len(rows)))
+	stateField := data.NewField("State", nil, make([]string, 0, len(rows)))
+	errorField := data.NewField("Error", nil, make([]string, 0, len(rows)))
+	tagFields := make([]*data.Field, 0, len(tagNames))
+	for _, name := range tagNames {
+		tagFields = append(tagFields, data.NewField(name, nil, make([]string, 0, len(rows))))
+	}
+
+	for _, row := range rows {
+		hostField.Append(row.host)
+		itemField.Append(row.item)
+		keyField.Append(row.key)
+		stateField.Append(row.state)
+		errorField.Append(row.errorText)
+		for i, name := range tagNames {
+			tagFields[i].Append(row.tags[name])
+		}
+	}
+
+	frame := data.NewFrame("Table", hostField, itemField, keyField, valueField, stateField, errorField)
+	frame.Fields = append(frame.Fields, tagFields...)
+	return frame
+}
+
+// setItemTags adds item tags to the labels of the corresponding series. Series should be in the same order as items.
+func (ds *ZabbixDatasourceInstance) setItemTags(ctx context.Context, series []*timeseries.TimeSeriesData, items Items) error {
+	tags, err := ds.getItemTags(ctx, items)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		if i >= len(series) {
+			break
+		}
+		if series[i].Meta.Labels == nil {
+			series[i].Meta.Labels = data.Labels{}
+		}
+		for name, value := range tagsToLabels(tags[item.ID]) {
+			series[i].Meta.Labels[name] = value
+		}
+	}
+	return nil
+}
+
+// getItemTags returns tags of the items by item id. Item tags are available in Zabbix 5.4 and higher,
+// item applications are returned as "application" tags for the older versions.
+func (ds *ZabbixDatasourceInstance) getItemTags(ctx context.Context, items Items) (map[string][]ItemTag, error) {
+	tags := make(map[string][]ItemTag)
+	if len(items) == 0 {
+		return tags, nil
+	}
+
+	itemids := make([]string, 0, len(items))
+	for _, item := range items {
+		itemids = append(itemids, item.ID)
+	}
+
+	params := ZabbixAPIParams{
+		"output":     []string{"itemid"},
+		"itemids":    itemids,
+		"selectTags": "extend",
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "item.get", Params: params})
+	if errors.Is(err, zabbixapi.ErrUnexpectedParam) {
+		return ds.getItemApplications(ctx, itemids)
+	} else if err != nil {
+		return nil, err
+	}
+
+	responseJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var itemTags []struct {
+		ItemID string    `json:"itemid"`
+		Tags   []ItemTag `json:"tags"`
+	}
+	err = json.Unmarshal(responseJSON, &itemTags)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range itemTags {
+		tags[item.ItemID] = item.Tags
+	}
+	return tags, nil
+}
+
+// getItemApplications returns item applications as "application" tags (Zabbix versions before 5.4)
+func (ds *ZabbixDatasourceInstance) getItemApplications(ctx context.Context, itemids []string) (map[string][]ItemTag, error) {
+	params := ZabbixAPIParams{
+		"output":             []string{"itemid"},
+		"itemids":            itemids,
+		"selectApplications": []string{"name"},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "item.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	responseJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var itemApps []struct {
+		ItemID       string `json:"itemid"`
+		Applications []struct {
+			Name string `json:"name"`
+		} `json:"applications"`
+	}
+	err = json.Unmarshal(responseJSON, &itemApps)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string][]ItemTag, len(itemApps))
+	for _, item := range itemApps {
+		for _, app := range item.Applications {
+			tags[item.ItemID] = append(tags[item.ItemID], ItemTag{Tag: "application", Value: app.Name})
+		}
+	}
+	return tags, nil
+}
+
+// tagsToLabels converts item tags to labels, tags with the names of the item labels are skipped.
+func tagsToLabels(tags []ItemTag) data.Labels {
+	return tagLabels(tags, reservedLabels)
+}
+
+// tagLabels returns tags as labels, values of the tags with the same name are joined. Tags with reserved
+// names are skipped.
+func tagLabels(tags []ItemTag, reserved map[string]bool) data.Labels {
+	labels := data.Labels{}
+	for _, tag := range tags {
+		if reserved[tag.Tag] {
+			continue
+		}
+		if value, ok := labels[tag.Tag]; ok && value != "" {
+			labels[tag.Tag] = strings.Join([]string{value, tag.Value}, ", ")
+		} else {
+			labels[tag.Tag] = tag.Value
+		}
+	}
+	return labels
+}
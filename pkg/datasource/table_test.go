@@ -0,0 +1,77 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertSeriesToTable(t *testing.T) {
+	a := mockSeries("web01: CPU", 1, 2, 3)
+	a.Meta.Labels = data.Labels{"host": "web01", "item": "CPU", "key": "system.cpu.util", "component": "cpu"}
+	b := mockSeries("web02: CPU")
+	b.Meta.Labels = data.Labels{"host": "web02", "item": "CPU", "key": "system.cpu.util"}
+	b.Meta.ItemState = "Not supported"
+	b.Meta.ItemError = "Permission denied"
+	series := []*timeseries.TimeSeriesData{a, b}
+
+	frame := convertSeriesToTable(series, QueryOptions{TableAggregation: "avg"})
+	assert.Equal(t, 2, frame.Rows())
+	assert.Equal(t, []string{"Host", "Item", "Key", "Value", "State", "Error", "component"}, fieldNames(frame))
+	assert.Equal(t, floatPtr(2), frame.Fields[3].At(0))
+	assert.Nil(t, frame.Fields[3].At(1))
+	assert.Equal(t, "Not supported", frame.Fields[4].At(1))
+	assert.Equal(t, "Permission denied", frame.Fields[5].At(1))
+	assert.Equal(t, "cpu", frame.Fields[6].At(0))
+	assert.Equal(t, "", frame.Fields[6].At(1))
+
+	frame = convertSeriesToTable(series, QueryOptions{TableAggregation: "last", SkipEmptyValues: true})
+	assert.Equal(t, 1, frame.Rows())
+	assert.Equal(t, floatPtr(3), frame.Fields[3].At(0))
+}
+
+func TestConvertTextHistoryToTable(t *testing.T) {
+	items := Items{
+		{ID: "1", Name: "Version", Key: "agent.version", State: ItemStateNormal, Hosts: []ItemHost{{ID: "10", Name: "web01"}}},
+		{ID: "2", Name: "Version", Key: "agent.version", State: ItemStateNotSupported, Error: "Permission denied", Hosts: []ItemHost{{ID: "11", Name: "web02"}}},
+	}
+	history := TextHistory{
+		{ItemID: "1", Clock: 2, Value: "5.4.1"},
+		{ItemID: "1", Clock: 1, Value: "5.2.0"},
+	}
+	tags := map[string][]ItemTag{"1": {{Tag: "scope", Value: "agent"}, {Tag: "scope", Value: "system"}, {Tag: "host", Value: "ignored"}}}
+
+	frame := convertTextHistoryToTable(history, items, tags, false)
+	assert.Equal(t, []string{"Host", "Item", "Key", "Value", "State", "Error", "scope"}, fieldNames(frame))
+	assert.Equal(t, 2, frame.Rows())
+	value := "5.4.1"
+	assert.Equal(t, &value, frame.Fields[3].At(0))
+	assert.Equal(t, "web01", frame.Fields[0].At(0))
+	assert.Equal(t, "Normal", frame.Fields[4].At(0))
+	assert.Equal(t, "Not supported", frame.Fields[4].At(1))
+	assert.Equal(t, "Permission denied", frame.Fields[5].At(1))
+	assert.Equal(t, "agent, system", frame.Fields[6].At(0))
+
+	frame = convertTextHistoryToTable(history, items, tags, true)
+	assert.Equal(t, 1, frame.Rows())
+}
+
+func fieldNames(frame *data.Frame) []string {
+	names := make([]string, 0, len(frame.Fields))
+	for _, field := range frame.Fields {
+		names = append(names, field.Name)
+	}
+	return names
+}
+
+func TestTagsToLabels(t *testing.T) {
+	tags := []ItemTag{
+		{Tag: "application", Value: "CPU"},
+		{Tag: "application", Value: "Performance"},
+		{Tag: "item", Value: "reserved"},
+		{Tag: "scope", Value: ""},
+	}
+	assert.Equal(t, data.Labels{"application": "CPU, Performance", "scope": ""}, tagsToLabels(tags))
+}
@@ -0,0 +1,109 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Tag filter operators, same as in the Zabbix frontend problems view
+const (
+	TagOperatorContains    = "contains"
+	TagOperatorEquals      = "equals"
+	TagOperatorNotContains = "notContains"
+	TagOperatorNotEquals   = "notEquals"
+	TagOperatorExists      = "exists"
+	TagOperatorNotExists   = "notExists"
+)
+
+// Zabbix API values of the tag filter operators
+var tagOperators = map[string]int{
+	TagOperatorContains:    0,
+	TagOperatorEquals:      1,
+	TagOperatorNotContains: 2,
+	TagOperatorNotEquals:   3,
+	TagOperatorExists:      4,
+	TagOperatorNotExists:   5,
+}
+
+// Evaluation types of the tag filters: And/Or (default) requires all tags with different names to match and any of
+// the tags with the same name, Or requires any tag to match
+const (
+	TagsEvalTypeAndOr = "andor"
+	TagsEvalTypeOr    = "or"
+)
+
+// Zabbix API values of the tags evaluation types
+var tagsEvalTypes = map[string]int{
+	TagsEvalTypeAndOr: 0,
+	TagsEvalTypeOr:    2,
+}
+
+// TagFilter is a problem, trigger or host tag condition. Value is not used by the exists and not exists operators.
+type TagFilter struct {
+	Tag      string `json:"tag"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// validateTagFilters checks operators and evaluation type of the query tag filters
+func (query *QueryModel) validateTagFilters() error {
+	return validateTags(query.TagFilters, query.TagsEvalType)
+}
+
+// validateTags checks operators of the problem, trigger or host tag filters and their evaluation type
+func validateTags(filters []TagFilter, evalType string) error {
+	for _, filter := range filters {
+		if filter.Tag == "" {
+			return errors.New("tag name is required in the tag filter")
+		}
+		if _, ok := tagOperators[filter.Operator]; filter.Operator != "" && !ok {
+			return fmt.Errorf("unsupported tag operator: %s", filter.Operator)
+		}
+	}
+	if _, ok := tagsEvalTypes[evalType]; evalType != "" && !ok {
+		return fmt.Errorf("unsupported tags evaluation type: %s", evalType)
+	}
+	return nil
+}
+
+// setTagsParams sets tags and evaltype params of the problem.get, event.get or trigger.get request from the tags
+// filter like "service:web, scope" and the tag filters with operators of the query
+func setTagsParams(params ZabbixAPIParams, query *QueryModel) {
+	var tags []map[string]interface{}
+	for _, tag := range parseTags(query.Tags.Filter) {
+		filter := map[string]interface{}{"tag": tag["tag"]}
+		if value, ok := tag["value"]; ok {
+			filter["value"] = value
+		}
+		tags = append(tags, filter)
+	}
+	tags = append(tags, tagFiltersParam(query.TagFilters)...)
+	setTagsParam(params, tags, query.TagsEvalType)
+}
+
+// tagFiltersParam converts tag filters into the tags param of the Zabbix API
+func tagFiltersParam(filters []TagFilter) []map[string]interface{} {
+	tags := make([]map[string]interface{}, 0, len(filters))
+	for _, tag := range filters {
+		filter := map[string]interface{}{"tag": tag.Tag}
+		if operator, ok := tagOperators[tag.Operator]; ok {
+			filter["operator"] = operator
+		}
+		if tag.Operator != TagOperatorExists && tag.Operator != TagOperatorNotExists {
+			filter["value"] = tag.Value
+		}
+		tags = append(tags, filter)
+	}
+	return tags
+}
+
+// setTagsParam sets tags and evaltype params if there are tags
+func setTagsParam(params ZabbixAPIParams, tags []map[string]interface{}, evalType string) {
+	if len(tags) == 0 {
+		return
+	}
+	params["tags"] = tags
+	if value, ok := tagsEvalTypes[evalType]; ok {
+		params["evaltype"] = value
+	}
+}
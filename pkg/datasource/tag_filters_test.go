@@ -0,0 +1,90 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTagsParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        QueryModel
+		wantTags     interface{}
+		wantEvalType interface{}
+	}{
+		{
+			name:  "No filters",
+			query: QueryModel{},
+		},
+		{
+			name:     "Tags filter",
+			query:    QueryModel{Tags: QueryFilter{Filter: "service: web, scope"}},
+			wantTags: []map[string]interface{}{{"tag": "service", "value": "web"}, {"tag": "scope"}},
+		},
+		{
+			name: "Operators",
+			query: QueryModel{TagFilters: []TagFilter{
+				{Tag: "service", Operator: TagOperatorEquals, Value: "web"},
+				{Tag: "env", Operator: TagOperatorNotEquals, Value: "dev"},
+				{Tag: "team", Operator: TagOperatorExists, Value: "ignored"},
+				{Tag: "muted", Operator: TagOperatorNotExists},
+				{Tag: "scope", Value: "perf"},
+			}},
+			wantTags: []map[string]interface{}{
+				{"tag": "service", "operator": 1, "value": "web"},
+				{"tag": "env", "operator": 3, "value": "dev"},
+				{"tag": "team", "operator": 4},
+				{"tag": "muted", "operator": 5},
+				{"tag": "scope", "value": "perf"},
+			},
+		},
+		{
+			name: "Or",
+			query: QueryModel{
+				Tags:         QueryFilter{Filter: "service:web"},
+				TagFilters:   []TagFilter{{Tag: "service", Operator: TagOperatorContains, Value: "db"}},
+				TagsEvalType: TagsEvalTypeOr,
+			},
+			wantTags: []map[string]interface{}{
+				{"tag": "service", "value": "web"},
+				{"tag": "service", "operator": 0, "value": "db"},
+			},
+			wantEvalType: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := ZabbixAPIParams{}
+			setTagsParams(params, &tt.query)
+			assert.Equal(t, tt.wantTags, params["tags"])
+			assert.Equal(t, tt.wantEvalType, params["evaltype"])
+		})
+	}
+}
+
+func TestValidateTagFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   QueryModel
+		wantErr string
+	}{
+		{name: "Valid", query: QueryModel{TagFilters: []TagFilter{{Tag: "service", Operator: TagOperatorNotContains, Value: "web"}}, TagsEvalType: TagsEvalTypeAndOr}},
+		{name: "Default operator", query: QueryModel{TagFilters: []TagFilter{{Tag: "service"}}}},
+		{name: "No tag name", query: QueryModel{TagFilters: []TagFilter{{Operator: TagOperatorExists}}}, wantErr: "tag name is required in the tag filter"},
+		{name: "Unsupported operator", query: QueryModel{TagFilters: []TagFilter{{Tag: "service", Operator: "like"}}}, wantErr: "unsupported tag operator: like"},
+		{name: "Unsupported evaltype", query: QueryModel{TagsEvalType: "and"}, wantErr: "unsupported tags evaluation type: and"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.validateTagFilters()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
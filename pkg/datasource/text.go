@@ -0,0 +1,178 @@
+package datasource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// queryTextItems returns history of text items as logs frames (one frame per item)
+func (ds *ZabbixDatasourceInstance) queryTextItems(ctx context.Context, query *QueryModel) ([]*data.Frame, error) {
+	var re *regexp.Regexp
+	var err error
+	if query.TextFilter != "" {
+		re, err = regexp.Compile(query.TextFilter)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing text filter: %w", err)
+		}
+	}
+
+	items, err := ds.getItems(ctx, newQueryFilters(query), query.Group.Filter, query.Host.Filter, query.Application.Filter, query.Item.Filter, "text")
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyFunctionsPre(query)
+	if err != nil {
+		return nil, err
+	}
+
+	textHistory, err := ds.getTextHistory(ctx, query, items)
+	if err != nil {
+		return nil, err
+	}
+	textHistory, err = applyTextFunctionsPost(textHistory, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	if re != nil {
+		for i, point := range textHistory {
+			textHistory[i].Value = extractText(point.Value, re, query.UseCaptureGroups)
+		}
+	}
+
+	tags, err := ds.getItemTags(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.ResultFormat == ResultFormatTable {
+		return []*data.Frame{convertTextHistoryToTable(textHistory, items, tags, query.Options.SkipEmptyValues)}, nil
+	}
+	return convertTextHistoryToLogsFrames(textHistory, items, tags), nil
+}
+
+// queryTextItemsAsNumeric extracts numbers from the history of text items (using textFilter regex)
+// and returns them as numeric series.
+func (ds *ZabbixDatasourceInstance) queryTextItemsAsNumeric(ctx context.Context, query *QueryModel) (*data.Frame, error) {
+	if query.TextFilter == "" {
+		return nil, errors.New("text filter is required to extract numeric values")
+	}
+	re, err := regexp.Compile(query.TextFilter)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing text filter: %w", err)
+	}
+
+	items, err := ds.getItems(ctx, newQueryFilters(query), query.Group.Filter, query.Host.Filter, query.Application.Filter, query.Item.Filter, "text")
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyFunctionsPre(query)
+	if err != nil {
+		return nil, err
+	}
+
+	textHistory, err := ds.getTextHistory(ctx, query, items)
+	if err != nil {
+		return nil, err
+	}
+
+	history := extractNumericHistory(textHistory, re, query.UseCaptureGroups)
+	series := convertHistoryToTimeSeries(history, items)
+	return ds.processSeriesData(ctx, query, items, series, false, ds.getConsolidateBy(query))
+}
+
+func (ds *ZabbixDatasourceInstance) getTextHistory(ctx context.Context, query *QueryModel, items Items) (TextHistory, error) {
+	timeRange := query.TimeRange
+	if history, ok, err := ds.getTextHistoryWithFallback(ctx, timeRange, items); ok || err != nil {
+		return history, err
+	}
+	recordHistorySource(ctx, HistorySourceAPI)
+
+	allHistory := TextHistory{}
+
+	groupedItems := map[int]Items{}
+	for _, item := range items {
+		groupedItems[item.ValueType] = append(groupedItems[item.ValueType], item)
+	}
+
+	for valueType, groupItems := range groupedItems {
+		var itemids []string
+		for _, item := range groupItems {
+			itemids = append(itemids, item.ID)
+		}
+
+		historyType := valueType
+		params := ZabbixAPIParams{
+			"output":    "extend",
+			"sortfield": "clock",
+			"sortorder": "ASC",
+			"itemids":   itemids,
+			"history":   &historyType,
+			"time_from": timeRange.From.Unix(),
+			"time_till": timeRange.To.Unix(),
+		}
+
+		response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "history.get", Params: params})
+		if err != nil {
+			return nil, err
+		}
+
+		pointJSON, err := response.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+		}
+
+		history := TextHistory{}
+		err = json.Unmarshal(pointJSON, &history)
+		if err != nil {
+			requestid.Logger(ctx, ds.logger).Error("Error handling history response", "error", err.Error())
+		} else {
+			allHistory = append(allHistory, history...)
+		}
+	}
+	return allHistory, nil
+}
+
+// extractNumericHistory extracts numbers from the text values. The first capture group is used
+// if useCaptureGroups is set, otherwise the whole match. Values without a number are skipped.
+func extractNumericHistory(textHistory TextHistory, re *regexp.Regexp, useCaptureGroups bool) History {
+	history := make(History, 0, len(textHistory))
+	for _, point := range textHistory {
+		text := extractText(point.Value, re, useCaptureGroups)
+		value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			continue
+		}
+		history = append(history, HistoryPoint{
+			ItemID: point.ItemID,
+			Clock:  point.Clock,
+			NS:     point.NS,
+			Value:  value,
+		})
+	}
+	return history
+}
+
+func extractText(text string, re *regexp.Regexp, useCaptureGroups bool) string {
+	matches := re.FindStringSubmatch(text)
+	if len(matches) == 0 {
+		return ""
+	}
+	if useCaptureGroups {
+		if len(matches) < 2 {
+			return ""
+		}
+		return matches[1]
+	}
+	return matches[0]
+}
@@ -0,0 +1,55 @@
+package datasource
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractNumericHistory(t *testing.T) {
+	textHistory := TextHistory{
+		{ItemID: "1", Clock: 1, Value: "version 5.4.1"},
+		{ItemID: "1", Clock: 2, Value: `{"connections": 42}`},
+		{ItemID: "1", Clock: 3, Value: "no numbers here"},
+	}
+
+	history := extractNumericHistory(textHistory, regexp.MustCompile(`(\d+\.\d+)`), true)
+	assert.Len(t, history, 1)
+	assert.Equal(t, 5.4, history[0].Value)
+
+	history = extractNumericHistory(textHistory, regexp.MustCompile(`"connections": (\d+)`), true)
+	assert.Len(t, history, 1)
+	assert.Equal(t, float64(42), history[0].Value)
+	assert.Equal(t, int64(2), history[0].Clock)
+
+	history = extractNumericHistory(textHistory, regexp.MustCompile(`\d+`), false)
+	assert.Len(t, history, 2)
+	assert.Equal(t, float64(5), history[0].Value)
+}
+
+func TestConvertTextHistoryToLogsFrames(t *testing.T) {
+	items := Items{
+		{ID: "1", Name: "syslog", ValueType: ValueTypeLog, Hosts: []ItemHost{{ID: "10", Name: "backend01"}}},
+		{ID: "2", Name: "version", ValueType: ValueTypeText},
+	}
+	history := TextHistory{
+		{ItemID: "1", Clock: 2, Value: "kernel: out of memory, process failed"},
+		{ItemID: "1", Clock: 1, Value: "Service started", Severity: 1},
+		{ItemID: "2", Clock: 1, Value: "5.4.1"},
+	}
+
+	tags := map[string][]ItemTag{"1": {{Tag: "component", Value: "system"}}}
+
+	frames := convertTextHistoryToLogsFrames(history, items, tags)
+	assert.Len(t, frames, 2)
+
+	frame := frames[0]
+	assert.Equal(t, 2, frame.Rows())
+	assert.Equal(t, "Service started", frame.Fields[1].At(0))
+	assert.Equal(t, "info", frame.Fields[2].At(0))
+	assert.Equal(t, "error", frame.Fields[2].At(1))
+	assert.Equal(t, "backend01", frame.Fields[1].Labels["host"])
+	assert.Equal(t, "system", frame.Fields[1].Labels["component"])
+	assert.Equal(t, "unknown", frames[1].Fields[2].At(0))
+}
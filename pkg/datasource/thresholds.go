@@ -0,0 +1,214 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+var thresholdSuffixMultipliers = map[string]float64{
+	"":  1,
+	"K": 1024,
+	"M": 1024 * 1024,
+	"G": 1024 * 1024 * 1024,
+	"T": 1024 * 1024 * 1024 * 1024,
+	"s": 1,
+	"m": 60,
+	"h": 3600,
+	"d": 86400,
+	"w": 604800,
+}
+
+const thresholdBaseColor = "green"
+
+// setTriggerThresholds attaches constant thresholds of the enabled triggers using the item to the corresponding
+// series as field thresholds. Series should be in the same order as items.
+func (ds *ZabbixDatasourceInstance) setTriggerThresholds(ctx context.Context, series []*timeseries.TimeSeriesData, items Items) error {
+	triggersByItem, err := ds.getTriggersByItem(ctx, items)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		if i >= len(series) {
+			break
+		}
+		thresholds := convertTriggerThresholds(triggersByItem[item.ID], ds.Settings.Severities)
+		if thresholds == nil {
+			continue
+		}
+		if series[i].Meta.FieldConfig == nil {
+			series[i].Meta.FieldConfig = &data.FieldConfig{}
+		}
+		config := series[i].Meta.FieldConfig
+		config.Thresholds = thresholds
+		if config.Custom == nil {
+			config.Custom = map[string]interface{}{}
+		}
+		config.Custom["thresholdsStyle"] = map[string]interface{}{"mode": "line"}
+	}
+	return nil
+}
+
+// getTriggerThresholdSeries returns a constant series over the query time range for each constant threshold of
+// the enabled triggers using the item, drawn as a dashed line of the trigger severity color. Series should be in
+// the same order as items.
+func (ds *ZabbixDatasourceInstance) getTriggerThresholdSeries(ctx context.Context, query *QueryModel, series []*timeseries.TimeSeriesData, items Items) ([]*timeseries.TimeSeriesData, error) {
+	triggersByItem, err := ds.getTriggersByItem(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholdSeries := make([]*timeseries.TimeSeriesData, 0)
+	for i, item := range items {
+		if i >= len(series) {
+			break
+		}
+		severities := triggerThresholdSeverities(triggersByItem[item.ID])
+		values := make([]float64, 0, len(severities))
+		for value := range severities {
+			values = append(values, value)
+		}
+		sort.Float64s(values)
+
+		for _, value := range values {
+			thresholdSeries = append(thresholdSeries, newThresholdSeries(series[i], value, severities[value], ds.Settings.Severities, query.TimeRange))
+		}
+	}
+	return thresholdSeries, nil
+}
+
+// newThresholdSeries returns series of the threshold value at the start and the end of the time range, named
+// after the item series and the trigger severity like "web01: CPU load (High: 90)"
+func newThresholdSeries(itemSeries *timeseries.TimeSeriesData, value float64, priority int, severities Severities, timeRange backend.TimeRange) *timeseries.TimeSeriesData {
+	severity := severities.Name(priority)
+
+	s := timeseries.NewTimeSeriesData()
+	s.Meta.Name = fmt.Sprintf("%s (%s: %s)", itemSeries.Meta.Name, severity, strconv.FormatFloat(value, 'f', -1, 64))
+	s.Meta.Labels = data.Labels{"threshold": strings.ToLower(severity)}
+	for name, label := range itemSeries.Meta.Labels {
+		s.Meta.Labels[name] = label
+	}
+	s.Meta.FieldConfig = &data.FieldConfig{
+		Color: map[string]interface{}{"mode": "fixed", "fixedColor": severities.Color(priority)},
+		Custom: map[string]interface{}{
+			"lineStyle":   map[string]interface{}{"fill": "dash", "dash": []int{10, 10}},
+			"fillOpacity": 0,
+			"showPoints":  "never",
+			// Wide frame has nulls at the timestamps of other series
+			"spanNulls": true,
+		},
+	}
+
+	from, to := value, value
+	s.Add(timeseries.TimePoint{Time: timeRange.From, Value: &from})
+	s.Add(timeseries.TimePoint{Time: timeRange.To, Value: &to})
+	return s
+}
+
+// getTriggersByItem returns enabled triggers of the items by item id. Triggers depending on several items are
+// skipped, threshold can't be related to the single one.
+func (ds *ZabbixDatasourceInstance) getTriggersByItem(ctx context.Context, items Items) (map[string]Triggers, error) {
+	triggersByItem := make(map[string]Triggers)
+	if len(items) == 0 {
+		return triggersByItem, nil
+	}
+
+	itemids := make([]string, 0, len(items))
+	for _, item := range items {
+		itemids = append(itemids, item.ID)
+	}
+	triggers, err := ds.getItemTriggers(ctx, itemids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trigger := range triggers {
+		if len(trigger.Items) != 1 {
+			continue
+		}
+		itemid := trigger.Items[0].ID
+		triggersByItem[itemid] = append(triggersByItem[itemid], trigger)
+	}
+	return triggersByItem, nil
+}
+
+func (ds *ZabbixDatasourceInstance) getItemTriggers(ctx context.Context, itemids []string) (Triggers, error) {
+	params := ZabbixAPIParams{
+		"output":           []string{"triggerid", "description", "expression", "priority"},
+		"itemids":          itemids,
+		"expandExpression": true,
+		"selectItems":      []string{"itemid"},
+		"filter":           map[string]interface{}{"status": 0},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "trigger.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	responseJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	triggers := Triggers{}
+	err = json.Unmarshal(responseJSON, &triggers)
+	if err != nil {
+		return nil, err
+	}
+	return triggers, nil
+}
+
+// convertTriggerThresholds returns threshold steps colored by the trigger severity, or nil if triggers have
+// no constant thresholds. The highest severity wins if several triggers have the same threshold.
+func convertTriggerThresholds(triggers Triggers, severities Severities) *data.ThresholdsConfig {
+	priorities := triggerThresholdSeverities(triggers)
+	if len(priorities) == 0 {
+		return nil
+	}
+
+	values := make([]float64, 0, len(priorities))
+	for value := range priorities {
+		values = append(values, value)
+	}
+	sort.Float64s(values)
+
+	steps := []data.Threshold{data.NewThreshold(math.Inf(-1), thresholdBaseColor, "")}
+	for _, value := range values {
+		steps = append(steps, data.NewThreshold(value, severities.Color(priorities[value]), ""))
+	}
+	return &data.ThresholdsConfig{Mode: data.ThresholdsModeAbsolute, Steps: steps}
+}
+
+// triggerThresholdSeverities returns constant thresholds of the triggers with the highest severity of the triggers
+// having each threshold
+func triggerThresholdSeverities(triggers Triggers) map[float64]int {
+	priorities := make(map[float64]int)
+	for _, trigger := range triggers {
+		for _, threshold := range parseTriggerThresholds(trigger.Expression) {
+			if priority, ok := priorities[threshold]; !ok || trigger.Priority > priority {
+				priorities[threshold] = trigger.Priority
+			}
+		}
+	}
+	return priorities
+}
+
+// parseTriggerThresholds returns constants the expression compares values with using > or >= operators
+func parseTriggerThresholds(expression string) []float64 {
+	var thresholds []float64
+	for _, condition := range parseTriggerConditions(expression, nil, nil) {
+		if (condition.Operator == ">" || condition.Operator == ">=") && condition.Macro == "" && condition.Value != nil {
+			thresholds = append(thresholds, *condition.Value)
+		}
+	}
+	return thresholds
+}
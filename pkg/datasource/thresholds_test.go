@@ -0,0 +1,76 @@
+package datasource
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTriggerThresholds(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   []float64
+	}{
+		{expression: "last(/web01/system.cpu.util)>90", expected: []float64{90}},
+		{expression: "{web01:vfs.fs.size[/,used].last()}>=1.5G", expected: []float64{1.5 * 1024 * 1024 * 1024}},
+		{expression: "avg(/web01/net.if.in[eth0],5m) > 100K or last(/web01/agent.ping)=0", expected: []float64{100 * 1024}},
+		{expression: "last(/web01/system.uptime)<10m", expected: nil},
+		{expression: "last(/web01/proc.num)<>5", expected: nil},
+		{expression: "last(/web01/system.cpu.load)>{$CPU.LOAD.MAX}", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseTriggerThresholds(tt.expression))
+		})
+	}
+}
+
+func TestConvertTriggerThresholds(t *testing.T) {
+	triggers := Triggers{
+		{ID: "1", Expression: "last(/web01/system.cpu.util)>90", Priority: SeverityHigh},
+		{ID: "2", Expression: "last(/web01/system.cpu.util)>70", Priority: SeverityWarning},
+		{ID: "3", Expression: "min(/web01/system.cpu.util,5m)>90", Priority: SeverityDisaster},
+	}
+
+	thresholds := convertTriggerThresholds(triggers, Severities{})
+	assert.Equal(t, data.ThresholdsModeAbsolute, thresholds.Mode)
+	assert.Len(t, thresholds.Steps, 3)
+	assert.True(t, math.IsInf(float64(thresholds.Steps[0].Value), -1))
+	assert.Equal(t, data.ConfFloat64(70), thresholds.Steps[1].Value)
+	assert.Equal(t, SeverityColors[SeverityWarning], thresholds.Steps[1].Color)
+	assert.Equal(t, SeverityColors[SeverityDisaster], thresholds.Steps[2].Color)
+
+	assert.Nil(t, convertTriggerThresholds(Triggers{{ID: "4", Expression: "nodata(/web01/agent.ping,5m)=1"}}, Severities{}))
+}
+
+func TestGetTriggerThresholdSeries(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[
+		{"triggerid":"1","expression":"last(/web01/system.cpu.util)>90","priority":"4","items":[{"itemid":"100"}]},
+		{"triggerid":"2","expression":"last(/web01/system.cpu.util)>70","priority":"2","items":[{"itemid":"100"}]},
+		{"triggerid":"3","expression":"last(/web01/system.cpu.util)>50 and last(/web01/system.cpu.load)>5","priority":"1","items":[{"itemid":"100"},{"itemid":"101"}]}
+	]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	itemSeries := mockSeries("web01: CPU utilization", 10, 20)
+	itemSeries.Meta.Labels = data.Labels{"host": "web01"}
+	query := &QueryModel{TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)}}
+
+	series, err := dsInstance.getTriggerThresholdSeries(context.Background(), query, []*timeseries.TimeSeriesData{itemSeries}, Items{{ID: "100"}})
+	assert.NoError(t, err)
+	assert.Len(t, series, 2)
+	assert.Equal(t, "web01: CPU utilization (Warning: 70)", series[0].Meta.Name)
+	assert.Equal(t, "web01: CPU utilization (High: 90)", series[1].Meta.Name)
+	assert.Equal(t, data.Labels{"host": "web01", "threshold": "high"}, series[1].Meta.Labels)
+	assert.Equal(t, SeverityColors[SeverityHigh], series[1].Meta.FieldConfig.Color["fixedColor"])
+
+	assert.Len(t, series[1].TS, 2)
+	assert.Equal(t, time.Unix(3600, 0), series[1].TS[1].Time)
+	assert.Equal(t, 90.0, *series[1].TS[1].Value)
+}
@@ -0,0 +1,94 @@
+package datasource
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"golang.org/x/net/context"
+)
+
+// Frontend default timezone meaning timezone of the Zabbix server system, which isn't returned by the API
+const zabbixSystemTimezone = "system"
+
+// Detection of the timezone failed with an API error is retried after the delay, doubled on each failure up to
+// the max delay
+const (
+	timezoneRetryDelay    = time.Minute
+	timezoneMaxRetryDelay = time.Hour
+)
+
+// timezoneState caches timezone of the Zabbix server detected from the API
+type timezoneState struct {
+	mu         sync.Mutex
+	detected   bool
+	location   *time.Location
+	retryAt    time.Time
+	retryDelay time.Duration
+}
+
+// getTimezone returns timezone of the Zabbix server set in the settings, or the default timezone of the Zabbix
+// frontend (Zabbix 5.2 and higher). Returns nil if timezone isn't set or detected, so time frames are aligned
+// like before the timezone support.
+func (ds *ZabbixDatasourceInstance) getTimezone(ctx context.Context) *time.Location {
+	if ds.Settings != nil && ds.Settings.Timezone != nil {
+		return ds.Settings.Timezone
+	}
+
+	state := &ds.timezone
+	state.mu.Lock()
+	if state.detected || time.Now().Before(state.retryAt) {
+		defer state.mu.Unlock()
+		return state.location
+	}
+	state.mu.Unlock()
+
+	// API is requested without holding the lock, so slow response doesn't block other queries
+	location, err := ds.detectTimezone(ctx)
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if err != nil {
+		if state.retryDelay == 0 {
+			state.retryDelay = timezoneRetryDelay
+		} else if state.retryDelay < timezoneMaxRetryDelay {
+			state.retryDelay *= 2
+		}
+		state.retryAt = time.Now().Add(state.retryDelay)
+		requestid.Logger(ctx, ds.logger).Debug("Cannot detect Zabbix server timezone", "error", err, "retryIn", state.retryDelay)
+		return nil
+	}
+	state.detected = true
+	state.location = location
+	return location
+}
+
+// detectTimezone returns default timezone of the Zabbix frontend, or nil if it's the system one or isn't
+// supported by the API
+func (ds *ZabbixDatasourceInstance) detectTimezone(ctx context.Context) (*time.Location, error) {
+	response, err := ds.ZabbixRequest(ctx, "settings.get", ZabbixAPIParams{"output": []string{"default_timezone"}})
+	if errors.Is(err, zabbixapi.ErrMethodNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	name := response.Get("default_timezone").MustString()
+	if name == "" || name == zabbixSystemTimezone {
+		return nil, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// setSeriesTimezone sets location of the series points to the Zabbix server timezone, so flexible update
+// intervals (like "1-5,09:00-18:00") are checked against the server clock. Time of the points isn't changed.
+func setSeriesTimezone(series []*timeseries.TimeSeriesData, loc *time.Location) {
+	for _, s := range series {
+		for i := range s.TS {
+			s.TS[i].Time = s.TS[i].Time.In(loc)
+		}
+	}
+}
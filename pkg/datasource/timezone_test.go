@@ -0,0 +1,72 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTimezone(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":{"default_timezone":"Europe/Riga"}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	assert.Equal(t, "Europe/Riga", dsInstance.getTimezone(context.Background()).String())
+
+	// Detected timezone is cached
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"result":{"default_timezone":"Asia/Tokyo"}}`, 200)
+	assert.Equal(t, "Europe/Riga", dsInstance.getTimezone(context.Background()).String())
+
+	// Timezone of the settings is used instead of the detected one
+	location, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+	dsInstance.Settings.Timezone = location
+	assert.Equal(t, location, dsInstance.getTimezone(context.Background()))
+
+	// System timezone isn't returned by the API
+	dsInstance = MockZabbixDataSource(`{"result":{"default_timezone":"system"}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	assert.Nil(t, dsInstance.getTimezone(context.Background()))
+
+	// settings.get isn't supported before Zabbix 5.2
+	dsInstance = MockZabbixDataSource(`{"error":{"code":-32601,"message":"Method not found.","data":"Incorrect API \"settings\"."}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	assert.Nil(t, dsInstance.getTimezone(context.Background()))
+	assert.True(t, dsInstance.timezone.detected)
+}
+
+func TestGetTimezoneRetry(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"error":{"code":-32602,"message":"Invalid params.","data":"Invalid parameter \"/\": unexpected parameter \"output\"."}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	assert.Nil(t, dsInstance.getTimezone(context.Background()))
+	assert.False(t, dsInstance.timezone.detected)
+	assert.Equal(t, timezoneRetryDelay, dsInstance.timezone.retryDelay)
+
+	// Failed detection isn't repeated until the retry delay passes
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"result":{"default_timezone":"Europe/Riga"}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	assert.Nil(t, dsInstance.getTimezone(context.Background()))
+
+	dsInstance.timezone.retryAt = time.Now().Add(-time.Second)
+	assert.Equal(t, "Europe/Riga", dsInstance.getTimezone(context.Background()).String())
+}
+
+func TestDownsampleSeriesTimezone(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+
+	ts := timeseries.NewTimeSeriesData()
+	for _, hour := range []int{20, 21, 22} {
+		value := float64(hour)
+		ts.Add(timeseries.TimePoint{Time: time.Date(2021, 5, 17, hour, 0, 0, 0, time.UTC), Value: &value})
+	}
+	query := &QueryModel{MaxDataPoints: 1, Interval: 24 * time.Hour, Location: location}
+
+	// Midnight in Kolkata is 18:30 UTC
+	series, err := downsampleSeries([]*timeseries.TimeSeriesData{ts}, query, "sum")
+	assert.NoError(t, err)
+	assert.Len(t, series[0].TS, 1)
+	assert.True(t, time.Date(2021, 5, 17, 18, 30, 0, 0, time.UTC).Equal(series[0].TS[0].Time))
+	assert.Equal(t, 63.0, *series[0].TS[0].Value)
+}
@@ -0,0 +1,366 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Trigger recovery modes. With the expression mode problem is resolved when the problem expression becomes false,
+// with the recovery expression mode - when the recovery expression becomes true, so trigger has hysteresis.
+// Problems of the triggers without recovery are closed manually.
+const (
+	TriggerRecoveryModeExpression         = 0
+	TriggerRecoveryModeRecoveryExpression = 1
+	TriggerRecoveryModeNone               = 2
+)
+
+// Matches comparisons with constants or user macros, like "last(/host/key)>90", "{host:key.avg(5m)}<=1G"
+// or "last(/host/key)>{$CPU.MAX}". Operators are ordered so "<>", ">=" and "<=" are matched before "<" and ">".
+var triggerConditionPattern = regexp.MustCompile(`(>=|<=|<>|>|<|=)\s*(?:(-?\d+(?:\.\d+)?)([KMGTsmhdw]?)|(\{\$[A-Z0-9_.]+(?::[^}]*)?\}))(?:[^\w.]|$)`)
+
+// Matches context of the user macro, like {$MACRO:"context"}
+var macroContextPattern = regexp.MustCompile(`^\{(\$[A-Z0-9_.]+):.*\}$`)
+
+// TriggerCondition is a comparison of the trigger expression function with a constant or a user macro.
+// Value is not set if the macro can't be resolved.
+type TriggerCondition struct {
+	Operator string   `json:"operator"`
+	Value    *float64 `json:"value"`
+	Macro    string   `json:"macro,omitempty"`
+}
+
+// TriggerConditions contains conditions extracted from the trigger expression and recovery conditions extracted
+// from the recovery expression if trigger uses it
+type TriggerConditions struct {
+	TriggerID          string             `json:"triggerid"`
+	Description        string             `json:"description"`
+	Expression         string             `json:"expression"`
+	Priority           int                `json:"priority"`
+	Conditions         []TriggerCondition `json:"conditions"`
+	RecoveryMode       int                `json:"recoveryMode"`
+	RecoveryExpression string             `json:"recoveryExpression,omitempty"`
+	RecoveryConditions []TriggerCondition `json:"recoveryConditions,omitempty"`
+}
+
+// UserMacro model
+type UserMacro struct {
+	Macro  string `json:"macro"`
+	Value  string `json:"value"`
+	HostID string `json:"hostid,omitempty"`
+}
+
+// hostTemplates model, result of the host.get with parent templates
+type hostTemplates struct {
+	ID              string `json:"hostid"`
+	ParentTemplates []struct {
+		ID string `json:"templateid"`
+	} `json:"parentTemplates"`
+}
+
+// userMacros contains values of the host and template level and global user macros
+type userMacros struct {
+	// host contains macros of the hosts and templates by their IDs
+	host map[string]map[string]string
+	// templates contains IDs of the templates linked to the host, directly or through other templates, in the order
+	// Zabbix uses to resolve macros: templates linked to the host in link order first, then their parents.
+	templates map[string][]string
+	global    map[string]string
+}
+
+// resolve returns value of the macro defined on the first of the hosts having it, then on the first of their
+// templates, or the global one. Macros with context fall back to the macro without context.
+func (m *userMacros) resolve(macro string, hostids []string) (string, bool) {
+	candidates := []string{macro}
+	if matches := macroContextPattern.FindStringSubmatch(macro); matches != nil {
+		candidates = append(candidates, "{"+matches[1]+"}")
+	}
+
+	lookupIDs := append([]string{}, hostids...)
+	for _, hostid := range hostids {
+		lookupIDs = append(lookupIDs, m.templates[hostid]...)
+	}
+
+	for _, candidate := range candidates {
+		for _, hostid := range lookupIDs {
+			if value, ok := m.host[hostid][candidate]; ok {
+				return value, true
+			}
+		}
+		if value, ok := m.global[candidate]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// parseTriggerConditions returns comparisons of the expression with constants and user macros. Macros are
+// resolved using given macros of the trigger hosts, macros is optional.
+func parseTriggerConditions(expression string, macros *userMacros, hostids []string) []TriggerCondition {
+	var conditions []TriggerCondition
+	for _, match := range triggerConditionPattern.FindAllStringSubmatch(expression, -1) {
+		condition := TriggerCondition{Operator: match[1]}
+		if match[4] != "" {
+			condition.Macro = match[4]
+			if macros != nil {
+				if macroValue, ok := macros.resolve(condition.Macro, hostids); ok {
+					condition.Value = parseThresholdValue(macroValue)
+				}
+			}
+		} else {
+			condition.Value = parseThresholdValue(match[2] + match[3])
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
+
+// parseRecoveryConditions returns conditions of the trigger recovery expression, or nil if trigger is recovered
+// by the problem expression or manually
+func parseRecoveryConditions(trigger Trigger, macros *userMacros) []TriggerCondition {
+	if trigger.RecoveryMode != TriggerRecoveryModeRecoveryExpression {
+		return nil
+	}
+	return parseTriggerConditions(trigger.RecoveryExpression, macros, triggerHostIDs(trigger))
+}
+
+// Matches constants with optional unit suffix, like 90, -1.5 or 10G
+var thresholdValuePattern = regexp.MustCompile(`^\s*(-?\d+(?:\.\d+)?)([KMGTsmhdw]?)\s*$`)
+
+// parseThresholdValue parses constant with optional unit suffix, or returns nil if value is not a number
+func parseThresholdValue(value string) *float64 {
+	matches := thresholdValuePattern.FindStringSubmatch(value)
+	if matches == nil {
+		return nil
+	}
+	number, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return nil
+	}
+	number *= thresholdSuffixMultipliers[matches[2]]
+	return &number
+}
+
+// formatTriggerConditions formats conditions like "> 90, <= {$DISK.MIN} (10)"
+func formatTriggerConditions(conditions []TriggerCondition) string {
+	formatted := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		var value string
+		switch {
+		case condition.Macro != "" && condition.Value != nil:
+			value = fmt.Sprintf("%s (%s)", condition.Macro, strconv.FormatFloat(*condition.Value, 'f', -1, 64))
+		case condition.Macro != "":
+			value = condition.Macro
+		case condition.Value != nil:
+			value = strconv.FormatFloat(*condition.Value, 'f', -1, 64)
+		}
+		formatted = append(formatted, condition.Operator+" "+value)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// getTriggerConditions returns conditions of the triggers with user macros resolved
+func (ds *ZabbixDatasourceInstance) getTriggerConditions(ctx context.Context, triggerids []string) ([]TriggerConditions, error) {
+	if len(triggerids) == 0 {
+		return []TriggerConditions{}, nil
+	}
+
+	params := ZabbixAPIParams{
+		"output":           []string{"triggerid", "description", "expression", "priority", "recovery_mode", "recovery_expression"},
+		"triggerids":       triggerids,
+		"expandExpression": true,
+		"selectHosts":      []string{"hostid", "name"},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "trigger.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	responseJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	triggers := Triggers{}
+	err = json.Unmarshal(responseJSON, &triggers)
+	if err != nil {
+		return nil, err
+	}
+
+	macros, err := ds.getTriggersMacros(ctx, triggers)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TriggerConditions, 0, len(triggers))
+	for _, trigger := range triggers {
+		conditions := TriggerConditions{
+			TriggerID:          trigger.ID,
+			Description:        trigger.Description,
+			Expression:         trigger.Expression,
+			Priority:           trigger.Priority,
+			Conditions:         parseTriggerConditions(trigger.Expression, macros, triggerHostIDs(trigger)),
+			RecoveryMode:       trigger.RecoveryMode,
+			RecoveryConditions: parseRecoveryConditions(trigger, macros),
+		}
+		if trigger.RecoveryMode == TriggerRecoveryModeRecoveryExpression {
+			conditions.RecoveryExpression = trigger.RecoveryExpression
+		}
+		result = append(result, conditions)
+	}
+	return result, nil
+}
+
+// getTriggersMacros returns user macros of the triggers hosts and their templates and global macros. Macros are
+// requested only if some of the expressions use them.
+func (ds *ZabbixDatasourceInstance) getTriggersMacros(ctx context.Context, triggers Triggers) (*userMacros, error) {
+	macros := &userMacros{host: map[string]map[string]string{}, templates: map[string][]string{}, global: map[string]string{}}
+
+	var hostids []string
+	useMacros := false
+	for _, trigger := range triggers {
+		if strings.Contains(trigger.Expression, "{$") || strings.Contains(trigger.RecoveryExpression, "{$") {
+			useMacros = true
+			hostids = append(hostids, triggerHostIDs(trigger)...)
+		}
+	}
+	if !useMacros {
+		return macros, nil
+	}
+
+	parentTemplates, err := ds.getParentTemplates(ctx, hostids)
+	if err != nil {
+		return nil, err
+	}
+	macroHostIDs := append([]string{}, hostids...)
+	for _, hostid := range hostids {
+		macros.templates[hostid] = linkedTemplates(parentTemplates, hostid)
+		macroHostIDs = append(macroHostIDs, macros.templates[hostid]...)
+	}
+
+	hostMacros, err := ds.getUserMacros(ctx, ZabbixAPIParams{"output": "extend", "hostids": macroHostIDs})
+	if err != nil {
+		return nil, err
+	}
+	for _, macro := range hostMacros {
+		if macros.host[macro.HostID] == nil {
+			macros.host[macro.HostID] = map[string]string{}
+		}
+		macros.host[macro.HostID][macro.Macro] = macro.Value
+	}
+
+	globalMacros, err := ds.getUserMacros(ctx, ZabbixAPIParams{"output": "extend", "globalmacro": true})
+	if err != nil {
+		return nil, err
+	}
+	for _, macro := range globalMacros {
+		macros.global[macro.Macro] = macro.Value
+	}
+	return macros, nil
+}
+
+// getParentTemplates returns IDs of the templates linked to the given hosts and, recursively, to these templates,
+// by the host or template ID in link order
+func (ds *ZabbixDatasourceInstance) getParentTemplates(ctx context.Context, hostids []string) (map[string][]string, error) {
+	parents := map[string][]string{}
+	for len(hostids) > 0 {
+		params := ZabbixAPIParams{
+			"output":                []string{"hostid"},
+			"hostids":               hostids,
+			"templated_hosts":       true,
+			"selectParentTemplates": []string{"templateid"},
+		}
+		response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "host.get", Params: params})
+		if err != nil {
+			return nil, err
+		}
+
+		responseJSON, err := response.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+		}
+
+		var hosts []hostTemplates
+		err = json.Unmarshal(responseJSON, &hosts)
+		if err != nil {
+			return nil, err
+		}
+
+		var added []string
+		for _, host := range hosts {
+			if _, ok := parents[host.ID]; ok {
+				continue
+			}
+			parents[host.ID] = make([]string, 0, len(host.ParentTemplates))
+			for _, template := range host.ParentTemplates {
+				parents[host.ID] = append(parents[host.ID], template.ID)
+			}
+			added = append(added, host.ID)
+		}
+
+		// Request parents of the templates seen for the first time
+		hostids = nil
+		requested := map[string]bool{}
+		for _, id := range added {
+			for _, templateid := range parents[id] {
+				if _, ok := parents[templateid]; !ok && !requested[templateid] {
+					requested[templateid] = true
+					hostids = append(hostids, templateid)
+				}
+			}
+		}
+	}
+	return parents, nil
+}
+
+// linkedTemplates returns IDs of the templates linked to the host directly, then of their parent templates,
+// level by level, so the closest template defining a macro wins
+func linkedTemplates(parents map[string][]string, hostid string) []string {
+	var templates []string
+	visited := map[string]bool{hostid: true}
+	level := parents[hostid]
+	for len(level) > 0 {
+		var next []string
+		for _, templateid := range level {
+			if visited[templateid] {
+				continue
+			}
+			visited[templateid] = true
+			templates = append(templates, templateid)
+			next = append(next, parents[templateid]...)
+		}
+		level = next
+	}
+	return templates
+}
+
+func (ds *ZabbixDatasourceInstance) getUserMacros(ctx context.Context, params ZabbixAPIParams) ([]UserMacro, error) {
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "usermacro.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	responseJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var macros []UserMacro
+	err = json.Unmarshal(responseJSON, &macros)
+	if err != nil {
+		return nil, err
+	}
+	return macros, nil
+}
+
+func triggerHostIDs(trigger Trigger) []string {
+	hostids := make([]string, 0, len(trigger.Hosts))
+	for _, host := range trigger.Hosts {
+		hostids = append(hostids, host.ID)
+	}
+	return hostids
+}
@@ -0,0 +1,143 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTriggerConditions(t *testing.T) {
+	macros := &userMacros{
+		host: map[string]map[string]string{
+			"1":  {"{$CPU.MAX}": "80", `{$FS.MIN:"/"}`: "5G"},
+			"20": {"{$CPU.MAX}": "70"},
+			"21": {"{$CPU.MAX}": "60", "{$LOAD.MAX}": "5"},
+		},
+		templates: map[string][]string{
+			"1": {"20"},
+			"3": {"20", "21"},
+		},
+		global: map[string]string{"{$CPU.MAX}": "90", "{$FS.MIN}": "1G", "{$NAME}": "web"},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		hostids    []string
+		expected   []TriggerCondition
+	}{
+		{
+			name:       "constants",
+			expression: "last(/web01/system.cpu.util)>90 and last(/web01/system.cpu.util,#2)<=1.5K",
+			expected: []TriggerCondition{
+				{Operator: ">", Value: floatPtr(90)},
+				{Operator: "<=", Value: floatPtr(1.5 * 1024)},
+			},
+		},
+		{
+			name:       "equality operators",
+			expression: "{web01:agent.ping.nodata(5m)}=1 or {web01:proc.num.last()}<>-5",
+			expected: []TriggerCondition{
+				{Operator: "=", Value: floatPtr(1)},
+				{Operator: "<>", Value: floatPtr(-5)},
+			},
+		},
+		{
+			name:       "host macro",
+			expression: "last(/web01/system.cpu.util)>{$CPU.MAX}",
+			hostids:    []string{"1"},
+			expected:   []TriggerCondition{{Operator: ">", Value: floatPtr(80), Macro: "{$CPU.MAX}"}},
+		},
+		{
+			name:       "global macro",
+			expression: "last(/web02/system.cpu.util)>={$CPU.MAX}",
+			hostids:    []string{"2"},
+			expected:   []TriggerCondition{{Operator: ">=", Value: floatPtr(90), Macro: "{$CPU.MAX}"}},
+		},
+		{
+			name:       "template macro",
+			expression: "last(/web03/system.cpu.util)>{$CPU.MAX} or last(/web03/system.cpu.load)>{$LOAD.MAX}",
+			hostids:    []string{"3"},
+			expected: []TriggerCondition{
+				{Operator: ">", Value: floatPtr(70), Macro: "{$CPU.MAX}"},
+				{Operator: ">", Value: floatPtr(5), Macro: "{$LOAD.MAX}"},
+			},
+		},
+		{
+			name:       "macro with context",
+			expression: `last(/web01/vfs.fs.size[/,free])<{$FS.MIN:"/"} or last(/web01/vfs.fs.size[/home,free])<{$FS.MIN:"/home"}`,
+			hostids:    []string{"1"},
+			expected: []TriggerCondition{
+				{Operator: "<", Value: floatPtr(5 * 1024 * 1024 * 1024), Macro: `{$FS.MIN:"/"}`},
+				{Operator: "<", Value: floatPtr(1024 * 1024 * 1024), Macro: `{$FS.MIN:"/home"}`},
+			},
+		},
+		{
+			name:       "unresolved and not numeric macros",
+			expression: "last(/web01/system.cpu.util)>{$UNKNOWN} or last(/web01/system.hostname)={$NAME}",
+			expected: []TriggerCondition{
+				{Operator: ">", Macro: "{$UNKNOWN}"},
+				{Operator: "=", Macro: "{$NAME}"},
+			},
+		},
+		{
+			name:       "no conditions",
+			expression: "nodata(/web01/agent.ping,5m)",
+			expected:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseTriggerConditions(tt.expression, macros, tt.hostids))
+		})
+	}
+}
+
+func TestGetParentTemplates(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[
+		{"hostid":"10","parentTemplates":[{"templateid":"21"},{"templateid":"20"}]},
+		{"hostid":"21","parentTemplates":[{"templateid":"30"}]},
+		{"hostid":"20","parentTemplates":[{"templateid":"30"},{"templateid":"10"}]}
+	]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	dsInstance.Settings.ZabbixVersion = "6.0"
+
+	ctx, apiCalls := withAPICallsRecorder(context.Background())
+	parents, err := dsInstance.getParentTemplates(ctx, []string{"10"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"10": {"21", "20"}, "20": {"30", "10"}, "21": {"30"}}, parents)
+
+	// Parents of the template 30 are requested, but it's not in the response, so requests stop
+	calls := apiCalls.Calls()
+	assert.Len(t, calls, 2)
+	assert.Equal(t, "host.get", calls[1].Method)
+	assert.Contains(t, calls[1].Params, `"hostids":["30"]`)
+
+	// Loops in the links are skipped
+	assert.Equal(t, []string{"21", "20", "30"}, linkedTemplates(parents, "10"))
+	assert.Empty(t, linkedTemplates(parents, "30"))
+}
+
+func TestFormatTriggerConditions(t *testing.T) {
+	conditions := []TriggerCondition{
+		{Operator: ">", Value: floatPtr(90)},
+		{Operator: "<=", Value: floatPtr(10), Macro: "{$DISK.MIN}"},
+		{Operator: "=", Macro: "{$NAME}"},
+	}
+	assert.Equal(t, "> 90, <= {$DISK.MIN} (10), = {$NAME}", formatTriggerConditions(conditions))
+	assert.Equal(t, "", formatTriggerConditions(nil))
+}
+
+func TestParseRecoveryConditions(t *testing.T) {
+	trigger := Trigger{
+		Expression:         "last(/web01/system.cpu.util)>90",
+		RecoveryMode:       TriggerRecoveryModeRecoveryExpression,
+		RecoveryExpression: "last(/web01/system.cpu.util)<80",
+	}
+	assert.Equal(t, []TriggerCondition{{Operator: "<", Value: floatPtr(80)}}, parseRecoveryConditions(trigger, nil))
+
+	trigger.RecoveryMode = TriggerRecoveryModeNone
+	assert.Nil(t, parseRecoveryConditions(trigger, nil))
+}
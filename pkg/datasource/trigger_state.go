@@ -0,0 +1,198 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+// Trigger states, same as the trigger and event values
+const (
+	TriggerStateOK      = 0
+	TriggerStateProblem = 1
+)
+
+// queryTriggerState returns state of the triggers over time as series of 0 (OK) and 1 (problem) values,
+// built from the trigger events.
+func (ds *ZabbixDatasourceInstance) queryTriggerState(ctx context.Context, query *QueryModel) (*data.Frame, error) {
+	triggers, err := ds.getStateTriggers(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyFunctionsPre(query)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]*timeseries.TimeSeriesData, 0, len(triggers))
+	for _, trigger := range triggers {
+		initialState, err := ds.getTriggerStateAt(ctx, trigger.ID, query.TimeRange.From)
+		if err != nil {
+			return nil, err
+		}
+
+		events, err := ds.getEvents(ctx, "event.get", ZabbixAPIParams{
+			"output":    []string{"eventid", "clock", "ns", "value"},
+			"source":    0,
+			"object":    0,
+			"objectids": []string{trigger.ID},
+			"time_from": query.TimeRange.From.Unix(),
+			"time_till": query.TimeRange.To.Unix(),
+			"sortfield": []string{"clock", "eventid"},
+			"sortorder": "ASC",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		series = append(series, convertEventsToStateSeries(trigger, initialState, events, query.TimeRange.From, query.TimeRange.To))
+	}
+
+	series, err = applyFunctions(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err = applyFunctionsPost(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertSeriesToFrame(series, query.Options), nil
+}
+
+// getStateTriggers returns trigger selected by id, or triggers of the matching hosts with description
+// matching the trigger filter (all triggers of the hosts if filter is empty)
+func (ds *ZabbixDatasourceInstance) getStateTriggers(ctx context.Context, query *QueryModel) (Triggers, error) {
+	params := ZabbixAPIParams{
+		"output":            []string{"triggerid", "description", "priority", "value", "lastchange"},
+		"selectHosts":       []string{"hostid", "name"},
+		"selectLastEvent":   []string{"eventid", "acknowledged"},
+		"selectGroups":      []string{"groupid", "name"},
+		"selectTags":        "extend",
+		"expandDescription": true,
+	}
+
+	if query.TriggerID != "" {
+		params["triggerids"] = []string{query.TriggerID}
+	} else {
+		hosts, err := ds.getHosts(ctx, newQueryFilters(query), query.Group.Filter, query.Host.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(hosts) == 0 {
+			return Triggers{}, nil
+		}
+		var hostids []string
+		for _, host := range hosts {
+			hostids = append(hostids, host["hostid"].(string))
+		}
+		params["hostids"] = hostids
+	}
+
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "trigger.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	triggersJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	triggers := Triggers{}
+	err = json.Unmarshal(triggersJSON, &triggers)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.TriggerID != "" || query.Trigger.Filter == "" {
+		return triggers, nil
+	}
+
+	nameFilter, err := newNameFilter(query.Trigger.Filter)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(triggers))
+	for _, trigger := range triggers {
+		names = append(names, trigger.Description)
+	}
+	nameFilter = nameFilter.forNames(names)
+	filtered := Triggers{}
+	for _, trigger := range triggers {
+		if nameFilter.match(trigger.Description) {
+			filtered = append(filtered, trigger)
+		}
+	}
+	return filtered, nil
+}
+
+// getTriggerStateAt returns state of the trigger at the given time from the last event before it. Trigger
+// without events is considered OK.
+func (ds *ZabbixDatasourceInstance) getTriggerStateAt(ctx context.Context, triggerID string, t time.Time) (int, error) {
+	events, err := ds.getEvents(ctx, "event.get", ZabbixAPIParams{
+		"output":    []string{"eventid", "clock", "value"},
+		"source":    0,
+		"object":    0,
+		"objectids": []string{triggerID},
+		"time_till": t.Unix() - 1,
+		"sortfield": []string{"clock", "eventid"},
+		"sortorder": "DESC",
+		"limit":     1,
+	})
+	if err != nil {
+		return TriggerStateOK, err
+	}
+	if len(events) == 0 {
+		return TriggerStateOK, nil
+	}
+	return events[0].Value, nil
+}
+
+// convertEventsToStateSeries returns series with the trigger state at the start of the time range, a point
+// for each event and the last state at the end of the time range. Series is labeled with the trigger, its
+// hosts, host groups and tags.
+func convertEventsToStateSeries(trigger Trigger, initialState int, events Events, from time.Time, to time.Time) *timeseries.TimeSeriesData {
+	labels := tagLabels(trigger.Tags, problemReservedLabels)
+	for name, value := range triggerHostLabels(trigger) {
+		labels[name] = value
+	}
+	labels["trigger"] = trigger.Description
+	labels[AlertLabelTriggerID] = trigger.ID
+
+	ts := timeseries.NewTimeSeriesData()
+	ts.Meta.Name = trigger.Description
+	if host := labels["host"]; host != "" {
+		ts.Meta.Name = fmt.Sprintf("%s: %s", host, trigger.Description)
+	}
+	ts.Meta.Labels = labels
+
+	// Event at the same time as the previous point replaces its state
+	addState := func(t time.Time, state int) {
+		value := float64(state)
+		if last := ts.Len() - 1; last >= 0 && ts.TS[last].Time.Equal(t) {
+			ts.TS[last].Value = &value
+			return
+		}
+		ts.Add(timeseries.TimePoint{Time: t, Value: &value})
+	}
+
+	state := initialState
+	addState(from, state)
+	for _, event := range events {
+		eventTime := time.Unix(event.Clock, event.NS)
+		if eventTime.Before(from) || eventTime.After(to) {
+			continue
+		}
+		state = event.Value
+		addState(eventTime, state)
+	}
+	addState(to, state)
+	return ts
+}
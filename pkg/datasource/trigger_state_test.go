@@ -0,0 +1,45 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertEventsToStateSeries(t *testing.T) {
+	from := time.Unix(1600000000, 0)
+	to := time.Unix(1600003600, 0)
+	trigger := Trigger{
+		ID:          "1",
+		Description: "CPU is high",
+		Hosts:       []ItemHost{{ID: "1", Name: "web01"}},
+		Groups:      []TriggerGroup{{ID: "1", Name: "Linux servers"}},
+		Tags:        []ItemTag{{Tag: "service", Value: "web"}, {Tag: "trigger", Value: "ignored"}},
+	}
+
+	events := Events{
+		{ID: "10", Clock: 1600000000, Value: TriggerStateProblem},
+		{ID: "11", Clock: 1600001000, Value: TriggerStateOK},
+		{ID: "12", Clock: 1600002000, Value: TriggerStateProblem},
+	}
+	ts := convertEventsToStateSeries(trigger, TriggerStateOK, events, from, to)
+	assert.Equal(t, "web01: CPU is high", ts.Meta.Name)
+	assert.Equal(t, data.Labels{
+		"host":      "web01",
+		"group":     "Linux servers",
+		"trigger":   "CPU is high",
+		"triggerid": "1",
+		"service":   "web",
+	}, ts.Meta.Labels)
+	assert.Equal(t, 4, ts.Len())
+	assert.Equal(t, from, ts.TS[0].Time)
+	assert.Equal(t, to, ts.TS[3].Time)
+	assert.Equal(t, []*float64{floatPtr(1), floatPtr(0), floatPtr(1), floatPtr(1)}, pointValues(ts.TS))
+
+	// Trigger without events keeps initial state
+	ts = convertEventsToStateSeries(Trigger{ID: "2", Description: "Agent is down"}, TriggerStateProblem, Events{}, from, to)
+	assert.Equal(t, "Agent is down", ts.Meta.Name)
+	assert.Equal(t, []*float64{floatPtr(1), floatPtr(1)}, pointValues(ts.TS))
+}
@@ -0,0 +1,305 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/net/context"
+)
+
+const defaultTriggersCountInterval = time.Minute
+
+// queryTriggersCount counts problems of the matching hosts by severity and returns them as series
+// (one series per severity, one point per time bucket).
+func (ds *ZabbixDatasourceInstance) queryTriggersCount(ctx context.Context, query *QueryModel) (*data.Frame, error) {
+	hosts, err := ds.getHosts(ctx, newQueryFilters(query), query.Group.Filter, query.Host.Filter)
+	if err != nil {
+		return nil, err
+	}
+	var hostids []string
+	for _, host := range hosts {
+		hostids = append(hostids, host["hostid"].(string))
+	}
+
+	err = applyFunctionsPre(query)
+	if err != nil {
+		return nil, err
+	}
+
+	events := Events{}
+	if len(hostids) > 0 {
+		events, err = ds.getProblemEvents(ctx, query, hostids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	series := countEventsBySeverity(events, query, ds.Settings.Severities)
+
+	series, err = applyFunctions(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err = applyFunctionsPost(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertSeriesToFrame(series, query.Options), nil
+}
+
+// queryCurrentProblemsCount counts current problems (triggers in problem state) of the matching hosts by severity
+// and returns instant series with a single point at the end of the time range. Counts can be split by host or
+// host group, each host (group) of the trigger is counted.
+func (ds *ZabbixDatasourceInstance) queryCurrentProblemsCount(ctx context.Context, query *QueryModel) (*data.Frame, error) {
+	hosts, err := ds.getHosts(ctx, newQueryFilters(query), query.Group.Filter, query.Host.Filter)
+	if err != nil {
+		return nil, err
+	}
+	var hostids []string
+	for _, host := range hosts {
+		hostids = append(hostids, host["hostid"].(string))
+	}
+
+	err = applyFunctionsPre(query)
+	if err != nil {
+		return nil, err
+	}
+
+	triggers := Triggers{}
+	if len(hostids) > 0 {
+		triggers, err = ds.getProblemTriggersByHosts(ctx, query, hostids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var groupNames map[string]bool
+	if query.Triggers.GroupBy == TriggersGroupByGroup {
+		groups, err := ds.getGroups(ctx, newQueryFilters(query), query.Group.Filter)
+		if err != nil {
+			return nil, err
+		}
+		groupNames = make(map[string]bool, len(groups))
+		for _, group := range groups {
+			groupNames[group["name"].(string)] = true
+		}
+	}
+
+	series := countTriggersBySeverity(triggers, query, groupNames, ds.Settings.Severities)
+
+	series, err = applyFunctions(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err = applyFunctionsPost(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertSeriesToFrame(series, query.Options), nil
+}
+
+// getProblemTriggersByHosts returns monitored triggers of the hosts in problem state filtered by the query
+// min severity and acknowledge state of the last event
+func (ds *ZabbixDatasourceInstance) getProblemTriggersByHosts(ctx context.Context, query *QueryModel, hostids []string) (Triggers, error) {
+	params := ZabbixAPIParams{
+		"output":            []string{"triggerid", "description", "priority"},
+		"hostids":           hostids,
+		"min_severity":      triggersMinSeverity(query),
+		"filter":            map[string]interface{}{"value": 1},
+		"expandDescription": true,
+		"monitored":         true,
+		"skipDependent":     true,
+		"selectHosts":       []string{"hostid", "name"},
+		"selectGroups":      []string{"groupid", "name"},
+		"selectLastEvent":   []string{"eventid", "acknowledged"},
+	}
+	setTagsParams(params, query)
+
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "trigger.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	triggersJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	triggers := Triggers{}
+	err = json.Unmarshal(triggersJSON, &triggers)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Triggers.Acknowledged != AckFilterUnacknowledged && query.Triggers.Acknowledged != AckFilterAcknowledged {
+		return triggers, nil
+	}
+
+	acknowledged := "0"
+	if query.Triggers.Acknowledged == AckFilterAcknowledged {
+		acknowledged = "1"
+	}
+	filtered := Triggers{}
+	for _, trigger := range triggers {
+		if trigger.LastEvent != nil && trigger.LastEvent.Acknowledged == acknowledged {
+			filtered = append(filtered, trigger)
+		}
+	}
+	return filtered, nil
+}
+
+// triggersMinSeverity returns min severity of the triggers query, not classified if it's not set or invalid
+func triggersMinSeverity(query *QueryModel) int {
+	if query.Triggers.MinSeverity < SeverityNotClassified {
+		return SeverityNotClassified
+	}
+	return query.Triggers.MinSeverity
+}
+
+// countTriggersBySeverity builds instant series of the triggers count for each severity starting from the query
+// min severity, split by host or group if set in the query. Only groups from the groupNames are counted.
+// Severities without problems are set to zero if counts aren't split.
+func countTriggersBySeverity(triggers Triggers, query *QueryModel, groupNames map[string]bool, severities Severities) []*timeseries.TimeSeriesData {
+	minSeverity := triggersMinSeverity(query)
+	groupBy := query.Triggers.GroupBy
+
+	type countKey struct {
+		severity int
+		name     string
+	}
+	counts := make(map[countKey]float64)
+	if groupBy == "" {
+		for severity := minSeverity; severity <= SeverityDisaster; severity++ {
+			counts[countKey{severity: severity}] = 0
+		}
+	}
+
+	for _, trigger := range triggers {
+		if trigger.Priority < minSeverity || trigger.Priority > SeverityDisaster {
+			continue
+		}
+		switch groupBy {
+		case TriggersGroupByHost:
+			for _, host := range trigger.Hosts {
+				counts[countKey{severity: trigger.Priority, name: host.Name}]++
+			}
+		case TriggersGroupByGroup:
+			for _, group := range trigger.Groups {
+				if groupNames[group.Name] {
+					counts[countKey{severity: trigger.Priority, name: group.Name}]++
+				}
+			}
+		default:
+			counts[countKey{severity: trigger.Priority}]++
+		}
+	}
+
+	keys := make([]countKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].severity < keys[j].severity
+	})
+
+	series := make([]*timeseries.TimeSeriesData, 0, len(keys))
+	for _, key := range keys {
+		ts := timeseries.NewTimeSeriesData()
+		ts.Meta.Name = severities.Name(key.severity)
+		ts.Meta.Labels = data.Labels{"severity": severities.Name(key.severity)}
+		if groupBy != "" {
+			ts.Meta.Name = fmt.Sprintf("%s: %s", key.name, severities.Name(key.severity))
+			ts.Meta.Labels[groupBy] = key.name
+		}
+		value := counts[key]
+		ts.Add(timeseries.TimePoint{Time: query.TimeRange.To, Value: &value})
+		series = append(series, ts)
+	}
+	return series
+}
+
+// getProblemEvents returns trigger problem events of the hosts fired within the query time range, requested by
+// pages since there may be a lot of them on the wide time ranges
+func (ds *ZabbixDatasourceInstance) getProblemEvents(ctx context.Context, query *QueryModel, hostids []string) (Events, error) {
+	var severities []int
+	for severity := triggersMinSeverity(query); severity <= SeverityDisaster; severity++ {
+		severities = append(severities, severity)
+	}
+
+	params := ZabbixAPIParams{
+		"output":     []string{"eventid", "objectid", "clock", "ns", "severity", "acknowledged", "name"},
+		"source":     0,
+		"object":     0,
+		"value":      1,
+		"hostids":    hostids,
+		"severities": severities,
+		"time_from":  query.TimeRange.From.Unix(),
+		"time_till":  query.TimeRange.To.Unix(),
+	}
+	setTagsParams(params, query)
+	if query.Triggers.Acknowledged == AckFilterUnacknowledged {
+		params["acknowledged"] = false
+	} else if query.Triggers.Acknowledged == AckFilterAcknowledged {
+		params["acknowledged"] = true
+	}
+
+	events, _, err := ds.getEventsPaged(ctx, "event.get", params, 0, "")
+	return events, err
+}
+
+// countEventsBySeverity builds series of the problem counts per time bucket for each severity
+// starting from the query min severity. Buckets without problems are set to zero.
+func countEventsBySeverity(events Events, query *QueryModel, severities Severities) []*timeseries.TimeSeriesData {
+	interval := query.Interval
+	if interval <= 0 {
+		interval = defaultTriggersCountInterval
+	}
+
+	from := query.TimeRange.From.Truncate(interval)
+	to := query.TimeRange.To
+	bucketsNum := int(to.Sub(from)/interval) + 1
+
+	minSeverity := triggersMinSeverity(query)
+
+	counts := make(map[int][]float64)
+	for severity := minSeverity; severity <= SeverityDisaster; severity++ {
+		counts[severity] = make([]float64, bucketsNum)
+	}
+
+	for _, event := range events {
+		severityCounts, ok := counts[event.Severity]
+		if !ok {
+			continue
+		}
+		bucket := int(time.Unix(event.Clock, event.NS).Sub(from) / interval)
+		if bucket < 0 || bucket >= bucketsNum {
+			continue
+		}
+		severityCounts[bucket]++
+	}
+
+	series := make([]*timeseries.TimeSeriesData, 0, len(counts))
+	for severity := minSeverity; severity <= SeverityDisaster; severity++ {
+		ts := timeseries.NewTimeSeriesData()
+		ts.Meta.Name = severities.Name(severity)
+		ts.Meta.Labels = data.Labels{"severity": severities.Name(severity)}
+		ts.Meta.Interval = timeseries.FixedInterval(interval)
+		for i, count := range counts[severity] {
+			value := count
+			ts.Add(timeseries.TimePoint{Time: from.Add(time.Duration(i) * interval), Value: &value})
+		}
+		series = append(series, ts)
+	}
+	return series
+}
@@ -0,0 +1,92 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountEventsBySeverity(t *testing.T) {
+	from := time.Unix(1600000030, 0)
+	query := &QueryModel{
+		Triggers:  QueryTriggers{MinSeverity: SeverityAverage},
+		TimeRange: backend.TimeRange{From: from, To: from.Add(3 * time.Minute)},
+		Interval:  time.Minute,
+	}
+	events := Events{
+		{ID: "1", Clock: 1600000030, Severity: SeverityAverage},
+		{ID: "2", Clock: 1600000050, Severity: SeverityAverage},
+		{ID: "3", Clock: 1600000130, Severity: SeverityDisaster},
+		{ID: "4", Clock: 1600000130, Severity: SeverityWarning},
+	}
+
+	series := countEventsBySeverity(events, query, Severities{})
+	assert.Equal(t, []string{"Average", "High", "Disaster"}, seriesNames(series))
+	for _, s := range series {
+		assert.Equal(t, 4, s.Len())
+		assert.Equal(t, time.Unix(1600000020, 0), s.TS[0].Time)
+	}
+	assert.Equal(t, float64(2), *series[0].TS[0].Value)
+	assert.Equal(t, float64(0), *series[0].TS[1].Value)
+	assert.Equal(t, float64(0), *series[1].TS[1].Value)
+	assert.Equal(t, float64(1), *series[2].TS[1].Value)
+}
+
+func TestGetProblemEventsMinSeverity(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	ctx, apiCalls := withAPICallsRecorder(context.Background())
+
+	query := &QueryModel{Triggers: QueryTriggers{MinSeverity: -1}, TimeRange: backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}}
+	_, err := dsInstance.getProblemEvents(ctx, query, []string{"10"})
+	assert.NoError(t, err)
+	calls := apiCalls.Calls()
+	assert.Len(t, calls, 1)
+	assert.Contains(t, calls[0].Params, `"severities":[0,1,2,3,4,5]`)
+}
+
+func TestCountTriggersBySeverity(t *testing.T) {
+	to := time.Unix(1600003600, 0)
+	triggers := Triggers{
+		{ID: "1", Priority: SeverityDisaster, Hosts: []ItemHost{{Name: "web01"}}, Groups: []TriggerGroup{{Name: "Web"}, {Name: "Linux"}}},
+		{ID: "2", Priority: SeverityDisaster, Hosts: []ItemHost{{Name: "web02"}}, Groups: []TriggerGroup{{Name: "Web"}}},
+		{ID: "3", Priority: SeverityHigh, Hosts: []ItemHost{{Name: "web01"}}, Groups: []TriggerGroup{{Name: "Web"}, {Name: "Linux"}}},
+		{ID: "4", Priority: SeverityWarning, Hosts: []ItemHost{{Name: "web01"}}, Groups: []TriggerGroup{{Name: "Web"}}},
+	}
+
+	query := &QueryModel{
+		Triggers:  QueryTriggers{MinSeverity: SeverityHigh, Count: true},
+		TimeRange: backend.TimeRange{From: to.Add(-time.Hour), To: to},
+	}
+	series := countTriggersBySeverity(triggers, query, nil, Severities{})
+	assert.Equal(t, []string{"High", "Disaster"}, seriesNames(series))
+	assert.Equal(t, []*float64{floatPtr(1)}, pointValues(series[0].TS))
+	assert.Equal(t, []*float64{floatPtr(2)}, pointValues(series[1].TS))
+	assert.Equal(t, to, series[1].TS[0].Time)
+
+	query.Triggers.GroupBy = TriggersGroupByHost
+	series = countTriggersBySeverity(triggers, query, nil, Severities{})
+	assert.Equal(t, []string{"web01: High", "web01: Disaster", "web02: Disaster"}, seriesNames(series))
+	assert.Equal(t, data.Labels{"severity": "Disaster", "host": "web02"}, series[2].Meta.Labels)
+
+	query.Triggers.GroupBy = TriggersGroupByGroup
+	series = countTriggersBySeverity(triggers, query, map[string]bool{"Web": true}, Severities{})
+	assert.Equal(t, []string{"Web: High", "Web: Disaster"}, seriesNames(series))
+	assert.Equal(t, []*float64{floatPtr(2)}, pointValues(series[1].TS))
+}
+
+func TestUnmarshalTriggerLastEvent(t *testing.T) {
+	triggers := Triggers{}
+	err := json.Unmarshal([]byte(`[
+		{"triggerid": "1", "priority": "4", "lastEvent": {"eventid": "10", "acknowledged": "1"}},
+		{"triggerid": "2", "priority": "4", "lastEvent": []}
+	]`), &triggers)
+	assert.NoError(t, err)
+	assert.Equal(t, &TriggerLastEvent{ID: "10", Acknowledged: "1"}, triggers[0].LastEvent)
+	assert.Equal(t, &TriggerLastEvent{}, triggers[1].LastEvent)
+}
@@ -0,0 +1,208 @@
+package datasource
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ItemUpdateInterval is a parsed item delay, like "1m;50s/1-5,09:00-18:00;wd1-5h9".
+// Update interval is followed by custom intervals, which are either flexible or scheduling ones.
+type ItemUpdateInterval struct {
+	Interval   time.Duration
+	Flexible   []FlexibleInterval
+	Scheduling []string
+}
+
+// FlexibleInterval redefines update interval for the given time period.
+type FlexibleInterval struct {
+	Interval time.Duration
+	Period   TimePeriod
+}
+
+// TimePeriod is a Zabbix time period, like "1-5,09:00-18:00". Week days are from 1 (Monday) to 7 (Sunday),
+// time is set as offset from the start of the day.
+type TimePeriod struct {
+	WeekDayFrom int
+	WeekDayTo   int
+	TimeFrom    time.Duration
+	TimeTo      time.Duration
+}
+
+var (
+	delayValuePattern      = regexp.MustCompile(`^(\d+)([smhdw]?)$`)
+	timePeriodPattern      = regexp.MustCompile(`^([1-7])(?:-([1-7]))?,(\d{1,2}):(\d{2})-(\d{1,2}):(\d{2})$`)
+	schedulingPeriodPrefix = regexp.MustCompile(`^(md|wd|h|m|s)`)
+)
+
+// parseItemUpdateInterval parses item delay string. User macros are not supported, since they are not
+// resolved by item.get.
+func parseItemUpdateInterval(delay string) (*ItemUpdateInterval, error) {
+	parts := strings.Split(strings.TrimSpace(delay), ";")
+
+	interval, err := parseDelayValue(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	updateInterval := &ItemUpdateInterval{Interval: interval}
+	for _, custom := range parts[1:] {
+		custom = strings.TrimSpace(custom)
+		if custom == "" {
+			continue
+		}
+
+		if schedulingPeriodPrefix.MatchString(custom) {
+			updateInterval.Scheduling = append(updateInterval.Scheduling, custom)
+			continue
+		}
+
+		flexible, err := parseFlexibleInterval(custom)
+		if err != nil {
+			return nil, err
+		}
+		updateInterval.Flexible = append(updateInterval.Flexible, *flexible)
+	}
+
+	return updateInterval, nil
+}
+
+// IntervalAt returns update interval used at the given time. Zero means item isn't polled at fixed interval
+// at that time (polled only by the scheduling intervals or not polled at all).
+func (i *ItemUpdateInterval) IntervalAt(t time.Time) time.Duration {
+	for _, flexible := range i.Flexible {
+		if flexible.Period.Contains(t) {
+			return flexible.Interval
+		}
+	}
+	return i.Interval
+}
+
+// MinInterval returns the smallest update interval including flexible intervals, zero if item has no fixed
+// update interval at any time
+func (i *ItemUpdateInterval) MinInterval() time.Duration {
+	minInterval := i.Interval
+	for _, flexible := range i.Flexible {
+		if flexible.Interval > 0 && (minInterval <= 0 || flexible.Interval < minInterval) {
+			minInterval = flexible.Interval
+		}
+	}
+	return minInterval
+}
+
+// IsZero checks if item has no fixed update interval at any time
+func (i *ItemUpdateInterval) IsZero() bool {
+	if i.Interval > 0 {
+		return false
+	}
+	for _, flexible := range i.Flexible {
+		if flexible.Interval > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains checks if given time is inside of the period
+func (p *TimePeriod) Contains(t time.Time) bool {
+	weekDay := int(t.Weekday())
+	if weekDay == 0 {
+		weekDay = 7
+	}
+	if weekDay < p.WeekDayFrom || weekDay > p.WeekDayTo {
+		return false
+	}
+
+	dayStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	dayTime := t.Sub(dayStart)
+	return dayTime >= p.TimeFrom && dayTime < p.TimeTo
+}
+
+func parseFlexibleInterval(custom string) (*FlexibleInterval, error) {
+	parts := strings.SplitN(custom, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid custom interval %q", custom)
+	}
+
+	interval, err := parseDelayValue(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	period, err := parseTimePeriod(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &FlexibleInterval{Interval: interval, Period: *period}, nil
+}
+
+func parseTimePeriod(period string) (*TimePeriod, error) {
+	matches := timePeriodPattern.FindStringSubmatch(period)
+	if len(matches) != 7 {
+		return nil, fmt.Errorf("invalid time period %q", period)
+	}
+
+	weekDayFrom, _ := strconv.Atoi(matches[1])
+	weekDayTo := weekDayFrom
+	if matches[2] != "" {
+		weekDayTo, _ = strconv.Atoi(matches[2])
+	}
+
+	timeFrom, err := parseDayTime(matches[3], matches[4])
+	if err != nil {
+		return nil, err
+	}
+	timeTo, err := parseDayTime(matches[5], matches[6])
+	if err != nil {
+		return nil, err
+	}
+
+	if weekDayFrom > weekDayTo || timeFrom >= timeTo {
+		return nil, fmt.Errorf("invalid time period %q", period)
+	}
+
+	return &TimePeriod{
+		WeekDayFrom: weekDayFrom,
+		WeekDayTo:   weekDayTo,
+		TimeFrom:    timeFrom,
+		TimeTo:      timeTo,
+	}, nil
+}
+
+func parseDayTime(hoursStr string, minutesStr string) (time.Duration, error) {
+	hours, _ := strconv.Atoi(hoursStr)
+	minutes, _ := strconv.Atoi(minutesStr)
+	if hours > 24 || minutes > 59 || (hours == 24 && minutes > 0) {
+		return 0, fmt.Errorf("invalid time %s:%s", hoursStr, minutesStr)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// parseDelayValue parses interval value like 30s, 5m or 60 (seconds)
+func parseDelayValue(value string) (time.Duration, error) {
+	matches := delayValuePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("unsupported item update interval %q", value)
+	}
+
+	num, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, err
+	}
+
+	unit := time.Second
+	switch matches[2] {
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	}
+	return time.Duration(num) * unit, nil
+}
@@ -0,0 +1,76 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseItemUpdateInterval(t *testing.T) {
+	tests := []struct {
+		delay    string
+		expected *ItemUpdateInterval
+		err      bool
+	}{
+		{delay: "30s", expected: &ItemUpdateInterval{Interval: 30 * time.Second}},
+		{delay: "5m", expected: &ItemUpdateInterval{Interval: 5 * time.Minute}},
+		{delay: "60", expected: &ItemUpdateInterval{Interval: time.Minute}},
+		{
+			delay: "1m;wd1-5h9-18",
+			expected: &ItemUpdateInterval{
+				Interval:   time.Minute,
+				Scheduling: []string{"wd1-5h9-18"},
+			},
+		},
+		{
+			delay: "0;30s/1-5,09:00-18:00;10m/6-7,00:00-24:00",
+			expected: &ItemUpdateInterval{
+				Interval: 0,
+				Flexible: []FlexibleInterval{
+					{
+						Interval: 30 * time.Second,
+						Period:   TimePeriod{WeekDayFrom: 1, WeekDayTo: 5, TimeFrom: 9 * time.Hour, TimeTo: 18 * time.Hour},
+					},
+					{
+						Interval: 10 * time.Minute,
+						Period:   TimePeriod{WeekDayFrom: 6, WeekDayTo: 7, TimeFrom: 0, TimeTo: 24 * time.Hour},
+					},
+				},
+			},
+		},
+		{delay: "{$DELAY}", err: true},
+		{delay: "1m;30s/1-5,18:00-09:00", err: true},
+		{delay: "1m;30s", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.delay, func(t *testing.T) {
+			interval, err := parseItemUpdateInterval(tt.delay)
+			if tt.err {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.expected, interval)
+			}
+		})
+	}
+}
+
+func TestItemUpdateIntervalAt(t *testing.T) {
+	interval, err := parseItemUpdateInterval("5m;30s/1-5,09:00-18:00")
+	assert.Nil(t, err)
+
+	// Monday
+	assert.Equal(t, 30*time.Second, interval.IntervalAt(time.Date(2021, 5, 17, 10, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 5*time.Minute, interval.IntervalAt(time.Date(2021, 5, 17, 18, 0, 0, 0, time.UTC)))
+	// Sunday
+	assert.Equal(t, 5*time.Minute, interval.IntervalAt(time.Date(2021, 5, 16, 10, 0, 0, 0, time.UTC)))
+	assert.False(t, interval.IsZero())
+	assert.Equal(t, 30*time.Second, interval.MinInterval())
+
+	scheduled, err := parseItemUpdateInterval("0;wd1-5h9")
+	assert.Nil(t, err)
+	assert.True(t, scheduled.IsZero())
+	assert.Equal(t, time.Duration(0), scheduled.MinInterval())
+}
@@ -0,0 +1,269 @@
+package datasource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/net/context"
+)
+
+// Variable query types, same as in the variable query editor
+const (
+	VariableQueryTypeGroup       = "group"
+	VariableQueryTypeHost        = "host"
+	VariableQueryTypeApplication = "application"
+	VariableQueryTypeItem        = "item"
+	VariableQueryTypeItemValues  = "itemValues"
+)
+
+// Time range of the resource requests (like item values query) if it's not set in the request
+const defaultResourceTimeRange = 2 * time.Hour
+
+var (
+	templateQueryBracesPattern = regexp.MustCompile(`^\{.+\}$`)
+	templateQuerySplitPattern  = regexp.MustCompile(`\{[^\{\}]*\}|\{\/.*\/\}`)
+)
+
+// VariableQuery is a template variable query. Template variables should be replaced before the query is sent
+// to the backend.
+type VariableQuery struct {
+	QueryType   string `json:"queryType"`
+	Group       string `json:"group"`
+	Host        string `json:"host"`
+	Application string `json:"application"`
+	Item        string `json:"item"`
+	// Host tag filters, evaluated as And/Or (default) or Or
+	HostTags         []TagFilter `json:"hostTags,omitempty"`
+	HostTagsEvalType string      `json:"hostTagsEvalType,omitempty"`
+	// Page of the values, all values are returned if limit isn't set
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
+}
+
+// VariableQueryResourceRequest is a request of the /variable-query resource. Query is either a variable query
+// object or a legacy query string like "group.host.app.item". From and To (in ms) are used by the item values
+// query only.
+type VariableQueryResourceRequest struct {
+	Query json.RawMessage `json:"query"`
+	From  int64           `json:"from,omitempty"`
+	To    int64           `json:"to,omitempty"`
+}
+
+// MetricFindValue is a value of the template variable
+type MetricFindValue struct {
+	Text string `json:"text"`
+}
+
+// parseVariableQuery reads variable query object or legacy query string
+func parseVariableQuery(raw json.RawMessage) (*VariableQuery, error) {
+	var legacyQuery string
+	if err := json.Unmarshal(raw, &legacyQuery); err == nil {
+		return parseLegacyVariableQuery(legacyQuery), nil
+	}
+
+	query := &VariableQuery{}
+	if err := json.Unmarshal(raw, query); err != nil {
+		return nil, fmt.Errorf("invalid variable query: %w", err)
+	}
+	if err := validateTags(query.HostTags, query.HostTagsEvalType); err != nil {
+		return nil, fmt.Errorf("invalid host tags: %w", err)
+	}
+	if query.Limit < 0 || query.Offset < 0 {
+		return nil, fmt.Errorf("limit and offset must not be negative")
+	}
+	return query, nil
+}
+
+// parseLegacyVariableQuery converts query like "group.host.app.item" into the variable query. Type of the query
+// is defined by the number of the parts, wildcard "*" matches all objects.
+func parseLegacyVariableQuery(query string) *VariableQuery {
+	parts := splitTemplateQuery(query)
+	for i, part := range parts {
+		if part == "*" {
+			parts[i] = matchAllFilter
+		}
+	}
+
+	part := func(i int) string {
+		if i < len(parts) {
+			return parts[i]
+		}
+		return ""
+	}
+
+	variableQuery := &VariableQuery{
+		Group:       part(0),
+		Host:        part(1),
+		Application: part(2),
+		Item:        part(3),
+	}
+
+	switch len(parts) {
+	case 1:
+		variableQuery.QueryType = VariableQueryTypeGroup
+	case 2:
+		variableQuery.QueryType = VariableQueryTypeHost
+	case 3:
+		variableQuery.QueryType = VariableQueryTypeApplication
+	case 4:
+		variableQuery.QueryType = VariableQueryTypeItem
+		// Search for all items, even if they don't belong to any application
+		if variableQuery.Application == matchAllFilter {
+			variableQuery.Application = ""
+		}
+	}
+	return variableQuery
+}
+
+// splitTemplateQuery splits legacy query to the parts:
+// group.host.app.item -> [group, host, app, item]
+// {group}{host.com} -> [group, host.com]
+func splitTemplateQuery(query string) []string {
+	if !templateQueryBracesPattern.MatchString(query) {
+		return strings.Split(query, ".")
+	}
+
+	matches := templateQuerySplitPattern.FindAllString(query, -1)
+	parts := make([]string, 0, len(matches))
+	for _, match := range matches {
+		parts = append(parts, strings.Trim(match, "{}"))
+	}
+	return parts
+}
+
+// queryVariable returns values of the template variable. Results are cached (except item values, which depend
+// on the time range), so dashboards with many variables don't filter all groups, hosts and items on each load.
+// Values are paged by the limit and offset of the query.
+func (ds *ZabbixDatasourceInstance) queryVariable(ctx context.Context, query *VariableQuery, timeRange backend.TimeRange) ([]MetricFindValue, error) {
+	filters := &queryFilters{hosts: newHostFilters(QueryOptions{HostTags: query.HostTags, HostTagsEvalType: query.HostTagsEvalType})}
+	page := Page{Limit: query.Limit, Offset: query.Offset}
+	if query.QueryType == VariableQueryTypeItemValues {
+		values, err := ds.getItemValues(ctx, filters, query, timeRange)
+		if err != nil {
+			return nil, err
+		}
+		start, end := page.bounds(len(values))
+		return values[start:end], nil
+	}
+
+	cacheKey, _ := json.Marshal(query)
+	if values, ok := ds.queryCache.GetVariable(string(cacheKey)); ok {
+		return values, nil
+	}
+
+	var objects []DiscoveredObject
+	switch query.QueryType {
+	case VariableQueryTypeGroup:
+		groups, _, err := ds.getGroupsPage(ctx, filters, page, query.Group)
+		if err != nil {
+			return nil, err
+		}
+		objects = discoveredObjects(groups, "groupid")
+	case VariableQueryTypeHost:
+		hosts, _, err := ds.getHostsPage(ctx, filters, page, query.Group, query.Host)
+		if err != nil {
+			return nil, err
+		}
+		objects = discoveredObjects(hosts, "hostid")
+	case VariableQueryTypeApplication:
+		apps, _, err := ds.getAppsPage(ctx, filters, page, query.Group, query.Host, query.Application)
+		// Apps not supported in Zabbix 5.4 and higher
+		if err != nil && !errors.Is(err, zabbixapi.ErrMethodNotFound) {
+			return nil, err
+		}
+		objects = discoveredObjects(apps, "applicationid")
+	case VariableQueryTypeItem:
+		items, err := ds.getItems(ctx, filters, query.Group, query.Host, query.Application, query.Item, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			objects = append(objects, DiscoveredObject{Name: item.ExpandItem()})
+		}
+		// Items of different hosts have the same names, so they're paged by the distinct names
+		objects = uniqueByName(objects)
+		start, end := page.bounds(len(objects))
+		objects = objects[start:end]
+	}
+
+	values := metricFindValues(uniqueByName(objects))
+	ds.queryCache.SetVariable(string(cacheKey), values)
+	return values, nil
+}
+
+// getItemValues returns distinct history values of the items in the time range
+func (ds *ZabbixDatasourceInstance) getItemValues(ctx context.Context, filters *queryFilters, query *VariableQuery, timeRange backend.TimeRange) ([]MetricFindValue, error) {
+	items, err := ds.getItems(ctx, filters, query.Group, query.Host, query.Application, query.Item, "")
+	if err != nil {
+		return nil, err
+	}
+
+	groupedItems := map[int][]string{}
+	for _, item := range items {
+		groupedItems[item.ValueType] = append(groupedItems[item.ValueType], item.ID)
+	}
+	valueTypes := make([]int, 0, len(groupedItems))
+	for valueType := range groupedItems {
+		valueTypes = append(valueTypes, valueType)
+	}
+	sort.Ints(valueTypes)
+
+	values := []MetricFindValue{}
+	seen := map[string]bool{}
+	for _, valueType := range valueTypes {
+		valueType := valueType
+		response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "history.get", Params: ZabbixAPIParams{
+			"output":    "extend",
+			"sortfield": "clock",
+			"sortorder": "ASC",
+			"history":   &valueType,
+			"itemids":   groupedItems[valueType],
+			"time_from": timeRange.From.Unix(),
+			"time_till": timeRange.To.Unix(),
+		}})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, point := range response.MustArray() {
+			point, ok := point.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			value, ok := point["value"].(string)
+			if !ok || seen[value] {
+				continue
+			}
+			seen[value] = true
+			values = append(values, MetricFindValue{Text: value})
+		}
+	}
+	return values, nil
+}
+
+func metricFindValues(objects []DiscoveredObject) []MetricFindValue {
+	values := make([]MetricFindValue, 0, len(objects))
+	for _, object := range objects {
+		values = append(values, MetricFindValue{Text: object.Name})
+	}
+	return values
+}
+
+// resourceTimeRange returns time range of the resource request (in ms), or the last 2 hours if it's not set
+func resourceTimeRange(from int64, to int64) backend.TimeRange {
+	if from == 0 || to == 0 {
+		now := time.Now()
+		return backend.TimeRange{From: now.Add(-defaultResourceTimeRange), To: now}
+	}
+	return backend.TimeRange{
+		From: time.Unix(0, from*int64(time.Millisecond)),
+		To:   time.Unix(0, to*int64(time.Millisecond)),
+	}
+}
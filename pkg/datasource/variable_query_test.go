@@ -0,0 +1,166 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVariableQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  *VariableQuery
+	}{
+		{
+			name:  "Groups",
+			query: `"*"`,
+			want:  &VariableQuery{QueryType: VariableQueryTypeGroup, Group: "/.*/"},
+		},
+		{
+			name:  "Hosts",
+			query: `"Linux servers.*"`,
+			want:  &VariableQuery{QueryType: VariableQueryTypeHost, Group: "Linux servers", Host: "/.*/"},
+		},
+		{
+			name:  "Applications",
+			query: `"*.web01.*"`,
+			want:  &VariableQuery{QueryType: VariableQueryTypeApplication, Group: "/.*/", Host: "web01", Application: "/.*/"},
+		},
+		{
+			name:  "Items of any application",
+			query: `"*.web01.*.CPU*"`,
+			want:  &VariableQuery{QueryType: VariableQueryTypeItem, Group: "/.*/", Host: "web01", Item: "CPU*"},
+		},
+		{
+			name:  "Braces",
+			query: `"{Linux servers}{web01.example.com}{/.*/}"`,
+			want:  &VariableQuery{QueryType: VariableQueryTypeApplication, Group: "Linux servers", Host: "web01.example.com", Application: "/.*/"},
+		},
+		{
+			name:  "Too many parts",
+			query: `"a.b.c.d.e"`,
+			want:  &VariableQuery{Group: "a", Host: "b", Application: "c", Item: "d"},
+		},
+		{
+			name:  "Query object",
+			query: `{"queryType":"itemValues","group":"/.*/","host":"web01","application":"","item":"Version"}`,
+			want:  &VariableQuery{QueryType: VariableQueryTypeItemValues, Group: "/.*/", Host: "web01", Item: "Version"},
+		},
+		{
+			name:  "Host tags",
+			query: `{"queryType":"host","group":"/.*/","host":"/.*/","hostTags":[{"tag":"env","operator":"equals","value":"prod"}]}`,
+			want: &VariableQuery{
+				QueryType: VariableQueryTypeHost,
+				Group:     "/.*/",
+				Host:      "/.*/",
+				HostTags:  []TagFilter{{Tag: "env", Operator: TagOperatorEquals, Value: "prod"}},
+			},
+		},
+		{
+			name:  "Page",
+			query: `{"queryType":"host","group":"/.*/","host":"/.*/","limit":100,"offset":200}`,
+			want:  &VariableQuery{QueryType: VariableQueryTypeHost, Group: "/.*/", Host: "/.*/", Limit: 100, Offset: 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parseVariableQuery(json.RawMessage(tt.query))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, query)
+		})
+	}
+
+	_, err := parseVariableQuery(json.RawMessage(`[1]`))
+	assert.Error(t, err)
+
+	_, err = parseVariableQuery(json.RawMessage(`{"queryType":"host","hostTags":[{"tag":"env","operator":"like"}]}`))
+	assert.EqualError(t, err, "invalid host tags: unsupported tag operator: like")
+
+	_, err = parseVariableQuery(json.RawMessage(`{"queryType":"host","limit":-1}`))
+	assert.EqualError(t, err, "limit and offset must not be negative")
+}
+
+func TestQueryVariableHostTags(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"groupid":"1","hostid":"10","name":"web01"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	ctx, calls := withAPICallsRecorder(context.Background())
+
+	query := &VariableQuery{QueryType: VariableQueryTypeHost, Group: "/.*/", Host: "/.*/", HostTags: []TagFilter{{Tag: "env", Value: "prod"}}}
+	values, err := dsInstance.queryVariable(ctx, query, backend.TimeRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []MetricFindValue{{Text: "web01"}}, values)
+
+	var hostParams string
+	for _, call := range calls.Calls() {
+		if call.Method == "host.get" {
+			hostParams = call.Params
+		}
+	}
+	assert.Contains(t, hostParams, `"tags":[{"tag":"env","value":"prod"}]`)
+}
+
+func TestQueryVariable(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"groupid":"1","name":"Linux servers"},{"groupid":"2","name":"Windows servers"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+
+	values, err := dsInstance.queryVariable(context.Background(), &VariableQuery{QueryType: VariableQueryTypeGroup, Group: "/Linux/"}, backend.TimeRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []MetricFindValue{{Text: "Linux servers"}}, values)
+
+	values, err = dsInstance.queryVariable(context.Background(), &VariableQuery{QueryType: VariableQueryTypeGroup, Group: "/.*/", Limit: 1, Offset: 1}, backend.TimeRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []MetricFindValue{{Text: "Windows servers"}}, values)
+
+	// Groups are cached, so only hosts are requested
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"result":[{"hostid":"10","name":"web01"},{"hostid":"11","name":"web01"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	values, err = dsInstance.queryVariable(context.Background(), &VariableQuery{QueryType: VariableQueryTypeHost, Group: "/.*/", Host: "/.*/"}, backend.TimeRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []MetricFindValue{{Text: "web01"}}, values)
+
+	// Variable values are cached
+	values, err = dsInstance.queryVariable(context.Background(), &VariableQuery{QueryType: VariableQueryTypeGroup, Group: "/Linux/"}, backend.TimeRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []MetricFindValue{{Text: "Linux servers"}}, values)
+
+	// Apps aren't supported in Zabbix 5.4 and higher
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"error":{"code":-32601,"message":"Method not found.","data":"Incorrect API \"application\"."}}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	values, err = dsInstance.queryVariable(context.Background(), &VariableQuery{QueryType: VariableQueryTypeApplication, Group: "/.*/", Host: "/.*/", Application: "/.*/"}, backend.TimeRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []MetricFindValue{}, values)
+
+	values, err = dsInstance.queryVariable(context.Background(), &VariableQuery{}, backend.TimeRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []MetricFindValue{}, values)
+}
+
+func TestGetItemValues(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`{"result":[{"groupid":"1","hostid":"10","itemid":"100","name":"Version","key_":"agent.version","value_type":"1","status":"0"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	// Request groups, hosts and items, so they are cached
+	_, err := dsInstance.getItems(context.Background(), nil, "/.*/", "/.*/", "", "Version", "")
+	assert.NoError(t, err)
+
+	dsInstance = MockZabbixDataSourceResponse(dsInstance, `{"result":[{"itemid":"100","clock":"1","value":"5.0.1"},{"itemid":"100","clock":"2","value":"5.0.2"},{"itemid":"100","clock":"3","value":"5.0.1"}]}`, 200)
+	dsInstance.zabbixAPI.SetAuth("secretauth")
+	values, err := dsInstance.queryVariable(context.Background(), &VariableQuery{QueryType: VariableQueryTypeItemValues, Group: "/.*/", Host: "/.*/", Item: "Version"}, backend.TimeRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []MetricFindValue{{Text: "5.0.1"}, {Text: "5.0.2"}}, values)
+}
+
+func TestResourceTimeRange(t *testing.T) {
+	timeRange := resourceTimeRange(1600000000000, 1600003600000)
+	assert.Equal(t, int64(1600000000), timeRange.From.Unix())
+	assert.Equal(t, int64(1600003600), timeRange.To.Unix())
+
+	timeRange = resourceTimeRange(0, 0)
+	assert.Equal(t, defaultResourceTimeRange, timeRange.To.Sub(timeRange.From))
+	assert.WithinDuration(t, time.Now(), timeRange.To, time.Minute)
+}
@@ -2,11 +2,16 @@ package datasource
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/timeseries"
 	"github.com/alexanderzobnin/grafana-zabbix/pkg/zabbixapi"
 	simplejson "github.com/bitly/go-simplejson"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -14,6 +19,13 @@ import (
 	"golang.org/x/net/context"
 )
 
+// Sessions expired earlier after login are reported, since it's likely caused by the session settings of the
+// Zabbix user rather than by the idle timeout
+const shortSessionAge = 5 * time.Minute
+
+// Delay before repeating the request rate limited by Zabbix
+var tooManyRequestsRetryDelay = time.Second
+
 var CachedMethods = map[string]bool{
 	"hostgroup.get":   true,
 	"host.get":        true,
@@ -22,6 +34,7 @@ var CachedMethods = map[string]bool{
 	"service.get":     true,
 	"usermacro.get":   true,
 	"proxy.get":       true,
+	"valuemap.get":    true,
 }
 
 // ZabbixQuery handles query requests to Zabbix
@@ -29,15 +42,16 @@ func (ds *ZabbixDatasourceInstance) ZabbixQuery(ctx context.Context, apiReq *Zab
 	var resultJson *simplejson.Json
 	var err error
 
+	start := time.Now()
 	cachedResult, queryExistInCache := ds.queryCache.GetAPIRequest(apiReq)
 	if !queryExistInCache {
-		resultJson, err = ds.ZabbixRequest(ctx, apiReq.Method, apiReq.Params)
+		resultJson, err = ds.zabbixQueryNoCache(ctx, apiReq)
 		if err != nil {
 			return nil, err
 		}
 
 		if _, ok := CachedMethods[apiReq.Method]; ok {
-			ds.logger.Debug("Writing result to cache", "method", apiReq.Method)
+			requestid.Logger(ctx, ds.logger).Debug("Writing result to cache", "method", apiReq.Method)
 			ds.queryCache.SetAPIRequest(apiReq, resultJson)
 		}
 	} else {
@@ -46,11 +60,30 @@ func (ds *ZabbixDatasourceInstance) ZabbixQuery(ctx context.Context, apiReq *Zab
 		if !ok {
 			resultJson = simplejson.New()
 		}
+		call := newAPICall(apiReq, time.Since(start))
+		call.Cached = true
+		call.ResultSize = resultSize(resultJson)
+		recordAPICall(ctx, call)
 	}
 
 	return resultJson, nil
 }
 
+// zabbixQueryNoCache makes the request bypassing the query cache, for the data changing faster than the cache TTL
+func (ds *ZabbixDatasourceInstance) zabbixQueryNoCache(ctx context.Context, apiReq *ZabbixAPIRequest) (*simplejson.Json, error) {
+	start := time.Now()
+	resultJson, err := ds.ZabbixRequest(ctx, apiReq.Method, apiReq.Params)
+	call := newAPICall(apiReq, time.Since(start))
+	if err != nil {
+		call.Error = err.Error()
+		recordAPICall(ctx, call)
+		return nil, err
+	}
+	call.ResultSize = resultSize(resultJson)
+	recordAPICall(ctx, call)
+	return resultJson, nil
+}
+
 // ZabbixAPIQuery handles query requests to Zabbix API
 func (ds *ZabbixDatasourceInstance) ZabbixAPIQuery(ctx context.Context, apiReq *ZabbixAPIRequest) (*ZabbixAPIResourceResponse, error) {
 	resultJson, err := ds.ZabbixQuery(ctx, apiReq)
@@ -70,40 +103,69 @@ func BuildAPIResponse(responseData *interface{}) (*ZabbixAPIResourceResponse, er
 // TestConnection checks authentication and version of the Zabbix API and returns that info
 func (ds *ZabbixDatasourceInstance) TestConnection(ctx context.Context) (string, error) {
 	_, err := ds.getAllGroups(ctx)
-	if err != nil {
+	if errors.Is(err, zabbixapi.ErrNotAPIEndpoint) {
+		return "", fmt.Errorf("Zabbix API not found at %s, check the API path in the data source URL: %w", redactCredentials(ds.dsInfo.URL), err)
+	} else if err != nil {
 		return "", err
 	}
 
-	response, err := ds.ZabbixRequest(ctx, "apiinfo.version", ZabbixAPIParams{})
+	version, pinned, err := ds.getZabbixVersion(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	resultByte, _ := response.MarshalJSON()
+	resultByte, _ := json.Marshal(version)
+	if pinned {
+		return string(resultByte) + " (pinned in settings)", nil
+	}
 	return string(resultByte), nil
 }
 
 // ZabbixRequest checks authentication and makes a request to the Zabbix API
 func (ds *ZabbixDatasourceInstance) ZabbixRequest(ctx context.Context, method string, params ZabbixAPIParams) (*simplejson.Json, error) {
-	ds.logger.Debug("Zabbix API request", "datasource", ds.dsInfo.Name, "method", method)
+	requestid.Logger(ctx, ds.logger).Debug("Zabbix API request", "datasource", ds.dsInfo.UID, "method", method)
 	var result *simplejson.Json
 	var err error
 
 	// Skip auth for methods that are not required it
 	if method == "apiinfo.version" {
-		return ds.zabbixAPI.RequestUnauthenticated(ctx, method, params)
+		return ds.observeAPIRequest(method, func() (*simplejson.Json, error) {
+			return ds.zabbixAPI.RequestUnauthenticated(ctx, method, params)
+		})
 	}
 
-	result, err = ds.zabbixAPI.Request(ctx, method, params)
-	notAuthorized := isNotAuthorized(err)
+	request := func() (*simplejson.Json, error) {
+		return ds.zabbixAPI.Request(ctx, method, params)
+	}
+	result, err = ds.observeAPIRequest(method, request)
+	// Rate limited request is repeated once after the delay
+	if errors.Is(err, zabbixapi.ErrTooManyRequests) {
+		requestid.Logger(ctx, ds.logger).Debug("Too many requests, retrying", "method", method, "delay", tooManyRequestsRetryDelay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(tooManyRequestsRetryDelay):
+		}
+		apiRequestRetries.WithLabelValues(ds.dsInfo.UID, method, retryReasonTooManyRequests).Inc()
+		result, err = ds.observeAPIRequest(method, request)
+	}
+
+	notAuthorized := errors.Is(err, zabbixapi.ErrNotAuthorized)
 	if err == zabbixapi.ErrNotAuthenticated || notAuthorized {
 		if notAuthorized {
-			ds.logger.Debug("Authentication token expired, performing re-login")
+			sessionRelogins.WithLabelValues(ds.dsInfo.UID).Inc()
+			if age, ok := ds.apiState.sessionAge(); ok && age < shortSessionAge {
+				requestid.Logger(ctx, ds.logger).Warn("Zabbix session expired shortly after login, check session settings of the Zabbix user",
+					"sessionAge", age.Round(time.Second).String())
+			} else {
+				requestid.Logger(ctx, ds.logger).Debug("Authentication token expired, performing re-login")
+			}
 		}
 		err = ds.login(ctx)
 		if err != nil {
 			return nil, err
 		}
+		apiRequestRetries.WithLabelValues(ds.dsInfo.UID, method, retryReasonRelogin).Inc()
 		return ds.ZabbixRequest(ctx, method, params)
 	} else if err != nil {
 		return nil, err
@@ -113,26 +175,24 @@ func (ds *ZabbixDatasourceInstance) ZabbixRequest(ctx context.Context, method st
 }
 
 func (ds *ZabbixDatasourceInstance) login(ctx context.Context) error {
-	jsonData, err := simplejson.NewJson(ds.dsInfo.JSONData)
-	if err != nil {
-		return err
-	}
+	zabbixLogin := ds.Settings.Username
+	zabbixPassword := ds.Settings.Password
 
-	zabbixLogin := jsonData.Get("username").MustString()
-	var zabbixPassword string
-	if securePassword, exists := ds.dsInfo.DecryptedSecureJSONData["password"]; exists {
-		zabbixPassword = securePassword
-	} else {
-		// Fallback
-		zabbixPassword = jsonData.Get("password").MustString()
-	}
-
-	err = ds.zabbixAPI.Authenticate(ctx, zabbixLogin, zabbixPassword)
+	loginAttempts.WithLabelValues(ds.dsInfo.UID).Inc()
+	_, err := ds.observeAPIRequest("user.login", func() (*simplejson.Json, error) {
+		return nil, ds.zabbixAPI.Authenticate(ctx, zabbixLogin, zabbixPassword)
+	})
 	if err != nil {
-		ds.logger.Error("Zabbix authentication error", "error", err)
+		reason := loginFailureReason(err)
+		loginFailures.WithLabelValues(ds.dsInfo.UID, reason).Inc()
+		failures := ds.apiState.setLoginFailed()
+		requestid.Logger(ctx, ds.logger).Warn("Zabbix login failed", "user", zabbixLogin, "reason", reason,
+			"consecutiveFailures", failures, "error", err)
 		return err
 	}
-	ds.logger.Debug("Successfully authenticated", "url", ds.zabbixAPI.GetUrl().String(), "user", zabbixLogin)
+	ds.apiState.setAuthenticated()
+	lastLoginSuccess.WithLabelValues(ds.dsInfo.UID).SetToCurrentTime()
+	requestid.Logger(ctx, ds.logger).Debug("Successfully authenticated", "url", ds.zabbixAPI.GetUrl().String(), "user", zabbixLogin)
 
 	return nil
 }
@@ -143,11 +203,15 @@ func (ds *ZabbixDatasourceInstance) queryNumericItems(ctx context.Context, query
 	appFilter := query.Application.Filter
 	itemFilter := query.Item.Filter
 
-	items, err := ds.getItems(ctx, groupFilter, hostFilter, appFilter, itemFilter, "num")
+	items, err := ds.getItems(ctx, newQueryFilters(query), groupFilter, hostFilter, appFilter, itemFilter, "num")
 	if err != nil {
 		return nil, err
 	}
 
+	if query.Options.UseLastValue {
+		return ds.queryLastValues(ctx, query, items)
+	}
+
 	frames, err := ds.queryNumericDataForItems(ctx, query, items)
 	if err != nil {
 		return nil, err
@@ -156,33 +220,115 @@ func (ds *ZabbixDatasourceInstance) queryNumericItems(ctx context.Context, query
 	return frames, nil
 }
 
-func (ds *ZabbixDatasourceInstance) getItems(ctx context.Context, groupFilter string, hostFilter string, appFilter string, itemFilter string, itemType string) (Items, error) {
-	hosts, err := ds.getHosts(ctx, groupFilter, hostFilter)
+// queryLastValues returns frame with the last values of the items
+func (ds *ZabbixDatasourceInstance) queryLastValues(ctx context.Context, query *QueryModel, items Items) (*data.Frame, error) {
+	items, err := ds.getLastValues(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	series := convertLastValuesToTimeSeries(items)
+	return ds.processSeriesData(ctx, query, items, series, false, ds.getConsolidateBy(query))
+}
+
+// lastValuesRequest returns item.get request of the items last values
+func lastValuesRequest(items Items) *ZabbixAPIRequest {
+	itemids := make([]string, 0, len(items))
+	for _, item := range items {
+		itemids = append(itemids, item.ID)
+	}
+	return &ZabbixAPIRequest{Method: "item.get", Params: ZabbixAPIParams{
+		"output":  []string{"itemid", "lastvalue", "lastclock", "lastns"},
+		"itemids": itemids,
+	}}
+}
+
+// getLastValues returns items with the current last values. Items are resolved by item.get cached for the cache
+// TTL, so last values are requested bypassing the cache.
+func (ds *ZabbixDatasourceInstance) getLastValues(ctx context.Context, items Items) (Items, error) {
+	if len(items) == 0 {
+		return items, nil
+	}
+	response, err := ds.zabbixQueryNoCache(ctx, lastValuesRequest(items))
+	if err != nil {
+		return nil, err
+	}
+
+	responseJSON, err := response.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("Internal error parsing response JSON: %w", err)
+	}
+
+	var lastValues Items
+	err = json.Unmarshal(responseJSON, &lastValues)
 	if err != nil {
 		return nil, err
 	}
+	lastValueByID := make(map[string]Item, len(lastValues))
+	for _, item := range lastValues {
+		lastValueByID[item.ID] = item
+	}
+
+	result := make(Items, 0, len(items))
+	for _, item := range items {
+		lastValue := lastValueByID[item.ID]
+		item.LastValue = lastValue.LastValue
+		item.LastClock = lastValue.LastClock
+		item.LastNS = lastValue.LastNS
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+func (ds *ZabbixDatasourceInstance) getItems(ctx context.Context, filters *queryFilters, groupFilter string, hostFilter string, appFilter string, itemFilter string, itemType string) (Items, error) {
+	items, _, err := ds.getItemsPage(ctx, filters, Page{}, groupFilter, hostFilter, appFilter, itemFilter, itemType)
+	return items, err
+}
+
+// getItemsPage returns page of the items matching the filters and total number of the matched items. If the
+// filters match all items of the hosts, limit of the page is passed to the API and the total is counted by the
+// API, so large installs don't return all items for each page.
+func (ds *ZabbixDatasourceInstance) getItemsPage(ctx context.Context, filters *queryFilters, page Page, groupFilter string, hostFilter string, appFilter string, itemFilter string, itemType string) (Items, int, error) {
+	hosts, err := ds.getHosts(ctx, filters, groupFilter, hostFilter)
+	if err != nil {
+		return nil, 0, err
+	}
 	var hostids []string
 	for _, k := range hosts {
 		hostids = append(hostids, k["hostid"].(string))
 	}
 
-	apps, err := ds.getApps(ctx, groupFilter, hostFilter, appFilter)
+	apps, err := ds.getApps(ctx, filters, groupFilter, hostFilter, appFilter)
 	// Apps not supported in Zabbix 5.4 and higher
-	if isAppMethodNotFoundError(err) {
+	if errors.Is(err, zabbixapi.ErrMethodNotFound) {
 		apps = []map[string]interface{}{}
 	} else if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	var appids []string
 	for _, l := range apps {
 		appids = append(appids, l["applicationid"].(string))
 	}
+	if len(hostids) > 0 {
+		appids = nil
+	}
 
+	limit := 0
+	if page.Limit > 0 && isMatchAllFilter(itemFilter) && filters.matchesAllItems() {
+		limit = page.Offset + page.Limit
+	}
 	var allItems *simplejson.Json
-	if len(hostids) > 0 {
-		allItems, err = ds.getAllItems(ctx, hostids, nil, itemType)
-	} else if len(appids) > 0 {
-		allItems, err = ds.getAllItems(ctx, nil, appids, itemType)
+	total := 0
+	if len(hostids) > 0 || len(appids) > 0 {
+		allItems, err = ds.getAllItems(ctx, filters, hostids, appids, itemType, limit)
+		if err != nil {
+			return nil, 0, err
+		}
+		if limit > 0 {
+			total, err = ds.countAllItems(ctx, filters, hostids, appids, itemType)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
 	}
 
 	var items Items
@@ -192,38 +338,57 @@ func (ds *ZabbixDatasourceInstance) getItems(ctx context.Context, groupFilter st
 	} else {
 		itemsJSON, err := allItems.MarshalJSON()
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
 		err = json.Unmarshal(itemsJSON, &items)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 	}
 
-	re, err := parseFilter(itemFilter)
+	nameFilter, err := newNameFilter(itemFilter)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
+	names := make([]string, 0, len(items))
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.ExpandItem())
+		keys = append(keys, item.Key)
+	}
+	nameFilter = nameFilter.forNames(names)
+	isExcludedName := filters.excluder(filterLevelItem, names)
+	isExcludedKey := filters.excluder(filterLevelItemKey, keys)
+	matchItemKey := filters.itemKeyMatcher(keys)
 
 	filteredItems := Items{}
-	for _, item := range items {
-		itemName := item.ExpandItem()
-		if item.Status == "0" {
-			if re != nil {
-				if re.MatchString(itemName) {
-					filteredItems = append(filteredItems, item)
-				}
-			} else if itemName == itemFilter {
-				filteredItems = append(filteredItems, item)
-			}
+	unsupportedItems := Items{}
+	for i, item := range items {
+		itemName := names[i]
+		if isExcludedName(itemName) || isExcludedKey(item.Key) || !matchItemKey(item.Key) {
+			continue
+		}
+		if !nameFilter.match(itemName) {
+			continue
 		}
+
+		if item.State == ItemStateNotSupported {
+			unsupportedItems = append(unsupportedItems, item)
+		}
+		filteredItems = append(filteredItems, item)
+	}
+
+	addItemsNotice(ctx, data.NoticeSeverityWarning, unsupportedItems, "not supported by Zabbix and may have no data")
+	if limit == 0 {
+		total = len(filteredItems)
 	}
-	return filteredItems, nil
+	start, end := page.bounds(len(filteredItems))
+	return filteredItems[start:end], total, nil
 }
 
-func (ds *ZabbixDatasourceInstance) getApps(ctx context.Context, groupFilter string, hostFilter string, appFilter string) ([]map[string]interface{}, error) {
-	hosts, err := ds.getHosts(ctx, groupFilter, hostFilter)
+func (ds *ZabbixDatasourceInstance) getApps(ctx context.Context, filters *queryFilters, groupFilter string, hostFilter string, appFilter string) ([]map[string]interface{}, error) {
+	hosts, err := ds.getHosts(ctx, filters, groupFilter, hostFilter)
 	if err != nil {
 		return nil, err
 	}
@@ -236,87 +401,167 @@ func (ds *ZabbixDatasourceInstance) getApps(ctx context.Context, groupFilter str
 		return nil, err
 	}
 
-	re, err := parseFilter(appFilter)
+	nameFilter, err := newNameFilter(appFilter)
 	if err != nil {
 		return nil, err
 	}
+	names := objectNames(allApps)
+	nameFilter = nameFilter.forNames(names)
+	isExcluded := filters.excluder(filterLevelApplication, names)
 
 	var apps []map[string]interface{}
-	for _, i := range allApps.MustArray() {
-		name := i.(map[string]interface{})["name"].(string)
-		if re != nil {
-			if re.MatchString(name) {
-				apps = append(apps, i.(map[string]interface{}))
-			}
-		} else if name == appFilter {
-			apps = append(apps, i.(map[string]interface{}))
+	for i, app := range allApps.MustArray() {
+		if !isExcluded(names[i]) && nameFilter.match(names[i]) {
+			apps = append(apps, app.(map[string]interface{}))
 		}
 	}
 	return apps, nil
 }
 
-func (ds *ZabbixDatasourceInstance) getHosts(ctx context.Context, groupFilter string, hostFilter string) ([]map[string]interface{}, error) {
-	groups, err := ds.getGroups(ctx, groupFilter)
+// getAppsPage returns page of the applications matching the filters with distinct names (applications with the
+// same name on different hosts are returned once) and total number of the distinct names. Names are made
+// distinct after the request, so the limit isn't passed to the API.
+func (ds *ZabbixDatasourceInstance) getAppsPage(ctx context.Context, filters *queryFilters, page Page, groupFilter string, hostFilter string, appFilter string) ([]map[string]interface{}, int, error) {
+	apps, err := ds.getApps(ctx, filters, groupFilter, hostFilter, appFilter)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	seen := map[string]bool{}
+	unique := make([]map[string]interface{}, 0, len(apps))
+	for _, app := range apps {
+		name, _ := app["name"].(string)
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, app)
+		}
+	}
+	start, end := page.bounds(len(unique))
+	return unique[start:end], len(unique), nil
+}
+
+func (ds *ZabbixDatasourceInstance) getHosts(ctx context.Context, filters *queryFilters, groupFilter string, hostFilter string) ([]map[string]interface{}, error) {
+	hosts, _, err := ds.getHostsPage(ctx, filters, Page{}, groupFilter, hostFilter)
+	return hosts, err
+}
+
+// getHostsPage returns page of the hosts matching the filters and total number of the matched hosts. Like
+// getItemsPage, limit is passed to the API if the filters match all hosts of the groups.
+func (ds *ZabbixDatasourceInstance) getHostsPage(ctx context.Context, filters *queryFilters, page Page, groupFilter string, hostFilter string) ([]map[string]interface{}, int, error) {
+	groups, err := ds.getGroups(ctx, filters, groupFilter)
+	if err != nil {
+		return nil, 0, err
 	}
 	var groupids []string
 	for _, k := range groups {
 		groupids = append(groupids, k["groupid"].(string))
 	}
-	allHosts, err := ds.getAllHosts(ctx, groupids)
+
+	limit := 0
+	total := 0
+	if page.Limit > 0 && isMatchAllFilter(hostFilter) && filters.matchesAllHosts() {
+		limit = page.Offset + page.Limit
+		total, err = ds.countAllHosts(ctx, filters, groupids)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	allHosts, err := ds.getAllHosts(ctx, filters, groupids, limit)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	re, err := parseFilter(hostFilter)
+	nameFilter, err := newNameFilter(hostFilter)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
+	names := objectNames(allHosts)
+	nameFilter = nameFilter.forNames(names)
+	isExcluded := filters.excluder(filterLevelHost, names)
 
 	var hosts []map[string]interface{}
-	for _, i := range allHosts.MustArray() {
-		name := i.(map[string]interface{})["name"].(string)
-		if re != nil {
-			if re.MatchString(name) {
-				hosts = append(hosts, i.(map[string]interface{}))
-			}
-		} else if name == hostFilter {
-			hosts = append(hosts, i.(map[string]interface{}))
+	for i, host := range allHosts.MustArray() {
+		if isExcluded(names[i]) || !filters.matchHostFilters(host.(map[string]interface{})) {
+			continue
+		}
+		if nameFilter.match(names[i]) {
+			hosts = append(hosts, host.(map[string]interface{}))
 		}
-
 	}
 
-	return hosts, nil
+	if limit == 0 {
+		total = len(hosts)
+	}
+	start, end := page.bounds(len(hosts))
+	return hosts[start:end], total, nil
 }
 
-func (ds *ZabbixDatasourceInstance) getGroups(ctx context.Context, groupFilter string) ([]map[string]interface{}, error) {
+func (ds *ZabbixDatasourceInstance) getGroups(ctx context.Context, filters *queryFilters, groupFilter string) ([]map[string]interface{}, error) {
 	allGroups, err := ds.getAllGroups(ctx)
 	if err != nil {
 		return nil, err
 	}
-	re, err := parseFilter(groupFilter)
+	nameFilter, err := newNameFilter(groupFilter)
 	if err != nil {
 		return nil, err
 	}
+	names := objectNames(allGroups)
+	nameFilter = nameFilter.forNames(names)
+	isExcluded := filters.excluder(filterLevelGroup, names)
+	parent, isPrefix := hostGroupPrefix(groupFilter)
+	isPrefix = isPrefix && nameFilter.re != nil
 
 	var groups []map[string]interface{}
-	for _, i := range allGroups.MustArray() {
-		name := i.(map[string]interface{})["name"].(string)
-		if re != nil {
-			if re.MatchString(name) {
-				groups = append(groups, i.(map[string]interface{}))
-			}
-		} else if name == groupFilter {
-			groups = append(groups, i.(map[string]interface{}))
+	for i, group := range allGroups.MustArray() {
+		if isExcluded(names[i]) {
+			continue
+		}
+		if nameFilter.match(names[i]) || isPrefix && names[i] == parent {
+			groups = append(groups, group.(map[string]interface{}))
 		}
 	}
 	return groups, nil
 }
 
-func (ds *ZabbixDatasourceInstance) getAllItems(ctx context.Context, hostids []string, appids []string, itemtype string) (*simplejson.Json, error) {
+// getGroupsPage returns page of the host groups matching the filters and total number of the matched groups.
+// Groups are filtered with their nested groups after the request, so the limit isn't passed to the API.
+func (ds *ZabbixDatasourceInstance) getGroupsPage(ctx context.Context, filters *queryFilters, page Page, groupFilter string) ([]map[string]interface{}, int, error) {
+	groups, err := ds.getGroups(ctx, filters, groupFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	start, end := page.bounds(len(groups))
+	return groups[start:end], len(groups), nil
+}
+
+// objectNames returns names of the objects from the API response
+func objectNames(objects *simplejson.Json) []string {
+	array := objects.MustArray()
+	names := make([]string, 0, len(array))
+	for _, object := range array {
+		name, _ := object.(map[string]interface{})["name"].(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+func (ds *ZabbixDatasourceInstance) getAllItems(ctx context.Context, filters *queryFilters, hostids []string, appids []string, itemtype string, limit int) (*simplejson.Json, error) {
+	params := allItemsParams(filters, hostids, appids, itemtype)
+	if limit > 0 {
+		params["limit"] = limit
+	}
+	return ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "item.get", Params: params})
+}
+
+// countAllItems returns number of the items requested by getAllItems without the limit
+func (ds *ZabbixDatasourceInstance) countAllItems(ctx context.Context, filters *queryFilters, hostids []string, appids []string, itemtype string) (int, error) {
+	params := allItemsParams(filters, hostids, appids, itemtype)
+	return ds.countObjects(ctx, "item.get", params)
+}
+
+func allItemsParams(filters *queryFilters, hostids []string, appids []string, itemtype string) ZabbixAPIParams {
 	params := ZabbixAPIParams{
-		"output":         []string{"itemid", "name", "key_", "value_type", "hostid", "status", "state"},
+		"output":         []string{"itemid", "name", "key_", "value_type", "hostid", "status", "state", "error", "delay", "valuemapid", "lastvalue", "lastclock", "lastns"},
 		"sortfield":      "name",
 		"webitems":       true,
 		"filter":         map[string]interface{}{},
@@ -326,16 +571,24 @@ func (ds *ZabbixDatasourceInstance) getAllItems(ctx context.Context, hostids []s
 	}
 
 	filter := params["filter"].(map[string]interface{})
+	// Enabled items of the monitored hosts only, unless disabled items are requested by the query
+	if filters.isMonitoredOnly() {
+		params["monitored"] = true
+	}
 	if itemtype == "num" {
 		filter["value_type"] = []int{0, 3}
 	} else if itemtype == "text" {
 		filter["value_type"] = []int{1, 2, 4}
 	}
-
-	return ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "item.get", Params: params})
+	return params
 }
 
 func (ds *ZabbixDatasourceInstance) getAllApps(ctx context.Context, hostids []string) (*simplejson.Json, error) {
+	// Applications are removed in Zabbix 5.4, skip the request failing anyway
+	if supported, ok := pinnedCapability(ds.Settings, "applications"); ok && !supported {
+		return nil, zabbixapi.ErrMethodNotFound
+	}
+
 	params := ZabbixAPIParams{
 		"output":  "extend",
 		"hostids": hostids,
@@ -344,14 +597,51 @@ func (ds *ZabbixDatasourceInstance) getAllApps(ctx context.Context, hostids []st
 	return ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "application.get", Params: params})
 }
 
-func (ds *ZabbixDatasourceInstance) getAllHosts(ctx context.Context, groupids []string) (*simplejson.Json, error) {
+func (ds *ZabbixDatasourceInstance) getAllHosts(ctx context.Context, filters *queryFilters, groupids []string, limit int) (*simplejson.Json, error) {
+	params := allHostsParams(filters, groupids)
+	if limit > 0 {
+		params["limit"] = limit
+	}
+	return ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "host.get", Params: params})
+}
+
+// countAllHosts returns number of the hosts requested by getAllHosts without the limit
+func (ds *ZabbixDatasourceInstance) countAllHosts(ctx context.Context, filters *queryFilters, groupids []string) (int, error) {
+	return ds.countObjects(ctx, "host.get", allHostsParams(filters, groupids))
+}
+
+func allHostsParams(filters *queryFilters, groupids []string) ZabbixAPIParams {
 	params := ZabbixAPIParams{
 		"output":    []string{"name", "host"},
 		"sortfield": "name",
 		"groupids":  groupids,
 	}
+	if filters.isMonitoredOnly() {
+		params["monitored_hosts"] = true
+	}
+	filters.setHostFiltersParams(params)
+	return params
+}
 
-	return ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "host.get", Params: params})
+// countObjects returns number of the objects requested with the params, counted by the API
+func (ds *ZabbixDatasourceInstance) countObjects(ctx context.Context, method string, params ZabbixAPIParams) (int, error) {
+	countParams := ZabbixAPIParams{"countOutput": true}
+	for name, value := range params {
+		switch name {
+		case "output", "sortfield", "selectHosts":
+		default:
+			countParams[name] = value
+		}
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: method, Params: countParams})
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.Atoi(response.MustString())
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s count: %w", method, err)
+	}
+	return count, nil
 }
 
 func (ds *ZabbixDatasourceInstance) getAllGroups(ctx context.Context) (*simplejson.Json, error) {
@@ -368,17 +658,336 @@ func (ds *ZabbixDatasourceInstance) queryNumericDataForItems(ctx context.Context
 	valueType := ds.getTrendValueType(query)
 	consolidateBy := ds.getConsolidateBy(query)
 
+	// consolidateBy() overrides trendValue() and selects trend column too
 	if consolidateBy == "" {
 		consolidateBy = valueType
+	} else {
+		valueType = consolidateBy
+	}
+
+	err := applyFunctionsPre(query)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline, err := getBaseline(query.Functions)
+	if err != nil {
+		return nil, err
+	}
+	if baseline != nil {
+		return ds.queryNumericDataWithBaseline(ctx, query, items, baseline, valueType, consolidateBy)
+	}
+
+	downsampling := newDBDownsampling(query, consolidateBy)
+	series, useTrend, err := ds.getNumericSeries(ctx, noDataFetchRange(query), items, valueType, downsampling)
+	if err != nil {
+		return nil, err
 	}
+	return ds.processSeriesData(ctx, query, items, series, useTrend, consolidateBy)
+}
 
-	history, err := ds.getHistotyOrTrend(ctx, query, items)
+// queryNumericDataWithBaseline fetches data for the query time range and the baseline period shifted one
+// in parallel, and adds baseline series as companions of the query series.
+func (ds *ZabbixDatasourceInstance) queryNumericDataWithBaseline(ctx context.Context, query *QueryModel, items Items, baseline *baselineParams, valueType string, consolidateBy string) (*data.Frame, error) {
+	baselineRange := backend.TimeRange{
+		From: query.TimeRange.From.Add(-baseline.period),
+		To:   query.TimeRange.To.Add(-baseline.period),
+	}
+
+	var wg sync.WaitGroup
+	var baselineSeries []*timeseries.TimeSeriesData
+	var baselineUseTrend bool
+	var baselineErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		baselineSeries, baselineUseTrend, baselineErr = ds.getNumericSeries(ctx, baselineRange, items, valueType, nil)
+	}()
+
+	series, useTrend, err := ds.getNumericSeries(ctx, noDataFetchRange(query), items, valueType, nil)
+	wg.Wait()
 	if err != nil {
 		return nil, err
 	}
+	if baselineErr != nil {
+		return nil, baselineErr
+	}
 
-	frame := convertHistory(history, items)
-	return frame, nil
+	series, err = ds.transformSeriesData(ctx, query, items, series, useTrend)
+	if err != nil {
+		return nil, err
+	}
+	baselineSeries, err = ds.transformSeriesData(ctx, query, items, baselineSeries, baselineUseTrend)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range baselineSeries {
+		s.TS = s.TS.ShiftTime(baseline.period)
+	}
+	series = addBaselineSeries(series, baselineSeries, baseline.mode)
+	return buildResultFrame(series, query, consolidateBy)
+}
+
+// getNumericSeries fetches history or trends (or both, stitched) of the items for the time range
+// depending on the datasource trends settings. Values are downsampled by the database if downsampling is set
+// and reduces the number of values. Returns series and whether they're built from trends only.
+func (ds *ZabbixDatasourceInstance) getNumericSeries(ctx context.Context, timeRange backend.TimeRange, items Items, valueType string, downsampling *dbDownsampling) ([]*timeseries.TimeSeriesData, bool, error) {
+	if trendsTill, ok := ds.getTrendsStitchTime(timeRange); ok {
+		series, err := ds.getStitchedTrendAndHistory(ctx, timeRange, items, trendsTill, valueType)
+		addNotice(ctx, data.NoticeSeverityInfo, fmt.Sprintf("Trends (%s values) are used for data older than %s", valueType, trendsTill.UTC().Format(time.RFC3339)))
+		return series, false, err
+	}
+
+	useTrend := ds.isUseTrend(timeRange)
+	downsampling = ds.getDBDownsampling(downsampling, timeRange, items, useTrend)
+	downsampled := false
+	var history History
+	var err error
+	if downsampling != nil {
+		// Downsampled values aren't cached since they can't be merged with the raw ones
+		history, downsampled, err = ds.getHistoryOrTrendWithFallback(ctx, timeRange, items, useTrend, valueType, downsampling)
+		if err == nil && !downsampled {
+			history, err = ds.getHistoryOrTrendFromAPI(ctx, timeRange, items, useTrend, valueType)
+		}
+	} else if useTrend {
+		history, err = ds.getHistotyOrTrend(ctx, timeRange, items, useTrend, valueType)
+	} else {
+		history, err = ds.getHistoryIncremental(ctx, timeRange, items)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if useTrend {
+		addNotice(ctx, data.NoticeSeverityInfo, fmt.Sprintf("Trends (%s values) are used instead of history for the time range", valueType))
+	}
+
+	series := convertHistoryToTimeSeries(history, items)
+	if downsampled {
+		// Series are aligned to the buckets instead of the item update or trends interval
+		for _, s := range series {
+			s.Meta.Interval = timeseries.FixedInterval(downsampling.interval)
+		}
+		return series, false, nil
+	}
+	return series, useTrend, nil
+}
+
+// getStitchedTrendAndHistory fetches trends for the part of the time range older than trendsTill and history
+// for the recent part, and joins them into one series per item.
+func (ds *ZabbixDatasourceInstance) getStitchedTrendAndHistory(ctx context.Context, timeRange backend.TimeRange, items Items, trendsTill time.Time, trendValueType string) ([]*timeseries.TimeSeriesData, error) {
+	trendRange := backend.TimeRange{From: timeRange.From, To: trendsTill.Add(-time.Second)}
+	trend, err := ds.getHistotyOrTrend(ctx, trendRange, items, true, trendValueType)
+	if err != nil {
+		return nil, err
+	}
+
+	historyRange := backend.TimeRange{From: trendsTill, To: timeRange.To}
+	history, err := ds.getHistotyOrTrend(ctx, historyRange, items, false, trendValueType)
+	if err != nil {
+		return nil, err
+	}
+
+	trendSeries := convertHistoryToTimeSeries(trend, items)
+	historySeries := convertHistoryToTimeSeries(history, items)
+	return stitchTrendAndHistory(trendSeries, historySeries, trendsTill), nil
+}
+
+// processSeriesData transforms the series fetched for the items (see transformSeriesData) and builds result data frame.
+func (ds *ZabbixDatasourceInstance) processSeriesData(ctx context.Context, query *QueryModel, items Items, series []*timeseries.TimeSeriesData, useTrend bool, consolidateBy string) (*data.Frame, error) {
+	series, err := ds.transformSeriesData(ctx, query, items, series, useTrend)
+	if err != nil {
+		return nil, err
+	}
+	return buildResultFrame(series, query, consolidateBy)
+}
+
+// buildResultFrame converts processed series into the frame of the query result format. Time series
+// are downsampled to the query max data points.
+func buildResultFrame(series []*timeseries.TimeSeriesData, query *QueryModel, consolidateBy string) (*data.Frame, error) {
+	switch query.ResultFormat {
+	case ResultFormatTable:
+		return convertSeriesToTable(series, query.Options), nil
+	case ResultFormatHeatmap:
+		return convertSeriesToHeatmap(series, query), nil
+	}
+
+	series, err := downsampleSeries(series, query, consolidateBy)
+	if err != nil {
+		return nil, err
+	}
+	return convertSeriesToFrame(series, query.Options), nil
+}
+
+// transformSeriesData applies value mappings, data alignment and query functions to the series fetched for the items.
+func (ds *ZabbixDatasourceInstance) transformSeriesData(ctx context.Context, query *QueryModel, items Items, series []*timeseries.TimeSeriesData, useTrend bool) ([]*timeseries.TimeSeriesData, error) {
+	var err error
+	if query.Options.UseZabbixValueMapping {
+		err = ds.setValueMappings(ctx, series, items)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if query.Options.UseTriggerThresholds {
+		err = ds.setTriggerThresholds(ctx, series, items)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Threshold series are built for the item series and added after the functions, so they aren't aggregated
+	var thresholdSeries []*timeseries.TimeSeriesData
+	if query.Options.TriggerThresholdSeries {
+		thresholdSeries, err = ds.getTriggerThresholdSeries(ctx, query, series, items)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hasFunction(query.Functions, "aggregateByHostGroup") {
+		err = ds.setHostGroups(ctx, query, series, items)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = ds.setItemTags(ctx, series, items)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Settings.ZabbixWebURL != "" {
+		setItemDataLinks(series, items, ds.Settings.ZabbixWebURL)
+	}
+	if query.Location != nil {
+		setSeriesTimezone(series, query.Location)
+	}
+	if query.Options.NoDataPeriod != "" {
+		err = convertToNoDataSeries(series, query)
+		if err != nil {
+			return nil, err
+		}
+	} else if !ds.Settings.DisableDataAlignment && !query.Options.DisableDataAlignment {
+		alignSeriesData(series, useTrend)
+	}
+	series, err = applyFunctions(series, query.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	series = append(series, thresholdSeries...)
+
+	return applyFunctionsPost(series, query.Functions)
+}
+
+// setHostGroups sets host groups (matching the query group filter) of the item hosts to the corresponding series.
+// Series should be in the same order as items.
+func (ds *ZabbixDatasourceInstance) setHostGroups(ctx context.Context, query *QueryModel, series []*timeseries.TimeSeriesData, items Items) error {
+	groups, err := ds.getGroups(ctx, newQueryFilters(query), query.Group.Filter)
+	if err != nil {
+		return err
+	}
+	matchedGroups := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		matchedGroups[group["groupid"].(string)] = true
+	}
+
+	var hostids []string
+	for _, item := range items {
+		if len(item.Hosts) > 0 {
+			hostids = append(hostids, item.Hosts[0].ID)
+		}
+	}
+	if len(hostids) == 0 {
+		return nil
+	}
+
+	params := ZabbixAPIParams{
+		"output":       []string{"hostid", "name"},
+		"hostids":      hostids,
+		"selectGroups": []string{"groupid", "name"},
+	}
+	response, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "host.get", Params: params})
+	if err != nil {
+		return err
+	}
+
+	hostGroups := make(map[string][]string)
+	for _, host := range response.MustArray() {
+		hostMap := host.(map[string]interface{})
+		hostid, _ := hostMap["hostid"].(string)
+		groupList, _ := hostMap["groups"].([]interface{})
+		for _, group := range groupList {
+			groupMap := group.(map[string]interface{})
+			groupid, _ := groupMap["groupid"].(string)
+			if matchedGroups[groupid] {
+				hostGroups[hostid] = append(hostGroups[hostid], groupMap["name"].(string))
+			}
+		}
+	}
+
+	for i, item := range items {
+		if i < len(series) && len(item.Hosts) > 0 {
+			series[i].Meta.HostGroups = hostGroups[item.Hosts[0].ID]
+		}
+	}
+	return nil
+}
+
+// setValueMappings attaches Zabbix value maps of the items to the corresponding series.
+// Series should be in the same order as items.
+func (ds *ZabbixDatasourceInstance) setValueMappings(ctx context.Context, series []*timeseries.TimeSeriesData, items Items) error {
+	var valuemapids []string
+	for _, item := range items {
+		if item.ValueMapID != "" && item.ValueMapID != "0" {
+			valuemapids = append(valuemapids, item.ValueMapID)
+		}
+	}
+	if len(valuemapids) == 0 {
+		return nil
+	}
+
+	valueMaps, err := ds.getValueMaps(ctx, valuemapids)
+	if err != nil {
+		return err
+	}
+
+	valueMapsByID := make(map[string]ValueMap, len(valueMaps))
+	for _, valueMap := range valueMaps {
+		valueMapsByID[valueMap.ID] = valueMap
+	}
+
+	for i, item := range items {
+		valueMap, ok := valueMapsByID[item.ValueMapID]
+		if !ok || i >= len(series) {
+			continue
+		}
+		if series[i].Meta.FieldConfig == nil {
+			series[i].Meta.FieldConfig = &data.FieldConfig{}
+		}
+		series[i].Meta.FieldConfig.Mappings = convertValueMappings(valueMap)
+	}
+	return nil
+}
+
+func (ds *ZabbixDatasourceInstance) getValueMaps(ctx context.Context, valuemapids []string) ([]ValueMap, error) {
+	params := ZabbixAPIParams{
+		"output":         "extend",
+		"selectMappings": "extend",
+		"valuemapids":    valuemapids,
+	}
+
+	result, err := ds.ZabbixQuery(ctx, &ZabbixAPIRequest{Method: "valuemap.get", Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	valueMapsJSON, err := result.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var valueMaps []ValueMap
+	err = json.Unmarshal(valueMapsJSON, &valueMaps)
+	if err != nil {
+		return nil, err
+	}
+	return valueMaps, nil
 }
 
 func (ds *ZabbixDatasourceInstance) getTrendValueType(query *QueryModel) string {
@@ -386,27 +995,35 @@ func (ds *ZabbixDatasourceInstance) getTrendValueType(query *QueryModel) string
 
 	for _, fn := range query.Functions {
 		if fn.Def.Name == "trendValue" && len(fn.Params) > 0 {
-			trendValue = fn.Params[0]
+			trendValue, _ = getStringParam(fn.Params, 0, trendValue)
 		}
 	}
 
 	return trendValue
 }
 
+// getConsolidateBy returns function set by consolidateBy() or empty string if query has no such function
 func (ds *ZabbixDatasourceInstance) getConsolidateBy(query *QueryModel) string {
-	consolidateBy := "avg"
+	consolidateBy := ""
 
 	for _, fn := range query.Functions {
 		if fn.Def.Name == "consolidateBy" && len(fn.Params) > 0 {
-			consolidateBy = fn.Params[0]
+			consolidateBy, _ = getStringParam(fn.Params, 0, consolidateBy)
 		}
 	}
 	return consolidateBy
 }
 
-func (ds *ZabbixDatasourceInstance) getHistotyOrTrend(ctx context.Context, query *QueryModel, items Items) (History, error) {
-	timeRange := query.TimeRange
-	useTrend := ds.isUseTrend(timeRange)
+func (ds *ZabbixDatasourceInstance) getHistotyOrTrend(ctx context.Context, timeRange backend.TimeRange, items Items, useTrend bool, trendValueType string) (History, error) {
+	if history, ok, err := ds.getHistoryOrTrendWithFallback(ctx, timeRange, items, useTrend, trendValueType, nil); ok || err != nil {
+		return history, err
+	}
+	return ds.getHistoryOrTrendFromAPI(ctx, timeRange, items, useTrend, trendValueType)
+}
+
+func (ds *ZabbixDatasourceInstance) getHistoryOrTrendFromAPI(ctx context.Context, timeRange backend.TimeRange, items Items, useTrend bool, trendValueType string) (History, error) {
+	recordHistorySource(ctx, HistorySourceAPI)
+
 	allHistory := History{}
 
 	groupedItems := map[int]Items{}
@@ -449,9 +1066,16 @@ func (ds *ZabbixDatasourceInstance) getHistotyOrTrend(ctx context.Context, query
 		}
 
 		history := History{}
-		err = json.Unmarshal(pointJSON, &history)
+		if useTrend {
+			trend := Trend{}
+			err = json.Unmarshal(pointJSON, &trend)
+			history = trend.ToHistory(trendValueType)
+		} else {
+			err = json.Unmarshal(pointJSON, &history)
+		}
+
 		if err != nil {
-			ds.logger.Error("Error handling history response", "error", err.Error())
+			requestid.Logger(ctx, ds.logger).Error("Error handling history response", "error", err.Error())
 		} else {
 			allHistory = append(allHistory, history...)
 		}
@@ -476,11 +1100,72 @@ func (ds *ZabbixDatasourceInstance) isUseTrend(timeRange backend.TimeRange) bool
 	return false
 }
 
+// getTrendsStitchTime returns the time trends should be used before, if the time range spans both the trends-only
+// past and the recent history window. Ranges longer than trendsRange use trends only, so they're not stitched.
+func (ds *ZabbixDatasourceInstance) getTrendsStitchTime(timeRange backend.TimeRange) (time.Time, bool) {
+	if !ds.Settings.Trends {
+		return time.Time{}, false
+	}
+	if timeRange.To.Sub(timeRange.From) > ds.Settings.TrendsRange {
+		return time.Time{}, false
+	}
+
+	// Trends are hourly, so switch to history at the start of an hour
+	trendsTill := time.Now().Add(-ds.Settings.TrendsFrom).Truncate(time.Hour)
+	if timeRange.From.Before(trendsTill) && timeRange.To.After(trendsTill) {
+		return trendsTill, true
+	}
+	return time.Time{}, false
+}
+
+var regexFilterPattern = regexp.MustCompile(`^/(.+)/(.*)$`)
+
+// parseFilter returns regex of the filter set as a regex (/pattern/flags) or a glob pattern, where * matches any
+// characters and ? matches one character. Returns nil if filter is an exact name. Names may contain glob
+// characters too, so glob patterns should be matched with nameFilter, preferring the exact name.
 func parseFilter(filter string) (*regexp.Regexp, error) {
-	regex := regexp.MustCompile(`^/(.+)/(.*)$`)
+	if re, err := parseRegexFilter(filter); re != nil || err != nil {
+		return re, err
+	}
+	if isGlobFilter(filter) {
+		return globToRegex(filter), nil
+	}
+	return nil, nil
+}
+
+// isMatchAllFilter returns true if the filter matches all names, like "/.*/" or "*"
+func isMatchAllFilter(filter string) bool {
+	return filter == matchAllFilter || filter == "*"
+}
+
+// isGlobFilter returns true if filter isn't a regex and has glob characters
+func isGlobFilter(filter string) bool {
+	return !regexFilterPattern.MatchString(filter) && strings.ContainsAny(filter, "*?")
+}
+
+// globToRegex converts glob pattern to the regex matching the whole name
+func globToRegex(glob string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			pattern.WriteString(".*")
+		case '?':
+			pattern.WriteString(".")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// parseRegexFilter returns regex of the filter set as /pattern/flags, or nil if filter isn't a regex
+func parseRegexFilter(filter string) (*regexp.Regexp, error) {
 	flagRE := regexp.MustCompile("[imsU]+")
 
-	matches := regex.FindStringSubmatch(filter)
+	matches := regexFilterPattern.FindStringSubmatch(filter)
 	if len(matches) <= 1 {
 		return nil, nil
 	}
@@ -497,23 +1182,3 @@ func parseFilter(filter string) (*regexp.Regexp, error) {
 
 	return regexp.Compile(pattern)
 }
-
-func isNotAuthorized(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	message := err.Error()
-	return strings.Contains(message, "Session terminated, re-login, please.") ||
-		strings.Contains(message, "Not authorised.") ||
-		strings.Contains(message, "Not authorized.")
-}
-
-func isAppMethodNotFoundError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	message := err.Error()
-	return message == `Method not found. Incorrect API "application".`
-}
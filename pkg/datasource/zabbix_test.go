@@ -2,6 +2,7 @@ package datasource
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"testing"
 	"time"
@@ -32,7 +33,7 @@ var basicDatasourceInfo = &backend.DataSourceInstanceSettings{
 	ID:       1,
 	Name:     "TestDatasource",
 	URL:      "http://zabbix.org/zabbix",
-	JSONData: []byte(`{"username":"username", "password":"password"}}`),
+	JSONData: []byte(`{"username":"username", "password":"password"}`),
 }
 
 func mockZabbixQuery(method string, params ZabbixAPIParams) *ZabbixAPIRequest {
@@ -132,3 +133,50 @@ func TestNonCachedQuery(t *testing.T) {
 	result, _ = resp.Result.(string)
 	assert.Equal(t, "testNew", result)
 }
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    string
+		matches   []string
+		noMatches []string
+		wantNil   bool
+		wantErr   bool
+	}{
+		{name: "Exact name", filter: "eth0", wantNil: true},
+		{name: "Regex", filter: "/^eth\\d$/", matches: []string{"eth0", "eth1"}, noMatches: []string{"eth10", "veth0"}},
+		{name: "Regex with flags", filter: "/^ETH/i", matches: []string{"eth0"}, noMatches: []string{"veth0"}},
+		{name: "Invalid regex", filter: "/eth(/", wantErr: true},
+		{name: "Glob", filter: "eth*", matches: []string{"eth", "eth0", "eth10"}, noMatches: []string{"veth0"}},
+		{name: "Glob single character", filter: "db-??", matches: []string{"db-01"}, noMatches: []string{"db-1", "db-001"}},
+		{name: "Glob special characters", filter: "CPU (*)", matches: []string{"CPU (user)"}, noMatches: []string{"CPU user"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := parseFilter(tt.filter)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantNil {
+				assert.Nil(t, re)
+				return
+			}
+			for _, name := range tt.matches {
+				assert.True(t, re.MatchString(name), name)
+			}
+			for _, name := range tt.noMatches {
+				assert.False(t, re.MatchString(name), name)
+			}
+		})
+	}
+}
+
+func TestTestConnectionWrongAPIPath(t *testing.T) {
+	dsInstance := MockZabbixDataSource(`<html><body>Zabbix frontend</body></html>`, 200)
+	_, err := dsInstance.TestConnection(context.Background())
+	assert.True(t, errors.Is(err, zabbixapi.ErrNotAPIEndpoint))
+	assert.Contains(t, err.Error(), "Zabbix API not found at http://zabbix.org/zabbix, check the API path in the data source URL")
+}
@@ -0,0 +1,146 @@
+package dbconnector
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Layouts of the history tables in ClickHouse
+const (
+	// ClickHouseSchemaGlaber is a layout used by Glaber: history_dbl/history_uint/history_str/history_log and
+	// trends_dbl/trends_uint tables with DateTime clock
+	ClickHouseSchemaGlaber = "glaber"
+	// ClickHouseSchemaHistory is a layout of the single history table with value (integer), value_dbl and
+	// value_str columns, used by the Zabbix history offloading extensions. It has no trends.
+	ClickHouseSchemaHistory = "history"
+)
+
+// Columns of the Glaber trends tables for the trend values
+var glaberTrendValueColumns = map[string]string{
+	TrendValueAvg:   "value_avg",
+	TrendValueMin:   "value_min",
+	TrendValueMax:   "value_max",
+	TrendValueSum:   "value_avg * count",
+	TrendValueCount: "count",
+}
+
+type clickHouseDialect struct {
+	schema string
+}
+
+func newClickHouseDialect(schema string) (*clickHouseDialect, error) {
+	switch schema {
+	case "":
+		return &clickHouseDialect{schema: ClickHouseSchemaGlaber}, nil
+	case ClickHouseSchemaGlaber, ClickHouseSchemaHistory:
+		return &clickHouseDialect{schema: schema}, nil
+	}
+	return nil, fmt.Errorf("unsupported ClickHouse schema: %s", schema)
+}
+
+func (d *clickHouseDialect) name() string {
+	return TypeClickHouse
+}
+
+func (d *clickHouseDialect) driverName() string {
+	return "clickhouse"
+}
+
+// dsn returns connection URL in the clickhouse-go format: tcp://host:port?database=dbname&username=user&params
+func (d *clickHouseDialect) dsn(settings Settings) string {
+	params := url.Values{}
+	params.Set("database", settings.Database)
+	params.Set("username", settings.User)
+	params.Set("password", settings.Password)
+	if settings.Timeout > 0 {
+		timeout := strconv.Itoa(int(settings.Timeout.Seconds()))
+		params.Set("read_timeout", timeout)
+		params.Set("write_timeout", timeout)
+	}
+	dsn := url.URL{
+		Scheme:   "tcp",
+		Host:     settings.Host,
+		RawQuery: params.Encode(),
+	}
+	return dsn.String()
+}
+
+func (d *clickHouseDialect) placeholder(index int) string {
+	return "?"
+}
+
+func (d *clickHouseDialect) bucket(intervalSec int64) string {
+	return fmt.Sprintf("intDiv(%s, %d) * %d", d.clock(), intervalSec, intervalSec)
+}
+
+// clock is a DateTime column in ClickHouse
+func (d *clickHouseDialect) clock() string {
+	return "toUnixTimestamp(clock)"
+}
+
+func (d *clickHouseDialect) timeArg(placeholder string) string {
+	return fmt.Sprintf("toDateTime(%s)", placeholder)
+}
+
+// replicationLagQuery returns empty query, replicas of ClickHouse tables are synchronized by the table engine
+func (d *clickHouseDialect) replicationLagQuery() (string, string) {
+	return "", ""
+}
+
+// schemaVersionQuery returns empty query, history tables in ClickHouse are created by the external scripts
+func (d *clickHouseDialect) schemaVersionQuery() string {
+	return ""
+}
+
+func (d *clickHouseDialect) historyTable(valueType int) (string, string, error) {
+	if valueType != ValueTypeFloat && valueType != ValueTypeUint {
+		return "", "", fmt.Errorf("unsupported value type: %d", valueType)
+	}
+
+	if d.schema == ClickHouseSchemaHistory {
+		if valueType == ValueTypeFloat {
+			return "history", "value_dbl", nil
+		}
+		return "history", "value", nil
+	}
+
+	if valueType == ValueTypeFloat {
+		return "history_dbl", "value", nil
+	}
+	return "history_uint", "value", nil
+}
+
+// textHistoryTable returns table of the text values, Glaber stores character and text values in the same table
+func (d *clickHouseDialect) textHistoryTable(valueType int) (string, string, bool, error) {
+	if valueType != ValueTypeChar && valueType != ValueTypeText && valueType != ValueTypeLog {
+		return "", "", false, fmt.Errorf("unsupported value type: %d", valueType)
+	}
+
+	if d.schema == ClickHouseSchemaHistory {
+		return "history", "value_str", false, nil
+	}
+
+	if valueType == ValueTypeLog {
+		return "history_log", "value", true, nil
+	}
+	return "history_str", "value", false, nil
+}
+
+func (d *clickHouseDialect) trendsTable(valueType int, trendValue string) (string, string, error) {
+	if d.schema == ClickHouseSchemaHistory {
+		return "", "", fmt.Errorf("trends are not supported by the ClickHouse %s schema", d.schema)
+	}
+
+	column, ok := glaberTrendValueColumns[trendValue]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported trend value: %s", trendValue)
+	}
+	switch valueType {
+	case ValueTypeFloat:
+		return "trends_dbl", column, nil
+	case ValueTypeUint:
+		return "trends_uint", column, nil
+	}
+	return "", "", fmt.Errorf("unsupported value type: %d", valueType)
+}
@@ -0,0 +1,126 @@
+package dbconnector
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClickHouseHistory(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    string
+		valueType int
+		query     string
+	}{
+		{
+			name:      "Glaber float",
+			schema:    ClickHouseSchemaGlaber,
+			valueType: ValueTypeFloat,
+			query:     "SELECT itemid, toUnixTimestamp(clock), ns, value FROM history_dbl WHERE itemid IN (?) AND clock >= toDateTime(?) AND clock <= toDateTime(?) ORDER BY clock",
+		},
+		{
+			name:      "Default schema is Glaber",
+			valueType: ValueTypeUint,
+			query:     "SELECT itemid, toUnixTimestamp(clock), ns, value FROM history_uint WHERE itemid IN (?) AND clock >= toDateTime(?) AND clock <= toDateTime(?) ORDER BY clock",
+		},
+		{
+			name:      "Single table float",
+			schema:    ClickHouseSchemaHistory,
+			valueType: ValueTypeFloat,
+			query:     "SELECT itemid, toUnixTimestamp(clock), ns, value_dbl FROM history WHERE itemid IN (?) AND clock >= toDateTime(?) AND clock <= toDateTime(?) ORDER BY clock",
+		},
+		{
+			name:      "Single table uint",
+			schema:    ClickHouseSchemaHistory,
+			valueType: ValueTypeUint,
+			query:     "SELECT itemid, toUnixTimestamp(clock), ns, value FROM history WHERE itemid IN (?) AND clock >= toDateTime(?) AND clock <= toDateTime(?) ORDER BY clock",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := Settings{Type: TypeClickHouse, Schema: tt.schema}
+			connector, mock, err := MockDBConnectorWithSettings(settings, [][]driver.Value{{int64(1), int64(1600000000), int64(0), 1.5}})
+			assert.NoError(t, err)
+
+			points, err := connector.History(context.Background(), []string{"1"}, tt.valueType, time.Unix(0, 0), time.Unix(3600, 0))
+			assert.NoError(t, err)
+			assert.Equal(t, []Point{{ItemID: "1", Clock: 1600000000, Value: 1.5}}, points)
+
+			queries := mock.ExecutedQueries()
+			assert.Len(t, queries, 1)
+			assert.Equal(t, tt.query, queries[0].Query)
+		})
+	}
+}
+
+func TestClickHouseTextHistory(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		query  string
+	}{
+		{
+			name:   "Glaber",
+			schema: ClickHouseSchemaGlaber,
+			query:  "SELECT itemid, toUnixTimestamp(clock), ns, value FROM history_str WHERE itemid IN (?) AND clock >= toDateTime(?) AND clock <= toDateTime(?) ORDER BY clock",
+		},
+		{
+			name:   "Single table",
+			schema: ClickHouseSchemaHistory,
+			query:  "SELECT itemid, toUnixTimestamp(clock), ns, value_str FROM history WHERE itemid IN (?) AND clock >= toDateTime(?) AND clock <= toDateTime(?) ORDER BY clock",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := Settings{Type: TypeClickHouse, Schema: tt.schema}
+			connector, mock, err := MockDBConnectorWithSettings(settings, [][]driver.Value{{int64(1), int64(1600000000), int64(0), "ok"}})
+			assert.NoError(t, err)
+
+			points, err := connector.TextHistory(context.Background(), []string{"1"}, ValueTypeText, time.Unix(0, 0), time.Unix(3600, 0))
+			assert.NoError(t, err)
+			assert.Equal(t, []TextPoint{{ItemID: "1", Clock: 1600000000, Value: "ok"}}, points)
+			assert.Equal(t, tt.query, mock.ExecutedQueries()[0].Query)
+		})
+	}
+}
+
+func TestClickHouseTrends(t *testing.T) {
+	connector, mock, _ := MockDBConnector(TypeClickHouse, [][]driver.Value{{int64(1), int64(1600000000), 3.0}})
+	_, err := connector.Trends(context.Background(), []string{"1"}, ValueTypeFloat, time.Unix(0, 0), time.Unix(3600, 0), TrendValueCount)
+	assert.NoError(t, err)
+
+	queries := mock.ExecutedQueries()
+	assert.Len(t, queries, 1)
+	assert.Equal(t, "SELECT itemid, toUnixTimestamp(clock), count FROM trends_dbl WHERE itemid IN (?) AND clock >= toDateTime(?) AND clock <= toDateTime(?) ORDER BY clock", queries[0].Query)
+
+	connector, mock, _ = MockDBConnectorWithSettings(Settings{Type: TypeClickHouse, Schema: ClickHouseSchemaHistory}, nil)
+	_, err = connector.Trends(context.Background(), []string{"1"}, ValueTypeFloat, time.Unix(0, 0), time.Unix(3600, 0), TrendValueAvg)
+	assert.Error(t, err)
+	assert.Len(t, mock.ExecutedQueries(), 0)
+}
+
+func TestClickHouseHistoryAggregated(t *testing.T) {
+	connector, mock, _ := MockDBConnector(TypeClickHouse, [][]driver.Value{{int64(1), int64(1600000000), 1.5}})
+	_, err := connector.HistoryAggregated(context.Background(), []string{"1"}, ValueTypeFloat, time.Unix(0, 0), time.Unix(3600, 0), time.Minute, "avg")
+	assert.NoError(t, err)
+
+	queries := mock.ExecutedQueries()
+	assert.Len(t, queries, 1)
+	assert.Equal(t, "SELECT itemid, intDiv(toUnixTimestamp(clock), 60) * 60 AS clock_bucket, AVG(value) FROM history_dbl WHERE itemid IN (?) AND clock >= toDateTime(?) AND clock <= toDateTime(?) GROUP BY 1, 2 ORDER BY 2", queries[0].Query)
+}
+
+func TestClickHouseDSN(t *testing.T) {
+	d, err := newClickHouseDialect("")
+	assert.NoError(t, err)
+	settings := Settings{Host: "localhost:9000", Database: "glaber", User: "default", Password: "secret", Timeout: 30 * time.Second}
+	assert.Equal(t, "tcp://localhost:9000?database=glaber&password=secret&read_timeout=30&username=default&write_timeout=30", d.dsn(settings))
+
+	_, err = newClickHouseDialect("unknown")
+	assert.Error(t, err)
+}
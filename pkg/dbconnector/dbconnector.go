@@ -0,0 +1,391 @@
+package dbconnector
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// Item value types stored in the history and trends tables
+const (
+	ValueTypeFloat = 0
+	ValueTypeChar  = 1
+	ValueTypeLog   = 2
+	ValueTypeUint  = 3
+	ValueTypeText  = 4
+)
+
+// Values of the trends, same as the trendValue() function params
+const (
+	TrendValueAvg   = "avg"
+	TrendValueMin   = "min"
+	TrendValueMax   = "max"
+	TrendValueSum   = "sum"
+	TrendValueCount = "count"
+)
+
+// Aggregation functions of the values within the time bucket
+var aggregationFunctions = map[string]string{
+	"avg":   "AVG",
+	"min":   "MIN",
+	"max":   "MAX",
+	"sum":   "SUM",
+	"count": "COUNT",
+}
+
+// IsAggregationSupported returns true if values can be aggregated with the function by the database
+func IsAggregationSupported(aggFunction string) bool {
+	_, ok := aggregationFunctions[aggFunction]
+	return ok
+}
+
+// Settings of the connection to the Zabbix database
+type Settings struct {
+	Type     string
+	Host     string
+	Database string
+	User     string
+	Password string
+	Timeout  time.Duration
+	// Schema is a layout of the history tables, used by databases with several known layouts (ClickHouse)
+	Schema string
+	// Partitioning is a period of the history tables partitions (PartitioningDaily, PartitioningWeekly)
+	Partitioning string
+	// SSL of the PostgreSQL connection: sslmode and paths to the CA, client certificate and key files. Driver
+	// default mode (require) is used if SSLMode isn't set.
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	// DatasourceUID is an UID of the Grafana SQL data source used instead of the direct connection. Queries are
+	// sent to the Grafana URL authenticated with the token, Type is a type of that data source.
+	DatasourceUID string
+	GrafanaURL    string
+	GrafanaToken  string
+
+	// Connection pool settings, zero values keep database/sql defaults
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// Logger of the data source, new logger is created if not set
+	Logger log.Logger
+}
+
+// Point is a value of the item read from the history or trends table
+type Point struct {
+	ItemID string
+	Clock  int64
+	NS     int64
+	Value  float64
+}
+
+// TextPoint is a value of the character, text or log item. Source, severity and event id are set for the log
+// items only.
+type TextPoint struct {
+	ItemID     string
+	Clock      int64
+	NS         int64
+	Value      string
+	Source     string
+	Severity   int
+	LogEventID string
+}
+
+// DBConnector reads history and trends of the items directly from the Zabbix database. Items metadata is
+// still requested from the API, connector only needs item ids and value types.
+type DBConnector struct {
+	db           *sql.DB
+	dialect      dialect
+	partitioning string
+	aggregates   *continuousAggregates
+	logger       log.Logger
+}
+
+// New returns connector to the database of the given type, or to the Grafana data source if its UID is set.
+// Connection is established on the first query.
+func New(settings Settings) (*DBConnector, error) {
+	d, err := getDialect(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePartitioning(settings.Partitioning); err != nil {
+		return nil, err
+	}
+
+	var db *sql.DB
+	if settings.DatasourceUID != "" {
+		db = sql.OpenDB(newGrafanaConnector(settings))
+	} else {
+		db, err = sql.Open(d.driverName(), d.dsn(settings))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if settings.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(settings.MaxOpenConns)
+	}
+	if settings.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(settings.MaxIdleConns)
+	}
+	if settings.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(settings.ConnMaxLifetime)
+	}
+
+	logger := settings.Logger
+	if logger == nil {
+		logger = log.New()
+	}
+
+	return &DBConnector{
+		db:           db,
+		dialect:      d,
+		partitioning: settings.Partitioning,
+		aggregates:   &continuousAggregates{},
+		logger:       logger,
+	}, nil
+}
+
+// Type returns type of the database
+func (c *DBConnector) Type() string {
+	return c.dialect.name()
+}
+
+// HasTrends returns true if database has trends tables
+func (c *DBConnector) HasTrends() bool {
+	_, _, err := c.dialect.trendsTable(ValueTypeFloat, TrendValueAvg)
+	return err == nil
+}
+
+// History returns values of the items with the given value type within the time range, ordered by time
+func (c *DBConnector) History(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time) ([]Point, error) {
+	table, column, err := c.dialect.historyTable(valueType)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := fmt.Sprintf("itemid, %s, ns, %s", c.dialect.clock(), column)
+	return c.queryHistory(ctx, columns, table, itemids, from, to, "ORDER BY clock", true, 1)
+}
+
+// TextHistory returns values of the character, text or log items within the time range, ordered by time
+func (c *DBConnector) TextHistory(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time) ([]TextPoint, error) {
+	table, column, hasLogColumns, err := c.dialect.textHistoryTable(valueType)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := fmt.Sprintf("itemid, %s, ns, %s", c.dialect.clock(), column)
+	if hasLogColumns {
+		columns += ", source, severity, logeventid"
+	}
+	points := make([]TextPoint, 0)
+	err = c.queryPartitions(from, to, 1, func(chunkFrom time.Time, chunkTo time.Time) error {
+		query, args, err := c.buildQuery(columns, table, itemids, chunkFrom, chunkTo, "ORDER BY clock")
+		if err != nil {
+			return err
+		}
+		chunkPoints, err := c.queryTextPoints(ctx, query, args, hasLogColumns)
+		if err != nil {
+			return err
+		}
+		points = append(points, chunkPoints...)
+		return nil
+	})
+	return points, err
+}
+
+// HistoryAggregated returns values of the items aggregated into time buckets of the given interval. Time of the
+// point is the start of the bucket. TimescaleDB continuous aggregate of the history table is used instead of
+// the table if its bucket fits into the interval.
+func (c *DBConnector) HistoryAggregated(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time, interval time.Duration, aggFunction string) ([]Point, error) {
+	table, column, err := c.dialect.historyTable(valueType)
+	if err != nil {
+		return nil, err
+	}
+	if view, viewColumn := c.continuousAggregate(ctx, table, interval, aggFunction); view != "" {
+		table, column = view, viewColumn
+	}
+	return c.queryAggregated(ctx, column, table, itemids, from, to, interval, aggFunction, true)
+}
+
+// Trends returns hourly trends of the items with the given value type within the time range, ordered by time.
+// Trend value is one of the TrendValue* constants, average value is returned if it's not set.
+func (c *DBConnector) Trends(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time, trendValue string) ([]Point, error) {
+	if trendValue == "" {
+		trendValue = TrendValueAvg
+	}
+	table, column, err := c.dialect.trendsTable(valueType, trendValue)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := fmt.Sprintf("itemid, %s, %s", c.dialect.clock(), column)
+	query, args, err := c.buildQuery(columns, table, itemids, from, to, "ORDER BY clock")
+	if err != nil {
+		return nil, err
+	}
+	return c.queryPoints(ctx, query, args, false)
+}
+
+// TrendsAggregated returns trends of the items aggregated into time buckets of the given interval
+func (c *DBConnector) TrendsAggregated(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time, interval time.Duration, aggFunction string, trendValue string) ([]Point, error) {
+	if trendValue == "" {
+		trendValue = TrendValueAvg
+	}
+	table, column, err := c.dialect.trendsTable(valueType, trendValue)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryAggregated(ctx, column, table, itemids, from, to, interval, aggFunction, false)
+}
+
+func (c *DBConnector) queryAggregated(ctx context.Context, column string, table string, itemids []string, from time.Time, to time.Time, interval time.Duration, aggFunction string, history bool) ([]Point, error) {
+	function, ok := aggregationFunctions[aggFunction]
+	if !ok {
+		return nil, fmt.Errorf("unsupported aggregation function: %s", aggFunction)
+	}
+	intervalSec := int64(interval.Seconds())
+	if intervalSec < 1 {
+		intervalSec = 1
+	}
+
+	columns := fmt.Sprintf("itemid, %s AS clock_bucket, %s(%s)", c.dialect.bucket(intervalSec), function, column)
+	tail := "GROUP BY 1, 2 ORDER BY 2"
+	if history {
+		return c.queryHistory(ctx, columns, table, itemids, from, to, tail, false, intervalSec)
+	}
+
+	query, args, err := c.buildQuery(columns, table, itemids, from, to, tail)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryPoints(ctx, query, args, false)
+}
+
+// TestConnection checks that database is reachable and has Zabbix tables
+func (c *DBConnector) TestConnection(ctx context.Context) error {
+	table, _, err := c.dialect.historyTable(ValueTypeUint)
+	if err != nil {
+		return err
+	}
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf("SELECT itemid FROM %s WHERE 1=0", table))
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// IsConnectionError returns true if error is caused by the broken or unreachable database connection rather
+// than by the particular query
+func IsConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Close closes the database connections
+func (c *DBConnector) Close() error {
+	return c.db.Close()
+}
+
+// buildQuery returns query of the items values within the time range, tail (grouping and ordering) is appended
+// to the conditions
+func (c *DBConnector) buildQuery(columns string, table string, itemids []string, from time.Time, to time.Time, tail string) (string, []interface{}, error) {
+	if len(itemids) == 0 {
+		return "", nil, fmt.Errorf("no items to query")
+	}
+
+	args := make([]interface{}, 0, len(itemids)+2)
+	placeholders := make([]string, 0, len(itemids))
+	for _, itemid := range itemids {
+		id, err := strconv.ParseInt(itemid, 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid item id %s: %w", itemid, err)
+		}
+		args = append(args, id)
+		placeholders = append(placeholders, c.dialect.placeholder(len(args)))
+	}
+	args = append(args, from.Unix(), to.Unix())
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE itemid IN (%s) AND clock >= %s AND clock <= %s",
+		columns, table, strings.Join(placeholders, ", "),
+		c.dialect.timeArg(c.dialect.placeholder(len(args)-1)), c.dialect.timeArg(c.dialect.placeholder(len(args))))
+	return query + " " + tail, args, nil
+}
+
+func (c *DBConnector) queryPoints(ctx context.Context, query string, args []interface{}, withNS bool) (points []Point, err error) {
+	requestid.Logger(ctx, c.logger).Debug("DB query", "type", c.dialect.name(), "query", query)
+	start := time.Now()
+	defer func() {
+		RecordQuery(ctx, query, args, start, len(points), err)
+	}()
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points = make([]Point, 0)
+	for rows.Next() {
+		var itemid int64
+		var point Point
+		if withNS {
+			err = rows.Scan(&itemid, &point.Clock, &point.NS, &point.Value)
+		} else {
+			err = rows.Scan(&itemid, &point.Clock, &point.Value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		point.ItemID = strconv.FormatInt(itemid, 10)
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+func (c *DBConnector) queryTextPoints(ctx context.Context, query string, args []interface{}, withLogColumns bool) (points []TextPoint, err error) {
+	requestid.Logger(ctx, c.logger).Debug("DB query", "type", c.dialect.name(), "query", query)
+	start := time.Now()
+	defer func() {
+		RecordQuery(ctx, query, args, start, len(points), err)
+	}()
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points = make([]TextPoint, 0)
+	for rows.Next() {
+		var itemid int64
+		var point TextPoint
+		if withLogColumns {
+			var logEventID int64
+			err = rows.Scan(&itemid, &point.Clock, &point.NS, &point.Value, &point.Source, &point.Severity, &logEventID)
+			point.LogEventID = strconv.FormatInt(logEventID, 10)
+		} else {
+			err = rows.Scan(&itemid, &point.Clock, &point.NS, &point.Value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		point.ItemID = strconv.FormatInt(itemid, 10)
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
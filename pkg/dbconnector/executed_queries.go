@@ -0,0 +1,61 @@
+package dbconnector
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ExecutedQuery describes query sent to the history storage during the data query execution. It's shown in the
+// query inspector as a part of the frame meta.
+type ExecutedQuery struct {
+	Query      string        `json:"query"`
+	Args       []interface{} `json:"args,omitempty"`
+	DurationMs float64       `json:"durationMs"`
+	Rows       int           `json:"rows"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type queriesRecorderKey struct{}
+
+// QueriesRecorder collects queries executed with the context. Queries may be executed concurrently.
+type QueriesRecorder struct {
+	mu      sync.Mutex
+	queries []ExecutedQuery
+}
+
+// WithQueriesRecorder returns context recording queries executed with it
+func WithQueriesRecorder(ctx context.Context) (context.Context, *QueriesRecorder) {
+	recorder := &QueriesRecorder{}
+	return context.WithValue(ctx, queriesRecorderKey{}, recorder), recorder
+}
+
+// RecordQuery adds query started at the given time to the recorder of the context if any
+func RecordQuery(ctx context.Context, query string, args []interface{}, start time.Time, rows int, err error) {
+	recorder, ok := ctx.Value(queriesRecorderKey{}).(*QueriesRecorder)
+	if !ok {
+		return
+	}
+	executed := ExecutedQuery{
+		Query:      query,
+		Args:       args,
+		DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+		Rows:       rows,
+	}
+	if err != nil {
+		executed.Error = err.Error()
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.queries = append(recorder.queries, executed)
+}
+
+// Queries returns recorded queries in order they're finished
+func (r *QueriesRecorder) Queries() []ExecutedQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	queries := make([]ExecutedQuery, len(r.queries))
+	copy(queries, r.queries)
+	return queries
+}
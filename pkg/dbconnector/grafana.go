@@ -0,0 +1,276 @@
+package dbconnector
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// Default URL of the Grafana server used to query the SQL data source
+const defaultGrafanaURL = "http://localhost:3000"
+
+// Placeholders of the query arguments, MySQL (?) and PostgreSQL ($1)
+var placeholderRegex = regexp.MustCompile(`\?|\$\d+`)
+
+// grafanaConnector implements driver.Connector sending queries to the Grafana SQL data source through the
+// /api/ds/query endpoint, so credentials of the database are configured only in that data source. Query
+// arguments are item ids and timestamps, they're inlined into the query as integers.
+type grafanaConnector struct {
+	url           string
+	token         string
+	datasourceUID string
+	httpClient    *http.Client
+}
+
+type grafanaQueryRequest struct {
+	From    string               `json:"from"`
+	To      string               `json:"to"`
+	Queries []grafanaQueryTarget `json:"queries"`
+}
+
+type grafanaQueryTarget struct {
+	RefID      string            `json:"refId"`
+	Datasource map[string]string `json:"datasource"`
+	RawSQL     string            `json:"rawSql"`
+	Format     string            `json:"format"`
+}
+
+type grafanaQueryResponse struct {
+	Message string `json:"message"`
+	Results map[string]struct {
+		Error  string             `json:"error"`
+		Frames []grafanaFrameJSON `json:"frames"`
+	} `json:"results"`
+}
+
+type grafanaFrameJSON struct {
+	Schema struct {
+		Fields []struct {
+			Name     string `json:"name"`
+			TypeInfo struct {
+				Frame string `json:"frame"`
+			} `json:"typeInfo"`
+		} `json:"fields"`
+	} `json:"schema"`
+	Data struct {
+		Values [][]interface{} `json:"values"`
+	} `json:"data"`
+}
+
+func newGrafanaConnector(settings Settings) *grafanaConnector {
+	grafanaURL := settings.GrafanaURL
+	if grafanaURL == "" {
+		grafanaURL = defaultGrafanaURL
+	}
+	return &grafanaConnector{
+		url:           strings.TrimSuffix(grafanaURL, "/"),
+		token:         settings.GrafanaToken,
+		datasourceUID: settings.DatasourceUID,
+		httpClient:    &http.Client{Timeout: settings.Timeout},
+	}
+}
+
+// Connect implements driver.Connector
+func (c *grafanaConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &grafanaConn{connector: c}, nil
+}
+
+// Driver implements driver.Connector
+func (c *grafanaConnector) Driver() driver.Driver {
+	return nil
+}
+
+func (c *grafanaConnector) query(ctx context.Context, query string) (*grafanaFrameJSON, error) {
+	now := strconv.FormatInt(time.Now().Unix()*1000, 10)
+	body, err := json.Marshal(grafanaQueryRequest{
+		From: now,
+		To:   now,
+		Queries: []grafanaQueryTarget{{
+			RefID:      "A",
+			Datasource: map[string]string{"uid": c.datasourceUID},
+			RawSQL:     query,
+			Format:     "table",
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url+"/api/ds/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		req.Header.Set(requestid.Header, requestID)
+	}
+
+	res, err := ctxhttp.Do(ctx, c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &grafanaQueryResponse{}
+	decoder := json.NewDecoder(bytes.NewReader(responseBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(response); err != nil {
+		return nil, fmt.Errorf("error parsing Grafana response (status %s): %w", res.Status, err)
+	}
+
+	result := response.Results["A"]
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Grafana data source query failed with status %s: %s", res.Status, response.Message)
+	}
+	if len(result.Frames) == 0 {
+		return &grafanaFrameJSON{}, nil
+	}
+	return &result.Frames[0], nil
+}
+
+type grafanaConn struct {
+	connector *grafanaConnector
+}
+
+func (c *grafanaConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported by Grafana data source connection")
+}
+
+func (c *grafanaConn) Close() error {
+	return nil
+}
+
+func (c *grafanaConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported by Grafana data source connection")
+}
+
+// QueryContext implements driver.QueryerContext
+func (c *grafanaConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rawSQL, err := inlineArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	frame, err := c.connector.query(ctx, rawSQL)
+	if err != nil {
+		return nil, err
+	}
+	return newFrameRows(frame)
+}
+
+// inlineArgs replaces placeholders with the integer arguments
+func inlineArgs(query string, args []driver.NamedValue) (string, error) {
+	values := make([]string, 0, len(args))
+	for _, arg := range args {
+		value, ok := arg.Value.(int64)
+		if !ok {
+			return "", fmt.Errorf("unsupported argument type %T", arg.Value)
+		}
+		values = append(values, strconv.FormatInt(value, 10))
+	}
+
+	var err error
+	next := 0
+	rawSQL := placeholderRegex.ReplaceAllStringFunc(query, func(placeholder string) string {
+		index := next
+		if placeholder != "?" {
+			index, _ = strconv.Atoi(placeholder[1:])
+			index--
+		}
+		next++
+		if index < 0 || index >= len(values) {
+			err = fmt.Errorf("no argument for placeholder %s", placeholder)
+			return placeholder
+		}
+		return values[index]
+	})
+	return rawSQL, err
+}
+
+// frameRows implements driver.Rows reading the data frame values, numbers are converted according to the
+// field types
+type frameRows struct {
+	columns []string
+	values  [][]driver.Value
+	pos     int
+}
+
+func newFrameRows(frame *grafanaFrameJSON) (*frameRows, error) {
+	rows := &frameRows{}
+	for i, field := range frame.Schema.Fields {
+		rows.columns = append(rows.columns, field.Name)
+
+		var column []interface{}
+		if i < len(frame.Data.Values) {
+			column = frame.Data.Values[i]
+		}
+		for j, frameValue := range column {
+			if j >= len(rows.values) {
+				rows.values = append(rows.values, make([]driver.Value, len(frame.Schema.Fields)))
+			}
+			value, err := convertFrameValue(frameValue, field.TypeInfo.Frame)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of the %s field: %w", field.Name, err)
+			}
+			rows.values[j][i] = value
+		}
+	}
+	return rows, nil
+}
+
+// convertFrameValue converts JSON value to the driver value, numbers are decoded as json.Number
+func convertFrameValue(value interface{}, frameType string) (driver.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		if strings.Contains(frameType, "int") {
+			return v.Int64()
+		}
+		return v.Float64()
+	case string, bool:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
+
+func (r *frameRows) Columns() []string {
+	return r.columns
+}
+
+func (r *frameRows) Close() error {
+	return nil
+}
+
+func (r *frameRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
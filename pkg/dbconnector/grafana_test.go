@@ -0,0 +1,80 @@
+package dbconnector
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrafanaDatasourceHistory(t *testing.T) {
+	var request grafanaQueryRequest
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/ds/query", r.URL.Path)
+		authorization = r.Header.Get("Authorization")
+		body, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &request)
+		_, _ = w.Write([]byte(`{"results":{"A":{"frames":[{
+			"schema":{"fields":[
+				{"name":"itemid","typeInfo":{"frame":"uint64"}},
+				{"name":"clock","typeInfo":{"frame":"int32"}},
+				{"name":"ns","typeInfo":{"frame":"int32"}},
+				{"name":"value","typeInfo":{"frame":"float64"}}
+			]},
+			"data":{"values":[[1,2],[1600000000,1600000060],[100,0],[1.5,2]]}
+		}]}}}`))
+	}))
+	defer server.Close()
+
+	connector, err := New(Settings{Type: TypePostgres, DatasourceUID: "zabbix-db", GrafanaURL: server.URL, GrafanaToken: "token"})
+	assert.NoError(t, err)
+
+	points, err := connector.History(context.Background(), []string{"1", "2"}, ValueTypeFloat, time.Unix(1600000000, 0), time.Unix(1600003600, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, []Point{
+		{ItemID: "1", Clock: 1600000000, NS: 100, Value: 1.5},
+		{ItemID: "2", Clock: 1600000060, Value: 2},
+	}, points)
+
+	assert.Equal(t, "Bearer token", authorization)
+	assert.Len(t, request.Queries, 1)
+	assert.Equal(t, "zabbix-db", request.Queries[0].Datasource["uid"])
+	assert.Equal(t, "SELECT itemid, clock, ns, value FROM history WHERE itemid IN (1, 2) AND clock >= 1600000000 AND clock <= 1600003600 ORDER BY clock", request.Queries[0].RawSQL)
+}
+
+func TestGrafanaDatasourceError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"results":{"A":{"error":"relation \"history\" does not exist"}}}`))
+	}))
+	defer server.Close()
+
+	connector, _ := New(Settings{Type: TypePostgres, DatasourceUID: "zabbix-db", GrafanaURL: server.URL})
+	_, err := connector.History(context.Background(), []string{"1"}, ValueTypeFloat, time.Unix(0, 0), time.Unix(3600, 0))
+	assert.EqualError(t, err, "relation \"history\" does not exist")
+}
+
+func TestInlineArgs(t *testing.T) {
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}, {Ordinal: 2, Value: int64(20)}}
+
+	query, err := inlineArgs("SELECT * FROM t WHERE a = ? AND b = ?", args)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = 1 AND b = 20", query)
+
+	query, err = inlineArgs("SELECT * FROM t WHERE b = $2 AND a = $1", args)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE b = 20 AND a = 1", query)
+
+	_, err = inlineArgs("SELECT * FROM t WHERE a = $3", args)
+	assert.Error(t, err)
+
+	_, err = inlineArgs("SELECT * FROM t WHERE a = ?", []driver.NamedValue{{Ordinal: 1, Value: "1 OR 1=1"}})
+	assert.Error(t, err)
+}
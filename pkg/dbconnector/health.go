@@ -0,0 +1,107 @@
+package dbconnector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// Health of the database connection: connectivity, schema version, replication lag and pool usage. Errors of
+// the schema version and replication lag queries are reported as warnings, since they may need additional
+// privileges.
+type Health struct {
+	Type                  string   `json:"type"`
+	Connected             bool     `json:"connected"`
+	Error                 string   `json:"error,omitempty"`
+	SchemaVersion         string   `json:"schemaVersion,omitempty"`
+	ReplicationLagSeconds *float64 `json:"replicationLagSeconds,omitempty"`
+	OpenConnections       int      `json:"openConnections"`
+	InUseConnections      int      `json:"inUseConnections"`
+	IdleConnections       int      `json:"idleConnections"`
+	Warnings              []string `json:"warnings,omitempty"`
+}
+
+// Health returns health of the database connection
+func (c *DBConnector) Health(ctx context.Context) *Health {
+	health := &Health{Type: c.dialect.name()}
+	defer func() {
+		stats := c.db.Stats()
+		health.OpenConnections = stats.OpenConnections
+		health.InUseConnections = stats.InUse
+		health.IdleConnections = stats.Idle
+	}()
+
+	if err := c.TestConnection(ctx); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Connected = true
+
+	if query := c.dialect.schemaVersionQuery(); query != "" {
+		version, err := c.queryColumn(ctx, query, "")
+		if err != nil {
+			health.Warnings = append(health.Warnings, fmt.Sprintf("cannot read schema version: %s", err))
+		} else if version != nil {
+			health.SchemaVersion = *version
+		}
+	}
+
+	if query, column := c.dialect.replicationLagQuery(); query != "" {
+		lag, err := c.queryColumn(ctx, query, column)
+		if err != nil {
+			health.Warnings = append(health.Warnings, fmt.Sprintf("cannot read replication lag: %s", err))
+		} else if lag != nil {
+			seconds, err := strconv.ParseFloat(*lag, 64)
+			if err != nil {
+				health.Warnings = append(health.Warnings, fmt.Sprintf("invalid replication lag %s", *lag))
+			} else {
+				health.ReplicationLagSeconds = &seconds
+			}
+		}
+	}
+	return health
+}
+
+// queryColumn returns value of the column (or the first one if column isn't set) in the first row of the
+// query result. Returns nil if there are no rows or value is NULL.
+func (c *DBConnector) queryColumn(ctx context.Context, query string, column string) (*string, error) {
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	index := 0
+	if column != "" {
+		index = -1
+		for i, name := range columns {
+			if name == column {
+				index = i
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("no %s column in the result", column)
+		}
+	}
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if !values[index].Valid {
+		return nil, nil
+	}
+	return &values[index].String, nil
+}
@@ -0,0 +1,90 @@
+package dbconnector
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	connector, mock, _ := MockDBConnector(TypeMySQL, nil)
+	mock.QueryRows = func(query MockQuery) [][]driver.Value {
+		switch {
+		case strings.HasPrefix(query.Query, "SELECT mandatory"):
+			return [][]driver.Value{{int64(5040000)}}
+		case strings.HasPrefix(query.Query, "SHOW SLAVE STATUS"):
+			return [][]driver.Value{{"Yes", int64(3)}}
+		}
+		return nil
+	}
+	mock.QueryColumns = func(query MockQuery) []string {
+		if strings.HasPrefix(query.Query, "SHOW SLAVE STATUS") {
+			return []string{"Slave_IO_Running", "Seconds_Behind_Master"}
+		}
+		return nil
+	}
+
+	health := connector.Health(context.Background())
+	lag := float64(3)
+	assert.Equal(t, &Health{
+		Type:                  TypeMySQL,
+		Connected:             true,
+		SchemaVersion:         "5040000",
+		ReplicationLagSeconds: &lag,
+		OpenConnections:       1,
+		IdleConnections:       1,
+	}, health)
+}
+
+func TestHealthPrimary(t *testing.T) {
+	connector, mock, _ := MockDBConnector(TypePostgres, nil)
+	mock.QueryRows = func(query MockQuery) [][]driver.Value {
+		switch {
+		case strings.HasPrefix(query.Query, "SELECT mandatory"):
+			return [][]driver.Value{{int64(5040000)}}
+		case strings.Contains(query.Query, "pg_is_in_recovery"):
+			return [][]driver.Value{{nil}}
+		}
+		return nil
+	}
+	mock.QueryColumns = func(query MockQuery) []string {
+		if strings.Contains(query.Query, "pg_is_in_recovery") {
+			return []string{"lag"}
+		}
+		return nil
+	}
+
+	health := connector.Health(context.Background())
+	assert.True(t, health.Connected)
+	assert.Nil(t, health.ReplicationLagSeconds)
+	assert.Empty(t, health.Warnings)
+}
+
+func TestHealthWarnings(t *testing.T) {
+	connector, mock, _ := MockDBConnector(TypeMySQL, nil)
+	mock.QueryErr = func(query MockQuery) error {
+		if strings.HasPrefix(query.Query, "SHOW SLAVE STATUS") {
+			return errors.New("Error 1227: Access denied; you need the REPLICATION CLIENT privilege")
+		}
+		return nil
+	}
+
+	health := connector.Health(context.Background())
+	assert.True(t, health.Connected)
+	assert.Len(t, health.Warnings, 1)
+	assert.Contains(t, health.Warnings[0], "REPLICATION CLIENT")
+}
+
+func TestHealthDisconnected(t *testing.T) {
+	connector, mock, _ := MockDBConnector(TypeClickHouse, nil)
+	mock.Err = errors.New("connection refused")
+
+	health := connector.Health(context.Background())
+	assert.False(t, health.Connected)
+	assert.Equal(t, "connection refused", health.Error)
+	assert.Len(t, mock.ExecutedQueries(), 1)
+}
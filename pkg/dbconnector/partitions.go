@@ -0,0 +1,100 @@
+package dbconnector
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Periods of the history tables partitions
+const (
+	PartitioningDaily  = "daily"
+	PartitioningWeekly = "weekly"
+)
+
+// timeChunk is a part of the queried time range within a single partition, both ends are included
+type timeChunk struct {
+	from time.Time
+	to   time.Time
+}
+
+func validatePartitioning(partitioning string) error {
+	switch partitioning {
+	case "", PartitioningDaily, PartitioningWeekly:
+		return nil
+	}
+	return fmt.Errorf("unsupported partitioning: %s", partitioning)
+}
+
+// queryHistory queries history tables. If history is partitioned, query is made for each partition of the
+// time range, so database prunes other partitions even with generic plans of the prepared statements.
+func (c *DBConnector) queryHistory(ctx context.Context, columns string, table string, itemids []string, from time.Time, to time.Time, tail string, withNS bool, alignSec int64) ([]Point, error) {
+	points := make([]Point, 0)
+	err := c.queryPartitions(from, to, alignSec, func(chunkFrom time.Time, chunkTo time.Time) error {
+		query, args, err := c.buildQuery(columns, table, itemids, chunkFrom, chunkTo, tail)
+		if err != nil {
+			return err
+		}
+		chunkPoints, err := c.queryPoints(ctx, query, args, withNS)
+		if err != nil {
+			return err
+		}
+		points = append(points, chunkPoints...)
+		return nil
+	})
+	return points, err
+}
+
+// queryPartitions calls query for each partition chunk of the time range. Queries select from the partitioned
+// table, so chunks without partitions return no rows instead of failing.
+func (c *DBConnector) queryPartitions(from time.Time, to time.Time, alignSec int64, query func(chunkFrom time.Time, chunkTo time.Time) error) error {
+	for _, chunk := range partitionChunks(c.partitioning, from, to, alignSec) {
+		if err := query(chunk.from, chunk.to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionChunks splits time range at the partition boundaries (midnight UTC, or Monday midnight for the weekly
+// partitions). Boundaries are aligned to alignSec, so aggregation buckets aren't split between chunks.
+func partitionChunks(partitioning string, from time.Time, to time.Time, alignSec int64) []timeChunk {
+	var period time.Duration
+	switch partitioning {
+	case PartitioningDaily:
+		period = 24 * time.Hour
+	case PartitioningWeekly:
+		period = 7 * 24 * time.Hour
+	default:
+		return []timeChunk{{from: from, to: to}}
+	}
+	if alignSec < 1 {
+		alignSec = 1
+	}
+
+	// Unix epoch is Thursday, weekly boundaries are shifted to Monday
+	offset := int64(0)
+	if partitioning == PartitioningWeekly {
+		offset = 4 * 24 * 3600
+	}
+	periodSec := int64(period / time.Second)
+
+	var chunks []timeChunk
+	chunkFrom := from.Unix()
+	end := to.Unix()
+	for chunkFrom <= end {
+		partitionEnd := (chunkFrom-offset)/periodSec*periodSec + offset + periodSec
+		boundary := partitionEnd / alignSec * alignSec
+		for boundary <= chunkFrom {
+			partitionEnd += periodSec
+			boundary = partitionEnd / alignSec * alignSec
+		}
+		chunkTo := boundary - 1
+		if chunkTo > end {
+			chunkTo = end
+		}
+		chunks = append(chunks, timeChunk{from: time.Unix(chunkFrom, 0), to: time.Unix(chunkTo, 0)})
+		chunkFrom = boundary
+	}
+	return chunks
+}
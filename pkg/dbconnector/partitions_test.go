@@ -0,0 +1,109 @@
+package dbconnector
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionChunks(t *testing.T) {
+	day := int64(24 * 3600)
+	// Monday, 2021-02-01 00:00 UTC
+	monday := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	tests := []struct {
+		name         string
+		partitioning string
+		from         int64
+		to           int64
+		alignSec     int64
+		expected     [][2]int64
+	}{
+		{
+			name:     "No partitioning",
+			from:     monday - day,
+			to:       monday + day,
+			alignSec: 1,
+			expected: [][2]int64{{monday - day, monday + day}},
+		},
+		{
+			name:         "Daily",
+			partitioning: PartitioningDaily,
+			from:         monday - 3600,
+			to:           monday + day + 3600,
+			alignSec:     1,
+			expected:     [][2]int64{{monday - 3600, monday - 1}, {monday, monday + day - 1}, {monday + day, monday + day + 3600}},
+		},
+		{
+			name:         "Daily within single partition",
+			partitioning: PartitioningDaily,
+			from:         monday + 3600,
+			to:           monday + 7200,
+			alignSec:     1,
+			expected:     [][2]int64{{monday + 3600, monday + 7200}},
+		},
+		{
+			name:         "Weekly starts on Monday",
+			partitioning: PartitioningWeekly,
+			from:         monday - day,
+			to:           monday + day,
+			alignSec:     1,
+			expected:     [][2]int64{{monday - day, monday - 1}, {monday, monday + day}},
+		},
+		{
+			name:         "Boundary aligned to the aggregation interval",
+			partitioning: PartitioningDaily,
+			from:         monday - 3600,
+			to:           monday + 3600,
+			alignSec:     7 * 60,
+			expected:     [][2]int64{{monday - 3600, monday/420*420 - 1}, {monday / 420 * 420, monday + 3600}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := partitionChunks(tt.partitioning, time.Unix(tt.from, 0), time.Unix(tt.to, 0), tt.alignSec)
+			actual := make([][2]int64, 0, len(chunks))
+			for _, chunk := range chunks {
+				actual = append(actual, [2]int64{chunk.from.Unix(), chunk.to.Unix()})
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestHistoryPartitioned(t *testing.T) {
+	monday := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC).Unix()
+	settings := Settings{Type: TypeMySQL, Partitioning: PartitioningDaily}
+	connector, mock, err := MockDBConnectorWithSettings(settings, [][]driver.Value{{int64(1), monday, int64(0), 1.5}})
+	assert.NoError(t, err)
+
+	// Mock returns the same row for each partition
+	points, err := connector.History(context.Background(), []string{"1"}, ValueTypeFloat, time.Unix(monday-3600, 0), time.Unix(monday+3600, 0))
+	assert.NoError(t, err)
+	assert.Len(t, points, 2)
+
+	queries := mock.ExecutedQueries()
+	assert.Len(t, queries, 2)
+	assert.Equal(t, []interface{}{int64(1), monday - 3600, monday - 1}, queries[0].Args)
+	assert.Equal(t, []interface{}{int64(1), monday, monday + 3600}, queries[1].Args)
+
+	// Error of any partition fails the query
+	mock.QueryErr = func(query MockQuery) error {
+		if query.Args[1] == monday {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	_, err = connector.History(context.Background(), []string{"1"}, ValueTypeFloat, time.Unix(monday-3600, 0), time.Unix(monday+3600, 0))
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestNewUnsupportedPartitioning(t *testing.T) {
+	_, err := New(Settings{Type: TypeMySQL, Partitioning: "monthly"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,147 @@
+package dbconnector
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// MockQuery is a query executed by the mock connector
+type MockQuery struct {
+	Query string
+	Args  []interface{}
+}
+
+// MockDB records queries and returns the same rows (or error) for all of them. QueryErr, QueryRows and
+// QueryColumns allow to fail particular queries or return different rows and column names for them.
+type MockDB struct {
+	mu           sync.Mutex
+	Rows         [][]driver.Value
+	Err          error
+	QueryErr     func(query MockQuery) error
+	QueryRows    func(query MockQuery) [][]driver.Value
+	QueryColumns func(query MockQuery) []string
+	Queries      []MockQuery
+}
+
+// ExecutedQueries returns queries executed by the connector
+func (m *MockDB) ExecutedQueries() []MockQuery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockQuery{}, m.Queries...)
+}
+
+// MockDBConnector returns connector of the given type using mock database, which returns given rows
+func MockDBConnector(dbType string, rows [][]driver.Value) (*DBConnector, *MockDB, error) {
+	return MockDBConnectorWithSettings(Settings{Type: dbType}, rows)
+}
+
+// MockDBConnectorWithSettings returns connector with the given settings using mock database
+func MockDBConnectorWithSettings(settings Settings, rows [][]driver.Value) (*DBConnector, *MockDB, error) {
+	d, err := getDialect(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	mock := &MockDB{Rows: rows}
+	return &DBConnector{
+		db:           sql.OpenDB(mock),
+		dialect:      d,
+		partitioning: settings.Partitioning,
+		aggregates:   &continuousAggregates{},
+		logger:       log.New(),
+	}, mock, nil
+}
+
+// Connect implements driver.Connector
+func (m *MockDB) Connect(ctx context.Context) (driver.Conn, error) {
+	return &mockConn{db: m}, nil
+}
+
+// Driver implements driver.Connector
+func (m *MockDB) Driver() driver.Driver {
+	return nil
+}
+
+type mockConn struct {
+	db *MockDB
+}
+
+func (c *mockConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported by mock")
+}
+
+func (c *mockConn) Close() error {
+	return nil
+}
+
+func (c *mockConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported by mock")
+}
+
+func (c *mockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	values := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+	mockQuery := MockQuery{Query: query, Args: values}
+	c.db.Queries = append(c.db.Queries, mockQuery)
+	if c.db.Err != nil {
+		return nil, c.db.Err
+	}
+	if c.db.QueryErr != nil {
+		if err := c.db.QueryErr(mockQuery); err != nil {
+			return nil, err
+		}
+	}
+	result := &mockRows{rows: c.db.Rows}
+	if c.db.QueryRows != nil {
+		if rows := c.db.QueryRows(mockQuery); rows != nil {
+			result.rows = rows
+		}
+	}
+	if c.db.QueryColumns != nil {
+		result.columns = c.db.QueryColumns(mockQuery)
+	}
+	return result, nil
+}
+
+type mockRows struct {
+	rows    [][]driver.Value
+	columns []string
+	pos     int
+}
+
+func (r *mockRows) Columns() []string {
+	if r.columns != nil {
+		return r.columns
+	}
+	if len(r.rows) == 0 {
+		return []string{}
+	}
+	columns := make([]string, len(r.rows[0]))
+	for i := range columns {
+		columns[i] = "column"
+	}
+	return columns
+}
+
+func (r *mockRows) Close() error {
+	return nil
+}
+
+func (r *mockRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
@@ -0,0 +1,120 @@
+package dbconnector
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+)
+
+// Continuous aggregates are detected again after this period, so new ones are used without restart
+const continuousAggregatesTTL = time.Hour
+
+// Continuous aggregates of the history tables are found by the column names. View should have itemid,
+// clock (start of the bucket) and value_avg, value_min, value_max columns like trends tables.
+const continuousAggregatesQuery = `SELECT ca.view_name, ca.hypertable_name, ca.view_definition
+FROM timescaledb_information.continuous_aggregates ca
+WHERE ca.hypertable_name IN ('history', 'history_uint')
+AND (SELECT COUNT(*) FROM information_schema.columns c
+WHERE c.table_name = ca.view_name AND c.column_name IN ('value_avg', 'value_min', 'value_max')) = 3`
+
+// Bucket width of the integer time_bucket() in the view definition
+var timeBucketRegex = regexp.MustCompile(`time_bucket\(\s*'?(\d+)'?(::\w+)?\s*,`)
+
+// Columns of the continuous aggregate for the aggregation functions, other functions can't be calculated
+// from the aggregated values
+var continuousAggregateColumns = map[string]string{
+	"avg": "value_avg",
+	"min": "value_min",
+	"max": "value_max",
+}
+
+type continuousAggregate struct {
+	view       string
+	hypertable string
+	bucketSec  int64
+}
+
+// continuousAggregates caches detected continuous aggregates of the connector
+type continuousAggregates struct {
+	mu         sync.Mutex
+	aggregates []continuousAggregate
+	updated    time.Time
+}
+
+// continuousAggregate returns view and column of the TimescaleDB continuous aggregate to query instead of the
+// history table, or empty view if there is no such aggregate. Aggregate with the largest bucket not exceeding
+// the interval is used, so fine intervals are still read from the raw hypertable.
+func (c *DBConnector) continuousAggregate(ctx context.Context, table string, interval time.Duration, aggFunction string) (string, string) {
+	if c.dialect.name() != TypeTimescaleDB || c.aggregates == nil {
+		return "", ""
+	}
+	column, ok := continuousAggregateColumns[aggFunction]
+	if !ok {
+		return "", ""
+	}
+
+	aggregates, err := c.getContinuousAggregates(ctx)
+	if err != nil {
+		requestid.Logger(ctx, c.logger).Warn("Cannot detect TimescaleDB continuous aggregates", "error", err)
+		return "", ""
+	}
+
+	intervalSec := int64(interval.Seconds())
+	var best *continuousAggregate
+	for i, aggregate := range aggregates {
+		if aggregate.hypertable != table || aggregate.bucketSec > intervalSec {
+			continue
+		}
+		if best == nil || aggregate.bucketSec > best.bucketSec {
+			best = &aggregates[i]
+		}
+	}
+	if best == nil {
+		return "", ""
+	}
+	return best.view, column
+}
+
+func (c *DBConnector) getContinuousAggregates(ctx context.Context) ([]continuousAggregate, error) {
+	c.aggregates.mu.Lock()
+	defer c.aggregates.mu.Unlock()
+	if !c.aggregates.updated.IsZero() && time.Since(c.aggregates.updated) < continuousAggregatesTTL {
+		return c.aggregates.aggregates, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, continuousAggregatesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aggregates := make([]continuousAggregate, 0)
+	for rows.Next() {
+		var view, hypertable, definition string
+		if err := rows.Scan(&view, &hypertable, &definition); err != nil {
+			return nil, err
+		}
+		match := timeBucketRegex.FindStringSubmatch(definition)
+		if match == nil {
+			requestid.Logger(ctx, c.logger).Debug("Continuous aggregate has no integer time bucket, skipping", "view", view)
+			continue
+		}
+		bucketSec, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || bucketSec < 1 {
+			continue
+		}
+		aggregates = append(aggregates, continuousAggregate{view: view, hypertable: hypertable, bucketSec: bucketSec})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	requestid.Logger(ctx, c.logger).Debug("Detected TimescaleDB continuous aggregates", "count", len(aggregates))
+	c.aggregates.aggregates = aggregates
+	c.aggregates.updated = time.Now()
+	return aggregates, nil
+}
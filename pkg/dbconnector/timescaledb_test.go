@@ -0,0 +1,84 @@
+package dbconnector
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryAggregatedContinuousAggregates(t *testing.T) {
+	connector, mock, err := MockDBConnector(TypeTimescaleDB, [][]driver.Value{{int64(1), int64(1600000000), 1.5}})
+	assert.NoError(t, err)
+	mock.QueryRows = func(query MockQuery) [][]driver.Value {
+		if strings.Contains(query.Query, "continuous_aggregates") {
+			return [][]driver.Value{
+				{"history_1h", "history", " SELECT history.itemid, time_bucket(3600, history.clock) AS clock, avg(history.value) AS value_avg"},
+				{"history_5m", "history", " SELECT history.itemid, time_bucket(300, history.clock) AS clock, avg(history.value) AS value_avg"},
+				{"history_uint_1h", "history_uint", " SELECT history_uint.itemid, time_bucket(3600, history_uint.clock) AS clock"},
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		interval    time.Duration
+		aggFunction string
+		query       string
+	}{
+		{
+			name:        "Fine interval uses hypertable",
+			interval:    time.Minute,
+			aggFunction: "avg",
+			query:       "SELECT itemid, time_bucket(60, clock) AS clock_bucket, AVG(value) FROM history WHERE itemid IN ($1) AND clock >= $2 AND clock <= $3 GROUP BY 1, 2 ORDER BY 2",
+		},
+		{
+			name:        "Largest fitting aggregate",
+			interval:    2 * time.Hour,
+			aggFunction: "max",
+			query:       "SELECT itemid, time_bucket(7200, clock) AS clock_bucket, MAX(value_max) FROM history_1h WHERE itemid IN ($1) AND clock >= $2 AND clock <= $3 GROUP BY 1, 2 ORDER BY 2",
+		},
+		{
+			name:        "Smaller aggregate",
+			interval:    10 * time.Minute,
+			aggFunction: "avg",
+			query:       "SELECT itemid, time_bucket(600, clock) AS clock_bucket, AVG(value_avg) FROM history_5m WHERE itemid IN ($1) AND clock >= $2 AND clock <= $3 GROUP BY 1, 2 ORDER BY 2",
+		},
+		{
+			name:        "Function not available in aggregate",
+			interval:    2 * time.Hour,
+			aggFunction: "count",
+			query:       "SELECT itemid, time_bucket(7200, clock) AS clock_bucket, COUNT(value) FROM history WHERE itemid IN ($1) AND clock >= $2 AND clock <= $3 GROUP BY 1, 2 ORDER BY 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := connector.HistoryAggregated(context.Background(), []string{"1"}, ValueTypeFloat, time.Unix(0, 0), time.Unix(86400, 0), tt.interval, tt.aggFunction)
+			assert.NoError(t, err)
+
+			queries := mock.ExecutedQueries()
+			assert.Equal(t, tt.query, queries[len(queries)-1].Query)
+		})
+	}
+
+	// Aggregates are detected once
+	detections := 0
+	for _, query := range mock.ExecutedQueries() {
+		if strings.Contains(query.Query, "continuous_aggregates") {
+			detections++
+		}
+	}
+	assert.Equal(t, 1, detections)
+}
+
+func TestContinuousAggregatesNotUsedForPostgres(t *testing.T) {
+	connector, mock, _ := MockDBConnector(TypePostgres, [][]driver.Value{{int64(1), int64(1600000000), 1.5}})
+	_, err := connector.HistoryAggregated(context.Background(), []string{"1"}, ValueTypeFloat, time.Unix(0, 0), time.Unix(86400, 0), time.Hour, "avg")
+	assert.NoError(t, err)
+	assert.Len(t, mock.ExecutedQueries(), 1)
+}
@@ -0,0 +1,365 @@
+package esconnector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// TypeElasticsearch is a type of the history storage
+const TypeElasticsearch = "elasticsearch"
+
+// SchemaDateIndex is a layout with daily indices like uint-2021-01-31, used when HistoryStorageDateIndex
+// is enabled in the Zabbix server config
+const SchemaDateIndex = "date-index"
+
+// Page size of the search requests
+const searchPageSize = 10000
+
+// Date indices are listed explicitly for shorter ranges, longer ones are queried with the index pattern
+const maxDateIndices = 31
+
+// ErrTrendsNotSupported is returned when trends are requested, Zabbix stores only history in Elasticsearch
+var ErrTrendsNotSupported = errors.New("trends are not stored in Elasticsearch")
+
+// Indices of the Zabbix history for the item value types
+var historyIndices = map[int]string{
+	dbconnector.ValueTypeFloat: "dbl",
+	dbconnector.ValueTypeUint:  "uint",
+	dbconnector.ValueTypeChar:  "str",
+	dbconnector.ValueTypeText:  "text",
+	dbconnector.ValueTypeLog:   "log",
+}
+
+// Settings of the connection to Elasticsearch
+type Settings struct {
+	URL      string
+	User     string
+	Password string
+	Timeout  time.Duration
+	Schema   string
+	// Logger of the data source, new logger is created if not set
+	Logger log.Logger
+}
+
+// ESConnector reads history of the items from the Elasticsearch indices written by Zabbix server
+type ESConnector struct {
+	url        *url.URL
+	httpClient *http.Client
+	user       string
+	password   string
+	dateIndex  bool
+	logger     log.Logger
+}
+
+type searchResponse struct {
+	Hits struct {
+		Hits []searchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+type searchHit struct {
+	Source struct {
+		ItemID json.Number `json:"itemid"`
+		Clock  json.Number `json:"clock"`
+		NS     json.Number `json:"ns"`
+		// Value is a number or a string depending on the index
+		Value json.RawMessage `json:"value"`
+	} `json:"_source"`
+	Sort []interface{} `json:"sort"`
+}
+
+// New returns connector to the Elasticsearch with the given URL
+func New(settings Settings) (*ESConnector, error) {
+	esURL, err := url.Parse(settings.URL)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Schema != "" && settings.Schema != SchemaDateIndex {
+		return nil, fmt.Errorf("unsupported Elasticsearch schema: %s", settings.Schema)
+	}
+
+	logger := settings.Logger
+	if logger == nil {
+		logger = log.New()
+	}
+
+	return &ESConnector{
+		url:        esURL,
+		httpClient: &http.Client{Timeout: settings.Timeout},
+		user:       settings.User,
+		password:   settings.Password,
+		dateIndex:  settings.Schema == SchemaDateIndex,
+		logger:     logger,
+	}, nil
+}
+
+// Type returns type of the history storage
+func (c *ESConnector) Type() string {
+	return TypeElasticsearch
+}
+
+// HasTrends returns false, trends are requested from the API
+func (c *ESConnector) HasTrends() bool {
+	return false
+}
+
+// History returns values of the numeric items with the given value type within the time range, ordered by time
+func (c *ESConnector) History(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time) ([]dbconnector.Point, error) {
+	if valueType != dbconnector.ValueTypeFloat && valueType != dbconnector.ValueTypeUint {
+		return nil, fmt.Errorf("unsupported value type: %d", valueType)
+	}
+
+	points := make([]dbconnector.Point, 0)
+	err := c.searchHistory(ctx, itemids, valueType, from, to, func(hit searchHit) error {
+		point, err := hitToPoint(hit)
+		if err != nil {
+			return err
+		}
+		points = append(points, point)
+		return nil
+	})
+	return points, err
+}
+
+// TextHistory returns values of the character, text or log items within the time range, ordered by time. Zabbix
+// doesn't store source, severity and event id of the log values in Elasticsearch.
+func (c *ESConnector) TextHistory(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time) ([]dbconnector.TextPoint, error) {
+	if valueType != dbconnector.ValueTypeChar && valueType != dbconnector.ValueTypeText && valueType != dbconnector.ValueTypeLog {
+		return nil, fmt.Errorf("unsupported value type: %d", valueType)
+	}
+
+	points := make([]dbconnector.TextPoint, 0)
+	err := c.searchHistory(ctx, itemids, valueType, from, to, func(hit searchHit) error {
+		point, err := hitToTextPoint(hit)
+		if err != nil {
+			return err
+		}
+		points = append(points, point)
+		return nil
+	})
+	return points, err
+}
+
+// searchHistory calls handle for each value of the items within the time range in time order. Results are read
+// page by page with search_after, so the number of values isn't limited by the max_result_window of the index.
+func (c *ESConnector) searchHistory(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time, handle func(hit searchHit) error) error {
+	if len(itemids) == 0 {
+		return fmt.Errorf("no items to query")
+	}
+	ids := make([]int64, 0, len(itemids))
+	for _, itemid := range itemids {
+		id, err := strconv.ParseInt(itemid, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid item id %s: %w", itemid, err)
+		}
+		ids = append(ids, id)
+	}
+
+	indices, err := c.indices(valueType, from, to)
+	if err != nil {
+		return err
+	}
+
+	query := map[string]interface{}{
+		"size":    searchPageSize,
+		"_source": []string{"itemid", "clock", "ns", "value"},
+		"sort":    []map[string]string{{"clock": "asc"}, {"ns": "asc"}, {"itemid": "asc"}},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"terms": map[string]interface{}{"itemid": ids}},
+					map[string]interface{}{"range": map[string]interface{}{
+						"clock": map[string]interface{}{"gte": from.Unix(), "lte": to.Unix(), "format": "epoch_second"},
+					}},
+				},
+			},
+		},
+	}
+
+	for {
+		response, err := c.search(ctx, indices, query)
+		if err != nil {
+			return err
+		}
+
+		hits := response.Hits.Hits
+		for _, hit := range hits {
+			if err := handle(hit); err != nil {
+				return err
+			}
+		}
+
+		if len(hits) < searchPageSize {
+			return nil
+		}
+		query["search_after"] = hits[len(hits)-1].Sort
+	}
+}
+
+// Trends returns ErrTrendsNotSupported
+func (c *ESConnector) Trends(ctx context.Context, itemids []string, valueType int, from time.Time, to time.Time, trendValue string) ([]dbconnector.Point, error) {
+	return nil, ErrTrendsNotSupported
+}
+
+// TestConnection checks that Elasticsearch is reachable with the given credentials
+func (c *ESConnector) TestConnection(ctx context.Context) error {
+	_, err := c.request(ctx, http.MethodGet, "/", nil)
+	return err
+}
+
+// Close closes idle connections
+func (c *ESConnector) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// indices returns comma-separated list of the history indices for the value type. Daily indices of the time
+// range are listed if date-based indices are used, missing ones are ignored by the search.
+func (c *ESConnector) indices(valueType int, from time.Time, to time.Time) (string, error) {
+	index, ok := historyIndices[valueType]
+	if !ok {
+		return "", fmt.Errorf("unsupported value type: %d", valueType)
+	}
+	if !c.dateIndex {
+		return index, nil
+	}
+
+	from = from.UTC().Truncate(24 * time.Hour)
+	to = to.UTC()
+	if to.Sub(from) > maxDateIndices*24*time.Hour {
+		return index + "-*", nil
+	}
+
+	var indices []string
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		indices = append(indices, index+"-"+day.Format("2006-01-02"))
+	}
+	return strings.Join(indices, ","), nil
+}
+
+// search sends search request to the indices, request is recorded as "POST /indices/_search {query}"
+func (c *ESConnector) search(ctx context.Context, indices string, query map[string]interface{}) (response *searchResponse, err error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	requestid.Logger(ctx, c.logger).Debug("Elasticsearch query", "indices", indices, "query", string(body))
+	path := "/" + indices + "/_search"
+	start := time.Now()
+	defer func() {
+		hits := 0
+		if response != nil {
+			hits = len(response.Hits.Hits)
+		}
+		dbconnector.RecordQuery(ctx, fmt.Sprintf("%s %s %s", http.MethodPost, path, body), nil, start, hits, err)
+	}()
+
+	responseBody, err := c.request(ctx, http.MethodPost, path+"?ignore_unavailable=true", body)
+	if err != nil {
+		return nil, err
+	}
+
+	response = &searchResponse{}
+	decoder := json.NewDecoder(bytes.NewReader(responseBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(response); err != nil {
+		return nil, fmt.Errorf("error parsing Elasticsearch response: %w", err)
+	}
+	return response, nil
+}
+
+func (c *ESConnector) request(ctx context.Context, method string, path string, body []byte) ([]byte, error) {
+	requestURL, err := c.url.Parse(strings.TrimSuffix(c.url.Path, "/") + path)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, requestURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		req.Header.Set(requestid.Header, requestID)
+	}
+
+	res, err := ctxhttp.Do(ctx, c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Elasticsearch request failed with status %s: %s", res.Status, string(responseBody))
+	}
+	return responseBody, nil
+}
+
+func hitToPoint(hit searchHit) (dbconnector.Point, error) {
+	point := dbconnector.Point{}
+	clock, ns, err := hitTime(hit)
+	if err != nil {
+		return point, err
+	}
+	point.ItemID, point.Clock, point.NS = hit.Source.ItemID.String(), clock, ns
+
+	var value json.Number
+	if err := json.Unmarshal(hit.Source.Value, &value); err != nil {
+		return point, fmt.Errorf("invalid value %s: %w", hit.Source.Value, err)
+	}
+	point.Value, err = value.Float64()
+	if err != nil {
+		return point, fmt.Errorf("invalid value %s: %w", value, err)
+	}
+	return point, nil
+}
+
+func hitToTextPoint(hit searchHit) (dbconnector.TextPoint, error) {
+	point := dbconnector.TextPoint{}
+	clock, ns, err := hitTime(hit)
+	if err != nil {
+		return point, err
+	}
+	point.ItemID, point.Clock, point.NS = hit.Source.ItemID.String(), clock, ns
+
+	if err := json.Unmarshal(hit.Source.Value, &point.Value); err != nil {
+		return point, fmt.Errorf("invalid value %s: %w", hit.Source.Value, err)
+	}
+	return point, nil
+}
+
+// hitTime returns clock and nanoseconds of the value, nanoseconds are optional
+func hitTime(hit searchHit) (int64, int64, error) {
+	clock, err := hit.Source.Clock.Int64()
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid clock %s: %w", hit.Source.Clock, err)
+	}
+
+	var ns int64
+	if hit.Source.NS != "" {
+		ns, _ = hit.Source.NS.Int64()
+	}
+	return clock, ns, nil
+}
@@ -0,0 +1,146 @@
+package esconnector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	connector := MockESConnector(false, func(req *http.Request) *http.Response {
+		requests = append(requests, req)
+		body, _ := ioutil.ReadAll(req.Body)
+		query := map[string]interface{}{}
+		_ = json.Unmarshal(body, &query)
+		bodies = append(bodies, query)
+		return MockResponse(`{"hits":{"hits":[
+			{"_source":{"itemid":1,"clock":1600000000,"ns":100,"value":1.5},"sort":[1600000000000,100,1]},
+			{"_source":{"itemid":2,"clock":1600000060,"value":2}}
+		]}}`, 200)
+	})
+
+	points, err := connector.History(context.Background(), []string{"1", "2"}, dbconnector.ValueTypeFloat, time.Unix(1600000000, 0), time.Unix(1600003600, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, []dbconnector.Point{
+		{ItemID: "1", Clock: 1600000000, NS: 100, Value: 1.5},
+		{ItemID: "2", Clock: 1600000060, Value: 2},
+	}, points)
+
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "/dbl/_search", requests[0].URL.Path)
+	assert.Equal(t, "true", requests[0].URL.Query().Get("ignore_unavailable"))
+	filter := bodies[0]["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"itemid": []interface{}{float64(1), float64(2)}}, filter[0].(map[string]interface{})["terms"])
+}
+
+func TestTextHistory(t *testing.T) {
+	var requests []*http.Request
+	connector := MockESConnector(false, func(req *http.Request) *http.Response {
+		requests = append(requests, req)
+		return MockResponse(`{"hits":{"hits":[{"_source":{"itemid":1,"clock":1600000000,"ns":100,"value":"Service started"}}]}}`, 200)
+	})
+
+	points, err := connector.TextHistory(context.Background(), []string{"1"}, dbconnector.ValueTypeLog, time.Unix(1600000000, 0), time.Unix(1600003600, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, []dbconnector.TextPoint{{ItemID: "1", Clock: 1600000000, NS: 100, Value: "Service started"}}, points)
+	assert.Equal(t, "/log/_search", requests[0].URL.Path)
+
+	_, err = connector.TextHistory(context.Background(), []string{"1"}, dbconnector.ValueTypeFloat, time.Unix(1600000000, 0), time.Unix(1600003600, 0))
+	assert.Error(t, err)
+}
+
+func TestHistoryPagination(t *testing.T) {
+	var searchAfter []interface{}
+	requests := 0
+	connector := MockESConnector(false, func(req *http.Request) *http.Response {
+		requests++
+		body, _ := ioutil.ReadAll(req.Body)
+		query := map[string]interface{}{}
+		_ = json.Unmarshal(body, &query)
+		if requests == 1 {
+			hits := make([]string, searchPageSize)
+			for i := range hits {
+				hits[i] = fmt.Sprintf(`{"_source":{"itemid":1,"clock":%d,"value":1},"sort":[%d,0,1]}`, i, i*1000)
+			}
+			return MockResponse(`{"hits":{"hits":[`+strings.Join(hits, ",")+`]}}`, 200)
+		}
+		searchAfter, _ = query["search_after"].([]interface{})
+		return MockResponse(`{"hits":{"hits":[{"_source":{"itemid":1,"clock":20000,"value":1}}]}}`, 200)
+	})
+
+	points, err := connector.History(context.Background(), []string{"1"}, dbconnector.ValueTypeUint, time.Unix(0, 0), time.Unix(30000, 0))
+	assert.NoError(t, err)
+	assert.Len(t, points, searchPageSize+1)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, []interface{}{float64((searchPageSize - 1) * 1000), float64(0), float64(1)}, searchAfter)
+}
+
+func TestHistoryError(t *testing.T) {
+	connector := MockESConnector(false, func(req *http.Request) *http.Response {
+		return MockResponse(`{"error":{"type":"security_exception"}}`, 403)
+	})
+	_, err := connector.History(context.Background(), []string{"1"}, dbconnector.ValueTypeFloat, time.Unix(0, 0), time.Unix(3600, 0))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "security_exception")
+
+	_, err = connector.History(context.Background(), []string{"1"}, 4, time.Unix(0, 0), time.Unix(3600, 0))
+	assert.Error(t, err)
+
+	_, err = connector.Trends(context.Background(), []string{"1"}, dbconnector.ValueTypeFloat, time.Unix(0, 0), time.Unix(3600, 0), "avg")
+	assert.Equal(t, ErrTrendsNotSupported, err)
+}
+
+func TestIndices(t *testing.T) {
+	tests := []struct {
+		name      string
+		dateIndex bool
+		from      time.Time
+		to        time.Time
+		expected  string
+	}{
+		{
+			name:     "Single index",
+			from:     time.Date(2021, 1, 30, 12, 0, 0, 0, time.UTC),
+			to:       time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC),
+			expected: "uint",
+		},
+		{
+			name:      "Daily indices",
+			dateIndex: true,
+			from:      time.Date(2021, 1, 30, 12, 0, 0, 0, time.UTC),
+			to:        time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC),
+			expected:  "uint-2021-01-30,uint-2021-01-31,uint-2021-02-01",
+		},
+		{
+			name:      "Index pattern for long range",
+			dateIndex: true,
+			from:      time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:        time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+			expected:  "uint-*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := MockESConnector(tt.dateIndex, nil)
+			indices, err := connector.indices(dbconnector.ValueTypeUint, tt.from, tt.to)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, indices)
+		})
+	}
+}
+
+func TestNewUnsupportedSchema(t *testing.T) {
+	_, err := New(Settings{URL: "http://localhost:9200", Schema: "weekly"})
+	assert.Error(t, err)
+}
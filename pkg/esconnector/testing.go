@@ -0,0 +1,39 @@
+package esconnector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// RoundTripFunc returns response for the request sent by the mock connector
+type RoundTripFunc func(req *http.Request) *http.Response
+
+// RoundTrip implements http.RoundTripper
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// MockESConnector returns connector sending requests to the given function instead of Elasticsearch
+func MockESConnector(dateIndex bool, fn RoundTripFunc) *ESConnector {
+	esURL, _ := url.Parse("http://elasticsearch:9200")
+	return &ESConnector{
+		url:        esURL,
+		httpClient: &http.Client{Transport: fn},
+		dateIndex:  dateIndex,
+		logger:     log.New(),
+	}
+}
+
+// MockResponse returns response with the given body and status
+func MockResponse(body string, statusCode int) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Status:     http.StatusText(statusCode),
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		Header:     make(http.Header),
+	}
+}
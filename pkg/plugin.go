@@ -8,6 +8,11 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+
+	// Drivers of the direct DB connection
+	_ "github.com/ClickHouse/clickhouse-go"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 )
 
 const ZABBIX_PLUGIN_ID = "alexanderzobnin-zabbix-datasource"
@@ -26,6 +31,7 @@ func main() {
 		CallResourceHandler: httpResourceHandler,
 		QueryDataHandler:    ds,
 		CheckHealthHandler:  ds,
+		StreamHandler:       ds,
 	})
 	if err != nil {
 		pluginLogger.Error("Error starting Zabbix datasource", "error", err.Error())
@@ -45,6 +51,23 @@ func Init(logger log.Logger, mux *http.ServeMux) *datasource.ZabbixDatasource {
 
 	mux.HandleFunc("/", ds.RootHandler)
 	mux.HandleFunc("/zabbix-api", ds.ZabbixAPIHandler)
+	mux.HandleFunc("/zabbix-api/groups", ds.DiscoveryHandler)
+	mux.HandleFunc("/zabbix-api/hosts", ds.DiscoveryHandler)
+	mux.HandleFunc("/zabbix-api/apps", ds.DiscoveryHandler)
+	mux.HandleFunc("/zabbix-api/items", ds.DiscoveryHandler)
+	mux.HandleFunc("/zabbix-api/group-tree", ds.GroupTreeHandler)
+	mux.HandleFunc("/zabbix-api/item-search", ds.ItemSearchHandler)
+	mux.HandleFunc("/zabbix-api/map-layer", ds.MapLayerHandler)
+	mux.HandleFunc("/variable-query", ds.VariableQueryHandler)
+	mux.HandleFunc("/query/validate", ds.QueryValidateHandler)
+	mux.HandleFunc("/query/explain", ds.QueryExplainHandler)
+	mux.HandleFunc("/query/export", ds.QueryExportHandler)
+	mux.HandleFunc("/trigger-conditions", ds.TriggerConditionsHandler)
+	mux.HandleFunc("/alert-webhook", ds.AlertWebhookHandler)
+	mux.HandleFunc("/alert-rules", ds.AlertRulesHandler)
+	mux.HandleFunc("/dashboard", ds.DashboardHandler)
+	mux.HandleFunc("/health/details", ds.HealthDetailsHandler)
+	mux.HandleFunc("/query-stats", ds.QueryStatsHandler)
 	// mux.Handle("/scenarios", getScenariosHandler(logger))
 
 	return ds
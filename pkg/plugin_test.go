@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/dbconnector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBConnectorDrivers(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings dbconnector.Settings
+	}{
+		{
+			name:     "MySQL",
+			settings: dbconnector.Settings{Type: dbconnector.TypeMySQL, Host: "127.0.0.1:1", Database: "zabbix", User: "zabbix", Password: "secret"},
+		},
+		{
+			name:     "PostgreSQL",
+			settings: dbconnector.Settings{Type: dbconnector.TypePostgres, Host: "127.0.0.1:1", Database: "zabbix", User: "zabbix", Password: "secret"},
+		},
+		{
+			name:     "TimescaleDB",
+			settings: dbconnector.Settings{Type: dbconnector.TypeTimescaleDB, Host: "127.0.0.1:1", Database: "zabbix", User: "zabbix", Password: "secret"},
+		},
+		{
+			name:     "ClickHouse",
+			settings: dbconnector.Settings{Type: dbconnector.TypeClickHouse, Host: "127.0.0.1:1", Database: "zabbix", User: "zabbix", Password: "secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.settings.Timeout = time.Second
+			connector, err := dbconnector.New(tt.settings)
+			assert.NoError(t, err)
+
+			// Driver is registered, so the query fails connecting to the database rather than with unknown driver
+			_, err = connector.History(context.Background(), []string{"1"}, dbconnector.ValueTypeFloat, time.Unix(0, 0), time.Unix(60, 0))
+			assert.Error(t, err)
+			assert.NotContains(t, err.Error(), "unknown driver")
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Package requestid threads ID of the Grafana request through the context, so log messages and requests to
+// Zabbix made while handling one dashboard refresh can be correlated.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// Header is a header with the request ID sent to Zabbix and Elasticsearch, so requests can be found in their
+// access logs
+const Header = "X-Request-Id"
+
+// Headers of the incoming requests holding the request ID, in order of priority. Trace ID is used if the
+// request is traced.
+var incomingHeaders = []string{"X-Grafana-Request-Id", "X-Request-Id", "Traceparent", "Uber-Trace-Id"}
+
+type requestIDKey struct{}
+
+// With returns context of the request with the given ID
+func With(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// FromContext returns ID of the request or empty string if context has no ID
+func FromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromHeaders returns request or trace ID of the incoming request headers. New ID is generated if headers have
+// no ID.
+func FromHeaders(getHeader func(name string) string) string {
+	for _, name := range incomingHeaders {
+		value := strings.TrimSpace(getHeader(name))
+		if value == "" {
+			continue
+		}
+		switch name {
+		case "Traceparent":
+			// W3C trace context: version-traceid-parentid-flags
+			if parts := strings.Split(value, "-"); len(parts) == 4 {
+				return parts[1]
+			}
+		case "Uber-Trace-Id":
+			// Jaeger: traceid:spanid:parentid:flags
+			if parts := strings.Split(value, ":"); len(parts) == 4 {
+				return parts[0]
+			}
+		default:
+			return value
+		}
+	}
+	return New()
+}
+
+// FromMap returns request ID of the headers map, header names are matched case-insensitively
+func FromMap(headers map[string]string) string {
+	return FromHeaders(func(name string) string {
+		for key, value := range headers {
+			if strings.EqualFold(key, name) {
+				return value
+			}
+		}
+		return ""
+	})
+}
+
+// New returns random request ID
+func New() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// Logger returns logger adding request ID of the context to the messages
+func Logger(ctx context.Context, logger log.Logger) log.Logger {
+	requestID := FromContext(ctx)
+	if requestID == "" {
+		return logger
+	}
+	return &requestLogger{logger: logger, requestID: requestID}
+}
+
+type requestLogger struct {
+	logger    log.Logger
+	requestID string
+}
+
+func (l *requestLogger) Debug(msg string, args ...interface{}) {
+	l.logger.Debug(msg, l.withRequestID(args)...)
+}
+
+func (l *requestLogger) Info(msg string, args ...interface{}) {
+	l.logger.Info(msg, l.withRequestID(args)...)
+}
+
+func (l *requestLogger) Warn(msg string, args ...interface{}) {
+	l.logger.Warn(msg, l.withRequestID(args)...)
+}
+
+func (l *requestLogger) Error(msg string, args ...interface{}) {
+	l.logger.Error(msg, l.withRequestID(args)...)
+}
+
+func (l *requestLogger) withRequestID(args []interface{}) []interface{} {
+	return append([]interface{}{"requestId", l.requestID}, args...)
+}
@@ -0,0 +1,67 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "grafana request id",
+			headers: map[string]string{"X-Grafana-Request-Id": "grafana-id", "X-Request-Id": "proxy-id"},
+			want:    "grafana-id",
+		},
+		{
+			name:    "proxy request id",
+			headers: map[string]string{"x-request-id": " proxy-id "},
+			want:    "proxy-id",
+		},
+		{
+			name:    "w3c trace",
+			headers: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			want:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:    "jaeger trace",
+			headers: map[string]string{"Uber-Trace-Id": "5d5d5d5d5d5d5d5d:1a2b3c4d:0:1"},
+			want:    "5d5d5d5d5d5d5d5d",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FromMap(tt.headers))
+		})
+	}
+
+	// Invalid trace header and no headers generate new ID
+	generated := FromMap(map[string]string{"traceparent": "invalid"})
+	assert.Len(t, generated, 16)
+	assert.NotEqual(t, generated, FromMap(nil))
+}
+
+type testLogger struct {
+	args []interface{}
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.args = args }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.args = args }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.args = args }
+func (l *testLogger) Error(msg string, args ...interface{}) { l.args = args }
+
+func TestLogger(t *testing.T) {
+	out := &testLogger{}
+	Logger(context.Background(), out).Info("Query", "refId", "A")
+	assert.Equal(t, []interface{}{"refId", "A"}, out.args)
+
+	ctx := With(context.Background(), "abc123")
+	assert.Equal(t, "abc123", FromContext(ctx))
+	Logger(ctx, out).Warn("Query", "refId", "A")
+	assert.Equal(t, []interface{}{"requestId", "abc123", "refId", "A"}, out.args)
+}
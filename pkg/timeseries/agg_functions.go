@@ -0,0 +1,115 @@
+package timeseries
+
+import (
+	"math"
+	"sort"
+)
+
+// AggFunc reduces a set of points to a single value. Null values are ignored,
+// nil is returned if there are no values to aggregate.
+type AggFunc = func(points []TimePoint) *float64
+
+func AggAvg(points []TimePoint) *float64 {
+	sum := AggSum(points)
+	if sum == nil {
+		return nil
+	}
+	avg := *sum / float64(len(getNonNullValues(points)))
+	return &avg
+}
+
+func AggSum(points []TimePoint) *float64 {
+	values := getNonNullValues(points)
+	if len(values) == 0 {
+		return nil
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return &sum
+}
+
+func AggMax(points []TimePoint) *float64 {
+	values := getNonNullValues(points)
+	if len(values) == 0 {
+		return nil
+	}
+
+	max := values[0]
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	return &max
+}
+
+func AggMin(points []TimePoint) *float64 {
+	values := getNonNullValues(points)
+	if len(values) == 0 {
+		return nil
+	}
+
+	min := values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+	}
+	return &min
+}
+
+func AggCount(points []TimePoint) *float64 {
+	count := float64(len(getNonNullValues(points)))
+	return &count
+}
+
+func AggMedian(points []TimePoint) *float64 {
+	return AggPercentile(50)(points)
+}
+
+func AggFirst(points []TimePoint) *float64 {
+	for _, p := range points {
+		if p.Value != nil {
+			value := *p.Value
+			return &value
+		}
+	}
+	return nil
+}
+
+func AggLast(points []TimePoint) *float64 {
+	for i := len(points) - 1; i >= 0; i-- {
+		if points[i].Value != nil {
+			value := *points[i].Value
+			return &value
+		}
+	}
+	return nil
+}
+
+// AggPercentile returns aggregation function calculating n-th percentile (nearest rank method).
+func AggPercentile(n float64) AggFunc {
+	return func(points []TimePoint) *float64 {
+		values := getNonNullValues(points)
+		if len(values) == 0 {
+			return nil
+		}
+
+		sort.Float64s(values)
+		percentIndex := int(math.Floor(float64(len(values)) * n / 100))
+		if percentIndex >= len(values) {
+			percentIndex = len(values) - 1
+		}
+		percentile := values[percentIndex]
+		return &percentile
+	}
+}
+
+func getNonNullValues(points []TimePoint) []float64 {
+	values := make([]float64, 0, len(points))
+	for _, p := range points {
+		if p.Value != nil {
+			values = append(values, *p.Value)
+		}
+	}
+	return values
+}
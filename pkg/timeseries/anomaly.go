@@ -0,0 +1,84 @@
+package timeseries
+
+import (
+	"math"
+	"sort"
+)
+
+// Scales MAD to be consistent with standard deviation for normally distributed data
+const madScale = 0.6745
+
+// RollingZScore returns series with z-score of each point relative to the preceding window of non-null
+// points. Score is null if there's not enough data in the window or it has no variance.
+func (ts TimeSeries) RollingZScore(window int) TimeSeries {
+	return ts.rollingScore(window, func(value float64, windowValues []float64) *float64 {
+		avg := mean(windowValues)
+		variance := 0.0
+		for _, v := range windowValues {
+			variance += (v - avg) * (v - avg)
+		}
+		std := math.Sqrt(variance / float64(len(windowValues)))
+		return scoreOrNil(value-avg, std)
+	})
+}
+
+// RollingMADScore returns series with the modified z-score (based on median absolute deviation) of each point
+// relative to the preceding window of non-null points. It's less sensitive to the outliers in the window than z-score.
+func (ts TimeSeries) RollingMADScore(window int) TimeSeries {
+	return ts.rollingScore(window, func(value float64, windowValues []float64) *float64 {
+		med := median(windowValues)
+		deviations := make([]float64, len(windowValues))
+		for i, v := range windowValues {
+			deviations[i] = math.Abs(v - med)
+		}
+		mad := median(deviations)
+		return scoreOrNil(madScale*(value-med), mad)
+	})
+}
+
+func (ts TimeSeries) rollingScore(window int, score func(value float64, windowValues []float64) *float64) TimeSeries {
+	result := make(TimeSeries, 0, len(ts))
+	windowValues := make([]float64, 0, window)
+	for _, p := range ts {
+		point := TimePoint{Time: p.Time}
+		if p.Value == nil {
+			result = append(result, point)
+			continue
+		}
+
+		if len(windowValues) >= 2 {
+			point.Value = score(*p.Value, windowValues)
+		}
+		result = append(result, point)
+
+		windowValues = append(windowValues, *p.Value)
+		if len(windowValues) > window {
+			windowValues = windowValues[1:]
+		}
+	}
+	return result
+}
+
+// scoreOrNil returns deviation divided by the spread. Zero spread gives zero score for zero deviation and null otherwise.
+func scoreOrNil(deviation float64, spread float64) *float64 {
+	if spread == 0 {
+		if deviation == 0 {
+			zero := 0.0
+			return &zero
+		}
+		return nil
+	}
+	value := deviation / spread
+	return &value
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
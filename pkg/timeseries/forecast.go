@@ -0,0 +1,172 @@
+package timeseries
+
+import (
+	"time"
+)
+
+// Smoothing factors used by Holt-Winters forecast (level, trend and seasonal components)
+const (
+	holtWintersAlpha = 0.5
+	holtWintersBeta  = 0.1
+	holtWintersGamma = 0.1
+)
+
+// LinearRegression returns slope (per second) and intercept (at the unix epoch) of the least squares
+// line fitted to the non-null points. ok is false if line can't be fitted (less than 2 distinct timestamps).
+func (ts TimeSeries) LinearRegression() (slope float64, intercept float64, ok bool) {
+	var n, sumX, sumY float64
+	for _, p := range ts {
+		if p.Value != nil {
+			n++
+			sumX += unixSeconds(p.Time)
+			sumY += *p.Value
+		}
+	}
+	if n < 2 {
+		return 0, 0, false
+	}
+
+	// Use deviations from the mean to avoid losing precision on large unix timestamps
+	meanX := sumX / n
+	meanY := sumY / n
+	var sumXY, sumXX float64
+	for _, p := range ts {
+		if p.Value != nil {
+			dx := unixSeconds(p.Time) - meanX
+			sumXY += dx * (*p.Value - meanY)
+			sumXX += dx * dx
+		}
+	}
+	if sumXX == 0 {
+		return 0, 0, false
+	}
+	slope = sumXY / sumXX
+	intercept = meanY - slope*meanX
+	return slope, intercept, true
+}
+
+// Trendline returns series with the values of linear regression line at each point timestamp.
+func (ts TimeSeries) Trendline() TimeSeries {
+	slope, intercept, ok := ts.LinearRegression()
+	if !ok {
+		return NewTimeSeries()
+	}
+
+	result := make(TimeSeries, 0, len(ts))
+	for _, p := range ts {
+		value := linearValueAt(p.Time, slope, intercept)
+		result = append(result, TimePoint{Time: p.Time, Value: &value})
+	}
+	return result
+}
+
+// ForecastLinear extrapolates linear regression line of the series to the horizon after the last point,
+// with one point per step.
+func (ts TimeSeries) ForecastLinear(horizon time.Duration, step time.Duration) TimeSeries {
+	slope, intercept, ok := ts.LinearRegression()
+	if !ok || step <= 0 {
+		return NewTimeSeries()
+	}
+
+	result := NewTimeSeries()
+	last := ts[len(ts)-1].Time
+	for t := last.Add(step); !t.After(last.Add(horizon)); t = t.Add(step) {
+		value := linearValueAt(t, slope, intercept)
+		result = append(result, TimePoint{Time: t, Value: &value})
+	}
+	return result
+}
+
+// ForecastHoltWinters predicts values to the horizon after the last point using additive Holt-Winters
+// (triple exponential smoothing) with given season. Series is treated as regular with the given interval
+// between points, nulls are skipped. If series is shorter than two seasons, seasonal component is omitted
+// (Holt's double exponential smoothing).
+func (ts TimeSeries) ForecastHoltWinters(horizon time.Duration, step time.Duration, interval time.Duration, season time.Duration) TimeSeries {
+	if step <= 0 || interval <= 0 {
+		return NewTimeSeries()
+	}
+
+	values := make([]float64, 0, len(ts))
+	for _, p := range ts {
+		if p.Value != nil {
+			values = append(values, *p.Value)
+		}
+	}
+	if len(values) < 2 {
+		return NewTimeSeries()
+	}
+
+	seasonLen := int(season / interval)
+	var level, trend float64
+	var seasonals []float64
+	if seasonLen >= 2 && len(values) >= 2*seasonLen {
+		level, trend, seasonals = holtWinters(values, seasonLen)
+	} else {
+		level, trend = holt(values)
+	}
+
+	result := NewTimeSeries()
+	last := ts[len(ts)-1].Time
+	for t := last.Add(step); !t.After(last.Add(horizon)); t = t.Add(step) {
+		m := int(t.Sub(last) / interval)
+		if m < 1 {
+			m = 1
+		}
+		value := level + float64(m)*trend
+		if seasonals != nil {
+			value += seasonals[(len(values)+m-1)%seasonLen]
+		}
+		result = append(result, TimePoint{Time: t, Value: &value})
+	}
+	return result
+}
+
+// holt returns final level and trend of double exponential smoothing
+func holt(values []float64) (level float64, trend float64) {
+	level = values[0]
+	trend = values[1] - values[0]
+	for _, y := range values[1:] {
+		lastLevel := level
+		level = holtWintersAlpha*y + (1-holtWintersAlpha)*(level+trend)
+		trend = holtWintersBeta*(level-lastLevel) + (1-holtWintersBeta)*trend
+	}
+	return level, trend
+}
+
+// holtWinters returns final level, trend and seasonal components of additive triple exponential smoothing.
+// Seasonal component for the point i is seasonals[i % seasonLen].
+func holtWinters(values []float64, seasonLen int) (level float64, trend float64, seasonals []float64) {
+	firstSeasonAvg := mean(values[:seasonLen])
+	secondSeasonAvg := mean(values[seasonLen : 2*seasonLen])
+	level = firstSeasonAvg
+	trend = (secondSeasonAvg - firstSeasonAvg) / float64(seasonLen)
+	seasonals = make([]float64, seasonLen)
+	for i := 0; i < seasonLen; i++ {
+		seasonals[i] = values[i] - firstSeasonAvg
+	}
+
+	for i, y := range values {
+		s := seasonals[i%seasonLen]
+		lastLevel := level
+		level = holtWintersAlpha*(y-s) + (1-holtWintersAlpha)*(level+trend)
+		trend = holtWintersBeta*(level-lastLevel) + (1-holtWintersBeta)*trend
+		seasonals[i%seasonLen] = holtWintersGamma*(y-level) + (1-holtWintersGamma)*s
+	}
+	return level, trend, seasonals
+}
+
+func linearValueAt(t time.Time, slope float64, intercept float64) float64 {
+	return slope*unixSeconds(t) + intercept
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
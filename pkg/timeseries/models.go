@@ -1,6 +1,10 @@
 package timeseries
 
-import "time"
+import (
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
 
 type TimePoint struct {
 	Time  time.Time
@@ -16,3 +20,62 @@ func NewTimeSeries() TimeSeries {
 func (ts *TimeSeries) Len() int {
 	return len(*ts)
 }
+
+// TimeSeriesData is a time series with the metadata needed to build a data frame field from it.
+type TimeSeriesData struct {
+	TS   TimeSeries
+	Meta TimeSeriesMeta
+}
+
+type TimeSeriesMeta struct {
+	Name string
+
+	// Item update interval. nil means unknown interval (detected from the data).
+	Interval UpdateInterval
+
+	// Labels describing the source of the series (host, item, etc)
+	Labels data.Labels
+
+	// State of the source item ("Normal" or "Not supported") and the error text of the not supported item
+	ItemState string
+	ItemError string
+
+	// Host groups of the series host, set only when needed for the aggregation by group
+	HostGroups []string
+
+	// Display config (value mappings, etc) for the data frame field built from the series
+	FieldConfig *data.FieldConfig
+}
+
+// UpdateInterval describes expected interval between series points, which can vary over time.
+type UpdateInterval interface {
+	IntervalAt(t time.Time) time.Duration
+	IsZero() bool
+}
+
+// FixedInterval is an update interval which is the same at any time.
+type FixedInterval time.Duration
+
+func (i FixedInterval) IntervalAt(t time.Time) time.Duration {
+	return time.Duration(i)
+}
+
+func (i FixedInterval) IsZero() bool {
+	return i <= 0
+}
+
+func NewTimeSeriesData() *TimeSeriesData {
+	return &TimeSeriesData{
+		TS:   NewTimeSeries(),
+		Meta: TimeSeriesMeta{},
+	}
+}
+
+func (tsd *TimeSeriesData) Len() int {
+	return len(tsd.TS)
+}
+
+func (tsd *TimeSeriesData) Add(point TimePoint) *TimeSeriesData {
+	tsd.TS = append(tsd.TS, point)
+	return tsd
+}
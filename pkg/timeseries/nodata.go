@@ -0,0 +1,62 @@
+package timeseries
+
+import (
+	"math"
+	"time"
+)
+
+// NoData returns series with a point for each step of the time range, set to 1 if the series has no points
+// within the period before the step time (like Zabbix nodata() function), and 0 otherwise.
+func (ts TimeSeries) NoData(from time.Time, to time.Time, step time.Duration, period time.Duration) TimeSeries {
+	return ts.noDataWindows(from, to, step, period, func(t time.Time, count int) float64 {
+		if count == 0 {
+			return 1
+		}
+		return 0
+	})
+}
+
+// NoDataPercent returns series with a point for each step of the time range, set to the percentage of the points
+// missing within the period before the step time. Expected number of points is based on the update interval.
+func (ts TimeSeries) NoDataPercent(from time.Time, to time.Time, step time.Duration, period time.Duration, interval UpdateInterval) TimeSeries {
+	return ts.noDataWindows(from, to, step, period, func(t time.Time, count int) float64 {
+		if interval == nil || interval.IntervalAt(t) <= 0 {
+			if count == 0 {
+				return 100
+			}
+			return 0
+		}
+		expected := math.Max(1, math.Floor(float64(period)/float64(interval.IntervalAt(t))))
+		missing := (expected - float64(count)) / expected * 100
+		return math.Max(0, math.Min(100, missing))
+	})
+}
+
+// noDataWindows counts non-null points in the (t - period, t] window for each step of the time range and converts
+// the count into value. Series should be sorted by time.
+func (ts TimeSeries) noDataWindows(from time.Time, to time.Time, step time.Duration, period time.Duration, value func(t time.Time, count int) float64) TimeSeries {
+	result := NewTimeSeries()
+	if step <= 0 {
+		return result
+	}
+
+	points := make([]time.Time, 0, len(ts))
+	for _, p := range ts {
+		if p.Value != nil {
+			points = append(points, p.Time)
+		}
+	}
+
+	start, end := 0, 0
+	for t := from.Truncate(step); !t.After(to); t = t.Add(step) {
+		for end < len(points) && !points[end].After(t) {
+			end++
+		}
+		for start < end && !points[start].After(t.Add(-period)) {
+			start++
+		}
+		v := value(t, end-start)
+		result = append(result, TimePoint{Time: t, Value: &v})
+	}
+	return result
+}
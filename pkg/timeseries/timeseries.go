@@ -1,44 +1,123 @@
 package timeseries
 
 import (
-	"errors"
 	"math"
 	"sort"
 	"time"
-
-	"github.com/grafana/grafana-plugin-sdk-go/backend"
-	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
-// Aligns point's time stamps according to provided interval.
+// Aligns point's time stamps according to provided interval. If there's no value in the interval, puts null as a value.
 func (ts TimeSeries) Align(interval time.Duration) TimeSeries {
-	if interval <= 0 || ts.Len() < 2 {
+	if interval <= 0 {
+		return ts
+	}
+	return ts.AlignBy(FixedInterval(interval))
+}
+
+// AlignBy aligns point's time stamps according to the interval which can vary over time (like Zabbix flexible
+// intervals). Points at the time without fixed interval are kept as is.
+func (ts TimeSeries) AlignBy(updateInterval UpdateInterval) TimeSeries {
+	if ts.Len() < 2 {
 		return ts
 	}
 
 	alignedTs := NewTimeSeries()
-	var frameTs = ts[0].GetTimeFrame(interval)
-	var pointFrameTs time.Time
-	var point TimePoint
+	var frameTs *time.Time
 
-	for i := 1; i < ts.Len(); i++ {
-		point = ts[i]
-		pointFrameTs = point.GetTimeFrame(interval)
+	for _, point := range ts {
+		interval := updateInterval.IntervalAt(point.Time)
+		if interval <= 0 {
+			alignedTs = append(alignedTs, point)
+			frameTs = nil
+			continue
+		}
 
-		if pointFrameTs.After(frameTs) {
+		pointFrameTs := point.GetTimeFrame(interval)
+		if frameTs != nil {
+			// Fill empty frames by nulls
 			for frameTs.Before(pointFrameTs) {
-				alignedTs = append(alignedTs, TimePoint{Time: frameTs, Value: nil})
-				frameTs = frameTs.Add(interval)
+				alignedTs = append(alignedTs, TimePoint{Time: *frameTs, Value: nil})
+				frameInterval := updateInterval.IntervalAt(*frameTs)
+				if frameInterval <= 0 {
+					break
+				}
+				nextFrameTs := frameTs.Add(frameInterval)
+				frameTs = &nextFrameTs
 			}
 		}
 
 		alignedTs = append(alignedTs, TimePoint{Time: pointFrameTs, Value: point.Value})
-		frameTs = frameTs.Add(interval)
+		nextFrameTs := pointFrameTs.Add(interval)
+		frameTs = &nextFrameTs
 	}
 
 	return alignedTs
 }
 
+// Sorts points by time (in place) and returns series.
+func (ts TimeSeries) SortByTime() TimeSeries {
+	sort.SliceStable(ts, func(i, j int) bool {
+		return ts[i].Time.Before(ts[j].Time)
+	})
+	return ts
+}
+
+// Moves all points forward in time by given duration.
+func (ts TimeSeries) ShiftTime(shift time.Duration) TimeSeries {
+	for i := range ts {
+		ts[i].Time = ts[i].Time.Add(shift)
+	}
+	return ts
+}
+
+// GroupBy groups points into time frames of given interval and reduces each frame with aggregation function.
+// Empty frames between points are filled with nulls. Series should be sorted by time.
+func (ts TimeSeries) GroupBy(interval time.Duration, aggFunc AggFunc) TimeSeries {
+	align := func(t time.Time) time.Time { return t.Truncate(interval) }
+	next := func(t time.Time) time.Time { return t.Add(interval) }
+	return ts.groupBy(interval, aggFunc, align, next)
+}
+
+// GroupByIn groups points like GroupBy, with time frames aligned to the clock of the location (see AlignTime).
+// Frames are aligned like GroupBy if location is nil.
+func (ts TimeSeries) GroupByIn(interval time.Duration, aggFunc AggFunc, loc *time.Location) TimeSeries {
+	if loc == nil {
+		return ts.GroupBy(interval, aggFunc)
+	}
+	align := func(t time.Time) time.Time { return AlignTime(t, interval, loc) }
+	next := func(t time.Time) time.Time { return NextTimeFrame(t, interval, loc) }
+	return ts.groupBy(interval, aggFunc, align, next)
+}
+
+func (ts TimeSeries) groupBy(interval time.Duration, aggFunc AggFunc, align func(time.Time) time.Time, next func(time.Time) time.Time) TimeSeries {
+	if interval <= 0 || ts.Len() == 0 {
+		return ts
+	}
+
+	groupedTs := NewTimeSeries()
+	frameTs := align(ts[0].Time)
+	frame := make([]TimePoint, 0)
+
+	for _, point := range ts {
+		pointFrameTs := align(point.Time)
+		if pointFrameTs.After(frameTs) {
+			groupedTs = append(groupedTs, TimePoint{Time: frameTs, Value: aggFunc(frame)})
+
+			// Move frame window to the next non-empty interval and fill empty frames with nulls
+			frameTs = next(frameTs)
+			for frameTs.Before(pointFrameTs) {
+				groupedTs = append(groupedTs, TimePoint{Time: frameTs, Value: nil})
+				frameTs = next(frameTs)
+			}
+			frame = make([]TimePoint, 0)
+		}
+		frame = append(frame, point)
+	}
+	groupedTs = append(groupedTs, TimePoint{Time: frameTs, Value: aggFunc(frame)})
+
+	return groupedTs
+}
+
 // Detects interval between data points in milliseconds based on median delta between points.
 func (ts TimeSeries) DetectInterval() time.Duration {
 	if ts.Len() < 2 {
@@ -60,94 +139,187 @@ func (p *TimePoint) GetTimeFrame(interval time.Duration) time.Time {
 	return p.Time.Truncate(interval)
 }
 
-func alignDataPoints(frame *data.Frame, interval time.Duration) *data.Frame {
-	if interval <= 0 || frame.Rows() < 2 {
-		return frame
+// AlignTime returns start of the time frame of given interval containing t. Frames are counted from the Unix
+// epoch on the clock of the location, so daily frames start at the local midnight and weekly ones on Monday.
+func AlignTime(t time.Time, interval time.Duration, loc *time.Location) time.Time {
+	const week = 7 * 24 * time.Hour
+	if interval <= 0 {
+		return t
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	_, offset := t.In(loc).Zone()
+	shift := int64(offset) * int64(time.Second)
+	// Unix epoch is Thursday
+	if interval%week == 0 {
+		shift += int64(3 * 24 * time.Hour)
 	}
 
-	timeFieldIdx := getTimeFieldIndex(frame)
-	if timeFieldIdx < 0 {
-		return frame
+	rem := (t.UnixNano() + shift) % int64(interval)
+	if rem < 0 {
+		rem += int64(interval)
 	}
-	var frameTs = getPointTimeFrame(getTimestampAt(frame, 0), interval)
-	var pointFrameTs *time.Time
-	var pointsInserted = 0
+	return time.Unix(0, t.UnixNano()-rem).In(t.Location())
+}
 
-	for i := 1; i < frame.Rows(); i++ {
-		pointFrameTs = getPointTimeFrame(getTimestampAt(frame, i), interval)
-		if pointFrameTs == nil || frameTs == nil {
-			continue
-		}
+// NextTimeFrame returns start of the time frame following the frame started at t. Frames of whole days are
+// moved by calendar days, so they keep starting at the local midnight after DST changes.
+func NextTimeFrame(t time.Time, interval time.Duration, loc *time.Location) time.Time {
+	const day = 24 * time.Hour
+	if loc == nil || interval%day != 0 {
+		return t.Add(interval)
+	}
+	return t.In(loc).AddDate(0, 0, int(interval/day)).In(t.Location())
+}
 
-		if pointFrameTs.After(*frameTs) {
-			for frameTs.Before(*pointFrameTs) {
-				insertAt := i + pointsInserted
-				err := insertNullPointAt(frame, *frameTs, insertAt)
-				if err != nil {
-					backend.Logger.Debug("Error inserting null point", "error", err)
-				}
-				*frameTs = frameTs.Add(interval)
-				pointsInserted++
+// SumSeries returns series with the sum of all given series at each timestamp. Missing points are
+// interpolated linearly, series are treated as 0 before the first and after the last point.
+func SumSeries(series []TimeSeries) TimeSeries {
+	timestamps := unionTimestamps(series)
+
+	sum := make([]float64, len(timestamps))
+	for _, ts := range series {
+		interpolated := ts.interpolate(timestamps)
+		for i, p := range interpolated {
+			if p.Value != nil {
+				sum[i] += *p.Value
 			}
 		}
-
-		setTimeAt(frame, *pointFrameTs, i+pointsInserted)
-		*frameTs = frameTs.Add(interval)
 	}
 
-	return frame
+	result := NewTimeSeries()
+	for i, t := range timestamps {
+		value := sum[i]
+		result = append(result, TimePoint{Time: t, Value: &value})
+	}
+	return result
 }
 
-func getPointTimeFrame(ts *time.Time, interval time.Duration) *time.Time {
-	if ts == nil {
-		return nil
+// Resample methods
+const (
+	ResampleLinear = "linear"
+	ResampleLast   = "last"
+)
+
+// Resample returns series with points at each interval (aligned to the unix epoch) between the first and last
+// point. Values are interpolated linearly or taken from the last point at or before the timestamp. Null points
+// are ignored, grid timestamps outside of the data are set to null.
+func (ts TimeSeries) Resample(interval time.Duration, method string) TimeSeries {
+	points := make([]TimePoint, 0, len(ts))
+	for _, p := range ts {
+		if p.Value != nil {
+			points = append(points, p)
+		}
 	}
-	timeFrame := ts.Truncate(interval)
-	return &timeFrame
+	if interval <= 0 || len(points) == 0 {
+		return NewTimeSeries()
+	}
+	TimeSeries(points).SortByTime()
+
+	// Time.Truncate counts intervals from the zero time, not from the epoch, so grid start is computed explicitly.
+	// Series with the different start times get the same grid.
+	start := points[0].Time
+	rem := start.UnixNano() % int64(interval)
+	if rem < 0 {
+		rem += int64(interval)
+	}
+
+	result := NewTimeSeries()
+	pointIdx := 0
+	last := points[len(points)-1].Time
+	for t := start.Add(-time.Duration(rem)); !t.After(last); t = t.Add(interval) {
+		// Move to the first point after t
+		for pointIdx < len(points) && !points[pointIdx].Time.After(t) {
+			pointIdx++
+		}
+
+		var value *float64
+		if pointIdx > 0 {
+			prev := points[pointIdx-1]
+			if method == ResampleLast || prev.Time.Equal(t) {
+				value = prev.Value
+			} else if pointIdx < len(points) {
+				interpolated := linearInterpolation(t, prev, points[pointIdx])
+				value = &interpolated
+			}
+		}
+		result = append(result, TimePoint{Time: t, Value: value})
+	}
+	return result
 }
 
-func getTimeFieldIndex(frame *data.Frame) int {
-	for i := 0; i < len(frame.Fields); i++ {
-		if frame.Fields[i].Type() == data.FieldTypeTime {
-			return i
+// AggregateSeries returns series with the aggregated value of the points all given series have at
+// each timestamp. Series are expected to be aligned, points are not interpolated.
+func AggregateSeries(series []TimeSeries, aggFunc AggFunc) TimeSeries {
+	timestamps := unionTimestamps(series)
+	pointsByTime := make(map[int64][]TimePoint, len(timestamps))
+	for _, ts := range series {
+		for _, p := range ts {
+			key := p.Time.UnixNano()
+			pointsByTime[key] = append(pointsByTime[key], p)
 		}
 	}
 
-	return -1
+	result := NewTimeSeries()
+	for _, t := range timestamps {
+		result = append(result, TimePoint{Time: t, Value: aggFunc(pointsByTime[t.UnixNano()])})
+	}
+	return result
 }
 
-func getTimestampAt(frame *data.Frame, index int) *time.Time {
-	timeFieldIdx := getTimeFieldIndex(frame)
-	if timeFieldIdx < 0 {
-		return nil
+// unionTimestamps returns sorted timestamps of the points of all given series
+func unionTimestamps(series []TimeSeries) []time.Time {
+	timestampSet := make(map[int64]time.Time)
+	for _, ts := range series {
+		for _, p := range ts {
+			timestampSet[p.Time.UnixNano()] = p.Time
+		}
 	}
 
-	tsValue := frame.Fields[timeFieldIdx].At(index)
-	ts, ok := tsValue.(time.Time)
-	if !ok {
-		return nil
+	timestamps := make([]time.Time, 0, len(timestampSet))
+	for _, t := range timestampSet {
+		timestamps = append(timestamps, t)
 	}
-
-	return &ts
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+	return timestamps
 }
 
-func insertNullPointAt(frame *data.Frame, frameTs time.Time, index int) error {
-	for _, field := range frame.Fields {
-		if field.Type() == data.FieldTypeTime {
-			field.Insert(index, frameTs)
-		} else if field.Type().Nullable() {
-			field.Insert(index, nil)
+// interpolate returns series containing a point for each of given (sorted) timestamps.
+func (ts TimeSeries) interpolate(timestamps []time.Time) TimeSeries {
+	points := make([]TimePoint, 0, len(ts))
+	for _, p := range ts {
+		if p.Value != nil {
+			points = append(points, p)
+		}
+	}
+	TimeSeries(points).SortByTime()
+
+	zero := 0.0
+	result := make(TimeSeries, 0, len(timestamps))
+	pointIdx := 0
+	for _, t := range timestamps {
+		for pointIdx < len(points) && points[pointIdx].Time.Before(t) {
+			pointIdx++
+		}
+
+		if len(points) == 0 || pointIdx == 0 && t.Before(points[0].Time) || pointIdx >= len(points) {
+			result = append(result, TimePoint{Time: t, Value: &zero})
+		} else if points[pointIdx].Time.Equal(t) {
+			result = append(result, points[pointIdx])
 		} else {
-			return errors.New("field is not nullable")
+			value := linearInterpolation(t, points[pointIdx-1], points[pointIdx])
+			result = append(result, TimePoint{Time: t, Value: &value})
 		}
 	}
-	return nil
+	return result
 }
 
-func setTimeAt(frame *data.Frame, frameTs time.Time, index int) {
-	for _, field := range frame.Fields {
-		if field.Type() == data.FieldTypeTime {
-			field.Insert(index, frameTs)
-		}
+func linearInterpolation(ts time.Time, left, right TimePoint) float64 {
+	if left.Time.Equal(right.Time) {
+		return (*left.Value + *right.Value) / 2
 	}
+	return *left.Value + (*right.Value-*left.Value)/float64(right.Time.UnixNano()-left.Time.UnixNano())*float64(ts.UnixNano()-left.Time.UnixNano())
 }
@@ -0,0 +1,76 @@
+package timeseries
+
+import "time"
+
+// TransformFunc maps single point to the new one
+type TransformFunc = func(point TimePoint) TimePoint
+
+// Transform applies transformation function to each point of the series.
+func (ts TimeSeries) Transform(transformFunc TransformFunc) TimeSeries {
+	for i, p := range ts {
+		ts[i] = transformFunc(p)
+	}
+	return ts
+}
+
+func TransformRemoveAboveValue(threshold float64) TransformFunc {
+	return func(point TimePoint) TimePoint {
+		if point.Value != nil && *point.Value > threshold {
+			point.Value = nil
+		}
+		return point
+	}
+}
+
+func TransformRemoveBelowValue(threshold float64) TransformFunc {
+	return func(point TimePoint) TimePoint {
+		if point.Value != nil && *point.Value < threshold {
+			point.Value = nil
+		}
+		return point
+	}
+}
+
+func TransformNull(nullValue float64) TransformFunc {
+	return func(point TimePoint) TimePoint {
+		if point.Value == nil {
+			value := nullValue
+			point.Value = &value
+		}
+		return point
+	}
+}
+
+// TransformCumulativeSum returns running total of the values. Null points stay null.
+// Points should be transformed in time order.
+func TransformCumulativeSum() TransformFunc {
+	sum := 0.0
+	return func(point TimePoint) TimePoint {
+		if point.Value != nil {
+			sum += *point.Value
+			value := sum
+			point.Value = &value
+		}
+		return point
+	}
+}
+
+// TransformIntegral returns running integral of the per-second values: each value is multiplied by the seconds
+// passed since the previous non-null point. Null points stay null. Points should be transformed in time order.
+func TransformIntegral() TransformFunc {
+	sum := 0.0
+	var prevTime *time.Time
+	return func(point TimePoint) TimePoint {
+		if point.Value == nil {
+			return point
+		}
+		if prevTime != nil {
+			sum += *point.Value * point.Time.Sub(*prevTime).Seconds()
+		}
+		t := point.Time
+		prevTime = &t
+		value := sum
+		point.Value = &value
+		return point
+	}
+}
@@ -0,0 +1,131 @@
+package zabbixapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// JSON-RPC error codes returned by Zabbix API
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+	ErrCodeApplication    = -32500
+	ErrCodePermission     = -32400
+)
+
+// Categories of the API errors, use errors.Is() to check category of the error returned by the API
+var (
+	// ErrNotAuthorized means session is expired or auth token is invalid, request can be repeated after re-login
+	ErrNotAuthorized = errors.New("zabbix api: not authorized")
+	// ErrInvalidCredentials means login failed because of the wrong user name or password, or the user is blocked
+	ErrInvalidCredentials = errors.New("zabbix api: invalid credentials")
+	// ErrNoPermission means user has no permissions to the requested objects or method
+	ErrNoPermission = errors.New("zabbix api: no permission")
+	// ErrMethodNotFound means API method doesn't exist in the Zabbix version
+	ErrMethodNotFound = errors.New("zabbix api: method not found")
+	// ErrUnexpectedParam means request has parameter not supported by the Zabbix version
+	ErrUnexpectedParam = errors.New("zabbix api: unexpected parameter")
+	// ErrDBDown means Zabbix frontend can't connect to its database
+	ErrDBDown = errors.New("zabbix api: database is unavailable")
+	// ErrTooManyRequests means requests are rate limited by the Zabbix frontend or a proxy in front of it
+	ErrTooManyRequests = errors.New("zabbix api: too many requests")
+	// ErrNotAPIEndpoint means URL of the data source doesn't point to Zabbix API, like a wrong path behind a proxy
+	ErrNotAPIEndpoint = errors.New("zabbix api: not an API endpoint")
+)
+
+// APIError is an error returned by Zabbix API in the response body. Kind is a category of the error (one of the
+// Err* errors above) or nil if it's unknown.
+type APIError struct {
+	Code    int
+	Message string
+	Data    string
+	Kind    error
+}
+
+func newAPIError(code int, message string, data string) *APIError {
+	return &APIError{
+		Code:    code,
+		Message: message,
+		Data:    data,
+		Kind:    apiErrorKind(code, message, data),
+	}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s", e.Message, e.Data)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// apiErrorKind returns category of the API error. Zabbix uses the same codes for different errors (permission
+// and auth errors, for example), so error messages are checked as well.
+func apiErrorKind(code int, message string, data string) error {
+	lowerData := strings.ToLower(data)
+	switch {
+	case strings.Contains(data, "Session terminated, re-login, please.") ||
+		strings.Contains(data, "Not authorised.") ||
+		strings.Contains(data, "Not authorized."):
+		return ErrNotAuthorized
+	case strings.Contains(lowerData, "password is incorrect") ||
+		strings.Contains(lowerData, "incorrect user name or password"):
+		return ErrInvalidCredentials
+	case strings.Contains(lowerData, "error connecting to database") ||
+		strings.Contains(lowerData, "database error"):
+		return ErrDBDown
+	case strings.Contains(lowerData, "too many requests"):
+		return ErrTooManyRequests
+	case code == ErrCodeMethodNotFound:
+		return ErrMethodNotFound
+	case code == ErrCodeInvalidParams && strings.Contains(data, "unexpected parameter"):
+		return ErrUnexpectedParam
+	case code == ErrCodePermission || strings.Contains(message, "No permissions") ||
+		strings.Contains(data, "No permissions"):
+		return ErrNoPermission
+	}
+	return nil
+}
+
+// HTTPError is returned if Zabbix API responded with non-OK HTTP status
+type HTTPError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("request failed, status: %v", e.Status)
+}
+
+// Unwrap returns category of the error for the statuses with a known meaning
+func (e *HTTPError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests:
+		return ErrTooManyRequests
+	case http.StatusForbidden:
+		return ErrNoPermission
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return ErrNotAPIEndpoint
+	}
+	return nil
+}
+
+// ResponseError is returned if the response isn't a JSON-RPC response, like a login page of the frontend or an
+// error page of the proxy
+type ResponseError struct {
+	Err error
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("invalid response: %s", e.Err)
+}
+
+// Unwrap returns ErrNotAPIEndpoint, so the category of the error can be checked with errors.Is()
+func (e *ResponseError) Unwrap() error {
+	return ErrNotAPIEndpoint
+}
@@ -0,0 +1,80 @@
+package zabbixapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	zabbixApi, _ := MockZabbixAPI(`{"error":{"code":-32601,"message":"Method not found.","data":"Incorrect API \"application\"."}}`, 200)
+	zabbixApi.auth = "secretauth"
+	_, err := zabbixApi.Request(context.Background(), "application.get", map[string]interface{}{})
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, ErrCodeMethodNotFound, apiErr.Code)
+	assert.Equal(t, `Method not found. Incorrect API "application".`, err.Error())
+}
+
+func TestHTTPError(t *testing.T) {
+	zabbixApi, _ := MockZabbixAPI(`{}`, 502)
+	zabbixApi.auth = "secretauth"
+	_, err := zabbixApi.Request(context.Background(), "item.get", map[string]interface{}{})
+
+	var httpErr *HTTPError
+	assert.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, 502, httpErr.StatusCode)
+}
+
+func TestResponseError(t *testing.T) {
+	zabbixApi, _ := MockZabbixAPI(`<html><body>Zabbix frontend</body></html>`, 200)
+	_, err := zabbixApi.RequestUnauthenticated(context.Background(), "apiinfo.version", map[string]interface{}{})
+
+	var responseErr *ResponseError
+	assert.True(t, errors.As(err, &responseErr))
+	assert.True(t, errors.Is(err, ErrNotAPIEndpoint))
+
+	zabbixApi, _ = MockZabbixAPI(`{"jsonrpc":"2.0"}`, 200)
+	_, err = zabbixApi.RequestUnauthenticated(context.Background(), "apiinfo.version", map[string]interface{}{})
+	assert.True(t, errors.Is(err, ErrNotAPIEndpoint))
+}
+
+func TestErrorCategories(t *testing.T) {
+	kinds := []error{ErrNotAuthorized, ErrInvalidCredentials, ErrNoPermission, ErrMethodNotFound, ErrUnexpectedParam, ErrDBDown, ErrTooManyRequests, ErrNotAPIEndpoint}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "method not found", err: newAPIError(ErrCodeMethodNotFound, "Method not found.", `Incorrect API "application".`), want: ErrMethodNotFound},
+		{name: "wrapped method not found", err: fmt.Errorf("get apps: %w", newAPIError(ErrCodeMethodNotFound, "Method not found.", "")), want: ErrMethodNotFound},
+		{name: "unexpected param", err: newAPIError(ErrCodeInvalidParams, "Invalid params.", `Invalid parameter "/": unexpected parameter "selectTags".`), want: ErrUnexpectedParam},
+		{name: "session terminated", err: newAPIError(ErrCodeInvalidParams, "Invalid params.", "Session terminated, re-login, please."), want: ErrNotAuthorized},
+		{name: "not authorized", err: newAPIError(ErrCodePermission, "No permissions.", "Not authorized."), want: ErrNotAuthorized},
+		{name: "invalid credentials", err: newAPIError(ErrCodeInvalidParams, "Invalid params.", "Incorrect user name or password or account is temporarily blocked."), want: ErrInvalidCredentials},
+		{name: "invalid credentials before 5.4", err: newAPIError(ErrCodeInvalidParams, "Invalid params.", "Login name or password is incorrect."), want: ErrInvalidCredentials},
+		{name: "no permission", err: newAPIError(ErrCodeApplication, "Application error.", "No permissions to referred object or it does not exist!"), want: ErrNoPermission},
+		{name: "db down", err: newAPIError(ErrCodeApplication, "Application error.", "Error connecting to database: Connection refused"), want: ErrDBDown},
+		{name: "rate limited api", err: newAPIError(ErrCodeApplication, "Application error.", "Too many requests, try again later."), want: ErrTooManyRequests},
+		{name: "rate limited http", err: &HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}, want: ErrTooManyRequests},
+		{name: "forbidden http", err: &HTTPError{StatusCode: 403, Status: "403 Forbidden"}, want: ErrNoPermission},
+		{name: "server error http", err: &HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}, want: nil},
+		{name: "not found http", err: &HTTPError{StatusCode: 404, Status: "404 Not Found"}, want: ErrNotAPIEndpoint},
+		{name: "invalid response", err: &ResponseError{Err: errors.New("invalid character '<'")}, want: ErrNotAPIEndpoint},
+		{name: "unknown api error", err: newAPIError(ErrCodeInvalidParams, "Invalid params.", `Invalid parameter "/1/name": cannot be empty.`), want: nil},
+		{name: "not an API error", err: errors.New(`unexpected parameter "selectTags". Not authorised.`), want: nil},
+		{name: "nil", err: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, kind := range kinds {
+				assert.Equal(t, kind == tt.want, errors.Is(tt.err, kind), kind.Error())
+			}
+		})
+	}
+}
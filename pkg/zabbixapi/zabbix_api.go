@@ -9,15 +9,20 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/alexanderzobnin/grafana-zabbix/pkg/httpclient"
+	"github.com/alexanderzobnin/grafana-zabbix/pkg/requestid"
 	"github.com/bitly/go-simplejson"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"golang.org/x/net/context/ctxhttp"
 )
 
+// JSON-RPC id of the API requests
+const requestRPCID = 2
+
 var (
 	ErrNotAuthenticated = errors.New("zabbix api: not authenticated")
 )
@@ -27,6 +32,8 @@ type ZabbixAPI struct {
 	httpClient *http.Client
 	logger     log.Logger
 	auth       string
+	// Name of the user.login parameter: user, or username in Zabbix 5.4 and higher
+	usernameParam string
 }
 
 type ZabbixAPIParams = map[string]interface{}
@@ -34,7 +41,7 @@ type ZabbixAPIParams = map[string]interface{}
 // New returns new ZabbixAPI instance initialized with given URL or error.
 func New(dsInfo *backend.DataSourceInstanceSettings, timeout time.Duration) (*ZabbixAPI, error) {
 	apiLogger := log.New()
-	zabbixURL, err := url.Parse(dsInfo.URL)
+	zabbixURL, err := parseAPIURL(dsInfo.URL)
 	if err != nil {
 		return nil, err
 	}
@@ -45,12 +52,35 @@ func New(dsInfo *backend.DataSourceInstanceSettings, timeout time.Duration) (*Za
 	}
 
 	return &ZabbixAPI{
-		url:        zabbixURL,
-		logger:     apiLogger,
-		httpClient: client,
+		url:           zabbixURL,
+		logger:        apiLogger,
+		httpClient:    client,
+		usernameParam: "user",
 	}, nil
 }
 
+// parseAPIURL parses URL of the Zabbix API. Path is kept as is, so the API can be served at any path, like
+// /zabbix/api/v2/jsonrpc behind a rewriting proxy, not only at /api_jsonrpc.php.
+func parseAPIURL(apiURL string) (*url.URL, error) {
+	zabbixURL, err := url.Parse(strings.TrimSpace(apiURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Zabbix API URL: %w", err)
+	}
+	if (zabbixURL.Scheme != "http" && zabbixURL.Scheme != "https") || zabbixURL.Host == "" {
+		return nil, fmt.Errorf("invalid Zabbix API URL %s: expected absolute http or https URL", redactURL(zabbixURL))
+	}
+	return zabbixURL, nil
+}
+
+// redactURL returns URL with the password replaced by "xxxxx", like url.URL.Redacted (added in Go 1.15)
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if _, ok := redacted.User.Password(); redacted.User != nil && ok {
+		redacted.User = url.UserPassword(redacted.User.Username(), "xxxxx")
+	}
+	return redacted.String()
+}
+
 // GetUrl gets new API URL
 func (api *ZabbixAPI) GetUrl() *url.URL {
 	return api.url
@@ -94,7 +124,7 @@ func (api *ZabbixAPI) RequestUnauthenticated(ctx context.Context, method string,
 func (api *ZabbixAPI) request(ctx context.Context, method string, params ZabbixAPIParams, auth string) (*simplejson.Json, error) {
 	apiRequest := map[string]interface{}{
 		"jsonrpc": "2.0",
-		"id":      2,
+		"id":      rpcID(ctx),
 		"method":  method,
 		"params":  params,
 	}
@@ -112,9 +142,15 @@ func (api *ZabbixAPI) request(ctx context.Context, method string, params ZabbixA
 	if err != nil {
 		return nil, err
 	}
+	// Use the configured URL instead of the parsed string, so escaping of the custom API path is kept
+	requestURL := *api.url
+	req.URL = &requestURL
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Grafana/grafana-zabbix")
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		req.Header.Set(requestid.Header, requestID)
+	}
 
 	response, err := makeHTTPRequest(ctx, api.httpClient, req)
 	if err != nil {
@@ -124,11 +160,20 @@ func (api *ZabbixAPI) request(ctx context.Context, method string, params ZabbixA
 	return handleAPIResult(response)
 }
 
+// SetUsernameParam sets name of the user name parameter of the login request, "user" is used by default.
+func (api *ZabbixAPI) SetUsernameParam(param string) {
+	api.usernameParam = param
+}
+
 // Login performs API authentication and returns authentication token.
 func (api *ZabbixAPI) Login(ctx context.Context, username string, password string) (string, error) {
+	usernameParam := api.usernameParam
+	if usernameParam == "" {
+		usernameParam = "user"
+	}
 	params := ZabbixAPIParams{
-		"user":     username,
-		"password": password,
+		usernameParam: username,
+		"password":    password,
 	}
 
 	auth, err := api.request(ctx, "user.login", params, "")
@@ -150,16 +195,30 @@ func (api *ZabbixAPI) Authenticate(ctx context.Context, username string, passwor
 	return nil
 }
 
+// rpcID returns JSON-RPC id of the request followed by the request ID if any, Zabbix returns it in the response
+func rpcID(ctx context.Context) interface{} {
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		return fmt.Sprintf("%d-%s", requestRPCID, requestID)
+	}
+	return requestRPCID
+}
+
 func handleAPIResult(response []byte) (*simplejson.Json, error) {
 	jsonResp, err := simplejson.NewJson([]byte(response))
 	if err != nil {
-		return nil, err
+		return nil, &ResponseError{Err: err}
 	}
 	if errJSON, isError := jsonResp.CheckGet("error"); isError {
-		errMessage := fmt.Sprintf("%s %s", errJSON.Get("message").MustString(), errJSON.Get("data").MustString())
-		return nil, errors.New(errMessage)
+		return nil, newAPIError(
+			errJSON.Get("code").MustInt(),
+			errJSON.Get("message").MustString(),
+			errJSON.Get("data").MustString(),
+		)
+	}
+	jsonResult, ok := jsonResp.CheckGet("result")
+	if !ok {
+		return nil, &ResponseError{Err: errors.New("no result or error in the response")}
 	}
-	jsonResult := jsonResp.Get("result")
 	return jsonResult, nil
 }
 
@@ -171,7 +230,7 @@ func makeHTTPRequest(ctx context.Context, httpClient *http.Client, req *http.Req
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed, status: %v", res.Status)
+		return nil, &HTTPError{StatusCode: res.StatusCode, Status: res.Status}
 	}
 
 	body, err := ioutil.ReadAll(res.Body)
@@ -224,6 +224,14 @@ export const ConfigEditor = (props: Props) => {
             This alignment required for proper work of the stacked graphs.
             If you don't need stacked graphs and want to get exactly the same timestamps as in Zabbix, then you can disable this feature."
         />
+        <Switch
+          label="Acknowledge events from alerts"
+          labelClass="width-16"
+          checked={!!options.jsonData.alertAcknowledge}
+          onChange={jsonDataSwitchHandler('alertAcknowledge', options, onOptionsChange)}
+          tooltip="Allow Grafana webhook contact point to post alert state to Zabbix events.
+            Set the webhook URL to the data source resource alert-webhook. Alerts are mapped to the events by the eventid or triggerid labels."
+        />
       </div>
     </>
   );
@@ -14,6 +14,7 @@ export interface ZabbixDSOptions extends DataSourceJsonData {
   dbConnectionRetentionPolicy?: string;
   disableReadOnlyUsersAck: boolean;
   disableDataAlignment: boolean;
+  alertAcknowledge?: boolean;
 }
 
 export interface ZabbixSecureJSONData {